@@ -0,0 +1,123 @@
+// Command benchphant starts the BenchPhant web UI or drives registered
+// benchmark workloads headlessly from the command line.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	_ "github.com/deadjoe/benchphant/internal/benchmark/sysbench"
+	_ "github.com/deadjoe/benchphant/internal/benchmark/tpcc"
+)
+
+// command is a benchphant subcommand
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// errAssertionsFailed is returned by run, sweep and search when one of the
+// assertions of a completed benchmark did not hold. It maps to its own exit
+// code so CI can tell a performance regression apart from a usage or
+// connection error.
+var errAssertionsFailed = errors.New("benchmark did not meet its assertions")
+
+// errRunFailed is returned by run, sweep and search when the benchmark failed
+// or was cancelled, so that its results say nothing about the assertions.
+var errRunFailed = errors.New("benchmark did not complete")
+
+// Exit codes
+const (
+	exitError            = 1
+	exitUsage            = 2
+	exitAssertionsFailed = 3
+	exitRunFailed        = 4
+)
+
+var commands = map[string]command{
+	"serve":     {usage: "Start the web UI and API server", run: runServe},
+	"prepare":   {usage: "Create tables and load data for a workload", run: runPrepare},
+	"run":       {usage: "Run a workload and print its results", run: runRun},
+	"sweep":     {usage: "Run a workload at growing threads or rate to find its knee", run: runSweep},
+	"search":    {usage: "Find the highest threads or rate that meets SLOs", run: runSearch},
+	"cleanup":   {usage: "Drop the tables created by prepare", run: runCleanup},
+	"workloads": {usage: "List workloads and show their config schema", run: runWorkloads},
+	"scenarios": {usage: "List, show and validate sysbench scenarios", run: runScenarios},
+}
+
+func main() {
+	args := os.Args[1:]
+
+	// Without a subcommand benchphant behaves like "benchphant serve"
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		usage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "benchphant: unknown command %q\n\n", name)
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "benchphant %s: %v\n", name, err)
+		if errors.Is(err, errAssertionsFailed) {
+			os.Exit(exitAssertionsFailed)
+		}
+		if errors.Is(err, errRunFailed) {
+			os.Exit(exitRunFailed)
+		}
+		os.Exit(exitError)
+	}
+}
+
+// usage prints the list of available subcommands
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: benchphant <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "benchphant <command> -h" for the flags of a command.`)
+}
+
+// newLogger creates a logger writing to stderr so stdout stays free for results
+func newLogger(level string) (*zap.Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", level)
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	cfg.Encoding = "console"
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	cfg.OutputPaths = []string{"stderr"}
+	cfg.ErrorOutputPaths = []string{"stderr"}
+	return cfg.Build()
+}
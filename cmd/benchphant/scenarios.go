@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench"
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/config"
+)
+
+// runScenarios lists the stored and predefined sysbench scenarios, prints the
+// definition of the one named as argument, or validates a scenario file.
+// Scenarios are run with the sysbench workload config {"scenario": "<name>"}.
+func runScenarios(args []string) error {
+	fs := flag.NewFlagSet("scenarios", flag.ContinueOnError)
+	dir := fs.String("dir", config.DefaultScenarioDir, "directory the scenarios are stored in")
+	validate := fs.String("validate", "", "validate the scenario file at this path")
+	save := fs.Bool("save", false, "store the file given by -validate in -dir once it is valid")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchphant scenarios [-dir dir] [scenario]")
+		fmt.Fprintln(fs.Output(), "       benchphant scenarios [-dir dir] -validate file [-save]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), `Run a scenario with: benchphant run -workload sysbench -workload-config '{"scenario": "<name>"}'`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	storage, err := sysbench.NewFileStorage(*dir)
+	if err != nil {
+		return err
+	}
+
+	if *validate != "" {
+		scenario, err := readScenarioFile(*validate)
+		if err != nil {
+			return err
+		}
+		if *save {
+			if err := storage.SaveScenario(*scenario); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stdout, "%s: scenario %s with %d tests is valid\n", *validate, scenario.Name, len(scenario.Tests))
+		return nil
+	}
+
+	switch fs.NArg() {
+	case 0:
+		return writeScenarios(os.Stdout, storage)
+	case 1:
+		scenario, err := sysbench.FindScenario(storage, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return writeWorkloadJSON(os.Stdout, scenario.Definition())
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected at most one scenario, got %d", fs.NArg())
+	}
+}
+
+// readScenarioFile reads and validates a scenario definition
+func readScenarioFile(path string) (*types.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var scenario types.Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// writeScenarios writes one line per stored scenario followed by the
+// predefined ones
+func writeScenarios(w io.Writer, storage *sysbench.FileStorage) error {
+	names, err := storage.ListScenarios()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		def, err := storage.LoadScenario(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\tstored\t%d tests\t%s\n", name, len(def.Tests), def.Description)
+	}
+	for _, s := range sysbench.GetPredefinedScenarios() {
+		fmt.Fprintf(tw, "%s\tpredefined\t%d tests\t%s\n", s.Type, len(s.Tests), s.Description)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench"
+)
+
+func TestScenarioFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nightly.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"name": "nightly",
+		"description": "Reads then paced writes",
+		"tests": [
+			{"name": "Reads", "type": "oltp_read_only", "duration": 60000000000, "threads": 16, "warmup": 10000000000},
+			{"name": "Writes", "type": "oltp_write_only", "duration": 30000000000, "rate": 200}
+		]
+	}`), 0644))
+
+	scenario, err := readScenarioFile(path)
+	require.NoError(t, err)
+	assert.Len(t, scenario.Tests, 2)
+
+	storage, err := sysbench.NewFileStorage(filepath.Join(dir, "store"))
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveScenario(*scenario))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeScenarios(&buf, storage))
+	assert.Contains(t, buf.String(), "nightly")
+	assert.Contains(t, buf.String(), "read_intensive")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"name": "broken", "tests": [{"type": "oltp_unknown"}]}`), 0644))
+	_, err = readScenarioFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/deadjoe/benchphant/internal/report"
+)
+
+// searchFlags holds the flags of a capacity search
+type searchFlags struct {
+	parameter     string
+	low           int
+	high          int
+	precision     int
+	probeDuration time.Duration
+	objectives    assertionFlags
+}
+
+// register adds the search flags to fs
+func (f *searchFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.parameter, "parameter", string(benchmark.SweepRate), "setting to search (threads, rate)")
+	fs.IntVar(&f.low, "low", 1, "lowest value to probe")
+	fs.IntVar(&f.high, "high", 0, "highest value to probe")
+	fs.IntVar(&f.precision, "precision", 0, "stop when the answer is within this distance of the limit (default 1% of -high)")
+	fs.DurationVar(&f.probeDuration, "probe-duration", 0, "duration of each probe, the confirmation run uses -duration")
+	fs.Var(&f.objectives, "slo", `objective every probe must meet, e.g. "latency_p99 < 10ms" (repeatable)`)
+}
+
+// config converts the flags into a validated search config and objectives
+func (f *searchFlags) config() (benchmark.SearchConfig, []benchmark.Objective, error) {
+	search := benchmark.SearchConfig{
+		Parameter:     benchmark.SweepParameter(f.parameter),
+		Low:           f.low,
+		High:          f.high,
+		Precision:     f.precision,
+		ProbeDuration: f.probeDuration,
+	}
+	if err := search.Validate(); err != nil {
+		return search, nil, err
+	}
+
+	if len(f.objectives) == 0 {
+		return search, nil, fmt.Errorf("at least one -slo is required")
+	}
+	assertions, err := parseAssertions(f.objectives)
+	if err != nil {
+		return search, nil, err
+	}
+	objectives := make([]benchmark.Objective, 0, len(assertions))
+	for _, a := range assertions {
+		objectives = append(objectives, a)
+	}
+	return search, objectives, nil
+}
+
+// runSearch searches for the highest threads or rate of a workload meeting
+// the objectives and prints every probe. It returns errRunFailed when the
+// search failed or was cancelled and errAssertionsFailed when no value met
+// the objectives or the answer was not confirmed.
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	format := fs.String("format", string(report.FormatText), "report format (text, json)")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	var sf searchFlags
+	sf.register(fs)
+	var wf workloadFlags
+	wf.register(fs)
+	if err := wf.parse(fs, args); err != nil {
+		return err
+	}
+
+	spec, err := wf.spec()
+	if err != nil {
+		return err
+	}
+	searchConfig, objectives, err := sf.config()
+	if err != nil {
+		return err
+	}
+	reportFormat, err := report.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if reportFormat == report.FormatJUnit {
+		return fmt.Errorf("search reports support text and json only")
+	}
+
+	logger, err := newLogger(wf.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	factory, conn, err := sharedConnection(spec)
+	if err != nil {
+		return err
+	}
+	defer conn.DB.Close()
+
+	search, err := benchmark.NewSearch(factory, spec.benchmark(), conn, searchConfig, objectives, logger)
+	if err != nil {
+		return err
+	}
+
+	w, closeOutput, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := search.Start(); err != nil {
+		return err
+	}
+	status := waitForRunner(ctx, search, os.Stderr, logger)
+
+	result := search.SearchReport()
+	if err := report.WriteSearch(w, result, reportFormat); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if status.Status != string(models.BenchmarkStatusCompleted) {
+		return fmt.Errorf("%w: %s", errRunFailed, status.Status)
+	}
+	if !result.Confirmed {
+		logger.Error("Search found no confirmed maximum")
+		return errAssertionsFailed
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+func TestSearchFlagsConfig(t *testing.T) {
+	f := &searchFlags{
+		parameter:     "rate",
+		low:           100,
+		high:          5000,
+		probeDuration: 30 * time.Second,
+		objectives:    assertionFlags{"latency_p99 < 10ms", "error_rate < 0.001"},
+	}
+	search, objectives, err := f.config()
+	require.NoError(t, err)
+	assert.Equal(t, benchmark.SearchConfig{Parameter: benchmark.SweepRate, Low: 100, High: 5000, ProbeDuration: 30 * time.Second}, search)
+	require.Len(t, objectives, 2)
+	assert.Equal(t, "error_rate < 0.001", objectives[1].String())
+
+	for name, f := range map[string]*searchFlags{
+		"NoObjectives": {parameter: "rate", low: 1, high: 10},
+		"BadObjective": {parameter: "rate", low: 1, high: 10, objectives: assertionFlags{"p99"}},
+		"NoHigh":       {parameter: "rate", low: 1, objectives: assertionFlags{"tps > 1"}},
+	} {
+		_, _, err := f.config()
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/deadjoe/benchphant/internal/api"
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench"
+	"github.com/deadjoe/benchphant/internal/config"
+	"github.com/deadjoe/benchphant/internal/database"
+)
+
+// runServe starts the API server and web UI
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a config file (default ~/.benchphant/config.json)")
+	port := fs.Int("port", 0, "port to listen on, overrides the config file")
+	maxRuns := fs.Int("max-runs-per-connection", 0, "concurrent benchmark runs allowed per connection, overrides the config file")
+	scenarioDir := fs.String("scenario-dir", "", "directory stored sysbench scenarios are read from, overrides the config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		cfg *config.Config
+		err error
+	)
+	if *configFile != "" {
+		cfg, err = config.LoadConfig(*configFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return err
+	}
+	if *port != 0 {
+		cfg.Port = *port
+	}
+	if *maxRuns != 0 {
+		cfg.MaxRunsPerConnection = *maxRuns
+	}
+	if *scenarioDir != "" {
+		cfg.ScenarioDir = *scenarioDir
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	logger, err := newLogger(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	if err := os.MkdirAll(filepath.Dir(cfg.StoragePath), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	storage, err := database.NewSQLiteStorage(cfg.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	key, err := loadEncryptionKey(cfg.EncryptionKeyFile)
+	if err != nil {
+		storage.Close()
+		return err
+	}
+
+	manager, err := database.NewManager(storage, key, logger)
+	if err != nil {
+		storage.Close()
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	defer manager.Close()
+
+	// Clients pick scenarios by name, the directory is the server's
+	benchmark.RegisterFactory("sysbench", sysbench.NewFactory(cfg.ScenarioDir))
+	server := api.NewServer(cfg, manager, logger)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case sig := <-sigCh:
+		logger.Info("Shutting down", zap.Stringer("signal", sig))
+		return server.Shutdown()
+	}
+}
+
+// loadEncryptionKey reads the hex encoded 32 byte key used to encrypt stored
+// passwords, generating a new one on first start
+func loadEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write encryption key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/deadjoe/benchphant/internal/report"
+)
+
+// sweepFlags holds the flags selecting the steps of a sweep
+type sweepFlags struct {
+	parameter string
+	values    string
+	start     int
+	end       int
+	factor    float64
+}
+
+// register adds the sweep flags to fs
+func (f *sweepFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.parameter, "parameter", string(benchmark.SweepThreads), "setting to sweep (threads, rate)")
+	fs.StringVar(&f.values, "values", "", "comma separated values to run, e.g. 1,2,4,8")
+	fs.IntVar(&f.start, "start", 1, "first value of a geometric series, used without -values")
+	fs.IntVar(&f.end, "end", 0, "last value of a geometric series, used without -values")
+	fs.Float64Var(&f.factor, "factor", 2, "growth factor of a geometric series")
+}
+
+// config converts the flags into a validated sweep config
+func (f *sweepFlags) config() (benchmark.SweepConfig, error) {
+	config := benchmark.SweepConfig{
+		Parameter: benchmark.SweepParameter(f.parameter),
+		Start:     f.start,
+		End:       f.end,
+		Factor:    f.factor,
+	}
+	if f.values != "" {
+		config.Start, config.End, config.Factor = 0, 0, 0
+		for _, s := range strings.Split(f.values, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return config, fmt.Errorf("invalid sweep value %q", s)
+			}
+			config.Values = append(config.Values, v)
+		}
+	}
+	if _, err := config.Steps(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// runSweep runs a workload once per thread count or rate and prints the
+// throughput-vs-latency curve with its knee. It returns errRunFailed when
+// the sweep failed or was cancelled.
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	format := fs.String("format", string(report.FormatText), "report format (text, json)")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	var sf sweepFlags
+	sf.register(fs)
+	var wf workloadFlags
+	wf.register(fs)
+	if err := wf.parse(fs, args); err != nil {
+		return err
+	}
+
+	spec, err := wf.spec()
+	if err != nil {
+		return err
+	}
+	sweepConfig, err := sf.config()
+	if err != nil {
+		return err
+	}
+	reportFormat, err := report.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if reportFormat == report.FormatJUnit {
+		return fmt.Errorf("sweep reports support text and json only")
+	}
+
+	logger, err := newLogger(wf.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	factory, conn, err := sharedConnection(spec)
+	if err != nil {
+		return err
+	}
+	defer conn.DB.Close()
+
+	sweep, err := benchmark.NewSweep(factory, spec.benchmark(), conn, sweepConfig, logger)
+	if err != nil {
+		return err
+	}
+
+	w, closeOutput, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := sweep.Start(); err != nil {
+		return err
+	}
+	status := waitForRunner(ctx, sweep, os.Stderr, logger)
+
+	if err := report.WriteSweep(w, sweep.SweepReport(), reportFormat); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if status.Status != string(models.BenchmarkStatusCompleted) {
+		return fmt.Errorf("%w: %s", errRunFailed, status.Status)
+	}
+	return nil
+}
+
+// sharedConnection looks up the factory of the spec's workload and opens the
+// connection pool shared by the runs of a sweep or search. The caller closes
+// the pool.
+func sharedConnection(spec *runSpec) (benchmark.Factory, *models.DBConnection, error) {
+	factory, err := benchmark.GetFactory(spec.Workload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn := spec.Connection
+	db, err := sql.Open(conn.Driver, conn.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn.SetDB(db)
+	return factory, &conn, nil
+}
+
+// createOutput opens the report file at path, or stdout if path is empty.
+// The returned function closes the file.
+func createOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create report file: %w", err)
+	}
+	return f, func() { f.Close() }, nil
+}
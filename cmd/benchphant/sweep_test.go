@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+func TestSweepFlagsConfig(t *testing.T) {
+	f := &sweepFlags{parameter: "threads", start: 1, end: 16, factor: 2}
+	config, err := f.config()
+	require.NoError(t, err)
+	assert.Equal(t, benchmark.SweepConfig{Parameter: benchmark.SweepThreads, Start: 1, End: 16, Factor: 2}, config)
+
+	// A value list replaces the series
+	f = &sweepFlags{parameter: "rate", values: "100, 200,400", start: 1, factor: 2}
+	config, err = f.config()
+	require.NoError(t, err)
+	assert.Equal(t, benchmark.SweepConfig{Parameter: benchmark.SweepRate, Values: []int{100, 200, 400}}, config)
+
+	for name, f := range map[string]*sweepFlags{
+		"BadValue":     {parameter: "threads", values: "1,two"},
+		"NoEnd":        {parameter: "threads", start: 1, factor: 2},
+		"BadParameter": {parameter: "memory", values: "1"},
+	} {
+		_, err := f.config()
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench"
+	"github.com/deadjoe/benchphant/internal/config"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/deadjoe/benchphant/internal/report"
+)
+
+// runSpec describes a headless workload run. It is read from the file given
+// by -config and then overridden by command line flags.
+type runSpec struct {
+	Workload       string              `json:"workload"`
+	Name           string              `json:"name"`
+	Connection     models.DBConnection `json:"connection"`
+	Threads        int                 `json:"threads"`
+	Duration       string              `json:"duration"`
+	ReportInterval string              `json:"report_interval"`
+	RampUp         string              `json:"ramp_up"`
+	RampSteps      int                 `json:"ramp_steps"`
+	Warmup         string              `json:"warmup"`
+	CoolDown       string              `json:"cool_down"`
+	OnError        string              `json:"on_error"`
+	MaxErrors      int64               `json:"max_errors"`
+	MaxErrorRate   float64             `json:"max_error_rate"`
+	Retries        int                 `json:"retries"`
+	RetryBackoff   string              `json:"retry_backoff"`
+	RetryOn        []string            `json:"retry_on"`
+	Config         json.RawMessage     `json:"config"`
+	Assertions     []string            `json:"assertions"`
+}
+
+// workloadFlags holds the flags shared by prepare, run and cleanup
+type workloadFlags struct {
+	configFile     string
+	workload       string
+	name           string
+	dbType         string
+	driver         string
+	dsn            string
+	threads        int
+	duration       time.Duration
+	reportInterval time.Duration
+	rampUp         time.Duration
+	rampSteps      int
+	warmup         time.Duration
+	coolDown       time.Duration
+	onError        string
+	maxErrors      int64
+	maxErrorRate   float64
+	retries        int
+	retryBackoff   time.Duration
+	retryOn        string
+	workloadConfig string
+	scenarioDir    string
+	logLevel       string
+}
+
+// register adds the workload flags to fs
+func (f *workloadFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.configFile, "config", "", "path to a JSON run spec")
+	fs.StringVar(&f.workload, "workload", "", "registered workload to run (query, sysbench, tpcc)")
+	fs.StringVar(&f.name, "name", "", "name of the run")
+	fs.StringVar(&f.dbType, "db-type", "", "database type (mysql, postgresql, sqlite)")
+	fs.StringVar(&f.driver, "driver", "", "database/sql driver name, derived from -db-type if empty")
+	fs.StringVar(&f.dsn, "dsn", "", "data source name of the target database")
+	fs.IntVar(&f.threads, "threads", 0, "number of concurrent threads or terminals")
+	fs.DurationVar(&f.duration, "duration", 0, "run duration")
+	fs.DurationVar(&f.reportInterval, "report-interval", 0, "interval between progress reports (default 1s)")
+	fs.DurationVar(&f.rampUp, "ramp-up", 0, "time over which the threads are started, not measured")
+	fs.IntVar(&f.rampSteps, "ramp-steps", 0, "start the threads in this many groups during the ramp-up instead of one by one")
+	fs.DurationVar(&f.warmup, "warmup", 0, "time run at full concurrency before measuring starts")
+	fs.DurationVar(&f.coolDown, "cool-down", 0, "time the load keeps running after measuring ends")
+	fs.StringVar(&f.onError, "on-error", "", "what a failed transaction does to the run (abort, continue, max_count, max_rate)")
+	fs.Int64Var(&f.maxErrors, "max-errors", 0, "failed transactions that end the run with -on-error max_count")
+	fs.Float64Var(&f.maxErrorRate, "max-error-rate", 0, "share of failed transactions that ends the run with -on-error max_rate")
+	fs.IntVar(&f.retries, "retries", 0, "retries of a transaction failing with a retryable error")
+	fs.DurationVar(&f.retryBackoff, "retry-backoff", 0, "wait before the first retry, doubled for each further one (default 10ms)")
+	fs.StringVar(&f.retryOn, "retry-on", "", "comma separated error classes to retry (default deadlock,lock_timeout,serialization_failure,connection_lost)")
+	fs.StringVar(&f.workloadConfig, "workload-config", "", "workload specific config as inline JSON")
+	fs.StringVar(&f.scenarioDir, "scenario-dir", config.DefaultScenarioDir, "directory stored sysbench scenarios are read from")
+	fs.StringVar(&f.logLevel, "log-level", "info", "log level (debug, info, warn, error)")
+}
+
+// parse parses args into fs and registers the sysbench workload reading
+// stored scenarios from -scenario-dir, which its workload config cannot name
+func (f *workloadFlags) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	benchmark.RegisterFactory("sysbench", sysbench.NewFactory(f.scenarioDir))
+	return nil
+}
+
+// spec loads the run spec file, if any, and applies the flag overrides
+func (f *workloadFlags) spec() (*runSpec, error) {
+	spec := &runSpec{}
+	if f.configFile != "" {
+		data, err := os.ReadFile(f.configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run spec: %w", err)
+		}
+		if err := json.Unmarshal(data, spec); err != nil {
+			return nil, fmt.Errorf("failed to parse run spec: %w", err)
+		}
+	}
+
+	if f.workload != "" {
+		spec.Workload = f.workload
+	}
+	if f.name != "" {
+		spec.Name = f.name
+	}
+	if f.dbType != "" {
+		spec.Connection.Type = models.DBType(f.dbType)
+	}
+	if f.driver != "" {
+		spec.Connection.Driver = f.driver
+	}
+	if f.dsn != "" {
+		spec.Connection.DSN = f.dsn
+	}
+	if f.threads > 0 {
+		spec.Threads = f.threads
+	}
+	if f.duration > 0 {
+		spec.Duration = f.duration.String()
+	}
+	if f.reportInterval > 0 {
+		spec.ReportInterval = f.reportInterval.String()
+	}
+	if f.rampUp > 0 {
+		spec.RampUp = f.rampUp.String()
+	}
+	if f.rampSteps > 0 {
+		spec.RampSteps = f.rampSteps
+	}
+	if f.warmup > 0 {
+		spec.Warmup = f.warmup.String()
+	}
+	if f.coolDown > 0 {
+		spec.CoolDown = f.coolDown.String()
+	}
+	if f.onError != "" {
+		spec.OnError = f.onError
+	}
+	if f.maxErrors > 0 {
+		spec.MaxErrors = f.maxErrors
+	}
+	if f.maxErrorRate > 0 {
+		spec.MaxErrorRate = f.maxErrorRate
+	}
+	if f.retries > 0 {
+		spec.Retries = f.retries
+	}
+	if f.retryBackoff > 0 {
+		spec.RetryBackoff = f.retryBackoff.String()
+	}
+	if f.retryOn != "" {
+		spec.RetryOn = strings.Split(f.retryOn, ",")
+	}
+	if f.workloadConfig != "" {
+		spec.Config = json.RawMessage(f.workloadConfig)
+	}
+
+	if spec.Connection.Driver == "" {
+		spec.Connection.Driver = driverFor(spec.Connection.Type)
+	}
+	if spec.Name == "" {
+		spec.Name = spec.Workload
+	}
+
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// validate checks that the spec has everything needed to create a runner
+func (s *runSpec) validate() error {
+	if s.Workload == "" {
+		return fmt.Errorf("workload is required")
+	}
+	if s.Connection.Driver == "" {
+		return fmt.Errorf("database type or driver is required")
+	}
+	if s.Connection.DSN == "" {
+		return fmt.Errorf("dsn is required")
+	}
+	if s.Threads < 0 {
+		return fmt.Errorf("threads must not be negative")
+	}
+	if s.Duration != "" {
+		if _, err := time.ParseDuration(s.Duration); err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+	if s.ReportInterval != "" {
+		interval, err := time.ParseDuration(s.ReportInterval)
+		if err != nil {
+			return fmt.Errorf("invalid report interval: %w", err)
+		}
+		if interval < 0 {
+			return fmt.Errorf("report interval must not be negative")
+		}
+	}
+	if _, err := s.phases(); err != nil {
+		return err
+	}
+	if _, err := s.errorPolicy(); err != nil {
+		return err
+	}
+	if len(s.Config) > 0 && !json.Valid(s.Config) {
+		return fmt.Errorf("workload config is not valid JSON")
+	}
+	return nil
+}
+
+// phases parses the phases run around the measurement
+func (s *runSpec) phases() (models.PhaseConfig, error) {
+	phases := models.PhaseConfig{RampSteps: s.RampSteps}
+	for _, p := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"ramp up", s.RampUp, &phases.RampUp},
+		{"warmup", s.Warmup, &phases.Warmup},
+		{"cool down", s.CoolDown, &phases.CoolDown},
+	} {
+		if p.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(p.value)
+		if err != nil {
+			return phases, fmt.Errorf("invalid %s: %w", p.name, err)
+		}
+		*p.dst = d
+	}
+	return phases, phases.Validate()
+}
+
+// errorPolicy parses the error policy of the run
+func (s *runSpec) errorPolicy() (models.ErrorPolicy, error) {
+	policy := models.ErrorPolicy{
+		OnError:      s.OnError,
+		MaxErrors:    s.MaxErrors,
+		MaxErrorRate: s.MaxErrorRate,
+		Retries:      s.Retries,
+		RetryOn:      s.RetryOn,
+	}
+	if s.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(s.RetryBackoff)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry backoff: %w", err)
+		}
+		policy.RetryBackoff = backoff
+	}
+	return policy, benchmark.ValidateErrorPolicy(policy)
+}
+
+// benchmark converts the spec into the model passed to a benchmark.Factory
+func (s *runSpec) benchmark() *models.Benchmark {
+	// Durations were checked by validate
+	duration, _ := time.ParseDuration(s.Duration)
+	interval, _ := time.ParseDuration(s.ReportInterval)
+	phases, _ := s.phases()
+	errorPolicy, _ := s.errorPolicy()
+
+	now := time.Now()
+	return &models.Benchmark{
+		Name:           s.Name,
+		ConnectionID:   s.Connection.ID,
+		NumThreads:     s.Threads,
+		Duration:       duration,
+		ReportInterval: interval,
+		Phases:         phases,
+		ErrorPolicy:    errorPolicy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Status:         models.BenchmarkStatusPending,
+		Config:         s.Config,
+	}
+}
+
+// driverFor returns the database/sql driver name for a database type
+func driverFor(t models.DBType) string {
+	switch t {
+	case models.MySQL:
+		return "mysql"
+	case models.PostgreSQL:
+		return "postgres"
+	case models.SQLite:
+		return "sqlite3"
+	default:
+		return ""
+	}
+}
+
+// newRunner creates a runner for the spec's workload through the factory registry
+func newRunner(spec *runSpec, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
+	factory, err := benchmark.GetFactory(spec.Workload)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := spec.Connection
+	runner, err := factory.Create(spec.benchmark(), &conn, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s benchmark: %w", spec.Workload, err)
+	}
+	return runner, nil
+}
+
+// parseWorkload parses the shared workload flags on top of any flags the
+// caller already registered on fs and creates the runner
+func parseWorkload(fs *flag.FlagSet, args []string) (benchmark.BenchmarkRunner, *runSpec, *zap.Logger, error) {
+	var wf workloadFlags
+	wf.register(fs)
+	if err := wf.parse(fs, args); err != nil {
+		return nil, nil, nil, err
+	}
+
+	spec, err := wf.spec()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	logger, err := newLogger(wf.logLevel)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	runner, err := newRunner(spec, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return runner, spec, logger, nil
+}
+
+// runPrepare creates the tables and data used by a workload
+func runPrepare(args []string) error {
+	fs := flag.NewFlagSet("prepare", flag.ContinueOnError)
+	runner, _, logger, err := parseWorkload(fs, args)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	preparer, ok := runner.(benchmark.Preparer)
+	if !ok {
+		return fmt.Errorf("workload does not support prepare")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- preparer.Prepare(ctx)
+	}()
+
+	// Log the load progress reported by the runner until Prepare returns
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+			logger.Info("Prepare finished", zap.Duration("elapsed", time.Since(start)))
+			return nil
+		case <-ticker.C:
+			logger.Info("Prepare progress", zap.Float64("progress", runner.Status().Progress))
+		}
+	}
+}
+
+// runCleanup drops the tables created by prepare
+func runCleanup(args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	runner, _, logger, err := parseWorkload(fs, args)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	cleaner, ok := runner.(benchmark.Cleaner)
+	if !ok {
+		return fmt.Errorf("workload does not support cleanup")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cleaner.Cleanup(ctx); err != nil {
+		return err
+	}
+	logger.Info("Cleanup finished")
+	return nil
+}
+
+// runRun starts a workload, waits for it to finish and writes a report. It
+// returns errRunFailed when the run failed or was cancelled and
+// errAssertionsFailed when an assertion did not hold.
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	format := fs.String("format", string(report.FormatText), "report format (text, json, junit)")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	var asserts assertionFlags
+	fs.Var(&asserts, "assert", `assertion on the result, e.g. "tps >= 500" or "latency_p99 <= 20ms" (repeatable)`)
+
+	runner, spec, logger, err := parseWorkload(fs, args)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	assertions, err := parseAssertions(append(spec.Assertions, asserts...))
+	if err != nil {
+		return err
+	}
+	reportFormat, err := report.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	startTime := time.Now()
+	if err := runner.Start(); err != nil {
+		return err
+	}
+
+	status := waitForRunner(ctx, runner, os.Stderr, logger)
+	result := benchmark.NewResult(spec.Name, status, startTime, time.Now())
+	result.Intervals = benchmark.IntervalsOf(runner)
+	result.Phases = benchmark.PhasesOf(runner)
+
+	rep := report.New(status.Status, result, assertions)
+	if err := rep.Write(w, reportFormat); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	for _, failure := range rep.Failures() {
+		logger.Error("Assertion failed", zap.String("assertion", failure.Expr), zap.String("reason", failure.Message))
+	}
+	if status.Status != string(models.BenchmarkStatusCompleted) {
+		return fmt.Errorf("%w: %s", errRunFailed, status.Status)
+	}
+	if !rep.Passed {
+		return errAssertionsFailed
+	}
+	return nil
+}
+
+// assertionFlags collects repeated -assert flags
+type assertionFlags []string
+
+// String implements flag.Value
+func (a *assertionFlags) String() string {
+	return strings.Join(*a, ", ")
+}
+
+// Set implements flag.Value
+func (a *assertionFlags) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// parseAssertions parses the assertions from the run spec and the command line
+func parseAssertions(exprs []string) ([]*report.Assertion, error) {
+	assertions := make([]*report.Assertion, 0, len(exprs))
+	for _, expr := range exprs {
+		a, err := report.ParseAssertion(expr)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// waitForRunner polls the runner until it reaches a final status, stopping
+// it when ctx is cancelled. Interval reports are written to progress as they
+// come in, other runners have their progress logged.
+func waitForRunner(ctx context.Context, runner benchmark.BenchmarkRunner, progress io.Writer, logger *zap.Logger) benchmark.BenchmarkStatus {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	reporter, hasIntervals := runner.(benchmark.IntervalReporter)
+	printed := 0
+	printIntervals := func() {
+		intervals := reporter.Intervals()
+		for _, interval := range intervals[printed:] {
+			fmt.Fprintln(progress, report.FormatInterval(interval))
+		}
+		printed = len(intervals)
+	}
+
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			logger.Info("Stopping benchmark")
+			runner.Stop()
+			done = nil
+		case <-ticker.C:
+		}
+
+		status := runner.Status()
+		if hasIntervals {
+			printIntervals()
+		}
+		if models.BenchmarkStatus(status.Status).IsFinished() {
+			return status
+		}
+		if !hasIntervals {
+			logger.Info("Benchmark progress",
+				zap.String("status", status.Status),
+				zap.Float64("progress", status.Progress),
+			)
+		}
+	}
+}
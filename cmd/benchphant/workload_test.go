@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+func TestWorkloadFlagsSpec(t *testing.T) {
+	t.Run("FlagsOnly", func(t *testing.T) {
+		f := &workloadFlags{
+			workload:       "sysbench",
+			dbType:         "postgresql",
+			dsn:            "postgres://localhost/test",
+			threads:        4,
+			duration:       time.Minute,
+			reportInterval: 10 * time.Second,
+			rampUp:         20 * time.Second,
+			rampSteps:      4,
+			warmup:         30 * time.Second,
+			onError:        models.ErrorPolicyMaxCount,
+			maxErrors:      100,
+			retries:        2,
+			retryOn:        "deadlock,lock_timeout",
+		}
+		spec, err := f.spec()
+		require.NoError(t, err)
+		assert.Equal(t, "sysbench", spec.Name)
+		assert.Equal(t, "postgres", spec.Connection.Driver)
+
+		b := spec.benchmark()
+		assert.Equal(t, 4, b.NumThreads)
+		assert.Equal(t, time.Minute, b.Duration)
+		assert.Equal(t, 10*time.Second, b.ReportInterval)
+		assert.Equal(t, models.PhaseConfig{RampUp: 20 * time.Second, RampSteps: 4, Warmup: 30 * time.Second}, b.Phases)
+		assert.Equal(t, models.ErrorPolicy{
+			OnError:   models.ErrorPolicyMaxCount,
+			MaxErrors: 100,
+			Retries:   2,
+			RetryOn:   []string{"deadlock", "lock_timeout"},
+		}, b.ErrorPolicy)
+		assert.Equal(t, models.BenchmarkStatusPending, b.Status)
+	})
+
+	t.Run("FileWithOverrides", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "run.json")
+		data := `{
+			"workload": "tpcc",
+			"name": "nightly",
+			"connection": {"type": "mysql", "dsn": "root@tcp(localhost:3306)/tpcc"},
+			"threads": 8,
+			"duration": "30s",
+			"report_interval": "5s",
+			"warmup": "1m",
+			"cool_down": "10s",
+			"on_error": "max_rate",
+			"max_error_rate": 0.05,
+			"retry_backoff": "20ms",
+			"config": {"warehouses": 2}
+		}`
+		require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+		f := &workloadFlags{configFile: path, threads: 16}
+		spec, err := f.spec()
+		require.NoError(t, err)
+		assert.Equal(t, "nightly", spec.Name)
+		assert.Equal(t, "mysql", spec.Connection.Driver)
+		assert.Equal(t, 16, spec.Threads)
+		assert.Equal(t, 30*time.Second, spec.benchmark().Duration)
+		assert.Equal(t, 5*time.Second, spec.benchmark().ReportInterval)
+		assert.Equal(t, models.PhaseConfig{Warmup: time.Minute, CoolDown: 10 * time.Second}, spec.benchmark().Phases)
+		assert.Equal(t, models.ErrorPolicy{OnError: models.ErrorPolicyMaxRate, MaxErrorRate: 0.05, RetryBackoff: 20 * time.Millisecond}, spec.benchmark().ErrorPolicy)
+		assert.JSONEq(t, `{"warehouses": 2}`, string(spec.Config))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			flags workloadFlags
+		}{
+			{"MissingWorkload", workloadFlags{dbType: "mysql", dsn: "dsn"}},
+			{"MissingDriver", workloadFlags{workload: "sysbench", dsn: "dsn"}},
+			{"MissingDSN", workloadFlags{workload: "sysbench", dbType: "mysql"}},
+			{"BadConfig", workloadFlags{workload: "sysbench", dbType: "mysql", dsn: "dsn", workloadConfig: "{"}},
+			{"BadErrorPolicy", workloadFlags{workload: "sysbench", dbType: "mysql", dsn: "dsn", onError: "ignore"}},
+			{"BadRetryClass", workloadFlags{workload: "sysbench", dbType: "mysql", dsn: "dsn", retries: 1, retryOn: "timeout"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := tt.flags.spec()
+				assert.Error(t, err)
+			})
+		}
+
+		spec := &runSpec{Workload: "sysbench", Connection: models.DBConnection{Driver: "mysql", DSN: "dsn"}}
+		require.NoError(t, spec.validate())
+		spec.Warmup = "soon"
+		assert.Error(t, spec.validate())
+		spec.Warmup, spec.RampSteps = "", -1
+		assert.Error(t, spec.validate())
+	})
+}
+
+func TestNewRunnerUnknownWorkload(t *testing.T) {
+	spec := &runSpec{
+		Workload:   "unknown",
+		Connection: models.DBConnection{Driver: "mysql", DSN: "dsn"},
+	}
+	_, err := newRunner(spec, nil)
+	assert.Error(t, err)
+}
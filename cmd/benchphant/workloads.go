@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+// runWorkloads lists the registered workloads, or prints the description and
+// config schema of the one named as argument
+func runWorkloads(args []string) error {
+	fs := flag.NewFlagSet("workloads", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the workload descriptions as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: benchphant workloads [-json] [workload]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch fs.NArg() {
+	case 0:
+		return writeWorkloads(os.Stdout, benchmark.ListFactories(), *asJSON)
+	case 1:
+		info, err := benchmark.DescribeFactory(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return writeWorkloadJSON(os.Stdout, info)
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected at most one workload, got %d", fs.NArg())
+	}
+}
+
+// writeWorkloads writes one line per workload, or a JSON array with asJSON
+func writeWorkloads(w io.Writer, infos []benchmark.FactoryInfo, asJSON bool) error {
+	if asJSON {
+		return writeWorkloadJSON(w, infos)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\n", info.Name, info.Description)
+	}
+	return tw.Flush()
+}
+
+// writeWorkloadJSON writes v as indented JSON
+func writeWorkloadJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+func TestWriteWorkloads(t *testing.T) {
+	infos := benchmark.ListFactories()
+	require.NotEmpty(t, infos)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeWorkloads(&buf, infos, false))
+	assert.Contains(t, buf.String(), "sysbench")
+	assert.Contains(t, buf.String(), "tpcc")
+
+	buf.Reset()
+	require.NoError(t, writeWorkloads(&buf, infos, true))
+	var decoded []benchmark.FactoryInfo
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded, len(infos))
+}
@@ -1,140 +1,593 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/deadjoe/benchphant/internal/report"
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-// BenchmarkRequest represents a request to start a benchmark
+// statusPollInterval is how often a running benchmark's status is checked
+var statusPollInterval = time.Second
+
+// BenchmarkRequest represents a request to create a benchmark
 type BenchmarkRequest struct {
-	ConnectionID int64     `json:"connection_id"`
-	Duration     string    `json:"duration"`
-	Concurrency  int       `json:"concurrency"`
-	QueryRate    int       `json:"query_rate"`
-	Queries      []string  `json:"queries"`
-	Distribution string    `json:"distribution"`
-	QueryWeights []float64 `json:"query_weights,omitempty"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Type           string          `json:"type"`
+	ConnectionID   int64           `json:"connection_id"`
+	Duration       string          `json:"duration"`
+	ReportInterval string          `json:"report_interval,omitempty"`
+	RampUp         string          `json:"ramp_up,omitempty"`
+	RampSteps      int             `json:"ramp_steps,omitempty"`
+	Warmup         string          `json:"warmup,omitempty"`
+	CoolDown       string          `json:"cool_down,omitempty"`
+	OnError        string          `json:"on_error,omitempty"`
+	MaxErrors      int64           `json:"max_errors,omitempty"`
+	MaxErrorRate   float64         `json:"max_error_rate,omitempty"`
+	Retries        int             `json:"retries,omitempty"`
+	RetryBackoff   string          `json:"retry_backoff,omitempty"`
+	RetryOn        []string        `json:"retry_on,omitempty"`
+	Concurrency    int             `json:"concurrency"`
+	QueryRate      int             `json:"query_rate"`
+	Arrival        string          `json:"arrival,omitempty"`
+	Queries        []string        `json:"queries"`
+	Distribution   string          `json:"distribution"`
+	QueryWeights   []float64       `json:"query_weights,omitempty"`
+	Config         json.RawMessage `json:"config,omitempty"`
+}
+
+// toBenchmark converts the request into a pending benchmark definition
+func (req *BenchmarkRequest) toBenchmark() (*models.Benchmark, error) {
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration: %w", err)
+	}
+	var interval time.Duration
+	if req.ReportInterval != "" {
+		if interval, err = time.ParseDuration(req.ReportInterval); err != nil {
+			return nil, fmt.Errorf("invalid report interval: %w", err)
+		}
+	}
+	phases := models.PhaseConfig{RampSteps: req.RampSteps}
+	if phases.RampUp, err = optionalDuration("ramp up", req.RampUp); err != nil {
+		return nil, err
+	}
+	if phases.Warmup, err = optionalDuration("warmup", req.Warmup); err != nil {
+		return nil, err
+	}
+	if phases.CoolDown, err = optionalDuration("cool down", req.CoolDown); err != nil {
+		return nil, err
+	}
+	if err := phases.Validate(); err != nil {
+		return nil, err
+	}
+	errorPolicy := models.ErrorPolicy{
+		OnError:      req.OnError,
+		MaxErrors:    req.MaxErrors,
+		MaxErrorRate: req.MaxErrorRate,
+		Retries:      req.Retries,
+		RetryOn:      req.RetryOn,
+	}
+	if errorPolicy.RetryBackoff, err = optionalDuration("retry backoff", req.RetryBackoff); err != nil {
+		return nil, err
+	}
+	if err := benchmark.ValidateErrorPolicy(errorPolicy); err != nil {
+		return nil, err
+	}
+
+	b := &models.Benchmark{
+		Name:           req.Name,
+		Description:    req.Description,
+		Type:           req.Type,
+		ConnectionID:   req.ConnectionID,
+		NumThreads:     req.Concurrency,
+		Duration:       duration,
+		ReportInterval: interval,
+		Phases:         phases,
+		ErrorPolicy:    errorPolicy,
+		Status:         models.BenchmarkStatusPending,
+		Config:         req.Config,
+	}
+	if b.Type == "" {
+		b.Type = models.BenchmarkTypeQuery
+	}
+	if b.Name == "" {
+		b.Name = "API Benchmark"
+	}
+	if b.Type != models.BenchmarkTypeQuery {
+		return b, nil
+	}
+
+	// A single query is the template, several queries are run one at a
+	// time, picked by the distribution
+	switch {
+	case len(req.Queries) == 1:
+		b.QueryTemplate = req.Queries[0]
+	case len(req.Queries) > 1 && len(b.Config) > 0:
+		return nil, fmt.Errorf("several queries cannot be combined with a config, list them under queries in the config")
+	}
+	if len(b.Config) == 0 {
+		queryConfig := benchmark.QueryConfig{
+			QueryRate:    req.QueryRate,
+			Arrival:      req.Arrival,
+			Distribution: req.Distribution,
+			QueryWeights: req.QueryWeights,
+		}
+		if len(req.Queries) > 1 {
+			for _, query := range req.Queries {
+				queryConfig.Queries = append(queryConfig.Queries, benchmark.Query{SQL: query})
+			}
+		}
+		config, err := json.Marshal(queryConfig)
+		if err != nil {
+			return nil, err
+		}
+		b.Config = config
+	}
+	queryConfig, err := benchmark.ParseQueryConfig(b.Config)
+	if err != nil {
+		return nil, err
+	}
+	if b.QueryTemplate == "" && len(queryConfig.Queries) == 0 {
+		return nil, fmt.Errorf("queries are required")
+	}
+	if _, err := benchmark.ParseQueryTemplate(b.QueryTemplate, false); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// optionalDuration parses the duration s of the named request field, which
+// is zero when s is empty
+func optionalDuration(name, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
+
+// withID adapts a handler taking the :id path parameter to gin
+func withID(h func(w http.ResponseWriter, r *http.Request, id int64)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(c.Writer, http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+			return
+		}
+		h(c.Writer, c.Request, id)
+	}
+}
+
+// handleBenchmarks handles listing and creating benchmarks
+func (s *Server) handleBenchmarks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		benchmarks, err := s.manager.Benchmarks().ListBenchmarks()
+		if err != nil {
+			s.logger.Error("Failed to list benchmarks", zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to list benchmarks"})
+			return
+		}
+		if benchmarks == nil {
+			benchmarks = []*models.Benchmark{}
+		}
+		writeJSON(w, http.StatusOK, benchmarks)
+
+	case http.MethodPost:
+		var req BenchmarkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.logger.Error("Failed to decode request", zap.Error(err))
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request data"})
+			return
+		}
+
+		b, err := req.toBenchmark()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := b.Validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if _, err := benchmark.GetFactory(b.Type); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if _, err := s.manager.GetConnection(b.ConnectionID); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Connection not found"})
+			return
+		}
+
+		now := time.Now()
+		b.CreatedAt = now
+		b.UpdatedAt = now
+		if err := s.manager.Benchmarks().SaveBenchmark(b); err != nil {
+			s.logger.Error("Failed to save benchmark", zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to save benchmark"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, b)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBenchmark handles getting and deleting a single benchmark
+func (s *Server) handleBenchmark(w http.ResponseWriter, r *http.Request, id int64) {
+	b, err := s.manager.Benchmarks().GetBenchmark(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Benchmark not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, b)
+
+	case http.MethodDelete:
+		if s.runs.activeFor(id) != nil {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "Benchmark is running"})
+			return
+		}
+		if err := s.manager.Benchmarks().DeleteBenchmark(id); err != nil {
+			s.logger.Error("Failed to delete benchmark", zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete benchmark"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
 }
 
-// handleBenchmarkStart handles starting a benchmark
-func (s *Server) handleBenchmarkStart(w http.ResponseWriter, r *http.Request) {
+// handleBenchmarkStart handles starting a benchmark. With ?prepare=true the
+// workload's schema and data are created before the run.
+func (s *Server) handleBenchmarkStart(w http.ResponseWriter, r *http.Request, id int64) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	s.startRun(w, r, id, nil)
+}
 
-	var req BenchmarkRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.logger.Error("Failed to decode request", zap.Error(err))
+// handleBenchmarkSweep handles starting a sweep, which runs the benchmark
+// once for each thread count or rate of the sweep config in the body
+func (s *Server) handleBenchmarkSweep(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var sweep benchmark.SweepConfig
+	if err := json.NewDecoder(r.Body).Decode(&sweep); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request data"})
 		return
 	}
+	if _, err := sweep.Steps(); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	s.startRun(w, r, id, func(factory benchmark.Factory, b *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
+		return benchmark.NewSweep(factory, b, conn, sweep, logger)
+	})
+}
+
+// SearchRequest represents a request to search for the highest threads or
+// rate of a benchmark that meets the objectives
+type SearchRequest struct {
+	Parameter     string   `json:"parameter"`
+	Low           int      `json:"low"`
+	High          int      `json:"high"`
+	Precision     int      `json:"precision,omitempty"`
+	ProbeDuration string   `json:"probe_duration,omitempty"`
+	Objectives    []string `json:"objectives"`
+}
+
+// toSearch converts the request into a search config and its objectives
+func (req *SearchRequest) toSearch() (benchmark.SearchConfig, []benchmark.Objective, error) {
+	search := benchmark.SearchConfig{
+		Parameter: benchmark.SweepParameter(req.Parameter),
+		Low:       req.Low,
+		High:      req.High,
+		Precision: req.Precision,
+	}
+	var err error
+	if search.ProbeDuration, err = optionalDuration("probe duration", req.ProbeDuration); err != nil {
+		return search, nil, err
+	}
+	if err := search.Validate(); err != nil {
+		return search, nil, err
+	}
+
+	if len(req.Objectives) == 0 {
+		return search, nil, fmt.Errorf("search needs at least one objective")
+	}
+	objectives := make([]benchmark.Objective, 0, len(req.Objectives))
+	for _, expr := range req.Objectives {
+		a, err := report.ParseAssertion(expr)
+		if err != nil {
+			return search, nil, err
+		}
+		objectives = append(objectives, a)
+	}
+	return search, objectives, nil
+}
 
-	// Validate request
-	if req.ConnectionID == 0 || len(req.Queries) == 0 {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Missing required fields"})
+// handleBenchmarkSearch handles starting a search for the highest threads or
+// rate of the benchmark that meets the objectives in the body
+func (s *Server) handleBenchmarkSearch(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get connection
-	conn, err := s.manager.GetConnection(req.ConnectionID)
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request data"})
+		return
+	}
+	search, objectives, err := req.toSearch()
 	if err != nil {
-		s.logger.Error("Failed to get connection", zap.Error(err))
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Connection not found"})
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		return
 	}
+	s.startRun(w, r, id, func(factory benchmark.Factory, b *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
+		return benchmark.NewSearch(factory, b, conn, search, objectives, logger)
+	})
+}
 
-	// Parse duration
-	duration, err := time.ParseDuration(req.Duration)
+// runnerWrapper creates a runner driving several runs of a benchmark, such
+// as a sweep, from the benchmark's factory
+type runnerWrapper func(factory benchmark.Factory, b *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error)
+
+// startRun creates the runner of benchmark id, wrapped by wrap if set, and
+// runs it in the background
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request, id int64, wrap runnerWrapper) {
+	b, err := s.manager.Benchmarks().GetBenchmark(id)
 	if err != nil {
-		s.logger.Error("Invalid duration", zap.Error(err))
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid duration"})
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Benchmark not found"})
 		return
 	}
 
-	// Create benchmark config
-	config := &models.BenchmarkConfig{
-		ConnectionID: strconv.FormatInt(req.ConnectionID, 10),
-		Duration:    int(duration.Seconds()),
-		Threads:     req.Concurrency,
-		Query:       strings.Join(req.Queries, ";"),
-		Name:        "API Benchmark",
-		Description: fmt.Sprintf("API Benchmark with %d threads and %d QPS", req.Concurrency, req.QueryRate),
+	factory, err := benchmark.GetFactory(b.Type)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
 	}
 
-	// Create and start benchmark
-	b, err := benchmark.NewBenchmark(config, conn)
+	conn, err := s.manager.GetConnection(b.ConnectionID)
 	if err != nil {
+		s.logger.Error("Failed to get connection", zap.Error(err))
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Connection not found"})
+		return
+	}
+	pool, err := s.manager.GetPool(b.ConnectionID)
+	if err != nil {
+		s.logger.Error("Failed to get connection pool", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to open connection"})
+		return
+	}
+	conn.SetDB(pool.GetDB())
+
+	logger := s.logger.With(zap.Int64("benchmark_id", id))
+	var runner benchmark.BenchmarkRunner
+	if wrap != nil {
+		if runner, err = wrap(factory, b, conn, logger); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	} else if runner, err = factory.Create(b, conn, logger); err != nil {
 		s.logger.Error("Failed to create benchmark", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to create benchmark"})
 		return
 	}
 
-	if err := b.Start(); err != nil {
-		s.logger.Error("Failed to start benchmark", zap.Error(err))
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to start benchmark"})
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &benchmarkRun{
+		benchmarkID:  id,
+		connectionID: b.ConnectionID,
+		runner:       runner,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+		startTime:    time.Now(),
+	}
+	if err := s.runs.add(run); err != nil {
+		cancel()
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	// Store benchmark for status checks
-	s.mu.Lock()
-	s.activeBenchmark = b
-	s.mu.Unlock()
+	b.Status = models.BenchmarkStatusRunning
+	b.UpdatedAt = time.Now()
+	if err := s.manager.Benchmarks().UpdateBenchmark(b); err != nil {
+		s.logger.Error("Failed to update benchmark", zap.Error(err))
+	}
+
+	prepare, _ := strconv.ParseBool(r.URL.Query().Get("prepare"))
+	go s.execute(ctx, run, b, prepare)
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "started"})
+	writeJSON(w, http.StatusAccepted, s.runs.info(run))
 }
 
-// handleBenchmarkStop handles stopping a benchmark
-func (s *Server) handleBenchmarkStop(w http.ResponseWriter, r *http.Request) {
+// handleBenchmarkStop handles stopping a running benchmark
+func (s *Server) handleBenchmarkStop(w http.ResponseWriter, r *http.Request, id int64) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	s.mu.Lock()
-	b := s.activeBenchmark
-	s.mu.Unlock()
-
-	if b == nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "No active benchmark"})
+	run := s.runs.activeFor(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Benchmark is not running"})
 		return
 	}
 
-	b.Stop()
+	run.cancel()
+	<-run.done
 	writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
 }
 
-// handleBenchmarkStatus handles getting benchmark status
-func (s *Server) handleBenchmarkStatus(w http.ResponseWriter, r *http.Request) {
+// handleBenchmarkStatus handles getting the live status of a benchmark
+func (s *Server) handleBenchmarkStatus(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if run := s.runs.activeFor(id); run != nil {
+		info := s.runs.info(run)
+		writeJSON(w, http.StatusOK, benchmark.BenchmarkStatus{
+			Status:   info.Status,
+			Progress: info.Progress,
+			Metrics:  info.Metrics,
+		})
+		return
+	}
+
+	b, err := s.manager.Benchmarks().GetBenchmark(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Benchmark not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, benchmark.BenchmarkStatus{
+		Status:  string(b.Status),
+		Metrics: map[string]interface{}{},
+	})
+}
+
+// handleBenchmarkResults handles listing the results of a benchmark's runs
+func (s *Server) handleBenchmarkResults(w http.ResponseWriter, r *http.Request, id int64) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	s.mu.RLock()
-	b := s.activeBenchmark
-	s.mu.RUnlock()
+	if _, err := s.manager.Benchmarks().GetBenchmark(id); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Benchmark not found"})
+		return
+	}
 
-	if b == nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "No active benchmark"})
+	results, err := s.manager.Benchmarks().ListResults(id)
+	if err != nil {
+		s.logger.Error("Failed to list results", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to list results"})
 		return
 	}
+	if results == nil {
+		results = []*models.BenchmarkResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// execute prepares and runs a benchmark until it finishes or ctx is
+// cancelled, then stores its result
+func (s *Server) execute(ctx context.Context, run *benchmarkRun, b *models.Benchmark, prepare bool) {
+	defer close(run.done)
+	defer run.cancel()
+
+	status, startTime, err := s.runToCompletion(ctx, run.runner, prepare)
+	if err != nil {
+		s.logger.Error("Benchmark failed", zap.Int64("benchmark_id", b.ID), zap.Error(err))
+		status.Status = string(models.BenchmarkStatusFailed)
+	}
+
+	result := benchmark.NewResult(b.Name, status, startTime, time.Now())
+	result.Intervals = benchmark.IntervalsOf(run.runner)
+	result.Phases = benchmark.PhasesOf(run.runner)
+	record := newBenchmarkResult(b.ID, models.BenchmarkStatus(status.Status), result)
+	record.Sweep = benchmark.SweepOf(run.runner)
+	record.Search = benchmark.SearchOf(run.runner)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := s.manager.Benchmarks().SaveResult(record); err != nil {
+		s.logger.Error("Failed to save benchmark result", zap.Int64("benchmark_id", b.ID), zap.Error(err))
+	}
+
+	b.Status = record.Status
+	b.UpdatedAt = time.Now()
+	if err := s.manager.Benchmarks().UpdateBenchmark(b); err != nil {
+		s.logger.Error("Failed to update benchmark", zap.Int64("benchmark_id", b.ID), zap.Error(err))
+	}
+
+	s.runs.finish(run, status, record)
+}
 
-	status := struct {
-		Status models.BenchmarkStatus `json:"status"`
-		Stats  map[string]interface{} `json:"stats,omitempty"`
-	}{
-		Status: b.Status(),
-		Stats:  b.Status().Metrics,
+// runToCompletion prepares runner if asked to, starts it and polls it until
+// it reaches a final status. It returns that status and the time the runner
+// was started, which leaves the prepare out of the run duration. Cancelling
+// ctx stops the runner.
+func (s *Server) runToCompletion(ctx context.Context, runner benchmark.BenchmarkRunner, prepare bool) (benchmark.BenchmarkStatus, time.Time, error) {
+	if prepare {
+		preparer, ok := runner.(benchmark.Preparer)
+		if !ok {
+			return runner.Status(), time.Now(), fmt.Errorf("workload does not support prepare")
+		}
+		if err := preparer.Prepare(ctx); err != nil {
+			return runner.Status(), time.Now(), fmt.Errorf("prepare: %w", err)
+		}
 	}
 
-	writeJSON(w, http.StatusOK, status)
+	startTime := time.Now()
+	if err := runner.Start(); err != nil {
+		return runner.Status(), startTime, err
+	}
+
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			runner.Stop()
+			done = nil
+		case <-ticker.C:
+		}
+
+		status := runner.Status()
+		if models.BenchmarkStatus(status.Status).IsFinished() {
+			return status, startTime, nil
+		}
+	}
+}
+
+// newBenchmarkResult converts a run result into its stored form
+func newBenchmarkResult(id int64, status models.BenchmarkStatus, result *benchmark.Result) *models.BenchmarkResult {
+	return &models.BenchmarkResult{
+		BenchmarkID:    id,
+		Status:         status,
+		StartTime:      result.StartTime,
+		EndTime:        result.EndTime,
+		TotalQueries:   result.TotalTransactions,
+		SuccessCount:   result.TotalTransactions - result.Errors,
+		FailureCount:   result.Errors,
+		AverageLatency: result.LatencyAvg,
+		MinLatency:     result.LatencyMin,
+		MaxLatency:     result.LatencyMax,
+		P95Latency:     result.LatencyP95,
+		P99Latency:     result.LatencyP99,
+		QPS:            result.TPS,
+		Metrics:        result.Metrics,
+		Intervals:      result.Intervals,
+		Phases:         result.Phases,
+		Breakdown:      result.Breakdown,
+	}
 }
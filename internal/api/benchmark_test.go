@@ -2,610 +2,597 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/config"
 	"github.com/deadjoe/benchphant/internal/database"
 	"github.com/deadjoe/benchphant/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
-func setupTestServer(t *testing.T) *Server {
-	cfg := &config.Config{
-		Server: config.ServerConfig{
-			Port: 8080,
-		},
-	}
-
-	dbManager, err := database.NewManager(&database.Config{
-		StorageType: "memory",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database manager: %v", err)
-	}
-
-	logger, err := zap.NewDevelopment()
-	if err != nil {
-		t.Fatalf("Failed to create logger: %v", err)
-	}
-
-	return NewServer(cfg, dbManager, logger)
+// fakeRunner completes after a fixed duration unless it is stopped first
+type fakeRunner struct {
+	duration  time.Duration
+	mu        sync.Mutex
+	status    benchmark.BenchmarkStatus
+	intervals []models.IntervalReport
+	phases    []models.PhaseReport
+	stop      chan struct{}
 }
 
-func TestHandleBenchmarkStart(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
-
-	// Test case 1: Valid request
-	validConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
-
-	validBody, err := json.Marshal(validConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
-	}
-
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(validBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "success", response["status"])
-
-	// Test case 2: Invalid request - missing required fields
-	invalidConfig := &models.BenchmarkConfig{
-		Duration: 60,
-		Threads:  10,
-	}
-
-	invalidBody, err := json.Marshal(invalidConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
-	}
-
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(invalidBody))
-	req.Header.Set("Content-Type", "application/json")
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-
-	// Test case 3: Invalid JSON
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBufferString("invalid json"))
-	req.Header.Set("Content-Type", "application/json")
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+func (f *fakeRunner) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.status.Status = string(models.BenchmarkStatusRunning)
+	f.stop = make(chan struct{})
+	go func() {
+		select {
+		case <-time.After(f.duration):
+			f.mu.Lock()
+			f.status = benchmark.BenchmarkStatus{
+				Status:   string(models.BenchmarkStatusCompleted),
+				Progress: 100,
+				Metrics: map[string]interface{}{
+					"total_transactions": int64(100),
+					"tps":                float64(1000),
+					"latency_avg":        time.Millisecond,
+					"errors":             int64(0),
+					"breakdown": map[string]benchmark.QueryStats{
+						"query": {Count: 100},
+					},
+				},
+			}
+			f.intervals = []models.IntervalReport{
+				{Elapsed: f.duration, Duration: f.duration, Transactions: 100, TPS: 1000},
+			}
+			f.phases = []models.PhaseReport{
+				{Phase: models.PhaseMeasure, Duration: f.duration, Transactions: 100, TPS: 1000},
+			}
+			f.mu.Unlock()
+		case <-f.stop:
+			f.mu.Lock()
+			f.status.Status = string(models.BenchmarkStatusCancelled)
+			f.mu.Unlock()
+		}
+	}()
+	return nil
 }
 
-func TestHandleBenchmarkStop(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/stop", gin.WrapF(server.handleBenchmarkStop))
-
-	// Test case 1: No active benchmark
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/stop", nil)
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-
-	// Test case 2: Active benchmark
-	validConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
-
-	// Start a benchmark first
-	body, _ := json.Marshal(validConfig)
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	// Now stop it
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/stop", nil)
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
+func (f *fakeRunner) Stop() {
+	close(f.stop)
 }
 
-func TestHandleBenchmarkStatus(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.GET("/api/v1/benchmark/status", gin.WrapF(server.handleBenchmarkStatus))
-
-	// Test case 1: No active benchmark
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/benchmark/status", nil)
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "no active benchmark", response["status"])
-
-	// Test case 2: Active benchmark
-	validConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
-
-	// Start a benchmark first
-	body, _ := json.Marshal(validConfig)
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	// Now check status
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/benchmark/status", nil)
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "running", response["status"])
+func (f *fakeRunner) Status() benchmark.BenchmarkStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status.Copy()
 }
 
-func TestHandleConnections(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
+func (f *fakeRunner) Intervals() []models.IntervalReport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.intervals
+}
 
-	server := setupTestServer(t)
-	router.GET("/api/v1/connections", gin.WrapF(server.handleConnections))
-	router.POST("/api/v1/connections", gin.WrapF(server.handleConnections))
+func (f *fakeRunner) Phases() []models.PhaseReport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.phases
+}
 
-	// Test case 1: List connections (GET)
-	req := httptest.NewRequest("GET", "/api/v1/connections", nil)
-	w := httptest.NewRecorder()
+// fakeFactory creates fakeRunners, reading the run time from the benchmark duration
+type fakeFactory struct{}
 
-	router.ServeHTTP(w, req)
+func (fakeFactory) Name() string { return "fake" }
 
-	assert.Equal(t, http.StatusOK, w.Code)
+func (fakeFactory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
+	return &fakeRunner{
+		duration: config.Duration,
+		status:   benchmark.BenchmarkStatus{Status: string(models.BenchmarkStatusPending)},
+	}, nil
+}
 
-	// Test case 2: Add connection (POST)
-	connection := &models.DBConnection{
-		ID:       "test-conn",
-		Name:     "Test Connection",
-		Type:     models.DBTypePostgreSQL,
-		Host:     "localhost",
-		Port:     5432,
-		Database: "test",
-		Username: "test",
-		Password: "test",
-	}
+// preparingRunner is a fakeRunner whose Prepare takes a while
+type preparingRunner struct {
+	*fakeRunner
+	prepareTime time.Duration
+}
 
-	body, _ := json.Marshal(connection)
-	req = httptest.NewRequest("POST", "/api/v1/connections", bytes.NewBuffer(body))
-	w = httptest.NewRecorder()
+func (p *preparingRunner) Prepare(ctx context.Context) error {
+	time.Sleep(p.prepareTime)
+	return nil
+}
 
-	router.ServeHTTP(w, req)
+// preparingFactory creates preparingRunners that prepare for 200ms
+type preparingFactory struct{}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+func (preparingFactory) Name() string { return "fake_prepare" }
 
-	// Test case 3: Invalid connection (POST)
-	invalidConnection := &models.DBConnection{
-		ID:   "", // Missing required field
-		Type: models.DBTypePostgreSQL,
+func (preparingFactory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
+	runner, err := fakeFactory{}.Create(config, conn, logger)
+	if err != nil {
+		return nil, err
 	}
+	return &preparingRunner{fakeRunner: runner.(*fakeRunner), prepareTime: 200 * time.Millisecond}, nil
+}
 
-	body, _ = json.Marshal(invalidConnection)
-	req = httptest.NewRequest("POST", "/api/v1/connections", bytes.NewBuffer(body))
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-
-	// Test case 4: Invalid JSON (POST)
-	req = httptest.NewRequest("POST", "/api/v1/connections", bytes.NewBufferString("invalid json"))
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+func init() {
+	benchmark.RegisterFactory("fake", fakeFactory{})
+	benchmark.RegisterFactory("fake_prepare", preparingFactory{})
 }
 
-func TestHandleTestConnection(t *testing.T) {
+func setupTestServer(t *testing.T) (*Server, int64) {
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.POST("/api/v1/connections/test", gin.WrapF(server.handleTestConnection))
-
-	// Test case 1: Test valid connection
-	connection := &models.DBConnection{
-		ID:       "test-conn",
-		Name:     "Test Connection",
-		Type:     models.DBTypePostgreSQL,
-		Host:     "localhost",
-		Port:     5432,
-		Database: "test",
-		Username: "test",
-		Password: "test",
-	}
-
-	body, _ := json.Marshal(connection)
-	req := httptest.NewRequest("POST", "/api/v1/connections/test", bytes.NewBuffer(body))
-	w := httptest.NewRecorder()
+	statusPollInterval = 10 * time.Millisecond
 
-	router.ServeHTTP(w, req)
+	cfg := &config.Config{Port: 8080}
+	key := []byte("12345678901234567890123456789012") // 32 bytes encryption key
+	manager, err := database.NewManager(database.NewMemoryStorage(), key, zap.NewNop())
+	require.NoError(t, err)
 
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	// Test case 2: Invalid connection
-	invalidConnection := &models.DBConnection{
-		ID:   "", // Missing required field
-		Type: models.DBTypePostgreSQL,
+	conn := &models.DBConnection{
+		Name:        "test-db",
+		Type:        models.MySQL,
+		Host:        "localhost",
+		Port:        3306,
+		Username:    "test-user",
+		Password:    "test-pass",
+		Database:    "test-db",
+		MaxIdleConn: 1,
+		MaxOpenConn: 1,
 	}
+	require.NoError(t, manager.AddConnection(conn))
 
-	body, _ = json.Marshal(invalidConnection)
-	req = httptest.NewRequest("POST", "/api/v1/connections/test", bytes.NewBuffer(body))
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-
-	// Test case 3: Invalid JSON
-	req = httptest.NewRequest("POST", "/api/v1/connections/test", bytes.NewBufferString("invalid json"))
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	return NewServer(cfg, manager, zap.NewNop()), conn.ID
 }
 
-func TestHandleBenchmarkStartWithDatabaseError(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
-
-	// Test case: Invalid connection ID
-	invalidConfig := &models.BenchmarkConfig{
-		ConnectionID: "non-existent-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
-
-	body, err := json.Marshal(invalidConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
+func doRequest(t *testing.T, s *Server, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
 	}
-
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
+	req := httptest.NewRequest(method, path, &buf)
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, req)
+	return w
+}
 
-	router.ServeHTTP(w, req)
+func createBenchmark(t *testing.T, s *Server, connID int64, duration string) *models.Benchmark {
+	w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+		Name:         "fake run",
+		Type:         "fake",
+		ConnectionID: connID,
+		Duration:     duration,
+		Concurrency:  4,
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response["error"].(string), "connection not found")
+	var b models.Benchmark
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+	return &b
 }
 
-func TestHandleBenchmarkStartWithInvalidQuery(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
+func waitForStatus(t *testing.T, s *Server, id int64, want models.BenchmarkStatus) {
+	require.Eventually(t, func() bool {
+		w := doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d", id), nil)
+		var b models.Benchmark
+		return json.Unmarshal(w.Body.Bytes(), &b) == nil && b.Status == want
+	}, 5*time.Second, 10*time.Millisecond)
+}
 
-	// First add a valid connection
-	conn := &models.DBConnection{
-		ID:       1,
-		Name:     "test-conn",
-		Type:     models.MySQL,
-		Host:     "localhost",
-		Port:     3306,
-		Username: "test",
-		Password: "test",
-		Database: "test",
-	}
-	err := server.manager.AddConnection(conn)
-	if err != nil {
-		t.Fatalf("Failed to add connection: %v", err)
-	}
+func TestHandleBenchmarksCreate(t *testing.T) {
+	s, connID := setupTestServer(t)
 
-	// Test case: Invalid SQL query
-	invalidConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "INVALID SQL QUERY",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
+	t.Run("Valid", func(t *testing.T) {
+		b := createBenchmark(t, s, connID, "1s")
+		assert.Greater(t, b.ID, int64(0))
+		assert.Equal(t, "fake", b.Type)
+		assert.Equal(t, time.Second, b.Duration)
+		assert.Equal(t, models.BenchmarkStatusPending, b.Status)
+	})
 
-	body, err := json.Marshal(invalidConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
-	}
+	t.Run("DefaultsToQueryType", func(t *testing.T) {
+		w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+			ConnectionID: connID,
+			Duration:     "1s",
+			Concurrency:  1,
+			Queries:      []string{"SELECT 1"},
+			QueryRate:    100,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+		var b models.Benchmark
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		assert.Equal(t, models.BenchmarkTypeQuery, b.Type)
+		assert.Equal(t, "SELECT 1", b.QueryTemplate)
+		assert.JSONEq(t, `{"query_rate":100}`, string(b.Config))
+	})
 
-	router.ServeHTTP(w, req)
+	t.Run("MultipleQueries", func(t *testing.T) {
+		w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+			ConnectionID: connID,
+			Duration:     "1s",
+			Concurrency:  1,
+			Queries:      []string{"SELECT 1", "UPDATE t SET a = 1"},
+			Distribution: "weighted",
+			QueryWeights: []float64{9, 1},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
 
-	assert.Equal(t, http.StatusOK, w.Code)
+		var b models.Benchmark
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		assert.JSONEq(t, `{"queries":[{"sql":"SELECT 1"},{"sql":"UPDATE t SET a = 1"}],"distribution":"weighted","query_weights":[9,1]}`, string(b.Config))
+		assert.Empty(t, b.QueryTemplate)
+	})
 
-	// Check status after a short delay
-	time.Sleep(100 * time.Millisecond)
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/benchmark/status", nil)
-	w = httptest.NewRecorder()
-	router.GET("/api/v1/benchmark/status", gin.WrapF(server.handleBenchmarkStatus))
-	router.ServeHTTP(w, req)
+	t.Run("QueriesInConfig", func(t *testing.T) {
+		w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+			ConnectionID: connID,
+			Duration:     "1s",
+			Concurrency:  1,
+			Config:       json.RawMessage(`{"queries":[{"name":"one","sql":"SELECT 1"}]}`),
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	metrics := response["metrics"].(map[string]interface{})
-	assert.True(t, metrics["errors"].(float64) > 0)
-}
+		var b models.Benchmark
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		assert.Empty(t, b.QueryTemplate)
+	})
 
-func TestHandleBenchmarkStartWithConcurrentRequests(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	t.Run("Phases", func(t *testing.T) {
+		w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+			Type:         "fake",
+			ConnectionID: connID,
+			Duration:     "1m",
+			Concurrency:  8,
+			RampUp:       "20s",
+			RampSteps:    4,
+			Warmup:       "30s",
+			CoolDown:     "10s",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var b models.Benchmark
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		assert.Equal(t, models.PhaseConfig{
+			RampUp:    20 * time.Second,
+			RampSteps: 4,
+			Warmup:    30 * time.Second,
+			CoolDown:  10 * time.Second,
+		}, b.Phases)
+	})
 
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
+	t.Run("ErrorPolicy", func(t *testing.T) {
+		w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+			Type:         "fake",
+			ConnectionID: connID,
+			Duration:     "1m",
+			Concurrency:  8,
+			OnError:      models.ErrorPolicyMaxRate,
+			MaxErrorRate: 0.01,
+			Retries:      3,
+			RetryBackoff: "50ms",
+			RetryOn:      []string{"deadlock", "serialization_failure"},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var b models.Benchmark
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+		assert.Equal(t, models.ErrorPolicy{
+			OnError:      models.ErrorPolicyMaxRate,
+			MaxErrorRate: 0.01,
+			Retries:      3,
+			RetryBackoff: 50 * time.Millisecond,
+			RetryOn:      []string{"deadlock", "serialization_failure"},
+		}, b.ErrorPolicy)
+	})
 
-	// First add a valid connection
-	conn := &models.DBConnection{
-		ID:       1,
-		Name:     "test-conn",
-		Type:     models.MySQL,
-		Host:     "localhost",
-		Port:     3306,
-		Username: "test",
-		Password: "test",
-		Database: "test",
+	tests := []struct {
+		name string
+		req  BenchmarkRequest
+	}{
+		{"InvalidDuration", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "soon", Concurrency: 1}},
+		{"InvalidReportInterval", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", ReportInterval: "often", Concurrency: 1}},
+		{"NegativeReportInterval", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", ReportInterval: "-1s", Concurrency: 1}},
+		{"InvalidWarmup", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", Warmup: "soon", Concurrency: 1}},
+		{"NegativeCoolDown", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", CoolDown: "-1s", Concurrency: 1}},
+		{"NegativeRampSteps", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", RampUp: "1s", RampSteps: -1, Concurrency: 1}},
+		{"UnknownErrorPolicy", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", Concurrency: 1, OnError: "ignore"}},
+		{"MaxCountWithoutLimit", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", Concurrency: 1, OnError: models.ErrorPolicyMaxCount}},
+		{"UnknownRetryClass", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", Concurrency: 1, Retries: 1, RetryOn: []string{"timeout"}}},
+		{"InvalidRetryBackoff", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s", Concurrency: 1, Retries: 1, RetryBackoff: "later"}},
+		{"MissingQueries", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1}},
+		{"InvalidArrival", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1, Queries: []string{"SELECT 1"}, QueryRate: 10, Arrival: "bursty"}},
+		{"MissingWeights", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1, Queries: []string{"SELECT 1", "SELECT 2"}, Distribution: "weighted"}},
+		{"QueriesWithConfig", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1, Queries: []string{"SELECT 1", "SELECT 2"}, Config: json.RawMessage(`{"query_rate":10}`)}},
+		{"EmptyConfig", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1, Config: json.RawMessage(`{}`)}},
+		{"InvalidPlaceholder", BenchmarkRequest{ConnectionID: connID, Duration: "1s", Concurrency: 1, Queries: []string{"SELECT * FROM t WHERE id = {{uniform 1}}"}}},
+		{"UnknownType", BenchmarkRequest{Type: "unknown", ConnectionID: connID, Duration: "1s", Concurrency: 1}},
+		{"UnknownConnection", BenchmarkRequest{Type: "fake", ConnectionID: connID + 100, Duration: "1s", Concurrency: 1}},
+		{"NoThreads", BenchmarkRequest{Type: "fake", ConnectionID: connID, Duration: "1s"}},
 	}
-	err := server.manager.AddConnection(conn)
-	if err != nil {
-		t.Fatalf("Failed to add connection: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", tt.req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
 	}
 
-	// Valid config
-	validConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     60,
-		Threads:      10,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
+	t.Run("InvalidJSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmarks", bytes.NewBufferString("invalid json"))
+		w := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
 
-	body, err := json.Marshal(validConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
-	}
+func TestHandleBenchmarksListGetDelete(t *testing.T) {
+	s, connID := setupTestServer(t)
 
-	// Start first benchmark
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+	w := doRequest(t, s, http.MethodGet, "/api/v1/benchmarks", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
 
-	router.ServeHTTP(w, req)
+	b := createBenchmark(t, s, connID, "1s")
 
+	// The unversioned prefix used by the web client serves the same resource
+	w = doRequest(t, s, http.MethodGet, "/api/benchmarks", nil)
 	assert.Equal(t, http.StatusOK, w.Code)
+	var list []models.Benchmark
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, b.ID, list[0].ID)
 
-	// Try to start another benchmark while the first one is running
-	req = httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w = httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d", b.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
 
+	w = doRequest(t, s, http.MethodGet, "/api/v1/benchmarks/abc", nil)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response["error"].(string), "benchmark already running")
-}
-
-func TestHandleBenchmarkStopWithoutActive(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
 
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/stop", gin.WrapF(server.handleBenchmarkStop))
+	w = doRequest(t, s, http.MethodDelete, fmt.Sprintf("/api/v1/benchmarks/%d", b.ID), nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
 
-	// Test case: Stop without active benchmark
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/stop", nil)
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response["error"].(string), "no active benchmark")
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d", b.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestHandleBenchmarkStatusProgress(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
+func TestHandleBenchmarkLifecycle(t *testing.T) {
+	s, connID := setupTestServer(t)
+	b := createBenchmark(t, s, connID, "50ms")
+	base := fmt.Sprintf("/api/v1/benchmarks/%d", b.ID)
 
-	server := setupTestServer(t)
-	router.POST("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
-	router.GET("/api/v1/benchmark/status", gin.WrapF(server.handleBenchmarkStatus))
+	w := doRequest(t, s, http.MethodPost, base+"/start", nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
 
-	// First add a valid connection
-	conn := &models.DBConnection{
-		ID:       1,
-		Name:     "test-conn",
-		Type:     models.MySQL,
-		Host:     "localhost",
-		Port:     3306,
-		Username: "test",
-		Password: "test",
-		Database: "test",
-	}
-	err := server.manager.AddConnection(conn)
-	if err != nil {
-		t.Fatalf("Failed to add connection: %v", err)
-	}
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCompleted)
 
-	// Start a short benchmark
-	validConfig := &models.BenchmarkConfig{
-		ConnectionID: "test-conn",
-		Duration:     1,
-		Threads:      1,
-		Query:        "SELECT 1",
-		Name:         "Test Benchmark",
-		Description:  "Test benchmark description",
-	}
+	w = doRequest(t, s, http.MethodGet, base+"/status", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	body, err := json.Marshal(validConfig)
-	if err != nil {
-		t.Fatalf("Failed to marshal request body: %v", err)
-	}
+	w = doRequest(t, s, http.MethodGet, base+"/results", nil)
+	require.Equal(t, http.StatusOK, w.Code)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/benchmark/start", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, models.BenchmarkStatusCompleted, results[0].Status)
+	assert.Equal(t, int64(100), results[0].TotalQueries)
+	assert.Equal(t, float64(1000), results[0].QPS)
+	assert.Equal(t, time.Millisecond, results[0].AverageLatency)
 
-	router.ServeHTTP(w, req)
+	// Stopping a finished benchmark is an error
+	w = doRequest(t, s, http.MethodPost, base+"/stop", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
 
+func TestHandleBenchmarkStop(t *testing.T) {
+	s, connID := setupTestServer(t)
+	b := createBenchmark(t, s, connID, "1h")
+	other := createBenchmark(t, s, connID, "1h")
+	base := fmt.Sprintf("/api/v1/benchmarks/%d", b.ID)
+
+	w := doRequest(t, s, http.MethodPost, base+"/start", nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	// A benchmark runs once at a time, and the connection allows one run by default
+	w = doRequest(t, s, http.MethodPost, base+"/start", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start", other.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// A running benchmark cannot be deleted
+	w = doRequest(t, s, http.MethodDelete, base, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(t, s, http.MethodGet, base+"/status", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status benchmark.BenchmarkStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, string(models.BenchmarkStatusRunning), status.Status)
+
+	w = doRequest(t, s, http.MethodPost, base+"/stop", nil)
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	// Check progress multiple times
-	var lastProgress float64
-	for i := 0; i < 5; i++ {
-		time.Sleep(200 * time.Millisecond)
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCancelled)
+
+	w = doRequest(t, s, http.MethodGet, base+"/results", nil)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, models.BenchmarkStatusCancelled, results[0].Status)
+}
 
-		req = httptest.NewRequest(http.MethodGet, "/api/v1/benchmark/status", nil)
-		w = httptest.NewRecorder()
+func TestHandleBenchmarkStartErrors(t *testing.T) {
+	s, _ := setupTestServer(t)
 
-		router.ServeHTTP(w, req)
+	w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks/42/start", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 
-		assert.Equal(t, http.StatusOK, w.Code)
-		var response map[string]interface{}
-		err = json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
+	w = doRequest(t, s, http.MethodPost, "/api/v1/benchmarks/42/stop", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 
-		progress := response["progress"].(float64)
-		assert.True(t, progress >= lastProgress)
-		lastProgress = progress
-	}
+	w = doRequest(t, s, http.MethodGet, "/api/v1/benchmarks/42/results", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
 
-	// Wait for benchmark to complete
-	time.Sleep(1 * time.Second)
+func TestHandleBenchmarkStartPrepareUnsupported(t *testing.T) {
+	s, connID := setupTestServer(t)
+	b := createBenchmark(t, s, connID, "1h")
 
-	// Check final status
-	req = httptest.NewRequest(http.MethodGet, "/api/v1/benchmark/status", nil)
-	w = httptest.NewRecorder()
+	w := doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start?prepare=true", b.ID), nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
 
-	router.ServeHTTP(w, req)
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusFailed)
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(100), response["progress"].(float64))
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d/results", b.ID), nil)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Error, "prepare")
 }
 
-func TestHandleBenchmarkWithInvalidMethods(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-
-	server := setupTestServer(t)
-	router.Any("/api/v1/benchmark/start", gin.WrapF(server.handleBenchmarkStart))
-	router.Any("/api/v1/benchmark/stop", gin.WrapF(server.handleBenchmarkStop))
-	router.Any("/api/v1/benchmark/status", gin.WrapF(server.handleBenchmarkStatus))
+func TestHandleBenchmarkStartPrepareNotTimed(t *testing.T) {
+	s, connID := setupTestServer(t)
+	w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks", BenchmarkRequest{
+		Name:         "fake run",
+		Type:         "fake_prepare",
+		ConnectionID: connID,
+		Duration:     "20ms",
+		Concurrency:  1,
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var b models.Benchmark
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b))
+
+	w = doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start?prepare=true", b.ID), nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCompleted)
+
+	// The stored run starts after the 200ms prepare
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d/results", b.ID), nil)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Less(t, results[0].EndTime.Sub(results[0].StartTime), 200*time.Millisecond)
+}
 
-	tests := []struct {
-		name     string
-		method   string
-		path     string
-		wantCode int
-	}{
-		{"Start with GET", http.MethodGet, "/api/v1/benchmark/start", http.StatusMethodNotAllowed},
-		{"Start with PUT", http.MethodPut, "/api/v1/benchmark/start", http.StatusMethodNotAllowed},
-		{"Start with DELETE", http.MethodDelete, "/api/v1/benchmark/start", http.StatusMethodNotAllowed},
-		{"Stop with GET", http.MethodGet, "/api/v1/benchmark/stop", http.StatusMethodNotAllowed},
-		{"Stop with PUT", http.MethodPut, "/api/v1/benchmark/stop", http.StatusMethodNotAllowed},
-		{"Stop with DELETE", http.MethodDelete, "/api/v1/benchmark/stop", http.StatusMethodNotAllowed},
-		{"Status with POST", http.MethodPost, "/api/v1/benchmark/status", http.StatusMethodNotAllowed},
-		{"Status with PUT", http.MethodPut, "/api/v1/benchmark/status", http.StatusMethodNotAllowed},
-		{"Status with DELETE", http.MethodDelete, "/api/v1/benchmark/status", http.StatusMethodNotAllowed},
+func TestHandleBenchmarkSweep(t *testing.T) {
+	s, connID := setupTestServer(t)
+	b := createBenchmark(t, s, connID, "20ms")
+	base := fmt.Sprintf("/api/v1/benchmarks/%d", b.ID)
+
+	for name, body := range map[string]interface{}{
+		"NotJSON":        "threads",
+		"NoValues":       benchmark.SweepConfig{Parameter: benchmark.SweepThreads},
+		"BadParameter":   benchmark.SweepConfig{Parameter: "memory", Values: []int{1}},
+		"RateNotSupport": benchmark.SweepConfig{Parameter: benchmark.SweepRate, Values: []int{100}},
+	} {
+		w := doRequest(t, s, http.MethodPost, base+"/sweep", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
 	}
+	w := doRequest(t, s, http.MethodPost, "/api/v1/benchmarks/42/sweep", benchmark.SweepConfig{Parameter: benchmark.SweepThreads, Values: []int{1}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(t, s, http.MethodPost, base+"/sweep", benchmark.SweepConfig{Parameter: benchmark.SweepThreads, Values: []int{1, 2}})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var run RunInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCompleted)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/sweep", run.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sweep models.SweepReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sweep))
+	assert.Equal(t, "threads", sweep.Parameter)
+	require.Len(t, sweep.Points, 2)
+	assert.Equal(t, 2, sweep.Points[1].Value)
+	assert.Equal(t, float64(1000), sweep.Points[1].TPS)
+	assert.Equal(t, 0, sweep.Knee)
+
+	// The result of a sweep is its knee step, with the curve stored alongside
+	w = doRequest(t, s, http.MethodGet, base+"/results", nil)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, models.BenchmarkStatusCompleted, results[0].Status)
+	assert.Equal(t, float64(1000), results[0].QPS)
+	assert.Equal(t, float64(1), results[0].Metrics["sweep_value"])
+	require.NotNil(t, results[0].Sweep)
+	assert.Len(t, results[0].Sweep.Points, 2)
+
+	// Plain runs have no sweep
+	w = doRequest(t, s, http.MethodPost, base+"/start", nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/sweep", run.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(t, s, http.MethodGet, "/api/v1/runs/42/sweep", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCompleted)
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(tt.method, tt.path, nil)
-			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
-
-			assert.Equal(t, tt.wantCode, w.Code)
-			var response map[string]interface{}
-			err := json.Unmarshal(w.Body.Bytes(), &response)
-			assert.NoError(t, err)
-			assert.Contains(t, response["error"].(string), "method not allowed")
-		})
+func TestHandleBenchmarkSearch(t *testing.T) {
+	s, connID := setupTestServer(t)
+	b := createBenchmark(t, s, connID, "20ms")
+	base := fmt.Sprintf("/api/v1/benchmarks/%d", b.ID)
+
+	valid := SearchRequest{Parameter: "threads", Low: 1, High: 4, ProbeDuration: "10ms", Objectives: []string{"latency_avg <= 2ms"}}
+	for name, modify := range map[string]func(*SearchRequest){
+		"NoObjectives":  func(r *SearchRequest) { r.Objectives = nil },
+		"BadObjective":  func(r *SearchRequest) { r.Objectives = []string{"latency_avg"} },
+		"ProbeDuration": func(r *SearchRequest) { r.ProbeDuration = "soon" },
+		"NoRange":       func(r *SearchRequest) { r.High = 0 },
+		"RateNotSupport": func(r *SearchRequest) {
+			r.Parameter = "rate"
+		},
+	} {
+		req := valid
+		modify(&req)
+		w := doRequest(t, s, http.MethodPost, base+"/search", req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
 	}
+
+	w := doRequest(t, s, http.MethodPost, base+"/search", valid)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var run RunInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+
+	waitForStatus(t, s, b.ID, models.BenchmarkStatusCompleted)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/search", run.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var search models.SearchReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &search))
+	assert.Equal(t, []string{"latency_avg <= 2ms"}, search.Objectives)
+	assert.Equal(t, 4, search.Value)
+	assert.True(t, search.Confirmed)
+	require.Len(t, search.Probes, 3)
+	assert.True(t, search.Probes[2].Confirmation)
+
+	// The result of a search is its confirmation run, with the probes stored alongside
+	w = doRequest(t, s, http.MethodGet, base+"/results", nil)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, float64(4), results[0].Metrics["search_value"])
+	assert.Equal(t, true, results[0].Metrics["search_confirmed"])
+	require.NotNil(t, results[0].Search)
+	assert.Len(t, results[0].Search.Probes, 3)
+
+	w = doRequest(t, s, http.MethodGet, "/api/v1/runs/42/search", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
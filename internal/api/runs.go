@@ -0,0 +1,368 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// maxFinishedRuns is the number of finished runs kept in memory. Older
+// results remain available through the benchmark results endpoint.
+const maxFinishedRuns = 100
+
+var (
+	errBenchmarkRunning = errors.New("benchmark is already running")
+	errConnectionBusy   = errors.New("too many benchmarks are running on this connection")
+)
+
+// benchmarkRun tracks one execution of a benchmark
+type benchmarkRun struct {
+	id           int64
+	benchmarkID  int64
+	connectionID int64
+	runner       benchmark.BenchmarkRunner
+	cancel       context.CancelFunc
+	done         chan struct{}
+	startTime    time.Time
+
+	// Set by runRegistry.finish
+	endTime time.Time
+	final   benchmark.BenchmarkStatus
+	result  *models.BenchmarkResult
+}
+
+// RunInfo describes a benchmark run
+type RunInfo struct {
+	ID           int64                   `json:"id"`
+	BenchmarkID  int64                   `json:"benchmark_id"`
+	ConnectionID int64                   `json:"connection_id"`
+	Status       string                  `json:"status"`
+	Progress     float64                 `json:"progress"`
+	Metrics      map[string]interface{}  `json:"metrics"`
+	StartTime    time.Time               `json:"start_time"`
+	EndTime      *time.Time              `json:"end_time,omitempty"`
+	Result       *models.BenchmarkResult `json:"result,omitempty"`
+}
+
+// runRegistry tracks running and recently finished benchmark runs by run ID
+type runRegistry struct {
+	mu               sync.RWMutex
+	nextID           int64
+	runs             map[int64]*benchmarkRun
+	maxPerConnection int
+}
+
+// newRunRegistry creates a registry allowing maxPerConnection concurrent
+// runs against each connection. Values below 1 allow a single run.
+func newRunRegistry(maxPerConnection int) *runRegistry {
+	if maxPerConnection < 1 {
+		maxPerConnection = 1
+	}
+	return &runRegistry{
+		runs:             make(map[int64]*benchmarkRun),
+		maxPerConnection: maxPerConnection,
+	}
+}
+
+// add registers run and assigns its ID. It fails if the benchmark is already
+// running or its connection has reached the concurrent run limit.
+func (r *runRegistry) add(run *benchmarkRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := 0
+	for _, other := range r.runs {
+		if other.result != nil {
+			continue
+		}
+		if other.benchmarkID == run.benchmarkID {
+			return errBenchmarkRunning
+		}
+		if other.connectionID == run.connectionID {
+			active++
+		}
+	}
+	if active >= r.maxPerConnection {
+		return fmt.Errorf("%w (limit %d)", errConnectionBusy, r.maxPerConnection)
+	}
+
+	r.nextID++
+	run.id = r.nextID
+	r.runs[run.id] = run
+	return nil
+}
+
+// finish records the final status and stored result of run
+func (r *runRegistry) finish(run *benchmarkRun, final benchmark.BenchmarkStatus, result *models.BenchmarkResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run.endTime = time.Now()
+	run.final = final
+	run.result = result
+
+	var finished []*benchmarkRun
+	for _, other := range r.runs {
+		if other.result != nil {
+			finished = append(finished, other)
+		}
+	}
+	if len(finished) <= maxFinishedRuns {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].id < finished[j].id })
+	for _, old := range finished[:len(finished)-maxFinishedRuns] {
+		delete(r.runs, old.id)
+	}
+}
+
+// get returns the run with the given ID, if it is known
+func (r *runRegistry) get(id int64) *benchmarkRun {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.runs[id]
+}
+
+// activeFor returns the unfinished run of benchmark benchmarkID, if any
+func (r *runRegistry) activeFor(benchmarkID int64) *benchmarkRun {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, run := range r.runs {
+		if run.benchmarkID == benchmarkID && run.result == nil {
+			return run
+		}
+	}
+	return nil
+}
+
+// active returns all unfinished runs
+func (r *runRegistry) active() []*benchmarkRun {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var runs []*benchmarkRun
+	for _, run := range r.runs {
+		if run.result == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// list describes all known runs ordered by ID
+func (r *runRegistry) list() []RunInfo {
+	r.mu.RLock()
+	runs := make([]*benchmarkRun, 0, len(r.runs))
+	for _, run := range r.runs {
+		runs = append(runs, run)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].id < runs[j].id })
+	infos := make([]RunInfo, 0, len(runs))
+	for _, run := range runs {
+		infos = append(infos, r.info(run))
+	}
+	return infos
+}
+
+// info describes run, asking the runner for its live status while it is active
+func (r *runRegistry) info(run *benchmarkRun) RunInfo {
+	r.mu.RLock()
+	info := RunInfo{
+		ID:           run.id,
+		BenchmarkID:  run.benchmarkID,
+		ConnectionID: run.connectionID,
+		StartTime:    run.startTime,
+		Result:       run.result,
+	}
+	status := run.final
+	finished := run.result != nil
+	if finished {
+		endTime := run.endTime
+		info.EndTime = &endTime
+	}
+	r.mu.RUnlock()
+
+	if !finished {
+		status = run.runner.Status()
+		if models.BenchmarkStatus(status.Status) == models.BenchmarkStatusPending {
+			// The runner is still being prepared or started
+			status.Status = string(models.BenchmarkStatusRunning)
+		}
+	}
+
+	info.Status = status.Status
+	info.Progress = status.Progress
+	info.Metrics = status.Metrics
+	if info.Metrics == nil {
+		info.Metrics = map[string]interface{}{}
+	}
+	return info
+}
+
+// intervals returns the interval reports of run: the stored ones once it
+// finished, the runner's so far otherwise
+func (r *runRegistry) intervals(run *benchmarkRun) []models.IntervalReport {
+	r.mu.RLock()
+	result := run.result
+	r.mu.RUnlock()
+
+	if result != nil {
+		return result.Intervals
+	}
+	return benchmark.IntervalsOf(run.runner)
+}
+
+// sweep returns the sweep curve of run: the stored one once it finished, the
+// runner's so far otherwise. It is nil if run is not a sweep.
+func (r *runRegistry) sweep(run *benchmarkRun) *models.SweepReport {
+	r.mu.RLock()
+	result := run.result
+	r.mu.RUnlock()
+
+	if result != nil {
+		return result.Sweep
+	}
+	return benchmark.SweepOf(run.runner)
+}
+
+// search returns the probes of a search run: the stored ones once it
+// finished, the runner's so far otherwise. It is nil if run is not a search.
+func (r *runRegistry) search(run *benchmarkRun) *models.SearchReport {
+	r.mu.RLock()
+	result := run.result
+	r.mu.RUnlock()
+
+	if result != nil {
+		return result.Search
+	}
+	return benchmark.SearchOf(run.runner)
+}
+
+// handleRuns handles listing benchmark runs
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runs.list())
+}
+
+// handleRun handles getting the status, and once finished the result, of a run
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	run := s.runs.get(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runs.info(run))
+}
+
+// handleRunStop handles stopping a run, waiting until its result is stored
+func (s *Server) handleRunStop(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	run := s.runs.get(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run not found"})
+		return
+	}
+
+	run.cancel()
+	<-run.done
+	writeJSON(w, http.StatusOK, s.runs.info(run))
+}
+
+// handleRunIntervals handles getting the interval reports of a run, live
+// while it is running. The since query parameter skips that many reports,
+// so clients can poll for the new ones only.
+func (s *Server) handleRunIntervals(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since := 0
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid since"})
+			return
+		}
+		since = n
+	}
+
+	run := s.runs.get(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run not found"})
+		return
+	}
+
+	intervals := s.runs.intervals(run)
+	if since > len(intervals) {
+		since = len(intervals)
+	}
+	writeJSON(w, http.StatusOK, append([]models.IntervalReport{}, intervals[since:]...))
+}
+
+// handleRunSweep handles getting the throughput-vs-latency curve of a sweep,
+// live while it is running
+func (s *Server) handleRunSweep(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	run := s.runs.get(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run not found"})
+		return
+	}
+
+	sweep := s.runs.sweep(run)
+	if sweep == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run is not a sweep"})
+		return
+	}
+	writeJSON(w, http.StatusOK, sweep)
+}
+
+// handleRunSearch handles getting the probes and answer of a search, live
+// while it is running
+func (s *Server) handleRunSearch(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	run := s.runs.get(id)
+	if run == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run not found"})
+		return
+	}
+
+	search := s.runs.search(run)
+	if search == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Run is not a search"})
+		return
+	}
+	writeJSON(w, http.StatusOK, search)
+}
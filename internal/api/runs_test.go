@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRegistry(t *testing.T) {
+	newRun := func(benchmarkID, connectionID int64) *benchmarkRun {
+		return &benchmarkRun{
+			benchmarkID:  benchmarkID,
+			connectionID: connectionID,
+			runner:       &fakeRunner{},
+			done:         make(chan struct{}),
+		}
+	}
+
+	r := newRunRegistry(2)
+	first := newRun(1, 1)
+	require.NoError(t, r.add(first))
+	assert.Equal(t, int64(1), first.id)
+
+	assert.ErrorIs(t, r.add(newRun(1, 1)), errBenchmarkRunning)
+	require.NoError(t, r.add(newRun(2, 1)))
+	assert.ErrorIs(t, r.add(newRun(3, 1)), errConnectionBusy)
+	require.NoError(t, r.add(newRun(3, 2)))
+
+	assert.Same(t, first, r.activeFor(1))
+	assert.Len(t, r.active(), 3)
+
+	result := &models.BenchmarkResult{Status: models.BenchmarkStatusCompleted}
+	r.finish(first, benchmark.BenchmarkStatus{Status: string(models.BenchmarkStatusCompleted)}, result)
+	assert.Nil(t, r.activeFor(1))
+	assert.Same(t, first, r.get(first.id))
+
+	info := r.info(first)
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), info.Status)
+	assert.Same(t, result, info.Result)
+	assert.NotNil(t, info.EndTime)
+
+	// A finished run frees its slot on the connection
+	require.NoError(t, r.add(newRun(4, 1)))
+	assert.Len(t, r.list(), 4)
+}
+
+func TestRunRegistryPrunesFinishedRuns(t *testing.T) {
+	r := newRunRegistry(1)
+	for i := int64(1); i <= maxFinishedRuns+5; i++ {
+		run := &benchmarkRun{benchmarkID: i, connectionID: 1, runner: &fakeRunner{}}
+		require.NoError(t, r.add(run))
+		r.finish(run, benchmark.BenchmarkStatus{}, &models.BenchmarkResult{})
+	}
+
+	assert.Len(t, r.list(), maxFinishedRuns)
+	assert.Nil(t, r.get(1))
+	assert.NotNil(t, r.get(maxFinishedRuns+5))
+}
+
+func TestHandleRuns(t *testing.T) {
+	s, connID := setupTestServer(t)
+	s.runs = newRunRegistry(2)
+
+	short := createBenchmark(t, s, connID, "50ms")
+	long := createBenchmark(t, s, connID, "1h")
+	third := createBenchmark(t, s, connID, "1h")
+
+	start := func(id int64) RunInfo {
+		w := doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start", id), nil)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var info RunInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		return info
+	}
+
+	shortRun := start(short.ID)
+	longRun := start(long.ID)
+	assert.NotEqual(t, shortRun.ID, longRun.ID)
+	assert.Equal(t, string(models.BenchmarkStatusRunning), longRun.Status)
+
+	// Both slots of the connection are taken
+	w := doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start", third.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	require.Eventually(t, func() bool {
+		w := doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d", shortRun.ID), nil)
+		var info RunInfo
+		return json.Unmarshal(w.Body.Bytes(), &info) == nil && info.Result != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d", shortRun.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var info RunInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), info.Status)
+	assert.Equal(t, int64(100), info.Result.TotalQueries)
+	assert.NotNil(t, info.EndTime)
+
+	// The finished run freed a slot
+	thirdRun := start(third.ID)
+
+	w = doRequest(t, s, http.MethodGet, "/api/v1/runs", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var runs []RunInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	require.Len(t, runs, 3)
+	assert.Equal(t, shortRun.ID, runs[0].ID)
+
+	for _, id := range []int64{longRun.ID, thirdRun.ID} {
+		w = doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/runs/%d/stop", id), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		assert.Equal(t, string(models.BenchmarkStatusCancelled), info.Status)
+	}
+
+	w = doRequest(t, s, http.MethodGet, "/api/v1/runs/42", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(t, s, http.MethodPost, "/api/v1/runs/42/stop", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleRunIntervals(t *testing.T) {
+	s, connID := setupTestServer(t)
+
+	b := createBenchmark(t, s, connID, "50ms")
+	w := doRequest(t, s, http.MethodPost, fmt.Sprintf("/api/v1/benchmarks/%d/start", b.ID), nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var run RunInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+
+	require.Eventually(t, func() bool {
+		w := doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d", run.ID), nil)
+		var info RunInfo
+		return json.Unmarshal(w.Body.Bytes(), &info) == nil && info.Result != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/intervals", run.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var intervals []models.IntervalReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &intervals))
+	require.Len(t, intervals, 1)
+	assert.Equal(t, int64(100), intervals[0].Transactions)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/intervals?since=1", run.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	// The intervals, phases and breakdown are stored with the result
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/benchmarks/%d/results", b.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var results []models.BenchmarkResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Intervals, 1)
+	assert.Len(t, results[0].Phases, 1)
+	assert.Equal(t, int64(100), results[0].Breakdown["query"].Count)
+
+	w = doRequest(t, s, http.MethodGet, fmt.Sprintf("/api/v1/runs/%d/intervals?since=-1", run.ID), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(t, s, http.MethodGet, "/api/v1/runs/42/intervals", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
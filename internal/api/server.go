@@ -4,9 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sync"
 
-	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/config"
 	"github.com/deadjoe/benchphant/internal/database"
 	"github.com/deadjoe/benchphant/internal/models"
@@ -16,12 +14,11 @@ import (
 
 // Server represents the API server
 type Server struct {
-	cfg             *config.Config
-	manager         *database.Manager
-	logger          *zap.Logger
-	mu              sync.RWMutex
-	activeBenchmark *benchmark.Benchmark
-	server          *http.Server
+	cfg     *config.Config
+	manager *database.Manager
+	logger  *zap.Logger
+	runs    *runRegistry
+	server  *http.Server
 }
 
 // NewServer creates a new API server
@@ -30,6 +27,7 @@ func NewServer(cfg *config.Config, manager *database.Manager, logger *zap.Logger
 		cfg:     cfg,
 		manager: manager,
 		logger:  logger,
+		runs:    newRunRegistry(cfg.MaxRunsPerConnection),
 	}
 
 	s.server = &http.Server{
@@ -47,8 +45,16 @@ func (s *Server) Start() error {
 	return s.server.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server, stopping all running benchmarks
 func (s *Server) Shutdown() error {
+	runs := s.runs.active()
+	for _, run := range runs {
+		run.cancel()
+	}
+	for _, run := range runs {
+		<-run.done
+	}
+
 	return s.server.Close()
 }
 
@@ -62,14 +68,45 @@ func (s *Server) registerRoutes() {
 		v1.GET("/connections", gin.WrapF(s.handleConnections))
 		v1.POST("/connections", gin.WrapF(s.handleConnections))
 		v1.POST("/connections/test", gin.WrapF(s.handleTestConnection))
+		s.registerBenchmarkRoutes(v1)
 	}
 
-	// Static files
-	router.Static("/", "web/dist")
+	// The web client addresses benchmarks without the version prefix
+	s.registerBenchmarkRoutes(router.Group("/api"))
+
+	// Static files. A catch-all route would conflict with the /api prefix,
+	// so the web UI is served for every path no API route matched.
+	webDir := s.cfg.WebDir
+	if webDir == "" {
+		webDir = "web/dist"
+	}
+	router.NoRoute(gin.WrapH(http.FileServer(http.Dir(webDir))))
 
 	s.server.Handler = router
 }
 
+// registerBenchmarkRoutes registers the benchmark, run and workload routes on group
+func (s *Server) registerBenchmarkRoutes(group *gin.RouterGroup) {
+	group.GET("/benchmarks", gin.WrapF(s.handleBenchmarks))
+	group.POST("/benchmarks", gin.WrapF(s.handleBenchmarks))
+	group.GET("/benchmarks/:id", withID(s.handleBenchmark))
+	group.DELETE("/benchmarks/:id", withID(s.handleBenchmark))
+	group.POST("/benchmarks/:id/start", withID(s.handleBenchmarkStart))
+	group.POST("/benchmarks/:id/stop", withID(s.handleBenchmarkStop))
+	group.POST("/benchmarks/:id/sweep", withID(s.handleBenchmarkSweep))
+	group.POST("/benchmarks/:id/search", withID(s.handleBenchmarkSearch))
+	group.GET("/benchmarks/:id/status", withID(s.handleBenchmarkStatus))
+	group.GET("/benchmarks/:id/results", withID(s.handleBenchmarkResults))
+	group.GET("/runs", gin.WrapF(s.handleRuns))
+	group.GET("/runs/:id", withID(s.handleRun))
+	group.POST("/runs/:id/stop", withID(s.handleRunStop))
+	group.GET("/runs/:id/intervals", withID(s.handleRunIntervals))
+	group.GET("/runs/:id/sweep", withID(s.handleRunSweep))
+	group.GET("/runs/:id/search", withID(s.handleRunSearch))
+	group.GET("/workloads", gin.WrapF(s.handleWorkloads))
+	group.GET("/workloads/:name", withName(s.handleWorkload))
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
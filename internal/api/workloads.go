@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/gin-gonic/gin"
+)
+
+// handleWorkloads handles listing the registered workloads
+func (s *Server) handleWorkloads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, benchmark.ListFactories())
+}
+
+// withName adapts a handler taking the :name path parameter to gin
+func withName(h func(w http.ResponseWriter, r *http.Request, name string)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h(c.Writer, c.Request, c.Param("name"))
+	}
+}
+
+// handleWorkload handles describing a single workload
+func (s *Server) handleWorkload(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	info, err := benchmark.DescribeFactory(name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Workload not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleWorkloads(t *testing.T) {
+	s, _ := setupTestServer(t)
+
+	w := doRequest(t, s, http.MethodGet, "/api/v1/workloads", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var infos []benchmark.FactoryInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	assert.Contains(t, names, "fake")
+	assert.Contains(t, names, "query")
+
+	w = doRequest(t, s, http.MethodGet, "/api/workloads/fake", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var info benchmark.FactoryInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, "fake", info.Name)
+	assert.JSONEq(t, `{"type":"object"}`, string(info.ConfigSchema))
+
+	w = doRequest(t, s, http.MethodGet, "/api/v1/workloads/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
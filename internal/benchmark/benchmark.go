@@ -3,11 +3,14 @@ package benchmark
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/deadjoe/benchphant/internal/metrics"
 	"github.com/deadjoe/benchphant/internal/models"
 	"go.uber.org/zap"
 )
@@ -19,16 +22,52 @@ type BenchmarkStatus struct {
 	Metrics  map[string]interface{} `json:"metrics"`
 }
 
+// Copy returns a copy of the status whose metrics, including a breakdown
+// among them, do not share maps with s. Runners return copies from Status
+// so that callers can read them while the run goes on.
+func (s BenchmarkStatus) Copy() BenchmarkStatus {
+	if s.Metrics == nil {
+		return s
+	}
+	metrics := make(map[string]interface{}, len(s.Metrics))
+	for k, v := range s.Metrics {
+		if breakdown, ok := v.(map[string]QueryStats); ok {
+			copied := make(map[string]QueryStats, len(breakdown))
+			for name, stats := range breakdown {
+				copied[name] = stats
+			}
+			v = copied
+		}
+		metrics[k] = v
+	}
+	s.Metrics = metrics
+	return s
+}
+
 // BenchmarkRunner represents a database benchmark
 type BenchmarkRunner interface {
 	// Start starts the benchmark
 	Start() error
 	// Stop stops the benchmark
 	Stop()
-	// Status returns the current benchmark status
+	// Status returns a copy of the current benchmark status, which the
+	// caller may read and change while the benchmark runs
 	Status() BenchmarkStatus
 }
 
+// Preparer is implemented by runners that need to create schema or load
+// data before they can be started
+type Preparer interface {
+	// Prepare creates the tables and data the workload runs against
+	Prepare(ctx context.Context) error
+}
+
+// Cleaner is implemented by runners that can remove the data created by Prepare
+type Cleaner interface {
+	// Cleanup drops the tables created by Prepare
+	Cleanup(ctx context.Context) error
+}
+
 // Benchmark represents a database benchmark implementation
 type Benchmark struct {
 	config     *models.Benchmark
@@ -36,6 +75,17 @@ type Benchmark struct {
 	db         *sql.DB
 	logger     *zap.Logger
 	status     BenchmarkStatus
+	latencies  *metrics.Histogram
+	intervals  *IntervalRecorder
+	phases     *PhaseRecorder
+	limiter    *RateLimiter
+	queries    []*runnableQuery
+	breakdown  *Breakdown
+	txCount    int64 // measured transactions, guarded by mu
+	stmtCount  int64 // statements run by the measured transactions, guarded by mu
+	rowsRead   int64 // rows read by the measured queries, guarded by mu
+	bytesRead  int64 // bytes of the rows read by the measured queries, guarded by mu
+	errors     *ErrorTracker
 	startTime  time.Time
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
@@ -46,17 +96,17 @@ type Benchmark struct {
 
 // NewBenchmark creates a new benchmark
 func NewBenchmark(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) *Benchmark {
-	metrics := make(map[string]interface{})
-	metrics["queries"] = float64(0)
-	metrics["errors"] = float64(0)
-	metrics["latency_sum"] = float64(0)
-	metrics["latency_min"] = float64(0)
-	metrics["latency_max"] = float64(0)
-	metrics["latency_avg"] = float64(0)
-	metrics["latency_p95"] = float64(0)
-	metrics["latency_p99"] = float64(0)
-	metrics["qps"] = float64(0)
-	metrics["latencies"] = make([]float64, 0, 1000)
+	initial := metrics.LatencySummary{}.Metrics()
+	initial["queries"] = float64(0)
+	initial["errors"] = float64(0)
+	initial["total_transactions"] = int64(0)
+	initial["tps"] = float64(0)
+	initial["qps"] = float64(0)
+
+	interval := config.ReportInterval
+	if interval == 0 {
+		interval = DefaultReportInterval
+	}
 
 	return &Benchmark{
 		config:     config,
@@ -64,9 +114,13 @@ func NewBenchmark(config *models.Benchmark, conn *models.DBConnection, logger *z
 		db:         conn.DB,
 		logger:     logger,
 		done:       make(chan struct{}),
+		latencies:  metrics.NewHistogram(),
+		breakdown:  NewBreakdown(),
+		intervals:  NewIntervalRecorder(interval),
+		phases:     NewPhaseRecorder(config.Phases, config.Duration),
 		status: BenchmarkStatus{
 			Status:  string(models.BenchmarkStatusPending),
-			Metrics: metrics,
+			Metrics: initial,
 		},
 	}
 }
@@ -83,9 +137,43 @@ func (b *Benchmark) Start() error {
 	if b.config.Duration <= 0 {
 		return fmt.Errorf("duration must be greater than 0")
 	}
-	if b.config.QueryTemplate == "" {
+	if err := b.config.Phases.Validate(); err != nil {
+		return err
+	}
+	queryConfig, err := ParseQueryConfig(b.config.Config)
+	if err != nil {
+		return err
+	}
+	if b.config.QueryTemplate == "" && len(queryConfig.Queries) == 0 {
 		return fmt.Errorf("query template cannot be empty")
 	}
+	tracker, err := NewErrorTracker(b.config.ErrorPolicy, models.ErrorPolicyAbort)
+	if err != nil {
+		return err
+	}
+	scan, err := ParseScanMode(queryConfig.Scan)
+	if err != nil {
+		return err
+	}
+	queries, err := parseQueries(workloadQueries(b.config, queryConfig), numberedPlaceholders(b.connection), scan)
+	if err != nil {
+		return err
+	}
+
+	// Every worker picks queries and draws values from a source of its own
+	executors := make([]*QueryExecutor, b.config.NumThreads)
+	args := make([][][]func() []interface{}, b.config.NumThreads)
+	seed := time.Now().UnixNano()
+	for i := range executors {
+		r := rand.New(rand.NewSource(seed + int64(i)))
+		if executors[i], err = queryConfig.executor(queries, r); err != nil {
+			return err
+		}
+		args[i] = make([][]func() []interface{}, len(queries))
+		for j, q := range queries {
+			args[i][j] = q.args(r)
+		}
+	}
 
 	// Check if already running
 	if b.status.Status == string(models.BenchmarkStatusRunning) {
@@ -93,12 +181,25 @@ func (b *Benchmark) Start() error {
 	}
 
 	// Reset metrics
-	b.status.Metrics = map[string]interface{}{
-		"qps":         float64(0),
-		"latency_avg": float64(0),
-		"latency_p95": float64(0),
-		"latency_p99": float64(0),
-		"errors":      float64(0),
+	b.errors = tracker
+	b.latencies.Reset()
+	b.breakdown.Reset()
+	b.txCount, b.stmtCount = 0, 0
+	b.rowsRead, b.bytesRead = 0, 0
+	b.status.Metrics = metrics.LatencySummary{}.Metrics()
+	b.status.Metrics["total_transactions"] = int64(0)
+	b.status.Metrics["tps"] = float64(0)
+	b.status.Metrics["qps"] = float64(0)
+	b.status.Metrics["errors"] = float64(0)
+
+	// With a query rate the load is open-loop, on a schedule of its own
+	b.limiter = nil
+	if queryConfig.QueryRate > 0 {
+		b.limiter, err = NewRateLimiter(float64(queryConfig.QueryRate), Arrival(queryConfig.Arrival))
+		if err != nil {
+			return err
+		}
+		b.status.Metrics["target_qps"] = b.limiter.Rate()
 	}
 
 	// Initialize benchmark
@@ -110,31 +211,35 @@ func (b *Benchmark) Start() error {
 	// Reset done channel
 	b.done = make(chan struct{})
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), b.config.Duration)
+	// Create context with timeout covering all phases of the run
+	b.phases = NewPhaseRecorder(b.config.Phases, b.config.Duration)
+	ctx, cancel := context.WithTimeout(context.Background(), b.phases.Total())
 	b.ctx = ctx
 	b.cancel = cancel
 
-	// Prepare statement
-	stmt, err := b.db.PrepareContext(b.ctx, b.config.QueryTemplate)
-	if err != nil {
+	// Prepare statements
+	if err := prepareQueries(b.ctx, b.db, queries); err != nil {
 		b.status.Status = string(models.BenchmarkStatusFailed)
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return err
 	}
+	b.queries = queries
 
 	// Start benchmark
 	b.startTime = time.Now()
 	b.status.Status = string(models.BenchmarkStatusRunning)
 	b.status.Progress = 0
+	b.intervals.Start()
+	b.phases.Start()
+	b.phases.Watch(ctx.Done(), b.intervals.SetPhase)
 
 	// Start workers
 	b.wg.Add(b.config.NumThreads)
 	for i := 0; i < b.config.NumThreads; i++ {
-		go b.worker(b.ctx, stmt, i)
+		go b.worker(b.ctx, executors[i], args[i], i)
 	}
 
 	// Start progress updater
-	go b.updateProgress(b.ctx, stmt)
+	go b.updateProgress(b.ctx)
 
 	return nil
 }
@@ -148,53 +253,98 @@ func (b *Benchmark) Stop() {
 	b.mu.Unlock()
 }
 
-// Status returns the current benchmark status
+// Status returns a copy of the current benchmark status
 func (b *Benchmark) Status() BenchmarkStatus {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return b.status
+	return b.status.Copy()
 }
 
-// worker runs queries in a loop
-func (b *Benchmark) worker(ctx context.Context, stmt *sql.Stmt, id int) {
+// Intervals returns the reports of the finished intervals of the run
+func (b *Benchmark) Intervals() []models.IntervalReport {
+	return b.intervals.Intervals()
+}
+
+// Phases returns the reports of the phases of the run started so far
+func (b *Benchmark) Phases() []models.PhaseReport {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.phases.Phases()
+}
+
+// worker runs queries in a loop, back to back or when the rate limiter
+// schedules them, once its turn in the ramp-up has come. The executor picks
+// the query to run next and args draws the values of the placeholders of
+// each statement of each query.
+func (b *Benchmark) worker(ctx context.Context, executor *QueryExecutor, args [][]func() []interface{}, id int) {
+	defer b.wg.Done()
+	if !b.phases.WaitForThread(ctx, nil, id, b.config.NumThreads) {
+		return
+	}
+
 	b.logger.Debug("worker started", zap.Int("worker_id", id))
-	defer func() {
-		b.logger.Debug("worker stopped", zap.Int("worker_id", id))
-		b.wg.Done()
-	}()
+	defer b.logger.Debug("worker stopped", zap.Int("worker_id", id))
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if err := b.runQuery(ctx, stmt); err != nil {
-				if err == context.Canceled || err == context.DeadlineExceeded {
+			start := time.Now()
+			if b.limiter != nil {
+				var ok bool
+				if start, ok = b.limiter.Wait(ctx, nil); !ok {
 					return
 				}
-				b.logger.Error("query failed", zap.Error(err), zap.Int("worker", id))
+			}
+
+			i := executor.Next()
+			q := b.queries[i]
+			values := make([][]interface{}, len(args[i]))
+			for j, draw := range args[i] {
+				values[j] = draw()
+			}
+
+			if err := b.runQuery(ctx, q, values, start); err != nil {
+				// Queries cut short by the end of the run are not errors
+				if ctx.Err() != nil {
+					return
+				}
+				b.logger.Error("query failed", zap.Error(err), zap.String("query", q.Name), zap.Int("worker", id))
+				b.intervals.RecordError(err)
+				measured := b.phases.RecordError(start)
+				abort := b.errors.Failed(err)
+				if measured {
+					b.breakdown.RecordError(q.Name)
+				}
 				b.mu.Lock()
-				b.status.Metrics["errors"] = b.status.Metrics["errors"].(float64) + 1
-				b.status.Status = string(models.BenchmarkStatusFailed)
+				if measured {
+					b.status.Metrics["errors"] = b.status.Metrics["errors"].(float64) + 1
+				}
+				if abort {
+					b.status.Status = string(models.BenchmarkStatusFailed)
+				}
 				b.mu.Unlock()
-				b.cancel() // Cancel other workers when one fails
-				return
+				if abort {
+					b.cancel() // Cancel the other workers once the error policy fails the run
+					return
+				}
 			}
-			// Update metrics for successful query
-			b.mu.Lock()
-			b.status.Metrics["qps"] = b.status.Metrics["qps"].(float64) + 1
-			b.mu.Unlock()
-
-			// Sleep a tiny bit to avoid overwhelming the mock
-			time.Sleep(time.Millisecond)
 		}
 	}
 }
 
-// runQuery executes a single query and updates metrics
-func (b *Benchmark) runQuery(ctx context.Context, stmt *sql.Stmt) error {
-	start := time.Now()
-	_, err := stmt.ExecContext(ctx)
+// runQuery executes a query once with args, the arguments of each of its
+// statements, retrying it as the error policy allows, and updates metrics.
+// Its latency is measured from start, the time it was scheduled to run. Only
+// queries started during the measurement count in the status metrics.
+func (b *Benchmark) runQuery(ctx context.Context, q *runnableQuery, args [][]interface{}, start time.Time) error {
+	var result queryResult
+	err := b.errors.Retry(ctx, nil, func() error {
+		var err error
+		result, err = q.run(ctx, b.db, args)
+		return err
+	})
 	duration := time.Since(start)
 
 	if err != nil {
@@ -204,24 +354,63 @@ func (b *Benchmark) runQuery(ctx context.Context, stmt *sql.Stmt) error {
 		return fmt.Errorf("query execution failed: %w", err)
 	}
 
-	// Update latency metrics
+	b.errors.Succeeded()
+	b.intervals.RecordTransaction(duration)
+	b.intervals.RecordQueries(int64(len(q.statements)))
+	if !b.phases.RecordTransaction(start, duration) {
+		return nil
+	}
+
+	// The latency metrics are updated from the histograms by updateProgress
+	b.latencies.Record(duration)
+	b.breakdown.Record(q.Name, duration, result.rows())
 	b.mu.Lock()
-	latencyAvg := b.status.Metrics["latency_avg"].(float64)
-	qps := b.status.Metrics["qps"].(float64)
-	b.status.Metrics["latency_avg"] = (latencyAvg*qps + duration.Seconds()) / (qps + 1)
-	b.status.Metrics["latency_p95"] = duration.Seconds() // Simplified for now
-	b.status.Metrics["latency_p99"] = duration.Seconds() // Simplified for now
+	b.txCount++
+	b.stmtCount += int64(len(q.statements))
+	b.rowsRead += result.rowsRead
+	b.bytesRead += result.bytesRead
 	b.mu.Unlock()
 
 	return nil
 }
 
+// updateMetrics copies the latency summary, the error counts, the measured
+// transactions and the rows read into the status metrics. Rates are over the
+// measurement so far. The caller must hold b.mu.
+func (b *Benchmark) updateMetrics() {
+	for k, v := range b.latencies.Summary().Metrics() {
+		b.status.Metrics[k] = v
+	}
+	for k, v := range b.errors.Metrics() {
+		b.status.Metrics[k] = v
+	}
+	b.status.Metrics["breakdown"] = b.breakdown.Stats()
+
+	b.status.Metrics["total_transactions"] = b.txCount
+	b.status.Metrics["rows_read"] = b.rowsRead
+	b.status.Metrics["bytes_read"] = b.bytesRead
+	var tps, qps, rowsPerSec, bytesPerSec float64
+	if measured := b.phases.Measured(time.Now()).Seconds(); measured > 0 {
+		tps = float64(b.txCount) / measured
+		qps = float64(b.stmtCount) / measured
+		rowsPerSec = float64(b.rowsRead) / measured
+		bytesPerSec = float64(b.bytesRead) / measured
+	}
+	b.status.Metrics["tps"] = tps
+	b.status.Metrics["qps"] = qps
+	b.status.Metrics["rows_read_per_sec"] = rowsPerSec
+	b.status.Metrics["bytes_read_per_sec"] = bytesPerSec
+}
+
 // updateProgress updates the benchmark progress
-func (b *Benchmark) updateProgress(ctx context.Context, stmt *sql.Stmt) {
+func (b *Benchmark) updateProgress(ctx context.Context) {
 	defer func() {
-		stmt.Close()
 		b.wg.Wait() // Wait for all workers to finish before updating final status
+		closeQueries(b.queries)
+		b.intervals.Stop()
 		b.mu.Lock()
+		b.updateMetrics()
+		delete(b.status.Metrics, "phase")
 		if b.status.Status != string(models.BenchmarkStatusFailed) {
 			if ctx.Err() == context.Canceled {
 				b.status.Status = string(models.BenchmarkStatusCancelled)
@@ -243,9 +432,10 @@ func (b *Benchmark) updateProgress(ctx context.Context, stmt *sql.Stmt) {
 			return
 		case <-ticker.C:
 			b.mu.Lock()
-			elapsed := time.Since(b.startTime)
-			progress := (elapsed.Seconds() / b.config.Duration.Seconds()) * 100
-			b.status.Progress = math.Min(100, progress)
+			now := time.Now()
+			b.status.Progress = b.phases.Progress(now)
+			b.status.Metrics["phase"] = b.phases.PhaseAt(now)
+			b.updateMetrics()
 			b.mu.Unlock()
 		}
 	}
@@ -259,24 +449,149 @@ type Factory interface {
 	Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (BenchmarkRunner, error)
 }
 
-var factories = make(map[string]Factory)
+// Describer is implemented by factories that can explain their workload
+type Describer interface {
+	// Description returns a short human readable summary of the workload
+	Description() string
+}
+
+// ConfigSchemer is implemented by factories that accept workload specific
+// configuration in models.Benchmark.Config
+type ConfigSchemer interface {
+	// ConfigSchema returns the JSON Schema of the workload config
+	ConfigSchema() json.RawMessage
+}
+
+// FactoryInfo describes a registered factory
+type FactoryInfo struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	ConfigSchema json.RawMessage `json:"config_schema"`
+}
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
 
 // RegisterFactory registers a benchmark factory
 func RegisterFactory(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
 	factories[name] = factory
 }
 
+// GetFactory returns the factory registered under name
+func GetFactory(name string) (Factory, error) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown benchmark type: %s", name)
+	}
+	return factory, nil
+}
+
+// DescribeFactory returns the description of the factory registered under name
+func DescribeFactory(name string) (FactoryInfo, error) {
+	factory, err := GetFactory(name)
+	if err != nil {
+		return FactoryInfo{}, err
+	}
+	return describe(name, factory), nil
+}
+
+// ListFactories describes all registered factories ordered by name
+func ListFactories() []FactoryInfo {
+	factoriesMu.RLock()
+	infos := make([]FactoryInfo, 0, len(factories))
+	for name, factory := range factories {
+		infos = append(infos, describe(name, factory))
+	}
+	factoriesMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// describe builds the FactoryInfo of factory. Factories without a schema
+// accept any config object.
+func describe(name string, factory Factory) FactoryInfo {
+	info := FactoryInfo{
+		Name:         name,
+		ConfigSchema: json.RawMessage(`{"type":"object"}`),
+	}
+	if d, ok := factory.(Describer); ok {
+		info.Description = d.Description()
+	}
+	if c, ok := factory.(ConfigSchemer); ok {
+		if schema := c.ConfigSchema(); len(schema) > 0 {
+			info.ConfigSchema = schema
+		}
+	}
+	return info
+}
+
+// queryFactory creates Benchmarks that run the QueryTemplate of a benchmark
+type queryFactory struct{}
+
+// Name returns the name of the benchmark type
+func (queryFactory) Name() string {
+	return models.BenchmarkTypeQuery
+}
+
+// Description returns a short summary of the workload
+func (queryFactory) Description() string {
+	return "Runs the benchmark's query template, or a weighted mix of custom queries and transactions, concurrently for the configured duration"
+}
+
+// ConfigSchema returns the JSON Schema of the workload config
+func (queryFactory) ConfigSchema() json.RawMessage {
+	return ConfigSchema(QueryConfig{})
+}
+
+// Create creates a new query benchmark on the connection's database handle
+func (queryFactory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (BenchmarkRunner, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+	if conn == nil || conn.DB == nil {
+		return nil, fmt.Errorf("connection is required")
+	}
+	queryConfig, err := ParseQueryConfig(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseQueries(workloadQueries(config, queryConfig), numberedPlaceholders(conn), ScanRaw); err != nil {
+		return nil, err
+	}
+	return NewBenchmark(config, conn, logger), nil
+}
+
+func init() {
+	RegisterFactory(models.BenchmarkTypeQuery, queryFactory{})
+}
+
 // Result represents the result of a benchmark run
 type Result struct {
-	Name              string                 `json:"name"`
-	Duration          time.Duration          `json:"duration"`
-	TotalTransactions int64                  `json:"total_transactions"`
-	TPS               float64                `json:"tps"`
-	LatencyAvg        time.Duration          `json:"latency_avg"`
-	LatencyP95        time.Duration          `json:"latency_p95"`
-	LatencyP99        time.Duration          `json:"latency_p99"`
-	Errors            int64                  `json:"errors"`
-	StartTime         time.Time              `json:"start_time"`
-	EndTime           time.Time              `json:"end_time"`
-	Metrics           map[string]interface{} `json:"metrics"`
+	Name              string                  `json:"name"`
+	Duration          time.Duration           `json:"duration"`
+	TotalTransactions int64                   `json:"total_transactions"`
+	TPS               float64                 `json:"tps"`
+	LatencyMin        time.Duration           `json:"latency_min"`
+	LatencyAvg        time.Duration           `json:"latency_avg"`
+	LatencyP50        time.Duration           `json:"latency_p50"`
+	LatencyP90        time.Duration           `json:"latency_p90"`
+	LatencyP95        time.Duration           `json:"latency_p95"`
+	LatencyP99        time.Duration           `json:"latency_p99"`
+	LatencyP999       time.Duration           `json:"latency_p999"`
+	LatencyMax        time.Duration           `json:"latency_max"`
+	Errors            int64                   `json:"errors"`
+	StartTime         time.Time               `json:"start_time"`
+	EndTime           time.Time               `json:"end_time"`
+	Metrics           map[string]interface{}  `json:"metrics"`
+	Intervals         []models.IntervalReport `json:"intervals,omitempty"`
+	Phases            []models.PhaseReport    `json:"phases,omitempty"`
+	Breakdown         map[string]QueryStats   `json:"breakdown,omitempty"`
 }
@@ -2,12 +2,16 @@ package benchmark
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/deadjoe/benchphant/internal/models"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -43,6 +47,31 @@ func setupTestBenchmark(t *testing.T) (*Benchmark, *sql.DB, sqlmock.Sqlmock) {
 	return b, db, mock
 }
 
+// setupSQLiteConnection opens a file backed SQLite database with a table of
+// ten accounts, for tests that run queries against a real database
+func setupSQLiteConnection(t *testing.T) *models.DBConnection {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "bench.db"))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)")
+	require.NoError(t, err)
+	for id := 1; id <= 10; id++ {
+		_, err = db.Exec("INSERT INTO accounts VALUES (?, 100)", id)
+		require.NoError(t, err)
+	}
+
+	conn := &models.DBConnection{Name: "test_db", Type: models.SQLite, Database: "bench.db"}
+	conn.SetDB(db)
+	return conn
+}
+
+// oneRow returns the rows of SELECT 1
+func oneRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"1"}).AddRow(1)
+}
+
 func TestNewBenchmark(t *testing.T) {
 	b, _, _ := setupTestBenchmark(t)
 
@@ -61,7 +90,7 @@ func TestBenchmarkStart(t *testing.T) {
 	threads := 1
 	expectedQueries := int(duration.Seconds() * float64(threads) * 500) // Expect more queries since we sleep only 1ms
 	for i := 0; i < expectedQueries; i++ {
-		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
 	}
 
 	// Start the benchmark
@@ -89,7 +118,7 @@ func TestBenchmarkStop(t *testing.T) {
 
 	// Expect multiple executions
 	for i := 0; i < 500; i++ { // Expect more queries to ensure we don't run out during the test
-		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
 	}
 
 	// Start the benchmark
@@ -121,7 +150,7 @@ func TestBenchmarkStatus(t *testing.T) {
 
 	// Expect multiple executions
 	for i := 0; i < 500; i++ { // Expect more queries to ensure we don't run out
-		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
 	}
 
 	// Start the benchmark
@@ -142,6 +171,7 @@ func TestBenchmarkStatus(t *testing.T) {
 	assert.NotZero(t, status.Metrics["latency_avg"])
 	assert.NotZero(t, status.Metrics["latency_p95"])
 	assert.NotZero(t, status.Metrics["latency_p99"])
+	assert.NotZero(t, status.Metrics["latency_max"])
 	assert.Zero(t, status.Metrics["errors"])
 
 	// Verify all expectations were met
@@ -154,7 +184,7 @@ func TestBenchmarkErrorHandling(t *testing.T) {
 
 		// Set up mock expectations
 		mock.ExpectPrepare("SELECT 1").WillBeClosed()
-		mock.ExpectExec("SELECT 1").WillReturnError(fmt.Errorf("query error"))
+		mock.ExpectQuery("SELECT 1").WillReturnError(fmt.Errorf("query error"))
 
 		// Start the benchmark
 		err := b.Start()
@@ -170,6 +200,33 @@ func TestBenchmarkErrorHandling(t *testing.T) {
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("Error Policy", func(t *testing.T) {
+		b, _, mock := setupTestBenchmark(t)
+		b.config.ErrorPolicy = models.ErrorPolicy{
+			OnError:      models.ErrorPolicyMaxCount,
+			MaxErrors:    2,
+			Retries:      1,
+			RetryBackoff: time.Millisecond,
+		}
+
+		// The deadlock is retried, the other errors count until the limit.
+		// Calls beyond the expectations fail too.
+		mock.ExpectPrepare("SELECT 1").WillBeClosed()
+		mock.ExpectQuery("SELECT 1").WillReturnError(fmt.Errorf("deadlock detected"))
+		mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
+		mock.ExpectQuery("SELECT 1").WillReturnError(fmt.Errorf("query error"))
+
+		require.NoError(t, b.Start())
+		<-b.done
+
+		status := b.Status()
+		assert.Equal(t, string(models.BenchmarkStatusFailed), status.Status)
+		assert.Equal(t, map[string]int64{"deadlock": 1}, status.Metrics["retries"])
+		assert.Equal(t, map[string]int64{"other": 2}, status.Metrics["error_classes"])
+		assert.Equal(t, "2 transactions failed, the limit is 2", status.Metrics["abort_reason"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Prepare Error", func(t *testing.T) {
 		b, _, mock := setupTestBenchmark(t)
 
@@ -253,7 +310,7 @@ func TestBenchmarkEdgeCases(t *testing.T) {
 		// Set up mock expectations for the first start
 		mock.ExpectPrepare("SELECT 1").WillBeClosed()
 		for i := 0; i < 100; i++ {
-			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
 		}
 
 		// First start should succeed
@@ -279,7 +336,7 @@ func TestProgressCalculation(t *testing.T) {
 
 	// Expect multiple executions
 	for i := 0; i < 1000; i++ { // Expect more queries to ensure we don't run out
-		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
 	}
 
 	// Define progress checkpoints
@@ -325,3 +382,299 @@ func TestProgressCalculation(t *testing.T) {
 	// Verify all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+type testFactory struct{}
+
+func (testFactory) Name() string { return "test_factory" }
+
+func (testFactory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (BenchmarkRunner, error) {
+	return NewBenchmark(config, conn, logger), nil
+}
+
+func TestGetFactory(t *testing.T) {
+	RegisterFactory("test_factory", testFactory{})
+
+	factory, err := GetFactory("test_factory")
+	require.NoError(t, err)
+	assert.Equal(t, "test_factory", factory.Name())
+
+	_, err = GetFactory("missing")
+	assert.Error(t, err)
+}
+
+func TestListFactories(t *testing.T) {
+	RegisterFactory("test_factory", testFactory{})
+
+	infos := ListFactories()
+	require.NotEmpty(t, infos)
+	for i := 1; i < len(infos); i++ {
+		assert.Less(t, infos[i-1].Name, infos[i].Name)
+	}
+
+	info, err := DescribeFactory("test_factory")
+	require.NoError(t, err)
+	assert.Empty(t, info.Description)
+	assert.JSONEq(t, `{"type":"object"}`, string(info.ConfigSchema))
+
+	info, err = DescribeFactory(models.BenchmarkTypeQuery)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.Description)
+
+	_, err = DescribeFactory("missing")
+	assert.Error(t, err)
+}
+
+func TestQueryFactory(t *testing.T) {
+	factory, err := GetFactory(models.BenchmarkTypeQuery)
+	require.NoError(t, err)
+
+	_, err = factory.Create(&models.Benchmark{}, &models.DBConnection{}, zap.NewNop())
+	assert.Error(t, err)
+
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	conn := &models.DBConnection{}
+	conn.SetDB(db)
+	runner, err := factory.Create(&models.Benchmark{QueryTemplate: "SELECT 1"}, conn, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, string(models.BenchmarkStatusPending), runner.Status().Status)
+
+	_, err = factory.Create(&models.Benchmark{QueryTemplate: "SELECT 1", Config: json.RawMessage(`{"query_rate": 10, "arrival": "bursty"}`)}, conn, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestBenchmarkQueryRate(t *testing.T) {
+	b, _, mock := setupTestBenchmark(t)
+	b.config.NumThreads = 1
+	b.config.Duration = 500 * time.Millisecond
+	b.config.Config = json.RawMessage(`{"query_rate": 100}`)
+
+	// Each query takes twice the 10ms the schedule allows for it
+	mock.ExpectPrepare("SELECT 1").WillBeClosed()
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT 1").WillDelayFor(20 * time.Millisecond).WillReturnRows(oneRow())
+	}
+
+	require.NoError(t, b.Start())
+	<-b.done
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+	assert.Equal(t, float64(100), status.Metrics["target_qps"])
+
+	// Latency is measured from the scheduled start, so the growing backlog
+	// shows up instead of just the 20ms service time
+	assert.InDelta(t, 25, status.Metrics["total_transactions"], 5)
+	assert.InDelta(t, 50, status.Metrics["tps"], 10)
+	assert.Greater(t, status.Metrics["latency_max"], 100*time.Millisecond)
+	assert.Greater(t, status.Metrics["latency_p50"], 40*time.Millisecond)
+}
+
+func TestBenchmarkPhases(t *testing.T) {
+	b, db, mock := setupTestBenchmark(t)
+	b.config.NumThreads = 2
+	b.config.Duration = 200 * time.Millisecond
+	b.config.Phases = models.PhaseConfig{
+		RampUp:    100 * time.Millisecond,
+		RampSteps: 2,
+		Warmup:    100 * time.Millisecond,
+		CoolDown:  100 * time.Millisecond,
+	}
+
+	// sqlmock serves a single connection, which the threads take turns on
+	db.SetMaxOpenConns(1)
+	mock.ExpectPrepare("SELECT 1").WillBeClosed()
+	for i := 0; i < 400; i++ {
+		mock.ExpectQuery("SELECT 1").WillDelayFor(5 * time.Millisecond).WillReturnRows(oneRow())
+	}
+
+	require.NoError(t, b.Start())
+	<-b.done
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+	assert.NotContains(t, status.Metrics, "phase")
+
+	phases := b.Phases()
+	require.Len(t, phases, 4)
+	var names []string
+	var total int64
+	for _, phase := range phases {
+		names = append(names, phase.Phase)
+		total += phase.Transactions
+		assert.Greater(t, phase.Transactions, int64(0), phase.Phase)
+	}
+	assert.Equal(t, []string{models.PhaseRampUp, models.PhaseWarmup, models.PhaseMeasure, models.PhaseCoolDown}, names)
+
+	// Only the measurement counts in the status, the intervals see everything
+	assert.Equal(t, phases[2].Transactions, status.Metrics["total_transactions"])
+	assert.Equal(t, phases[2].Transactions, b.latencies.Count())
+
+	var intervalTransactions int64
+	seen := make(map[string]bool)
+	for _, interval := range b.Intervals() {
+		intervalTransactions += interval.Transactions
+		seen[interval.Phase] = true
+	}
+	assert.Equal(t, total, intervalTransactions)
+	assert.Len(t, seen, 4)
+}
+
+func TestBenchmarkStatusCopy(t *testing.T) {
+	b, _, mock := setupTestBenchmark(t)
+	b.config.Duration = 200 * time.Millisecond
+
+	mock.ExpectPrepare("SELECT 1").WillBeClosed()
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT 1").WillDelayFor(5 * time.Millisecond).WillReturnRows(oneRow())
+	}
+
+	require.NoError(t, b.Start())
+
+	// Reading and changing the metrics while the workers update them must
+	// neither race with them nor show up in the runner
+	for running := true; running; {
+		select {
+		case <-b.done:
+			running = false
+		case <-time.After(time.Millisecond):
+		}
+		status := b.Status()
+		if breakdown, ok := status.Metrics["breakdown"].(map[string]QueryStats); ok {
+			delete(breakdown, templateQueryName)
+		}
+		for k := range status.Metrics {
+			status.Metrics[k] = nil
+		}
+	}
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+	assert.NotNil(t, status.Metrics["qps"])
+	assert.Contains(t, status.Metrics["breakdown"], templateQueryName)
+}
+
+// argRecorder is a sqlmock argument matcher recording the ids bound
+type argRecorder struct {
+	ids []int64
+}
+
+func (r *argRecorder) Match(v driver.Value) bool {
+	id, ok := v.(int64)
+	r.ids = append(r.ids, id)
+	return ok
+}
+
+func TestBenchmarkQueryTemplate(t *testing.T) {
+	b, _, mock := setupTestBenchmark(t)
+	b.config.Duration = 200 * time.Millisecond
+	b.config.QueryTemplate = "UPDATE accounts SET balance = balance + 1 WHERE id = {{sample accounts.id 2}} AND region = {{pick 'north' 'south'}}"
+
+	mock.ExpectQuery(`SELECT id FROM accounts LIMIT 2`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)).AddRow(int64(9)))
+	mock.ExpectPrepare(`UPDATE accounts SET balance = balance \+ 1 WHERE id = \? AND region = \?`).WillBeClosed()
+	ids := &argRecorder{}
+	for i := 0; i < 100; i++ {
+		mock.ExpectExec("UPDATE accounts").WithArgs(ids, sqlmock.AnyArg()).WillDelayFor(5 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	require.NoError(t, b.Start())
+	<-b.done
+
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), b.Status().Status)
+	require.NotEmpty(t, ids.ids)
+	for _, id := range ids.ids {
+		assert.Contains(t, []int64{7, 9}, id)
+	}
+
+	_, err := queryFactory{}.Create(&models.Benchmark{QueryTemplate: "SELECT {{nope}}"}, b.connection, zap.NewNop())
+	assert.ErrorContains(t, err, "unknown generator")
+}
+
+func TestBenchmarkCustomQueries(t *testing.T) {
+	b, _, mock := setupTestBenchmark(t)
+	b.config.Duration = 200 * time.Millisecond
+	b.config.QueryTemplate = ""
+	b.config.Config = json.RawMessage(`{
+		"queries": [
+			{"name": "balance", "sql": "SELECT balance FROM accounts WHERE id = {{uniform 1 10}}"},
+			{"name": "transfer", "statements": [
+				"UPDATE accounts SET balance = balance - 1 WHERE id = {{uniform 1 10}}",
+				"UPDATE accounts SET balance = balance + 1 WHERE id = {{uniform 1 10}}"
+			], "prepared": true}
+		],
+		"distribution": "weighted",
+		"query_weights": [3, 1]
+	}`)
+
+	// Reads are queried and their rows read, writes executed in a transaction
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectPrepare(`UPDATE accounts SET balance = balance - 1 WHERE id = \?`)
+	mock.ExpectPrepare(`UPDATE accounts SET balance = balance \+ 1 WHERE id = \?`)
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT balance").WithArgs(sqlmock.AnyArg()).WillDelayFor(2 * time.Millisecond).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100).AddRow(200))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE accounts").WithArgs(sqlmock.AnyArg()).WillDelayFor(time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE accounts").WithArgs(sqlmock.AnyArg()).WillDelayFor(time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	require.NoError(t, b.Start())
+	<-b.done
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+	stats, ok := status.Metrics["breakdown"].(map[string]QueryStats)
+	require.True(t, ok)
+	require.Len(t, stats, 2)
+	assert.Greater(t, stats["transfer"].Count, int64(0))
+	assert.Greater(t, stats["balance"].Count, stats["transfer"].Count)
+	assert.Greater(t, stats["transfer"].Latency.Max, 2*time.Millisecond)
+
+	// Reads count the rows returned, writes the rows affected
+	assert.Equal(t, 2*stats["balance"].Count, stats["balance"].Rows)
+	assert.Equal(t, 2*stats["transfer"].Count, stats["transfer"].Rows)
+	assert.Equal(t, stats["balance"].Count+stats["transfer"].Count, status.Metrics["total_transactions"])
+	assert.Equal(t, stats["balance"].Rows, status.Metrics["rows_read"])
+	assert.Greater(t, status.Metrics["rows_read_per_sec"], float64(0))
+
+	// A transfer counts as one transaction of two queries
+	var transactions, queries int64
+	for _, interval := range b.Intervals() {
+		transactions += interval.Transactions
+		queries += interval.Queries
+	}
+	assert.Equal(t, transactions+stats["transfer"].Count, queries)
+}
+
+func TestBenchmarkReadRows(t *testing.T) {
+	b, _, mock := setupTestBenchmark(t)
+	b.config.Duration = 200 * time.Millisecond
+	b.config.QueryTemplate = "SELECT id, name FROM accounts WHERE id = {{uniform 1 10}}"
+	b.config.Config = json.RawMessage(`{"scan": "typed"}`)
+
+	// A select template is queried and its rows read, NULLs included
+	mock.ExpectPrepare("SELECT id, name FROM accounts").WillBeClosed()
+	for i := 0; i < 100; i++ {
+		mock.ExpectQuery("SELECT id, name FROM accounts").WithArgs(sqlmock.AnyArg()).WillDelayFor(5 * time.Millisecond).
+			WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+				mock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+				mock.NewColumn("name").OfType("VARCHAR", "").Nullable(true),
+			).AddRow(int64(1), "ann").AddRow(int64(2), nil))
+	}
+
+	require.NoError(t, b.Start())
+	<-b.done
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+	stats := status.Metrics["breakdown"].(map[string]QueryStats)
+	rows := status.Metrics["rows_read"].(int64)
+	assert.Equal(t, 2*stats[templateQueryName].Count, rows)
+
+	// Two ids of 8 bytes and one 3 byte name per query
+	assert.Equal(t, rows/2*19, status.Metrics["bytes_read"])
+	assert.Greater(t, status.Metrics["bytes_read_per_sec"], status.Metrics["rows_read_per_sec"])
+}
@@ -0,0 +1,93 @@
+package benchmark
+
+import (
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// QueryStats holds the statistics of one query or one type of transaction
+type QueryStats = models.QueryStats
+
+// Breakdown collects the statistics of a run by query name or transaction
+// type, so that a regression can be traced to the statement causing it. It
+// is safe for concurrent use.
+type Breakdown struct {
+	mu      sync.Mutex
+	entries map[string]*breakdownEntry
+}
+
+// breakdownEntry holds the counters and latencies of one name
+type breakdownEntry struct {
+	count     int64
+	errors    int64
+	rows      int64
+	latencies *metrics.Histogram
+}
+
+// NewBreakdown creates an empty breakdown
+func NewBreakdown() *Breakdown {
+	return &Breakdown{entries: make(map[string]*breakdownEntry)}
+}
+
+// entry returns the entry of name, creating it if needed. The caller must
+// hold b.mu.
+func (b *Breakdown) entry(name string) *breakdownEntry {
+	e, ok := b.entries[name]
+	if !ok {
+		e = &breakdownEntry{latencies: metrics.NewHistogram()}
+		b.entries[name] = e
+	}
+	return e
+}
+
+// Record records a successful execution of name that took latency and
+// returned or affected rows rows
+func (b *Breakdown) Record(name string, latency time.Duration, rows int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := b.entry(name)
+	e.count++
+	e.rows += rows
+	e.latencies.Record(latency)
+}
+
+// RecordError records a failed execution of name
+func (b *Breakdown) RecordError(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entry(name).errors++
+}
+
+// Stats returns the statistics of every name recorded so far, or nil if
+// there is none
+func (b *Breakdown) Stats() map[string]QueryStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) == 0 {
+		return nil
+	}
+	stats := make(map[string]QueryStats, len(b.entries))
+	for name, e := range b.entries {
+		stats[name] = QueryStats{
+			Count:   e.count,
+			Errors:  e.errors,
+			Rows:    e.rows,
+			Latency: e.latencies.Summary(),
+		}
+	}
+	return stats
+}
+
+// Reset forgets everything recorded
+func (b *Breakdown) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = make(map[string]*breakdownEntry)
+}
@@ -0,0 +1,44 @@
+package benchmark
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakdown(t *testing.T) {
+	b := NewBreakdown()
+	assert.Nil(t, b.Stats())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Record("point_select", time.Millisecond, 1)
+			}
+			b.Record("order_range", 10*time.Millisecond, 100)
+			b.RecordError("order_range")
+		}()
+	}
+	wg.Wait()
+
+	stats := b.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, int64(400), stats["point_select"].Count)
+	assert.Equal(t, int64(400), stats["point_select"].Rows)
+	assert.Zero(t, stats["point_select"].Errors)
+	assert.InEpsilon(t, float64(time.Millisecond), float64(stats["point_select"].Latency.P99), 0.01)
+
+	assert.Equal(t, int64(4), stats["order_range"].Count)
+	assert.Equal(t, int64(4), stats["order_range"].Errors)
+	assert.Equal(t, int64(400), stats["order_range"].Rows)
+	assert.Equal(t, int64(4), stats["order_range"].Latency.Count)
+
+	b.Reset()
+	assert.Nil(t, b.Stats())
+}
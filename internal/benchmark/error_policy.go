@@ -0,0 +1,238 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/dberror"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// ErrorClass is the kind of error a transaction failed with, as told by
+// the dberror package
+type ErrorClass = dberror.Class
+
+const (
+	// ErrorClassDeadlock is a transaction chosen as a deadlock victim
+	ErrorClassDeadlock = dberror.Deadlock
+	// ErrorClassLockTimeout is a transaction that gave up waiting for a lock
+	ErrorClassLockTimeout = dberror.LockTimeout
+	// ErrorClassDuplicateKey is a write violating a unique key
+	ErrorClassDuplicateKey = dberror.DuplicateKey
+	// ErrorClassConnectionLost is a connection to the database that broke
+	ErrorClassConnectionLost = dberror.ConnectionLost
+	// ErrorClassSerialization is a transaction that could not be serialized
+	// with concurrent ones
+	ErrorClassSerialization = dberror.Serialization
+	// ErrorClassCanceled is a statement cancelled by a timeout
+	ErrorClassCanceled = dberror.Canceled
+	// ErrorClassOther is any other error
+	ErrorClassOther = dberror.Other
+)
+
+// defaultRetryOn are the classes retried when a policy with retries does
+// not list any: those that may succeed when run again
+var defaultRetryOn = []ErrorClass{
+	ErrorClassDeadlock,
+	ErrorClassLockTimeout,
+	ErrorClassSerialization,
+	ErrorClassConnectionLost,
+}
+
+// defaultRetryBackoff is the wait before the first retry of a policy that
+// does not set one
+const defaultRetryBackoff = 10 * time.Millisecond
+
+// errorRateMinTransactions is how many transactions a run needs before the
+// max_rate policy judges its error rate, so a single early error does not
+// end it
+const errorRateMinTransactions = 100
+
+// ClassifyError returns the class of a transaction error
+func ClassifyError(err error) ErrorClass {
+	return dberror.Classify(err)
+}
+
+// ErrorTracker applies an error policy to the transactions of a run. It
+// retries errors the policy retries, counts the errors of each class and
+// the conflicts with concurrent transactions, and decides when the run has
+// failed. It is safe for concurrent use.
+type ErrorTracker struct {
+	policy  models.ErrorPolicy
+	retryOn map[ErrorClass]bool
+
+	mu        sync.Mutex
+	succeeded int64
+	failed    int64
+	classes   map[ErrorClass]int64
+	retries   map[ErrorClass]int64
+	locks     LockStats
+	reason    string
+}
+
+// NewErrorTracker creates a tracker for policy. An empty OnError is replaced
+// by defaultAction, the default of the workload.
+func NewErrorTracker(policy models.ErrorPolicy, defaultAction string) (*ErrorTracker, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if policy.OnError == "" {
+		policy.OnError = defaultAction
+	}
+	if policy.RetryBackoff == 0 {
+		policy.RetryBackoff = defaultRetryBackoff
+	}
+
+	retryOn := make(map[ErrorClass]bool)
+	classes := defaultRetryOn
+	if len(policy.RetryOn) > 0 {
+		classes = nil
+		for _, name := range policy.RetryOn {
+			class, err := ParseErrorClass(name)
+			if err != nil {
+				return nil, err
+			}
+			classes = append(classes, class)
+		}
+	}
+	for _, class := range classes {
+		retryOn[class] = true
+	}
+
+	return &ErrorTracker{
+		policy:  policy,
+		retryOn: retryOn,
+		classes: make(map[ErrorClass]int64),
+		retries: make(map[ErrorClass]int64),
+	}, nil
+}
+
+// ValidateErrorPolicy validates policy, including the names of the error
+// classes it retries
+func ValidateErrorPolicy(policy models.ErrorPolicy) error {
+	_, err := NewErrorTracker(policy, models.ErrorPolicyContinue)
+	return err
+}
+
+// ParseErrorClass parses the name of an error class
+func ParseErrorClass(name string) (ErrorClass, error) {
+	return dberror.Parse(name)
+}
+
+// Retry calls fn until it succeeds, fails with an error the policy does not
+// retry or runs out of retries, and returns its last error. It waits the
+// backoff between attempts and gives up early when ctx is done or stop is
+// closed. Every attempt failing with a conflict is counted in the lock
+// statistics.
+func (t *ErrorTracker) Retry(ctx context.Context, stop <-chan struct{}, fn func() error) error {
+	backoff := t.policy.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		began := time.Now()
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		class := ClassifyError(err)
+		retry := attempt < t.policy.Retries && ctx.Err() == nil && t.retryOn[class]
+
+		t.mu.Lock()
+		if dberror.IsConflict(class) {
+			t.locks.RecordConflict(class, time.Since(began))
+			if retry {
+				t.locks.RetryCount++
+			}
+		}
+		if retry {
+			t.retries[class]++
+		}
+		t.mu.Unlock()
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-stop:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Succeeded counts a transaction that succeeded
+func (t *ErrorTracker) Succeeded() {
+	t.mu.Lock()
+	t.succeeded++
+	t.mu.Unlock()
+}
+
+// Failed counts a transaction that failed with err under its class and
+// reports whether the policy ends the run
+func (t *ErrorTracker) Failed(err error) bool {
+	class := ClassifyError(err)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failed++
+	t.classes[class]++
+	if t.reason != "" {
+		return true
+	}
+
+	switch t.policy.OnError {
+	case models.ErrorPolicyAbort:
+		t.reason = fmt.Sprintf("transaction failed with %s error: %v", class, err)
+	case models.ErrorPolicyMaxCount:
+		if t.failed >= t.policy.MaxErrors {
+			t.reason = fmt.Sprintf("%d transactions failed, the limit is %d", t.failed, t.policy.MaxErrors)
+		}
+	case models.ErrorPolicyMaxRate:
+		total := t.failed + t.succeeded
+		if rate := float64(t.failed) / float64(total); total >= errorRateMinTransactions && rate > t.policy.MaxErrorRate {
+			t.reason = fmt.Sprintf("error rate %.4f exceeds the limit of %.4f", rate, t.policy.MaxErrorRate)
+		}
+	}
+	return t.reason != ""
+}
+
+// Reason returns why the policy ended the run, or "" if it did not
+func (t *ErrorTracker) Reason() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reason
+}
+
+// Metrics returns the status metrics of the tracker: the failed
+// transactions and the retries of each class and the lock statistics, over
+// the whole run, and why the policy ended the run if it did
+func (t *ErrorTracker) Metrics() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics := map[string]interface{}{
+		"error_classes": classCounts(t.classes),
+		"retries":       classCounts(t.retries),
+		"lock_stats":    t.locks,
+	}
+	if t.reason != "" {
+		metrics["abort_reason"] = t.reason
+	}
+	return metrics
+}
+
+// classCounts copies counts keyed by class into a map keyed by class name
+func classCounts(counts map[ErrorClass]int64) map[string]int64 {
+	named := make(map[string]int64, len(counts))
+	for class, n := range counts {
+		named[string(class)] = n
+	}
+	return named
+}
@@ -0,0 +1,140 @@
+package benchmark
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	for err, class := range map[error]ErrorClass{
+		&pq.Error{Code: "40P01"}:                              ErrorClassDeadlock,
+		&mysql.MySQLError{Number: 1205}:                       ErrorClassLockTimeout,
+		&pq.Error{Code: "40001"}:                              ErrorClassSerialization,
+		&pq.Error{Code: "55P03"}:                              ErrorClassLockTimeout,
+		fmt.Errorf("insert: %w", &pq.Error{Code: "23505"}):    ErrorClassDuplicateKey,
+		&pq.Error{Code: "08006"}:                              ErrorClassConnectionLost,
+		fmt.Errorf("exec: %w", driver.ErrBadConn):             ErrorClassConnectionLost,
+		errors.New("Error 1213 (40001): Deadlock found"):      ErrorClassDeadlock,
+		errors.New("Error 1205 (HY000): Lock wait timeout"):   ErrorClassLockTimeout,
+		errors.New("Error 1062 (23000): Duplicate entry '1'"): ErrorClassDuplicateKey,
+		errors.New("database is locked"):                      ErrorClassLockTimeout,
+		errors.New("syntax error"):                            ErrorClassOther,
+	} {
+		assert.Equal(t, class, ClassifyError(err), err.Error())
+	}
+}
+
+func TestErrorTracker(t *testing.T) {
+	deadlock := errors.New("deadlock detected")
+	other := errors.New("syntax error")
+
+	t.Run("Defaults", func(t *testing.T) {
+		abort, err := NewErrorTracker(models.ErrorPolicy{}, models.ErrorPolicyAbort)
+		require.NoError(t, err)
+		assert.True(t, abort.Failed(other))
+		assert.Contains(t, abort.Reason(), "transaction failed with other error")
+
+		cont, err := NewErrorTracker(models.ErrorPolicy{}, models.ErrorPolicyContinue)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			assert.False(t, cont.Failed(deadlock))
+		}
+		assert.Empty(t, cont.Reason())
+		assert.Equal(t, map[string]int64{"deadlock": 10}, cont.Metrics()["error_classes"])
+	})
+
+	t.Run("MaxCount", func(t *testing.T) {
+		tracker, err := NewErrorTracker(models.ErrorPolicy{OnError: models.ErrorPolicyMaxCount, MaxErrors: 3}, models.ErrorPolicyAbort)
+		require.NoError(t, err)
+		assert.False(t, tracker.Failed(other))
+		assert.False(t, tracker.Failed(deadlock))
+		assert.True(t, tracker.Failed(other))
+		assert.Equal(t, "3 transactions failed, the limit is 3", tracker.Metrics()["abort_reason"])
+	})
+
+	t.Run("MaxRate", func(t *testing.T) {
+		tracker, err := NewErrorTracker(models.ErrorPolicy{OnError: models.ErrorPolicyMaxRate, MaxErrorRate: 0.1}, models.ErrorPolicyAbort)
+		require.NoError(t, err)
+		// Too few transactions to judge the rate
+		assert.False(t, tracker.Failed(other))
+		for i := 0; i < 98; i++ {
+			tracker.Succeeded()
+		}
+		assert.False(t, tracker.Failed(other), "2% of 100")
+		for i := 0; i < 10; i++ {
+			tracker.Failed(other)
+		}
+		assert.Contains(t, tracker.Reason(), "exceeds the limit of 0.1000")
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		tracker, err := NewErrorTracker(models.ErrorPolicy{Retries: 2, RetryBackoff: time.Millisecond}, models.ErrorPolicyContinue)
+		require.NoError(t, err)
+
+		calls := 0
+		err = tracker.Retry(context.Background(), nil, func() error {
+			calls++
+			if calls < 3 {
+				return deadlock
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+
+		// Out of retries
+		calls = 0
+		err = tracker.Retry(context.Background(), nil, func() error {
+			calls++
+			return deadlock
+		})
+		assert.Equal(t, deadlock, err)
+		assert.Equal(t, 3, calls)
+
+		// Not retried
+		calls = 0
+		err = tracker.Retry(context.Background(), nil, func() error {
+			calls++
+			return other
+		})
+		assert.Equal(t, other, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, map[string]int64{"deadlock": 4}, tracker.Metrics()["retries"])
+		locks := tracker.Metrics()["lock_stats"].(LockStats)
+		assert.Equal(t, int64(5), locks.LockCount)
+		assert.Equal(t, int64(5), locks.DeadlockCount)
+		assert.Equal(t, int64(4), locks.RetryCount)
+
+		// A stopped run does not wait for the backoff
+		slow, err := NewErrorTracker(models.ErrorPolicy{Retries: 1, RetryBackoff: time.Hour, RetryOn: []string{"other"}}, models.ErrorPolicyContinue)
+		require.NoError(t, err)
+		stop := make(chan struct{})
+		close(stop)
+		assert.Equal(t, other, slow.Retry(context.Background(), stop, func() error { return other }))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, policy := range map[string]models.ErrorPolicy{
+			"Unknown":          {OnError: "ignore"},
+			"MaxCountNoLimit":  {OnError: models.ErrorPolicyMaxCount},
+			"MaxRateAboveOne":  {OnError: models.ErrorPolicyMaxRate, MaxErrorRate: 2},
+			"NegativeRetries":  {Retries: -1},
+			"NegativeBackoff":  {RetryBackoff: -time.Second},
+			"UnknownRetryOn":   {Retries: 1, RetryOn: []string{"timeout"}},
+			"NegativeMaxCount": {OnError: models.ErrorPolicyContinue, MaxErrors: -1},
+		} {
+			assert.Error(t, ValidateErrorPolicy(policy), name)
+		}
+		assert.NoError(t, ValidateErrorPolicy(models.ErrorPolicy{}))
+	})
+}
@@ -0,0 +1,234 @@
+package benchmark
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/dberror"
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// DefaultReportInterval is the report interval of runs that do not set one
+const DefaultReportInterval = time.Second
+
+// IntervalReporter is implemented by runners that report statistics for each
+// report interval of a run
+type IntervalReporter interface {
+	// Intervals returns the reports of the intervals finished so far, oldest
+	// first
+	Intervals() []models.IntervalReport
+}
+
+// IntervalRecorder collects the transactions, queries and errors of a run
+// and turns them into one models.IntervalReport per report interval. The
+// latencies of each interval are recorded in their own histogram, so its
+// percentiles are not diluted by the rest of the run. A nil recorder or one
+// with a zero interval records nothing. It is safe for concurrent use.
+type IntervalRecorder struct {
+	interval time.Duration
+
+	mu           sync.Mutex
+	start        time.Time
+	windowStart  time.Time
+	latencies    *metrics.Histogram
+	transactions int64
+	queries      int64 // updated atomically, statements outnumber transactions
+	errors       int64
+	reconnects   int64
+	phase        string
+	reports      []models.IntervalReport
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewIntervalRecorder creates a recorder reporting every interval
+func NewIntervalRecorder(interval time.Duration) *IntervalRecorder {
+	return &IntervalRecorder{
+		interval:  interval,
+		latencies: metrics.NewHistogram(),
+	}
+}
+
+// Start starts the first interval. Reports of an earlier run are dropped.
+func (r *IntervalRecorder) Start() {
+	if r == nil || r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+	r.start = time.Now()
+	r.windowStart = r.start
+	r.latencies.Reset()
+	r.transactions, r.errors, r.reconnects = 0, 0, 0
+	atomic.StoreInt64(&r.queries, 0)
+	r.phase = ""
+	r.reports = nil
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.tick(r.stop, r.done)
+}
+
+// Stop ends the run, reporting the unfinished interval unless nothing
+// happened in it
+func (r *IntervalRecorder) Stop() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop = nil
+	r.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active() {
+		r.report(time.Now())
+	}
+}
+
+// SetPhase labels the following intervals with phase. The current interval
+// is reported early so that no interval spans two phases.
+func (r *IntervalRecorder) SetPhase(phase string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if phase == r.phase {
+		return
+	}
+	if r.stop != nil {
+		now := time.Now()
+		if r.active() {
+			r.report(now)
+		}
+		r.windowStart = now
+	}
+	r.phase = phase
+}
+
+// active reports whether anything happened in the current interval. The
+// caller must hold r.mu.
+func (r *IntervalRecorder) active() bool {
+	return r.transactions > 0 || atomic.LoadInt64(&r.queries) > 0 || r.errors > 0
+}
+
+// tick reports an interval every r.interval until stop is closed
+func (r *IntervalRecorder) tick(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			r.report(now)
+			r.mu.Unlock()
+		}
+	}
+}
+
+// report closes the current interval at now. The caller must hold r.mu.
+func (r *IntervalRecorder) report(now time.Time) {
+	duration := now.Sub(r.windowStart)
+	report := models.IntervalReport{
+		Elapsed:      now.Sub(r.start),
+		Duration:     duration,
+		Transactions: r.transactions,
+		Queries:      atomic.SwapInt64(&r.queries, 0),
+		Errors:       r.errors,
+		Reconnects:   r.reconnects,
+		Latency:      r.latencies.Summary(),
+		Phase:        r.phase,
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		report.TPS = float64(r.transactions) / seconds
+		report.QPS = float64(report.Queries) / seconds
+	}
+	r.reports = append(r.reports, report)
+
+	r.windowStart = now
+	r.latencies.Reset()
+	r.transactions, r.errors, r.reconnects = 0, 0, 0
+}
+
+// RecordTransaction records a successful transaction and its latency
+func (r *IntervalRecorder) RecordTransaction(latency time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.transactions++
+	r.latencies.Record(latency)
+}
+
+// RecordQueries records n statements sent to the database
+func (r *IntervalRecorder) RecordQueries(n int64) {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.queries, n)
+}
+
+// RecordError records a failed transaction. Errors caused by a lost
+// connection are also counted as reconnects, since database/sql replaces the
+// connection for the next transaction.
+func (r *IntervalRecorder) RecordError(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors++
+	if isConnectionError(err) {
+		r.reconnects++
+	}
+}
+
+// Intervals returns the reports of the intervals finished so far
+func (r *IntervalRecorder) Intervals() []models.IntervalReport {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.IntervalReport(nil), r.reports...)
+}
+
+// isConnectionError reports whether err means the connection to the
+// database was lost
+func isConnectionError(err error) bool {
+	return dberror.Classify(err) == dberror.ConnectionLost
+}
+
+// IntervalsOf returns the interval reports of runner, or nil if it does not
+// report intervals
+func IntervalsOf(runner BenchmarkRunner) []models.IntervalReport {
+	if r, ok := runner.(IntervalReporter); ok {
+		return r.Intervals()
+	}
+	return nil
+}
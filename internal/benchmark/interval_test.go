@@ -0,0 +1,97 @@
+package benchmark
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalRecorder(t *testing.T) {
+	r := NewIntervalRecorder(50 * time.Millisecond)
+	r.Start()
+
+	// Slow transactions in the first interval only
+	for i := 0; i < 10; i++ {
+		r.RecordTransaction(100 * time.Millisecond)
+		r.RecordQueries(3)
+	}
+	require.Eventually(t, func() bool { return len(r.Intervals()) >= 1 }, time.Second, 5*time.Millisecond)
+
+	r.RecordTransaction(time.Millisecond)
+	r.RecordError(errors.New("deadlock"))
+	r.RecordError(fmt.Errorf("exec: %w", driver.ErrBadConn))
+	r.Stop()
+
+	intervals := r.Intervals()
+	require.GreaterOrEqual(t, len(intervals), 2)
+
+	first := intervals[0]
+	assert.Equal(t, int64(10), first.Transactions)
+	assert.Equal(t, int64(30), first.Queries)
+	assert.Equal(t, 100*time.Millisecond, first.Latency.P95)
+	assert.InDelta(t, 10/first.Duration.Seconds(), first.TPS, 0.001)
+
+	// Later intervals do not see the latencies of the first one
+	var transactions, errs, reconnects int64
+	for _, interval := range intervals[1:] {
+		transactions += interval.Transactions
+		errs += interval.Errors
+		reconnects += interval.Reconnects
+		assert.LessOrEqual(t, interval.Latency.Max, time.Millisecond)
+		assert.Greater(t, interval.Elapsed, first.Elapsed)
+	}
+	assert.Equal(t, int64(1), transactions)
+	assert.Equal(t, int64(2), errs)
+	assert.Equal(t, int64(1), reconnects)
+
+	// Nothing is recorded once stopped, a restart drops the old reports
+	r.Stop()
+	assert.Len(t, r.Intervals(), len(intervals))
+	r.Start()
+	assert.Empty(t, r.Intervals())
+	r.Stop()
+}
+
+func TestIntervalRecorderDisabled(t *testing.T) {
+	var r *IntervalRecorder
+	r.Start()
+	r.RecordTransaction(time.Millisecond)
+	r.RecordQueries(1)
+	r.RecordError(errors.New("failed"))
+	r.Stop()
+	assert.Nil(t, r.Intervals())
+
+	r = NewIntervalRecorder(0)
+	r.Start()
+	r.RecordTransaction(time.Millisecond)
+	r.Stop()
+	assert.Empty(t, r.Intervals())
+
+	assert.Nil(t, IntervalsOf(nil))
+}
+
+func TestIntervalRecorderSetPhase(t *testing.T) {
+	r := NewIntervalRecorder(time.Hour)
+	r.SetPhase("warmup")
+	r.Start()
+	r.SetPhase("warmup")
+
+	r.RecordTransaction(time.Millisecond)
+	r.SetPhase("measure")
+	assert.Len(t, r.Intervals(), 1)
+
+	// An idle interval is not reported when the phase changes
+	r.SetPhase("cool_down")
+	r.RecordTransaction(time.Millisecond)
+	r.Stop()
+
+	intervals := r.Intervals()
+	require.Len(t, intervals, 2)
+	assert.Equal(t, "warmup", intervals[0].Phase)
+	assert.Equal(t, "cool_down", intervals[1].Phase)
+}
@@ -0,0 +1,273 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// PhaseReporter is implemented by runners that report statistics for each
+// phase of a run
+type PhaseReporter interface {
+	// Phases returns the reports of the phases started so far, in order
+	Phases() []models.PhaseReport
+}
+
+// phaseSpan is a phase of a run from start up to end
+type phaseSpan struct {
+	name       string
+	start, end time.Duration
+	latencies  *metrics.Histogram
+	count      int64
+	errors     int64
+}
+
+// PhaseRecorder schedules the phases of a run, ramp-up, warmup, measurement
+// and cool-down, and keeps the statistics of each. Operations are assigned to
+// the phase they started in, so runners count only those the recorder
+// reports as measured in their result. It is safe for concurrent use.
+type PhaseRecorder struct {
+	config    models.PhaseConfig
+	mu        sync.Mutex
+	start     time.Time
+	spans     []*phaseSpan
+	measureAt int
+}
+
+// NewPhaseRecorder creates a recorder for a run measuring for duration
+func NewPhaseRecorder(config models.PhaseConfig, duration time.Duration) *PhaseRecorder {
+	r := &PhaseRecorder{config: config}
+
+	var at time.Duration
+	add := func(name string, length time.Duration) {
+		if name == models.PhaseMeasure {
+			r.measureAt = len(r.spans)
+		} else if length <= 0 {
+			return
+		}
+		r.spans = append(r.spans, &phaseSpan{
+			name:      name,
+			start:     at,
+			end:       at + length,
+			latencies: metrics.NewHistogram(),
+		})
+		at += length
+	}
+	add(models.PhaseRampUp, config.RampUp)
+	add(models.PhaseWarmup, config.Warmup)
+	add(models.PhaseMeasure, duration)
+	add(models.PhaseCoolDown, config.CoolDown)
+	return r
+}
+
+// Start starts the schedule. Statistics of an earlier run are dropped.
+func (r *PhaseRecorder) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.start = time.Now()
+	for _, span := range r.spans {
+		span.latencies.Reset()
+		span.count, span.errors = 0, 0
+	}
+}
+
+// Total returns the run time of all phases
+func (r *PhaseRecorder) Total() time.Duration {
+	return r.spans[len(r.spans)-1].end
+}
+
+// MeasureStart returns the time the measurement starts
+func (r *PhaseRecorder) MeasureStart() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.start.Add(r.spans[r.measureAt].start)
+}
+
+// Measured returns how much of the measurement has run by now
+func (r *PhaseRecorder) Measured(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ran(r.spans[r.measureAt], now)
+}
+
+// ran returns how much of span has run by now. The caller must hold r.mu.
+func (r *PhaseRecorder) ran(span *phaseSpan, now time.Time) time.Duration {
+	elapsed := now.Sub(r.start)
+	switch {
+	case elapsed <= span.start:
+		return 0
+	case elapsed >= span.end:
+		return span.end - span.start
+	default:
+		return elapsed - span.start
+	}
+}
+
+// Progress returns the share of the whole schedule run by now in percent
+func (r *PhaseRecorder) Progress(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	progress := float64(now.Sub(r.start)) / float64(r.Total()) * 100
+	if progress > 100 {
+		return 100
+	}
+	return progress
+}
+
+// PhaseAt returns the phase running at t. Times after the schedule belong to
+// the last phase.
+func (r *PhaseRecorder) PhaseAt(t time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spanAt(t).name
+}
+
+// spanAt returns the phase running at t. The caller must hold r.mu.
+func (r *PhaseRecorder) spanAt(t time.Time) *phaseSpan {
+	elapsed := t.Sub(r.start)
+	for _, span := range r.spans {
+		if elapsed < span.end {
+			return span
+		}
+	}
+	return r.spans[len(r.spans)-1]
+}
+
+// ThreadDelay returns how long after the start of the run thread id of
+// threads starts. Without steps the threads are spread evenly over the
+// ramp-up, with RampSteps they start in that many equal groups.
+func (r *PhaseRecorder) ThreadDelay(id, threads int) time.Duration {
+	if r.config.RampUp <= 0 || threads <= 0 {
+		return 0
+	}
+	if steps := r.config.RampSteps; steps > 0 {
+		step := id * steps / threads
+		return r.config.RampUp * time.Duration(step) / time.Duration(steps)
+	}
+	return r.config.RampUp * time.Duration(id) / time.Duration(threads)
+}
+
+// WaitForThread sleeps until thread id of threads is due to start. It
+// returns false if ctx is done or stop is closed first.
+func (r *PhaseRecorder) WaitForThread(ctx context.Context, stop <-chan struct{}, id, threads int) bool {
+	r.mu.Lock()
+	at := r.start.Add(r.ThreadDelay(id, threads))
+	r.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Watch calls fn with the phase running now before it returns, and then in
+// the background with each following phase when it starts, until the last
+// phase started or stop is closed
+func (r *PhaseRecorder) Watch(stop <-chan struct{}, fn func(phase string)) {
+	r.mu.Lock()
+	start, spans := r.start, r.spans
+	current := r.spanAt(time.Now())
+	r.mu.Unlock()
+
+	fn(current.name)
+	for i, span := range spans {
+		if span == current {
+			go r.watch(stop, start, spans[i+1:], fn)
+			return
+		}
+	}
+}
+
+// watch calls fn when each of spans starts
+func (r *PhaseRecorder) watch(stop <-chan struct{}, start time.Time, spans []*phaseSpan, fn func(phase string)) {
+	for _, span := range spans {
+		timer := time.NewTimer(time.Until(start.Add(span.start)))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		fn(span.name)
+	}
+}
+
+// RecordTransaction records a successful operation started at start and
+// reports whether it was measured
+func (r *PhaseRecorder) RecordTransaction(start time.Time, latency time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span := r.spanAt(start)
+	span.count++
+	span.latencies.Record(latency)
+	return span.name == models.PhaseMeasure
+}
+
+// RecordError records a failed operation started at start and reports
+// whether it was measured
+func (r *PhaseRecorder) RecordError(start time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	span := r.spanAt(start)
+	span.errors++
+	return span.name == models.PhaseMeasure
+}
+
+// Phases returns the reports of the phases started so far
+func (r *PhaseRecorder) Phases() []models.PhaseReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.start.IsZero() {
+		return nil
+	}
+
+	now := time.Now()
+	var reports []models.PhaseReport
+	for _, span := range r.spans {
+		if now.Sub(r.start) < span.start && span.count == 0 && span.errors == 0 {
+			break
+		}
+		duration := r.ran(span, now)
+		report := models.PhaseReport{
+			Phase:        span.name,
+			Start:        span.start,
+			Duration:     duration,
+			Transactions: span.count,
+			Errors:       span.errors,
+			Latency:      span.latencies.Summary(),
+		}
+		if seconds := duration.Seconds(); seconds > 0 {
+			report.TPS = float64(span.count) / seconds
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// PhasesOf returns the phase reports of runner, or nil if it does not
+// report phases
+func PhasesOf(runner BenchmarkRunner) []models.PhaseReport {
+	if r, ok := runner.(PhaseReporter); ok {
+		return r.Phases()
+	}
+	return nil
+}
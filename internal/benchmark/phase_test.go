@@ -0,0 +1,112 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseRecorderSchedule(t *testing.T) {
+	r := NewPhaseRecorder(models.PhaseConfig{
+		RampUp:   time.Second,
+		Warmup:   2 * time.Second,
+		CoolDown: time.Second,
+	}, 10*time.Second)
+	r.Start()
+	assert.Equal(t, 14*time.Second, r.Total())
+
+	start := r.MeasureStart().Add(-3 * time.Second)
+	for offset, phase := range map[time.Duration]string{
+		0:                       models.PhaseRampUp,
+		999 * time.Millisecond:  models.PhaseRampUp,
+		time.Second:             models.PhaseWarmup,
+		3 * time.Second:         models.PhaseMeasure,
+		12 * time.Second:        models.PhaseMeasure,
+		13 * time.Second:        models.PhaseCoolDown,
+		20 * time.Second:        models.PhaseCoolDown,
+		-100 * time.Millisecond: models.PhaseRampUp,
+	} {
+		assert.Equal(t, phase, r.PhaseAt(start.Add(offset)), "phase at %v", offset)
+	}
+	assert.Equal(t, 5*time.Second, r.Measured(start.Add(8*time.Second)))
+	assert.Equal(t, 10*time.Second, r.Measured(start.Add(time.Minute)))
+	assert.Equal(t, float64(50), r.Progress(start.Add(7*time.Second)))
+
+	// Without phases everything is measured
+	r = NewPhaseRecorder(models.PhaseConfig{}, time.Second)
+	r.Start()
+	assert.Equal(t, time.Second, r.Total())
+	assert.Equal(t, models.PhaseMeasure, r.PhaseAt(time.Now()))
+	assert.Zero(t, r.ThreadDelay(3, 4))
+}
+
+func TestPhaseRecorderThreadDelay(t *testing.T) {
+	linear := NewPhaseRecorder(models.PhaseConfig{RampUp: 800 * time.Millisecond}, time.Second)
+	stepped := NewPhaseRecorder(models.PhaseConfig{RampUp: 800 * time.Millisecond, RampSteps: 2}, time.Second)
+
+	var linearDelays, steppedDelays []time.Duration
+	for id := 0; id < 4; id++ {
+		linearDelays = append(linearDelays, linear.ThreadDelay(id, 4))
+		steppedDelays = append(steppedDelays, stepped.ThreadDelay(id, 4))
+	}
+	ms := time.Millisecond
+	assert.Equal(t, []time.Duration{0, 200 * ms, 400 * ms, 600 * ms}, linearDelays)
+	assert.Equal(t, []time.Duration{0, 0, 400 * ms, 400 * ms}, steppedDelays)
+
+	linear.Start()
+	begin := time.Now()
+	require.True(t, linear.WaitForThread(context.Background(), nil, 1, 4))
+	assert.GreaterOrEqual(t, time.Since(begin), 150*ms)
+
+	stop := make(chan struct{})
+	close(stop)
+	assert.False(t, linear.WaitForThread(context.Background(), stop, 3, 4))
+	assert.True(t, linear.WaitForThread(context.Background(), stop, 0, 4))
+}
+
+func TestPhaseRecorderRecord(t *testing.T) {
+	r := NewPhaseRecorder(models.PhaseConfig{Warmup: 50 * time.Millisecond}, 100*time.Millisecond)
+	assert.Nil(t, r.Phases())
+	r.Start()
+
+	phases := make(chan string, 2)
+	r.Watch(nil, func(phase string) { phases <- phase })
+	assert.Equal(t, models.PhaseWarmup, <-phases)
+
+	start := time.Now()
+	assert.False(t, r.RecordTransaction(start, 100*time.Millisecond))
+	assert.False(t, r.RecordError(start))
+
+	reports := r.Phases()
+	require.Len(t, reports, 1)
+	assert.Equal(t, models.PhaseWarmup, reports[0].Phase)
+
+	assert.Equal(t, models.PhaseMeasure, <-phases)
+	assert.Equal(t, models.PhaseMeasure, r.PhaseAt(time.Now()))
+
+	measured := r.MeasureStart()
+	assert.True(t, r.RecordTransaction(measured, time.Millisecond))
+	assert.True(t, r.RecordTransaction(measured.Add(10*time.Millisecond), 2*time.Millisecond))
+
+	reports = r.Phases()
+	require.Len(t, reports, 2)
+	warmup, measure := reports[0], reports[1]
+	assert.Equal(t, int64(1), warmup.Transactions)
+	assert.Equal(t, int64(1), warmup.Errors)
+	assert.Equal(t, 50*time.Millisecond, warmup.Duration)
+	assert.Equal(t, 100*time.Millisecond, warmup.Latency.Max)
+	assert.Equal(t, 50*time.Millisecond, measure.Start)
+	assert.Equal(t, int64(2), measure.Transactions)
+	assert.Equal(t, 2*time.Millisecond, measure.Latency.Max)
+
+	// A restart drops the statistics of the earlier run
+	r.Start()
+	reports = r.Phases()
+	require.Len(t, reports, 1)
+	assert.Zero(t, reports[0].Transactions)
+	assert.Nil(t, PhasesOf(nil))
+}
@@ -1,11 +1,100 @@
 package benchmark
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
+	"unicode"
 )
 
+// QueryConfig is the workload config of query benchmarks. Without Queries
+// the benchmark runs its query template.
+type QueryConfig struct {
+	QueryRate    int       `json:"query_rate,omitempty" description:"Target queries per second across all threads, 0 runs as fast as possible"`
+	Arrival      string    `json:"arrival,omitempty" description:"Distribution of query start times with a query rate: constant or poisson"`
+	Queries      []Query   `json:"queries,omitempty" description:"Named queries of a custom workload, run instead of the query template"`
+	Distribution string    `json:"distribution,omitempty" description:"Order the queries run in: random or weighted" enum:"random,weighted"`
+	QueryWeights []float64 `json:"query_weights,omitempty" description:"Weight of each query with the weighted distribution"`
+	Scan         string    `json:"scan,omitempty" description:"How the rows of reads are read: raw bytes, or typed values of the column types" enum:"raw,typed"`
+}
+
+// ParseQueryConfig parses and validates the config of a query benchmark.
+// An empty config runs as fast as possible.
+func ParseQueryConfig(data json.RawMessage) (*QueryConfig, error) {
+	config := &QueryConfig{}
+	if len(data) > 0 {
+		if err := UnmarshalConfig(data, config); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+
+	if config.QueryRate < 0 {
+		return nil, fmt.Errorf("query rate must not be negative")
+	}
+	if _, err := ParseArrival(config.Arrival); err != nil {
+		return nil, err
+	}
+	if _, err := ParseScanMode(config.Scan); err != nil {
+		return nil, err
+	}
+	if len(config.Queries) > 0 {
+		if err := validateQueries(config.Queries); err != nil {
+			return nil, err
+		}
+		if _, err := NewQueryExecutor(config.Queries, QueryDistributionType(config.Distribution), config.QueryWeights, nil); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// executor creates the QueryExecutor picking from queries for a worker
+// drawing from r. Without custom queries the query template is the only
+// query to pick.
+func (c *QueryConfig) executor(queries []*runnableQuery, r *rand.Rand) (*QueryExecutor, error) {
+	list := make([]Query, len(queries))
+	for i, q := range queries {
+		list[i] = q.Query
+	}
+	if len(c.Queries) == 0 {
+		return NewQueryExecutor(list, QueryDistributionRandom, nil, r)
+	}
+	return NewQueryExecutor(list, QueryDistributionType(c.Distribution), c.QueryWeights, r)
+}
+
+// validateQueries checks the queries of a custom workload and names the
+// unnamed ones query_1, query_2, ... by their position
+func validateQueries(queries []Query) error {
+	names := make(map[string]bool, len(queries))
+	for i := range queries {
+		q := &queries[i]
+		if q.Name == "" {
+			q.Name = fmt.Sprintf("query_%d", i+1)
+		}
+		if names[q.Name] {
+			return fmt.Errorf("duplicate query name: %s", q.Name)
+		}
+		names[q.Name] = true
+
+		if (q.SQL == "") == (len(q.Statements) == 0) {
+			return fmt.Errorf("query %s needs either sql or statements", q.Name)
+		}
+		for _, statement := range q.Statements {
+			if strings.TrimSpace(statement) == "" {
+				return fmt.Errorf("query %s has an empty statement", q.Name)
+			}
+		}
+		switch q.Type {
+		case "", QueryTypeRead, QueryTypeWrite:
+		default:
+			return fmt.Errorf("query %s has unknown type: %s", q.Name, q.Type)
+		}
+	}
+	return nil
+}
+
 // QueryDistributionType represents the type of query distribution
 type QueryDistributionType string
 
@@ -19,62 +108,171 @@ const (
 // QueryType represents the type of a query
 type QueryType string
 
-// Query represents a SQL query
+const (
+	// QueryTypeRead is a statement returning rows, which are read to the end
+	QueryTypeRead QueryType = "read"
+	// QueryTypeWrite is a statement that is executed without reading rows
+	QueryTypeWrite QueryType = "write"
+)
+
+// ScanMode is how the rows returned by reads are read
+type ScanMode string
+
+const (
+	// ScanRaw reads the column values as the bytes the driver received
+	ScanRaw ScanMode = "raw"
+	// ScanTyped scans the column values into values of their column types,
+	// paying for the conversions an application would
+	ScanTyped ScanMode = "typed"
+)
+
+// ParseScanMode validates a scan mode name. The empty name reads raw bytes.
+func ParseScanMode(s string) (ScanMode, error) {
+	switch m := ScanMode(s); m {
+	case "":
+		return ScanRaw, nil
+	case ScanRaw, ScanTyped:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unsupported scan mode: %s", s)
+	}
+}
+
+// readKeywords are the first words of statements that return rows
+var readKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"SHOW":     true,
+	"EXPLAIN":  true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"VALUES":   true,
+	"TABLE":    true,
+}
+
+// StatementType returns the type of a statement by its first word: SELECT,
+// SHOW and the like are reads, anything else is a write
+func StatementType(statement string) QueryType {
+	fields := strings.FieldsFunc(statement, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '('
+	})
+	if len(fields) > 0 && readKeywords[strings.ToUpper(fields[0])] {
+		return QueryTypeRead
+	}
+	return QueryTypeWrite
+}
+
+// Query is a named query of a custom workload. It is either a single SQL
+// statement or a list of Statements run together in one transaction.
 type Query struct {
-	SQL      string    `json:"sql"`
-	Type     QueryType `json:"type"`
-	Name     string    `json:"name"`
-	Prepared bool      `json:"prepared"`
+	Name       string    `json:"name,omitempty" description:"Name the query is reported under, query_N by default"`
+	SQL        string    `json:"sql,omitempty" description:"Statement to run, with {{...}} placeholders"`
+	Statements []string  `json:"statements,omitempty" description:"Statements run in one transaction, instead of sql"`
+	Type       QueryType `json:"type,omitempty" description:"Whether the statements return rows to read or are executed, by default told from their first word" enum:"read,write"`
+	Prepared   bool      `json:"prepared,omitempty" description:"Prepare the statements once instead of sending their text every time"`
 }
 
-// QueryExecutor executes queries according to a distribution strategy
+// StatementList returns the statements of the query
+func (q Query) StatementList() []string {
+	if len(q.Statements) > 0 {
+		return q.Statements
+	}
+	return []string{q.SQL}
+}
+
+// Transaction reports whether the statements of the query run in a transaction
+func (q Query) Transaction() bool {
+	return len(q.Statements) > 0
+}
+
+// TypeOf returns the type of one of the statements of the query
+func (q Query) TypeOf(statement string) QueryType {
+	if q.Type != "" {
+		return q.Type
+	}
+	return StatementType(statement)
+}
+
+// QueryExecutor picks the queries to run according to a distribution. It is
+// not safe for concurrent use, every worker needs an executor of its own.
 type QueryExecutor struct {
-	queries      []string
+	queries      []Query
 	distribution QueryDistributionType
 	weights      []float64
-	current      int
 	rnd          *rand.Rand
 }
 
-// NewQueryExecutor creates a new query executor
-func NewQueryExecutor(queries []string, distribution QueryDistributionType, weights []float64) (*QueryExecutor, error) {
+// NewQueryExecutor creates a new query executor drawing from r, or from a
+// source seeded with the time if r is nil. The weights of the weighted
+// distribution are relative to their sum.
+func NewQueryExecutor(queries []Query, distribution QueryDistributionType, weights []float64, r *rand.Rand) (*QueryExecutor, error) {
 	if len(queries) == 0 {
 		return nil, fmt.Errorf("no queries provided")
 	}
 
-	if distribution == QueryDistributionWeighted && (weights == nil || len(weights) != len(queries)) {
-		return nil, fmt.Errorf("weights must be provided for weighted distribution")
+	switch distribution {
+	case "", QueryDistributionRandom:
+		distribution = QueryDistributionRandom
+		weights = nil
+	case QueryDistributionWeighted:
+		if len(weights) != len(queries) {
+			return nil, fmt.Errorf("weights must be provided for weighted distribution")
+		}
+		var sum float64
+		for _, w := range weights {
+			if w < 0 {
+				return nil, fmt.Errorf("query weights must not be negative")
+			}
+			sum += w
+		}
+		if sum <= 0 {
+			return nil, fmt.Errorf("query weights must not all be zero")
+		}
+		normalized := make([]float64, len(weights))
+		for i, w := range weights {
+			normalized[i] = w / sum
+		}
+		weights = normalized
+	default:
+		return nil, fmt.Errorf("unknown query distribution: %s", distribution)
 	}
 
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
 	return &QueryExecutor{
 		queries:      queries,
 		distribution: distribution,
 		weights:      weights,
-		rnd:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		rnd:          r,
 	}, nil
 }
 
-// NextQuery returns the next query to execute
-func (e *QueryExecutor) NextQuery() string {
-	switch e.distribution {
-	case QueryDistributionRandom:
-		return e.queries[e.rnd.Intn(len(e.queries))]
+// Next returns the index of the next query to execute
+func (e *QueryExecutor) Next() int {
+	if e.distribution != QueryDistributionWeighted {
+		return e.rnd.Intn(len(e.queries))
+	}
 
-	case QueryDistributionWeighted:
-		// Select a query based on weights
-		r := e.rnd.Float64()
-		var sum float64
-		for i, w := range e.weights {
-			sum += w
-			if r <= sum {
-				return e.queries[i]
-			}
+	// Select a query based on weights
+	r := e.rnd.Float64()
+	var sum float64
+	for i, w := range e.weights {
+		sum += w
+		if r < sum {
+			return i
 		}
-		// Fallback to last query if we somehow got here
-		return e.queries[len(e.queries)-1]
-
-	default:
-		// Default to random
-		return e.queries[e.rnd.Intn(len(e.queries))]
 	}
+	// Rounding may leave the sum just below 1
+	for i := len(e.weights) - 1; i > 0; i-- {
+		if e.weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// NextQuery returns the next query to execute
+func (e *QueryExecutor) NextQuery() Query {
+	return e.queries[e.Next()]
 }
@@ -0,0 +1,303 @@
+package benchmark
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// templateQueryName is the name the query template of a benchmark without
+// custom queries is reported under
+const templateQueryName = "query"
+
+// runnableQuery is a query of the workload ready to run, with the
+// placeholders of its statements parsed and, if the query is prepared, its
+// statements prepared
+type runnableQuery struct {
+	Query
+	statements []*runnableStatement
+}
+
+// runnableStatement is a statement of a runnableQuery
+type runnableStatement struct {
+	template *QueryTemplate
+	read     bool
+	scan     ScanMode
+	stmt     *sql.Stmt // nil unless the query is prepared
+}
+
+// queryResult counts the rows a query returned or changed and the bytes of
+// the rows it read
+type queryResult struct {
+	rowsRead     int64
+	bytesRead    int64
+	rowsAffected int64
+}
+
+// rows returns the rows the query returned or affected
+func (r queryResult) rows() int64 {
+	return r.rowsRead + r.rowsAffected
+}
+
+// add adds the counts of other to r
+func (r *queryResult) add(other queryResult) {
+	r.rowsRead += other.rowsRead
+	r.bytesRead += other.bytesRead
+	r.rowsAffected += other.rowsAffected
+}
+
+// queryer runs statements on a database handle or in a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// workloadQueries returns the queries a benchmark runs: the custom queries of
+// its config or else its query template. The template is prepared, and its
+// rows are read if it is a read.
+func workloadQueries(config *models.Benchmark, queryConfig *QueryConfig) []Query {
+	if len(queryConfig.Queries) > 0 {
+		return queryConfig.Queries
+	}
+	return []Query{{
+		Name:     templateQueryName,
+		SQL:      config.QueryTemplate,
+		Prepared: true,
+	}}
+}
+
+// parseQueries parses the placeholders of the statements of queries, with
+// bind parameters numbered if numbered is set. The rows of reads are read
+// as scan says.
+func parseQueries(queries []Query, numbered bool, scan ScanMode) ([]*runnableQuery, error) {
+	parsed := make([]*runnableQuery, len(queries))
+	for i, q := range queries {
+		rq := &runnableQuery{Query: q}
+		for _, statement := range q.StatementList() {
+			template, err := ParseQueryTemplate(statement, numbered)
+			if err != nil {
+				return nil, fmt.Errorf("query %s: %w", q.Name, err)
+			}
+			rq.statements = append(rq.statements, &runnableStatement{
+				template: template,
+				read:     q.TypeOf(statement) == QueryTypeRead,
+				scan:     scan,
+			})
+		}
+		parsed[i] = rq
+	}
+	return parsed, nil
+}
+
+// prepareQueries loads the samples of the placeholders of queries from db and
+// prepares the statements of prepared queries. The statements prepared so far
+// are closed if it fails.
+func prepareQueries(ctx context.Context, db *sql.DB, queries []*runnableQuery) error {
+	for _, q := range queries {
+		for _, s := range q.statements {
+			if err := s.template.LoadSamples(ctx, db); err != nil {
+				closeQueries(queries)
+				return err
+			}
+			if !q.Prepared {
+				continue
+			}
+			stmt, err := db.PrepareContext(ctx, s.template.SQL)
+			if err != nil {
+				closeQueries(queries)
+				return fmt.Errorf("failed to prepare statement: %w", err)
+			}
+			s.stmt = stmt
+		}
+	}
+	return nil
+}
+
+// closeQueries closes the prepared statements of queries
+func closeQueries(queries []*runnableQuery) {
+	for _, q := range queries {
+		for _, s := range q.statements {
+			if s.stmt != nil {
+				s.stmt.Close()
+			}
+		}
+	}
+}
+
+// args returns functions producing the arguments of each statement of the
+// query, drawing from r
+func (q *runnableQuery) args(r *rand.Rand) []func() []interface{} {
+	args := make([]func() []interface{}, len(q.statements))
+	for i, s := range q.statements {
+		args[i] = s.template.Args(r)
+	}
+	return args
+}
+
+// run executes the query once, args holding the arguments of each statement,
+// and counts the rows its statements returned or affected. The statements
+// of a transaction are rolled back if one of them fails.
+func (q *runnableQuery) run(ctx context.Context, db *sql.DB, args [][]interface{}) (queryResult, error) {
+	if !q.Transaction() {
+		return q.statements[0].run(ctx, db, nil, args[0])
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return queryResult{}, err
+	}
+	var total queryResult
+	for i, s := range q.statements {
+		result, err := s.run(ctx, tx, tx, args[i])
+		if err != nil {
+			tx.Rollback()
+			return queryResult{}, err
+		}
+		total.add(result)
+	}
+	return total, tx.Commit()
+}
+
+// run executes the statement with args on db, which is tx within a
+// transaction, and counts the rows it returned or affected. The rows of
+// reads are read to the end.
+func (s *runnableStatement) run(ctx context.Context, db queryer, tx *sql.Tx, args []interface{}) (queryResult, error) {
+	stmt := s.stmt
+	if stmt != nil && tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+
+	if !s.read {
+		var result sql.Result
+		var err error
+		if stmt != nil {
+			result, err = stmt.ExecContext(ctx, args...)
+		} else {
+			result, err = db.ExecContext(ctx, s.template.SQL, args...)
+		}
+		if err != nil {
+			return queryResult{}, err
+		}
+		// Drivers that do not count affected rows report no rows
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return queryResult{}, nil
+		}
+		return queryResult{rowsAffected: affected}, nil
+	}
+
+	var rows *sql.Rows
+	var err error
+	if stmt != nil {
+		rows, err = stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = db.QueryContext(ctx, s.template.SQL, args...)
+	}
+	if err != nil {
+		return queryResult{}, err
+	}
+	defer rows.Close()
+	return readRows(rows, s.scan)
+}
+
+// readRows reads rows to the end, scanning every column as scan says, and
+// counts the rows and the bytes of their values. The scan destinations are
+// reused from row to row.
+func readRows(rows *sql.Rows, scan ScanMode) (queryResult, error) {
+	dest, err := scanDest(rows, scan)
+	if err != nil {
+		return queryResult{}, err
+	}
+
+	var result queryResult
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return result, err
+		}
+		result.rowsRead++
+		for _, d := range dest {
+			result.bytesRead += valueSize(reflect.ValueOf(d).Elem().Interface())
+		}
+	}
+	return result, rows.Err()
+}
+
+// scannerType is the type of sql.Scanner
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// scanDest returns a scan destination for every column of rows: raw bytes,
+// or a value of the scan type the driver reports for the column. Columns
+// that may be NULL are scanned into types that hold NULLs.
+func scanDest(rows *sql.Rows, scan ScanMode) ([]interface{}, error) {
+	if scan != ScanTyped {
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		dest := make([]interface{}, len(columns))
+		for i := range dest {
+			dest[i] = new(sql.RawBytes)
+		}
+		return dest, nil
+	}
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		t := column.ScanType()
+		nullable, known := column.Nullable()
+		switch {
+		case t == nil || t.Kind() == reflect.Interface:
+			dest[i] = new(interface{})
+		case reflect.PointerTo(t).Implements(scannerType) || t == reflect.TypeOf(sql.RawBytes{}):
+			dest[i] = reflect.New(t).Interface()
+		case !known || nullable:
+			dest[i] = new(interface{})
+		default:
+			dest[i] = reflect.New(t).Interface()
+		}
+	}
+	return dest, nil
+}
+
+// valueSize returns the size of a scanned column value in bytes: the length
+// of strings and byte slices and the size of fixed-size values, with times
+// counting as 8 bytes and NULLs as none
+func valueSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case sql.RawBytes:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case time.Time:
+		return 8
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return 0
+		}
+		return valueSize(value)
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return int64(rv.Type().Size())
+	default:
+		return 0
+	}
+}
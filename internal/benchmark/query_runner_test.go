@@ -0,0 +1,43 @@
+package benchmark
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := func() *sql.Rows {
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			mock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+			mock.NewColumn("score").OfType("DOUBLE", float64(0)),
+			mock.NewColumn("name").OfType("VARCHAR", "").Nullable(true),
+		).AddRow(int64(12345), 1.5, "alice").AddRow(int64(7), nil, nil))
+		rows, err := db.Query("SELECT id, score, name FROM t")
+		require.NoError(t, err)
+		return rows
+	}
+
+	// Raw bytes are the values as the driver sends them
+	result, err := readRows(query(), ScanRaw)
+	require.NoError(t, err)
+	assert.Equal(t, queryResult{rowsRead: 2, bytesRead: int64(len("12345") + len("1.5") + len("alice") + len("7"))}, result)
+
+	// Typed values count their size, NULLs nothing
+	result, err = readRows(query(), ScanTyped)
+	require.NoError(t, err)
+	assert.Equal(t, queryResult{rowsRead: 2, bytesRead: 8 + 8 + 5 + 8}, result)
+
+	assert.Equal(t, int64(0), valueSize(sql.NullInt64{}))
+	assert.Equal(t, int64(3), valueSize(sql.NullString{String: "bob", Valid: true}))
+	assert.Equal(t, int64(8), valueSize(time.Now()))
+	assert.Equal(t, int64(1), valueSize(true))
+}
@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryConfig(t *testing.T) {
+	config, err := ParseQueryConfig(json.RawMessage(`{
+		"queries": [
+			{"sql": "SELECT * FROM accounts WHERE id = {{uniform 1 100}}"},
+			{"name": "transfer", "statements": ["UPDATE accounts SET balance = balance - 1 WHERE id = 1", "UPDATE accounts SET balance = balance + 1 WHERE id = 2"]}
+		],
+		"distribution": "weighted",
+		"query_weights": [3, 1]
+	}`))
+	require.NoError(t, err)
+	require.Len(t, config.Queries, 2)
+	assert.Equal(t, "query_1", config.Queries[0].Name)
+	assert.False(t, config.Queries[0].Transaction())
+	assert.Equal(t, "transfer", config.Queries[1].Name)
+	assert.True(t, config.Queries[1].Transaction())
+
+	for name, data := range map[string]string{
+		"NoStatement":    `{"queries": [{"name": "empty"}]}`,
+		"SQLAndList":     `{"queries": [{"sql": "SELECT 1", "statements": ["SELECT 2"]}]}`,
+		"EmptyStatement": `{"queries": [{"statements": ["SELECT 1", " "]}]}`,
+		"DuplicateName":  `{"queries": [{"name": "a", "sql": "SELECT 1"}, {"name": "a", "sql": "SELECT 2"}]}`,
+		"UnknownType":    `{"queries": [{"sql": "SELECT 1", "type": "ddl"}]}`,
+		"MissingWeights": `{"queries": [{"sql": "SELECT 1"}, {"sql": "SELECT 2"}], "distribution": "weighted", "query_weights": [1]}`,
+		"ZeroWeights":    `{"queries": [{"sql": "SELECT 1"}], "distribution": "weighted", "query_weights": [0]}`,
+		"Distribution":   `{"queries": [{"sql": "SELECT 1"}], "distribution": "round_robin"}`,
+		"Scan":           `{"scan": "json"}`,
+	} {
+		_, err := ParseQueryConfig(json.RawMessage(data))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestStatementType(t *testing.T) {
+	for statement, want := range map[string]QueryType{
+		"SELECT 1":                             QueryTypeRead,
+		"  select * from t":                    QueryTypeRead,
+		"(SELECT 1) UNION (SELECT 2)":          QueryTypeRead,
+		"WITH x AS (SELECT 1) SELECT * FROM x": QueryTypeRead,
+		"SHOW TABLES":                          QueryTypeRead,
+		"UPDATE t SET a = 1":                   QueryTypeWrite,
+		"INSERT INTO t SELECT * FROM u":        QueryTypeWrite,
+		"DELETE FROM t WHERE id = {{uuid}}":    QueryTypeWrite,
+		"":                                     QueryTypeWrite,
+	} {
+		assert.Equal(t, want, StatementType(statement), statement)
+	}
+
+	q := Query{SQL: "SELECT pg_advisory_lock(1)", Type: QueryTypeWrite}
+	assert.Equal(t, QueryTypeWrite, q.TypeOf(q.SQL))
+}
+
+func TestQueryExecutor(t *testing.T) {
+	queries := []Query{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	_, err := NewQueryExecutor(nil, QueryDistributionRandom, nil, nil)
+	assert.Error(t, err)
+	_, err = NewQueryExecutor(queries, QueryDistributionWeighted, []float64{1, -1, 1}, nil)
+	assert.Error(t, err)
+
+	// Weights need not add up to one
+	e, err := NewQueryExecutor(queries, QueryDistributionWeighted, []float64{6, 0, 2}, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for i := 0; i < 8000; i++ {
+		counts[e.NextQuery().Name]++
+	}
+	assert.InDelta(t, 6000, counts["a"], 300)
+	assert.Zero(t, counts["b"])
+	assert.InDelta(t, 2000, counts["c"], 300)
+
+	e, err = NewQueryExecutor(queries, "", nil, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	counts = make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[e.NextQuery().Name]++
+	}
+	for _, q := range queries {
+		assert.InDelta(t, 1000, counts[q.Name], 150, q.Name)
+	}
+}
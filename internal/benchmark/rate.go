@@ -0,0 +1,110 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Arrival is the distribution of the start times of rate limited operations
+type Arrival string
+
+const (
+	// ArrivalConstant starts operations at a fixed interval
+	ArrivalConstant Arrival = "constant"
+	// ArrivalPoisson starts operations with exponentially distributed gaps,
+	// like independent clients would
+	ArrivalPoisson Arrival = "poisson"
+)
+
+// ParseArrival validates an arrival name. The empty name is constant.
+func ParseArrival(s string) (Arrival, error) {
+	switch a := Arrival(s); a {
+	case "":
+		return ArrivalConstant, nil
+	case ArrivalConstant, ArrivalPoisson:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unsupported arrival distribution: %s", s)
+	}
+}
+
+// RateLimiter schedules operations at a target rate shared by all workers of
+// a run, making the load open-loop: the schedule does not slow down when the
+// database does. An operation that cannot start on time because every worker
+// is busy starts late but keeps its scheduled start, and its latency is
+// measured from there, so queueing delay is not hidden (coordinated omission).
+// It is safe for concurrent use.
+type RateLimiter struct {
+	interval time.Duration
+	arrival  Arrival
+
+	mu   sync.Mutex
+	next time.Time
+	rnd  *rand.Rand
+}
+
+// NewRateLimiter creates a limiter starting rate operations per second
+func NewRateLimiter(rate float64, arrival Arrival) (*RateLimiter, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be greater than 0")
+	}
+	if _, err := ParseArrival(string(arrival)); err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	return &RateLimiter{
+		interval: interval,
+		arrival:  arrival,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Rate returns the target rate in operations per second
+func (l *RateLimiter) Rate() float64 {
+	return float64(time.Second) / float64(l.interval)
+}
+
+// Reserve returns the scheduled start of the next operation. The schedule
+// starts with the first call.
+func (l *RateLimiter) Reserve() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next.IsZero() {
+		l.next = time.Now()
+	}
+	start := l.next
+
+	gap := l.interval
+	if l.arrival == ArrivalPoisson {
+		gap = time.Duration(l.rnd.ExpFloat64() * float64(l.interval))
+	}
+	l.next = l.next.Add(gap)
+	return start
+}
+
+// Wait reserves the next operation and sleeps until its scheduled start,
+// which it returns. It returns false if ctx is done or stop is closed first.
+func (l *RateLimiter) Wait(ctx context.Context, stop <-chan struct{}) (time.Time, bool) {
+	start := l.Reserve()
+
+	// Behind schedule the operation starts right away
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return start, false
+	case <-stop:
+		return start, false
+	case <-timer.C:
+		return start, true
+	}
+}
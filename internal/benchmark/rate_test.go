@@ -0,0 +1,90 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArrival(t *testing.T) {
+	a, err := ParseArrival("")
+	require.NoError(t, err)
+	assert.Equal(t, ArrivalConstant, a)
+
+	a, err = ParseArrival("poisson")
+	require.NoError(t, err)
+	assert.Equal(t, ArrivalPoisson, a)
+
+	_, err = ParseArrival("bursty")
+	assert.Error(t, err)
+}
+
+func TestRateLimiterSchedule(t *testing.T) {
+	_, err := NewRateLimiter(0, ArrivalConstant)
+	assert.Error(t, err)
+	_, err = NewRateLimiter(10, "bursty")
+	assert.Error(t, err)
+
+	constant, err := NewRateLimiter(100, ArrivalConstant)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), constant.Rate())
+
+	prev := constant.Reserve()
+	for i := 0; i < 100; i++ {
+		next := constant.Reserve()
+		assert.Equal(t, 10*time.Millisecond, next.Sub(prev))
+		prev = next
+	}
+
+	// Poisson gaps vary but keep the mean rate
+	poisson, err := NewRateLimiter(1000, ArrivalPoisson)
+	require.NoError(t, err)
+	first := poisson.Reserve()
+	var last time.Time
+	distinct := make(map[time.Duration]bool)
+	prev = first
+	for i := 0; i < 10000; i++ {
+		last = poisson.Reserve()
+		distinct[last.Sub(prev)] = true
+		prev = last
+	}
+	assert.InDelta(t, time.Millisecond, last.Sub(first)/10000, float64(50*time.Microsecond))
+	assert.Greater(t, len(distinct), 1000)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l, err := NewRateLimiter(50, ArrivalConstant)
+	require.NoError(t, err)
+
+	start := time.Now()
+	var scheduled []time.Time
+	for i := 0; i < 5; i++ {
+		at, ok := l.Wait(context.Background(), nil)
+		require.True(t, ok)
+		scheduled = append(scheduled, at)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	assert.Equal(t, 80*time.Millisecond, scheduled[4].Sub(scheduled[0]))
+
+	// Falling behind does not shift the schedule
+	time.Sleep(100 * time.Millisecond)
+	at, ok := l.Wait(context.Background(), nil)
+	require.True(t, ok)
+	assert.Equal(t, scheduled[4].Add(20*time.Millisecond), at)
+
+	stop := make(chan struct{})
+	close(stop)
+	l, err = NewRateLimiter(1, ArrivalConstant)
+	require.NoError(t, err)
+	l.Reserve()
+	_, ok = l.Wait(context.Background(), stop)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = l.Wait(ctx, nil)
+	assert.False(t, ok)
+}
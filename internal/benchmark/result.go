@@ -0,0 +1,105 @@
+package benchmark
+
+import (
+	"time"
+)
+
+// NewResult builds a Result from the final status of a runner. Well known
+// metrics are lifted into the typed fields, everything else is kept in Metrics.
+func NewResult(name string, status BenchmarkStatus, startTime, endTime time.Time) *Result {
+	result := &Result{
+		Name:      name,
+		Duration:  endTime.Sub(startTime),
+		StartTime: startTime,
+		EndTime:   endTime,
+		Metrics:   make(map[string]interface{}),
+	}
+
+	for k, v := range status.Metrics {
+		switch k {
+		case "total_transactions":
+			result.TotalTransactions = toInt64(v)
+		case "tps":
+			result.TPS = toFloat64(v)
+		case "latency_min":
+			result.LatencyMin = toDuration(v)
+		case "latency_avg":
+			result.LatencyAvg = toDuration(v)
+		case "latency_p50":
+			result.LatencyP50 = toDuration(v)
+		case "latency_p90":
+			result.LatencyP90 = toDuration(v)
+		case "latency_p95":
+			result.LatencyP95 = toDuration(v)
+		case "latency_p99":
+			result.LatencyP99 = toDuration(v)
+		case "latency_p999":
+			result.LatencyP999 = toDuration(v)
+		case "latency_max":
+			result.LatencyMax = toDuration(v)
+		case "errors":
+			result.Errors = toInt64(v)
+		case "breakdown":
+			if breakdown, ok := v.(map[string]QueryStats); ok {
+				result.Breakdown = breakdown
+			}
+		case "latencies":
+			// Raw samples are too large for a result document
+		default:
+			result.Metrics[k] = v
+		}
+	}
+
+	if result.TPS == 0 && result.Duration > 0 {
+		result.TPS = float64(result.TotalTransactions) / result.Duration.Seconds()
+	}
+
+	return result
+}
+
+// toInt64 converts a numeric metric value to int64
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case time.Duration:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+// toFloat64 converts a numeric metric value to float64
+func toFloat64(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	default:
+		return 0
+	}
+}
+
+// toDuration converts a latency metric to a time.Duration. Plain numbers are
+// treated as seconds.
+func toDuration(v interface{}) time.Duration {
+	switch n := v.(type) {
+	case time.Duration:
+		return n
+	case float64:
+		return time.Duration(n * float64(time.Second))
+	case int64:
+		return time.Duration(n) * time.Second
+	case int:
+		return time.Duration(n) * time.Second
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,93 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewResult(t *testing.T) {
+	start := time.Now()
+	end := start.Add(10 * time.Second)
+
+	t.Run("TypedMetrics", func(t *testing.T) {
+		status := BenchmarkStatus{
+			Status: "completed",
+			Metrics: map[string]interface{}{
+				"total_transactions": int64(5000),
+				"tps":                float64(500),
+				"latency_avg":        2 * time.Millisecond,
+				"latency_p95":        5 * time.Millisecond,
+				"latency_p99":        9 * time.Millisecond,
+				"latency_p999":       12 * time.Millisecond,
+				"latency_max":        15 * time.Millisecond,
+				"errors":             int64(3),
+				"rows_read":          int64(42),
+				"breakdown":          map[string]QueryStats{"point_select": {Count: 4000, Rows: 4000}},
+			},
+		}
+
+		result := NewResult("sysbench", status, start, end)
+		assert.Equal(t, "sysbench", result.Name)
+		assert.Equal(t, 10*time.Second, result.Duration)
+		assert.Equal(t, int64(5000), result.TotalTransactions)
+		assert.Equal(t, float64(500), result.TPS)
+		assert.Equal(t, 2*time.Millisecond, result.LatencyAvg)
+		assert.Equal(t, 5*time.Millisecond, result.LatencyP95)
+		assert.Equal(t, 9*time.Millisecond, result.LatencyP99)
+		assert.Equal(t, 12*time.Millisecond, result.LatencyP999)
+		assert.Equal(t, 15*time.Millisecond, result.LatencyMax)
+		assert.Equal(t, int64(3), result.Errors)
+		assert.Equal(t, int64(42), result.Metrics["rows_read"])
+		assert.NotContains(t, result.Metrics, "tps")
+		assert.Equal(t, map[string]QueryStats{"point_select": {Count: 4000, Rows: 4000}}, result.Breakdown)
+		assert.NotContains(t, result.Metrics, "breakdown")
+	})
+
+	t.Run("FloatSeconds", func(t *testing.T) {
+		status := BenchmarkStatus{
+			Metrics: map[string]interface{}{
+				"total_transactions": float64(100),
+				"latency_avg":        float64(0.002),
+				"latencies":          []float64{0.001, 0.003},
+			},
+		}
+
+		result := NewResult("custom", status, start, end)
+		assert.Equal(t, int64(100), result.TotalTransactions)
+		assert.Equal(t, float64(10), result.TPS)
+		assert.Equal(t, 2*time.Millisecond, result.LatencyAvg)
+		assert.NotContains(t, result.Metrics, "latencies")
+	})
+}
+
+func TestNewResultQueryRunner(t *testing.T) {
+	conn := setupSQLiteConnection(t)
+	config := &models.Benchmark{
+		Name:          "Point Select",
+		QueryTemplate: "SELECT balance FROM accounts WHERE id = {{uniform 1 10}}",
+		NumThreads:    2,
+		Duration:      200 * time.Millisecond,
+	}
+	runner, err := queryFactory{}.Create(config, conn, zap.NewNop())
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, runner.Start())
+	<-runner.(*Benchmark).done
+	status := runner.Status()
+	require.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+
+	// The typed fields come from the runner's own metrics
+	result := NewResult(config.Name, status, start, time.Now())
+	assert.Greater(t, result.TotalTransactions, int64(0))
+	assert.Greater(t, result.TPS, float64(0))
+	assert.InDelta(t, float64(result.TotalTransactions)/config.Duration.Seconds(), result.TPS, result.TPS*0.2)
+	assert.Equal(t, result.TPS, result.Metrics["qps"])
+	assert.Equal(t, result.TotalTransactions, result.Metrics["rows_read"])
+	assert.Greater(t, result.LatencyP99, time.Duration(0))
+}
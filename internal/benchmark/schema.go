@@ -0,0 +1,230 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationPattern matches the durations time.ParseDuration accepts, without
+// a sign
+const durationPattern = `^(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+)$`
+
+// ConfigSchema builds a JSON Schema describing the JSON encoding of the
+// config struct defaults, using its field values as defaults. Fields may
+// carry a `description` tag and an `enum` tag listing allowed values
+// separated by commas.
+func ConfigSchema(defaults interface{}) json.RawMessage {
+	schema := schemaFor(reflect.ValueOf(defaults))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		// Schemas only hold strings, numbers, booleans and nested maps
+		panic(err)
+	}
+	return data
+}
+
+// schemaFor returns the schema of v's type with v as its default
+func schemaFor(v reflect.Value) map[string]interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return map[string]interface{}{}
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == durationType {
+		return map[string]interface{}{
+			"type":        "string",
+			"pattern":     durationPattern,
+			"default":     time.Duration(v.Int()).String(),
+			"description": "Duration such as 500ms or 1m30s",
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean", "default": v.Bool()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "default": v.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "default": v.Uint()}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number", "default": v.Float()}
+	case reflect.String:
+		return map[string]interface{}{"type": "string", "default": v.String()}
+	case reflect.Slice, reflect.Array:
+		items := schemaFor(reflect.Zero(v.Type().Elem()))
+		delete(items, "default")
+		return map[string]interface{}{"type": "array", "items": items}
+	case reflect.Map:
+		values := schemaFor(reflect.Zero(v.Type().Elem()))
+		delete(values, "default")
+		return map[string]interface{}{"type": "object", "additionalProperties": values}
+	case reflect.Struct:
+		return structSchema(v)
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the schema of a struct, following encoding/json's
+// field naming rules
+func structSchema(v reflect.Value) map[string]interface{} {
+	properties := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		prop := schemaFor(v.Field(i))
+		if description := field.Tag.Get("description"); description != "" {
+			if field.Type == durationType {
+				description += ", such as 500ms or 1m30s"
+			}
+			prop["description"] = description
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = prop
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// jsonName returns the name encoding/json gives an exported struct field,
+// and false if the field is left out
+func jsonName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name, true
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		return field.Name, true
+	}
+	return name, true
+}
+
+// UnmarshalConfig parses a JSON workload config into v like json.Unmarshal,
+// except that time.Duration fields take the duration strings the config
+// schema describes, such as "30s", as well as integer nanoseconds.
+func UnmarshalConfig(data []byte, v interface{}) error {
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		// Let encoding/json report the syntax error
+		return json.Unmarshal(data, v)
+	}
+
+	raw, err := parseDurations(raw, reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// parseDurations replaces the duration strings in raw, the decoded JSON of a
+// value of type t, by integer nanoseconds
+func parseDurations(raw interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return raw, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		return int64(d), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return raw, nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, tagged := field.Tag.Lookup("json"); field.Anonymous && !tagged {
+				// The fields of embedded structs are promoted to the outer object
+				if _, err := parseDurations(fields, field.Type); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			name, ok := jsonName(field)
+			if !ok {
+				continue
+			}
+			for key, value := range fields {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				parsed, err := parseDurations(value, field.Type)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", key, err)
+				}
+				fields[key] = parsed
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return raw, nil
+		}
+		for i, item := range items {
+			parsed, err := parseDurations(item, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			items[i] = parsed
+		}
+	case reflect.Map:
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return raw, nil
+		}
+		for key, value := range values {
+			parsed, err := parseDurations(value, t.Elem())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			values[key] = parsed
+		}
+	}
+	return raw, nil
+}
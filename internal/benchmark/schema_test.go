@@ -0,0 +1,123 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaTestConfig struct {
+	Mode    string        `json:"mode" description:"Run mode" enum:"fast,slow"`
+	Threads int           `json:"threads"`
+	Rate    float64       `json:"rate,omitempty"`
+	Enabled bool          `json:"enabled"`
+	Timeout time.Duration `json:"timeout" description:"Query timeout"`
+	Tables  []string      `json:"tables"`
+	Nested  struct {
+		Size uint `json:"size"`
+	} `json:"nested"`
+	Ignored  string `json:"-"`
+	internal int
+	Untagged string
+}
+
+func TestConfigSchema(t *testing.T) {
+	defaults := &schemaTestConfig{Mode: "fast", Threads: 4, Timeout: time.Second}
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(ConfigSchema(defaults), &schema))
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, false, schema["additionalProperties"])
+
+	props := schema["properties"].(map[string]interface{})
+	assert.ElementsMatch(t,
+		[]string{"mode", "threads", "rate", "enabled", "timeout", "tables", "nested", "Untagged"},
+		keys(props))
+
+	mode := props["mode"].(map[string]interface{})
+	assert.Equal(t, "string", mode["type"])
+	assert.Equal(t, "fast", mode["default"])
+	assert.Equal(t, "Run mode", mode["description"])
+	assert.Equal(t, []interface{}{"fast", "slow"}, mode["enum"])
+
+	assert.Equal(t, "integer", props["threads"].(map[string]interface{})["type"])
+	assert.Equal(t, float64(4), props["threads"].(map[string]interface{})["default"])
+	assert.Equal(t, "number", props["rate"].(map[string]interface{})["type"])
+	assert.Equal(t, "boolean", props["enabled"].(map[string]interface{})["type"])
+
+	timeout := props["timeout"].(map[string]interface{})
+	assert.Equal(t, "string", timeout["type"])
+	assert.Equal(t, "1s", timeout["default"])
+	assert.Equal(t, "Query timeout, such as 500ms or 1m30s", timeout["description"])
+	pattern := regexp.MustCompile(timeout["pattern"].(string))
+	for _, d := range []string{"0", "1s", "500ms", "1m30s", "1.5h", "250us"} {
+		assert.True(t, pattern.MatchString(d), d)
+	}
+	for _, d := range []string{"", "1", "-1s", "1 s", "fast"} {
+		assert.False(t, pattern.MatchString(d), d)
+	}
+
+	tables := props["tables"].(map[string]interface{})
+	assert.Equal(t, "array", tables["type"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, tables["items"])
+
+	nested := props["nested"].(map[string]interface{})
+	assert.Equal(t, "object", nested["type"])
+	assert.Contains(t, nested["properties"], "size")
+}
+
+func TestUnmarshalConfig(t *testing.T) {
+	type phases struct {
+		Warmup time.Duration `json:"warmup"`
+	}
+	type config struct {
+		Timeout  time.Duration            `json:"timeout"`
+		Backoff  *time.Duration           `json:"backoff"`
+		Steps    []time.Duration          `json:"steps"`
+		Limits   map[string]time.Duration `json:"limits"`
+		Name     string                   `json:"name"`
+		Threads  int64                    `json:"threads"`
+		Untagged time.Duration
+		phases
+	}
+
+	var c config
+	require.NoError(t, UnmarshalConfig([]byte(`{
+		"timeout": "1m30s",
+		"backoff": "10ms",
+		"steps": ["1s", 2000000000],
+		"limits": {"new-order": "5s"},
+		"name": "30s",
+		"threads": 9007199254740993,
+		"untagged": "2s",
+		"warmup": "5s"
+	}`), &c))
+	assert.Equal(t, 90*time.Second, c.Timeout)
+	assert.Equal(t, 10*time.Millisecond, *c.Backoff)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, c.Steps)
+	assert.Equal(t, map[string]time.Duration{"new-order": 5 * time.Second}, c.Limits)
+	assert.Equal(t, "30s", c.Name)
+	assert.Equal(t, int64(9007199254740993), c.Threads)
+	assert.Equal(t, 2*time.Second, c.Untagged)
+	assert.Equal(t, 5*time.Second, c.Warmup)
+
+	// Nanoseconds are still accepted
+	require.NoError(t, UnmarshalConfig([]byte(`{"timeout": 1000000}`), &c))
+	assert.Equal(t, time.Millisecond, c.Timeout)
+
+	assert.ErrorContains(t, UnmarshalConfig([]byte(`{"timeout": "soon"}`), &c), `timeout: invalid duration "soon"`)
+	assert.Error(t, UnmarshalConfig([]byte(`{"timeout": true}`), &c))
+	assert.Error(t, UnmarshalConfig([]byte(`{`), &c))
+}
+
+func keys(m map[string]interface{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
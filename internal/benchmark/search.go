@@ -0,0 +1,404 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"go.uber.org/zap"
+)
+
+// Objective is a service level objective the probes of a Search must meet,
+// such as "latency_p99 < 10ms"
+type Objective interface {
+	// Check reports whether result meets the objective, and if not why
+	Check(result *Result) (bool, string)
+	// String returns the objective as written
+	String() string
+}
+
+// SearchConfig sets the range a Search looks for the highest value of
+// Parameter meeting the objectives in
+type SearchConfig struct {
+	Parameter SweepParameter `json:"parameter"`
+	Low       int            `json:"low"`
+	High      int            `json:"high"`
+	// Precision is how close the answer gets to the highest passing value,
+	// 1% of High and at least 1 by default
+	Precision int `json:"precision,omitempty"`
+	// ProbeDuration shortens the probes, the confirmation run always lasts
+	// the benchmark duration
+	ProbeDuration time.Duration `json:"probe_duration,omitempty"`
+}
+
+// Validate validates the search config
+func (c SearchConfig) Validate() error {
+	switch c.Parameter {
+	case SweepThreads, SweepRate:
+	default:
+		return fmt.Errorf("unsupported search parameter: %s", c.Parameter)
+	}
+	if c.Low <= 0 || c.High < c.Low {
+		return fmt.Errorf("search needs a low and high with 0 < low <= high")
+	}
+	if c.Precision < 0 {
+		return fmt.Errorf("search precision must not be negative")
+	}
+	if c.ProbeDuration < 0 {
+		return fmt.Errorf("probe duration must not be negative")
+	}
+	return nil
+}
+
+// precision returns the configured precision or its default
+func (c SearchConfig) precision() int {
+	if c.Precision > 0 {
+		return c.Precision
+	}
+	if p := c.High / 100; p > 1 {
+		return p
+	}
+	return 1
+}
+
+// SearchReporter is implemented by runners that search for the highest
+// load meeting service level objectives
+type SearchReporter interface {
+	// SearchReport returns the probes run so far and the answer
+	SearchReport() *models.SearchReport
+}
+
+// SearchOf returns the search report of runner, or nil if it is not a search
+func SearchOf(runner BenchmarkRunner) *models.SearchReport {
+	if r, ok := runner.(SearchReporter); ok {
+		return r.SearchReport()
+	}
+	return nil
+}
+
+// Search binary-searches the threads or rate of a benchmark for the highest
+// value whose runs meet all objectives. It probes Low and High first, then
+// halves the range between the highest passing and lowest failing value
+// until it is within the precision. The answer is confirmed by a run for the
+// full benchmark duration. A probe that fails to run counts as not meeting
+// the objectives. Its final status carries the metrics of the confirmation.
+type Search struct {
+	steps      *stepRunner
+	search     SearchConfig
+	objectives []Objective
+	expected   int
+	logger     *zap.Logger
+
+	mu           sync.RWMutex
+	first        BenchmarkRunner
+	current      BenchmarkRunner
+	value        int
+	status       BenchmarkStatus
+	probes       []models.SearchProbe
+	answer       int
+	answerTPS    float64
+	confirmed    bool
+	confirmation *Result
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewSearch creates a search of the benchmark config. The runner of the first
+// probe is created right away, so an invalid config fails here.
+func NewSearch(factory Factory, config *models.Benchmark, conn *models.DBConnection, search SearchConfig, objectives []Objective, logger *zap.Logger) (*Search, error) {
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+	if len(objectives) == 0 {
+		return nil, fmt.Errorf("search needs at least one objective")
+	}
+	steps, err := newStepRunner(factory, config, conn, search.Parameter, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Low, High, the bisection and the confirmation run
+	expected := 3
+	if span := float64(search.High-search.Low) / float64(search.precision()); span > 1 {
+		expected += int(math.Ceil(math.Log2(span)))
+	}
+
+	s := &Search{
+		steps:      steps,
+		search:     search,
+		objectives: objectives,
+		expected:   expected,
+		logger:     steps.logger,
+		status: BenchmarkStatus{
+			Status:  string(models.BenchmarkStatusPending),
+			Metrics: make(map[string]interface{}),
+		},
+	}
+	if s.first, err = steps.create(search.Low, search.ProbeDuration); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Prepare prepares the workload with the runner of the first probe, all
+// probes share its data
+func (s *Search) Prepare(ctx context.Context) error {
+	s.mu.Lock()
+	if s.first == nil {
+		first, err := s.steps.create(s.search.Low, s.search.ProbeDuration)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.first = first
+	}
+	runner := s.first
+	s.mu.Unlock()
+
+	return prepare(ctx, runner)
+}
+
+// Start starts the search in the background
+func (s *Search) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.Status == string(models.BenchmarkStatusRunning) {
+		return fmt.Errorf("search is already running")
+	}
+	if s.first == nil {
+		// A restart runs every probe with a new runner
+		first, err := s.steps.create(s.search.Low, s.search.ProbeDuration)
+		if err != nil {
+			return err
+		}
+		s.first = first
+	}
+
+	s.probes = nil
+	s.answer, s.answerTPS, s.confirmed, s.confirmation = 0, 0, false, nil
+	s.status = BenchmarkStatus{
+		Status:  string(models.BenchmarkStatusRunning),
+		Metrics: make(map[string]interface{}),
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+	return nil
+}
+
+// Stop stops the running probe and waits for the search to end
+func (s *Search) Stop() {
+	s.mu.Lock()
+	if s.status.Status != string(models.BenchmarkStatusRunning) {
+		s.mu.Unlock()
+		return
+	}
+	if !isClosed(s.stop) {
+		close(s.stop)
+	}
+	done := s.done
+	s.mu.Unlock()
+
+	<-done
+}
+
+// Status returns the status of the running probe. Once finished the metrics
+// are those of the confirmation run.
+func (s *Search) Status() BenchmarkStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return s.status.Copy()
+	}
+
+	// The runner returns a copy, which is ours to add to
+	current := s.current.Status()
+	metrics := current.Metrics
+	if metrics == nil {
+		metrics = make(map[string]interface{}, 2)
+	}
+	metrics["search_probe"] = len(s.probes) + 1
+	metrics["search_value"] = s.value
+	progress := (float64(len(s.probes)) + current.Progress/100) / float64(s.expected) * 100
+	if progress > 99 {
+		// The bisection may take a probe more than expected
+		progress = 99
+	}
+	return BenchmarkStatus{
+		Status:   string(models.BenchmarkStatusRunning),
+		Progress: progress,
+		Metrics:  metrics,
+	}
+}
+
+// SearchReport returns the probes run so far and the answer
+func (s *Search) SearchReport() *models.SearchReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objectives := make([]string, 0, len(s.objectives))
+	for _, o := range s.objectives {
+		objectives = append(objectives, o.String())
+	}
+	return &models.SearchReport{
+		Parameter:  string(s.search.Parameter),
+		Objectives: objectives,
+		Probes:     append([]models.SearchProbe{}, s.probes...),
+		Value:      s.answer,
+		TPS:        s.answerTPS,
+		Confirmed:  s.confirmed,
+	}
+}
+
+// run searches for the highest passing value and confirms it
+func (s *Search) run(stop, done chan struct{}) {
+	defer close(done)
+
+	final := models.BenchmarkStatusCompleted
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.status.Status = string(final)
+		if final == models.BenchmarkStatusCompleted {
+			s.status.Progress = 100
+		}
+		if s.confirmation != nil {
+			s.status.Metrics = resultMetrics(s.confirmation)
+		}
+		s.status.Metrics["search_value"] = s.answer
+		s.status.Metrics["search_confirmed"] = s.confirmed
+		s.status.Metrics["search_probes"] = len(s.probes)
+	}()
+
+	// lo is the highest passing value, hi the lowest failing one
+	lo, hi := 0, 0
+	for _, value := range []int{s.search.Low, s.search.High} {
+		if value == lo {
+			continue
+		}
+		passed, ok := s.probe(value, false, stop)
+		if !ok {
+			final = models.BenchmarkStatusCancelled
+			return
+		}
+		if !passed {
+			hi = value
+			break
+		}
+		lo = value
+	}
+	if lo == 0 {
+		// Even the lowest value misses the objectives
+		return
+	}
+
+	for hi > 0 && hi-lo > s.search.precision() {
+		mid := lo + (hi-lo)/2
+		passed, ok := s.probe(mid, false, stop)
+		if !ok {
+			final = models.BenchmarkStatusCancelled
+			return
+		}
+		if passed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	if _, ok := s.probe(lo, true, stop); !ok {
+		final = models.BenchmarkStatusCancelled
+	}
+}
+
+// probe runs the benchmark with the parameter set to value and records
+// whether it met the objectives. It returns false for ok when the search
+// was stopped.
+func (s *Search) probe(value int, confirmation bool, stop <-chan struct{}) (passed, ok bool) {
+	if isClosed(stop) {
+		return false, false
+	}
+
+	duration := s.search.ProbeDuration
+	if confirmation {
+		duration = 0
+	}
+
+	s.mu.Lock()
+	runner := s.first
+	s.first = nil
+	s.mu.Unlock()
+
+	probe := models.SearchProbe{Confirmation: confirmation}
+	var result *Result
+	if runner == nil {
+		var err error
+		if runner, err = s.steps.create(value, duration); err != nil {
+			s.logger.Error("Search probe failed", zap.Int("value", value), zap.Error(err))
+			result = &Result{}
+			probe.SweepPoint = newSweepPoint(value, string(models.BenchmarkStatusFailed), result)
+		}
+	}
+
+	if result == nil {
+		s.mu.Lock()
+		s.current, s.value = runner, value
+		s.mu.Unlock()
+
+		var status string
+		result, status = s.steps.run(runner, stop)
+		probe.SweepPoint = newSweepPoint(value, status, result)
+		probe.Duration = result.Duration
+		if probe.Status == models.BenchmarkStatusCancelled {
+			s.mu.Lock()
+			s.current = nil
+			s.mu.Unlock()
+			return false, false
+		}
+	}
+
+	probe.Passed, probe.Reasons = s.check(probe.Status, result)
+	s.logger.Info("Search probe finished",
+		zap.Int("value", value),
+		zap.Bool("confirmation", confirmation),
+		zap.Bool("passed", probe.Passed),
+		zap.Float64("tps", result.TPS),
+		zap.Strings("reasons", probe.Reasons),
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = nil
+	s.probes = append(s.probes, probe)
+	if probe.Passed {
+		s.answer, s.answerTPS = value, result.TPS
+	}
+	if confirmation {
+		s.confirmed = probe.Passed
+		s.confirmation = result
+	}
+	return probe.Passed, true
+}
+
+// check evaluates the objectives against the result of a probe, returning
+// why it did not meet them
+func (s *Search) check(status models.BenchmarkStatus, result *Result) (bool, []string) {
+	if status != models.BenchmarkStatusCompleted {
+		return false, []string{fmt.Sprintf("run finished with status %s", status)}
+	}
+
+	var reasons []string
+	for _, o := range s.objectives {
+		if ok, reason := o.Check(result); !ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	return len(reasons) == 0, reasons
+}
@@ -0,0 +1,203 @@
+package benchmark
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxLatency is met by results with a mean latency up to its value
+type maxLatency time.Duration
+
+func (m maxLatency) Check(result *Result) (bool, string) {
+	if result.LatencyAvg > time.Duration(m) {
+		return false, fmt.Sprintf("latency_avg is %v", result.LatencyAvg)
+	}
+	return true, ""
+}
+
+func (m maxLatency) String() string {
+	return fmt.Sprintf("latency_avg <= %v", time.Duration(m))
+}
+
+func TestSearchConfigValidate(t *testing.T) {
+	assert.NoError(t, SearchConfig{Parameter: SweepRate, Low: 100, High: 100}.Validate())
+	assert.Equal(t, 1, SearchConfig{Low: 1, High: 64}.precision())
+	assert.Equal(t, 50, SearchConfig{Low: 100, High: 5000}.precision())
+	assert.Equal(t, 5, SearchConfig{Low: 1, High: 64, Precision: 5}.precision())
+
+	for name, c := range map[string]SearchConfig{
+		"UnknownParameter": {Parameter: "memory", Low: 1, High: 2},
+		"NoLow":            {Parameter: SweepThreads, High: 2},
+		"HighBelowLow":     {Parameter: SweepThreads, Low: 4, High: 2},
+		"Precision":        {Parameter: SweepThreads, Low: 1, High: 2, Precision: -1},
+		"ProbeDuration":    {Parameter: SweepThreads, Low: 1, High: 2, ProbeDuration: -time.Second},
+	} {
+		assert.Error(t, c.Validate(), name)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	sweepPollInterval = time.Millisecond
+	config := &models.Benchmark{Name: "curve", NumThreads: 1, Duration: 10 * time.Millisecond}
+	search := SearchConfig{Parameter: SweepThreads, Low: 1, High: 64, ProbeDuration: 2 * time.Millisecond}
+
+	wait := func(t *testing.T, s *Search) BenchmarkStatus {
+		require.Eventually(t, func() bool {
+			return models.BenchmarkStatus(s.Status().Status).IsFinished()
+		}, 5*time.Second, time.Millisecond)
+		return s.Status()
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		factory := &curveFactory{}
+		s, err := NewSearch(factory, config, nil, search, []Objective{maxLatency(1500 * time.Microsecond)}, nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+
+		status := wait(t, s)
+		assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+		assert.Equal(t, float64(100), status.Progress)
+		assert.Equal(t, 12, status.Metrics["search_value"])
+		assert.Equal(t, true, status.Metrics["search_confirmed"])
+
+		report := SearchOf(s)
+		require.NotNil(t, report)
+		assert.Equal(t, "threads", report.Parameter)
+		assert.Equal(t, []string{"latency_avg <= 1.5ms"}, report.Objectives)
+		assert.Equal(t, 12, report.Value)
+		assert.Equal(t, float64(800), report.TPS)
+		assert.True(t, report.Confirmed)
+
+		var values []int
+		for _, p := range report.Probes {
+			values = append(values, p.Value)
+		}
+		assert.Equal(t, []int{1, 64, 32, 16, 8, 12, 14, 13, 12}, values)
+		assert.False(t, report.Probes[1].Passed)
+		assert.Equal(t, []string{"latency_avg is 8ms"}, report.Probes[1].Reasons)
+
+		// Probes are short, the confirmation runs for the benchmark duration
+		last := report.Probes[len(report.Probes)-1]
+		assert.True(t, last.Confirmation)
+		assert.True(t, last.Passed)
+		for i, c := range factory.configs {
+			want := search.ProbeDuration
+			if i == len(factory.configs)-1 {
+				want = config.Duration
+			}
+			assert.Equal(t, want, c.Duration)
+		}
+
+		// The headline result is the confirmation run
+		result := NewResult("curve", status, time.Now().Add(-time.Second), time.Now())
+		assert.Equal(t, float64(800), result.TPS)
+		assert.Equal(t, 1500*time.Microsecond, result.LatencyAvg)
+	})
+
+	t.Run("HighPasses", func(t *testing.T) {
+		s, err := NewSearch(&curveFactory{}, config, nil, SearchConfig{Parameter: SweepThreads, Low: 2, High: 4}, []Objective{maxLatency(time.Second)}, nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+		wait(t, s)
+
+		report := s.SearchReport()
+		assert.Len(t, report.Probes, 3)
+		assert.Equal(t, 4, report.Value)
+		assert.True(t, report.Confirmed)
+	})
+
+	t.Run("StatusWhileRunning", func(t *testing.T) {
+		b, db, mock := setupTestBenchmark(t)
+		db.SetMaxOpenConns(1)
+		mock.MatchExpectationsInOrder(false)
+		for run := 0; run < 3; run++ {
+			mock.ExpectPrepare("SELECT 1").WillBeClosed()
+		}
+		for i := 0; i < 200; i++ {
+			mock.ExpectQuery("SELECT 1").WillDelayFor(2 * time.Millisecond).WillReturnRows(oneRow())
+		}
+		probeConfig := *b.config
+		probeConfig.Duration = 50 * time.Millisecond
+		s, err := NewSearch(queryFactory{}, &probeConfig, b.connection,
+			SearchConfig{Parameter: SweepThreads, Low: 1, High: 2, ProbeDuration: 20 * time.Millisecond},
+			[]Objective{maxLatency(time.Second)}, nil)
+		require.NoError(t, err)
+
+		// Polling the status reads the metrics of the running probe while
+		// its workers update them, which -race checks
+		require.NoError(t, s.Start())
+		for !models.BenchmarkStatus(s.Status().Status).IsFinished() {
+			status := s.Status()
+			for k, v := range status.Metrics {
+				status.Metrics[k] = v
+			}
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, string(models.BenchmarkStatusCompleted), s.Status().Status)
+		assert.True(t, s.SearchReport().Confirmed)
+	})
+
+	t.Run("QueryRunner", func(t *testing.T) {
+		probeConfig := models.Benchmark{
+			Name:          "Point Select",
+			QueryTemplate: "SELECT balance FROM accounts WHERE id = {{uniform 1 10}}",
+			Duration:      50 * time.Millisecond,
+		}
+		s, err := NewSearch(queryFactory{}, &probeConfig, setupSQLiteConnection(t),
+			SearchConfig{Parameter: SweepThreads, Low: 1, High: 2, ProbeDuration: 20 * time.Millisecond},
+			[]Objective{maxLatency(time.Second)}, nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+		wait(t, s)
+
+		// The answer carries the throughput of the confirmation run
+		report := s.SearchReport()
+		assert.True(t, report.Confirmed)
+		assert.Greater(t, report.TPS, float64(0))
+		for _, p := range report.Probes {
+			assert.Greater(t, p.TPS, float64(0))
+		}
+	})
+
+	t.Run("NothingPasses", func(t *testing.T) {
+		s, err := NewSearch(&curveFactory{}, config, nil, search, []Objective{maxLatency(time.Microsecond)}, nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+
+		status := wait(t, s)
+		assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+		report := s.SearchReport()
+		assert.Len(t, report.Probes, 1)
+		assert.Zero(t, report.Value)
+		assert.False(t, report.Confirmed)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		slow := search
+		slow.ProbeDuration = time.Hour
+		s, err := NewSearch(&curveFactory{}, config, nil, slow, []Objective{maxLatency(time.Second)}, nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Start())
+		require.Eventually(t, func() bool {
+			return s.Status().Metrics["search_probe"] == 1
+		}, time.Second, time.Millisecond)
+		s.Stop()
+
+		assert.Equal(t, string(models.BenchmarkStatusCancelled), s.Status().Status)
+		assert.Empty(t, s.SearchReport().Probes)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewSearch(&curveFactory{}, config, nil, search, nil, nil)
+		assert.Error(t, err)
+		_, err = NewSearch(&curveFactory{}, config, nil, SearchConfig{Parameter: SweepThreads}, []Objective{maxLatency(1)}, nil)
+		assert.Error(t, err)
+		_, err = NewSearch(struct{ Factory }{&curveFactory{}}, config, nil, SearchConfig{Parameter: SweepRate, Low: 1, High: 2}, []Objective{maxLatency(1)}, nil)
+		assert.ErrorContains(t, err, "does not support a query rate")
+	})
+}
@@ -0,0 +1,522 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"go.uber.org/zap"
+)
+
+// SweepParameter is the benchmark setting varied by a sweep
+type SweepParameter string
+
+const (
+	// SweepThreads varies the number of threads, or terminals for tpcc
+	SweepThreads SweepParameter = "threads"
+	// SweepRate varies the query_rate of workloads that accept one
+	SweepRate SweepParameter = "rate"
+)
+
+// maxSweepSteps bounds the number of runs of a sweep
+const maxSweepSteps = 100
+
+// sweepPollInterval is how often the runner of a sweep step is checked
+var sweepPollInterval = 100 * time.Millisecond
+
+// SweepConfig sets the values a sweep runs the benchmark with: the listed
+// Values, or a geometric series from Start growing by Factor up to End
+type SweepConfig struct {
+	Parameter SweepParameter `json:"parameter"`
+	Values    []int          `json:"values,omitempty"`
+	Start     int            `json:"start,omitempty"`
+	End       int            `json:"end,omitempty"`
+	Factor    float64        `json:"factor,omitempty"`
+}
+
+// Steps validates the config and returns the values of the parameter in the
+// order they are run. The series always ends with End, Factor defaults to 2.
+func (c SweepConfig) Steps() ([]int, error) {
+	switch c.Parameter {
+	case SweepThreads, SweepRate:
+	default:
+		return nil, fmt.Errorf("unsupported sweep parameter: %s", c.Parameter)
+	}
+
+	if len(c.Values) > 0 {
+		for i, v := range c.Values {
+			if v <= 0 {
+				return nil, fmt.Errorf("sweep values must be greater than 0")
+			}
+			if i > 0 && v <= c.Values[i-1] {
+				return nil, fmt.Errorf("sweep values must be increasing")
+			}
+		}
+		if len(c.Values) > maxSweepSteps {
+			return nil, fmt.Errorf("sweep has more than %d steps", maxSweepSteps)
+		}
+		return append([]int(nil), c.Values...), nil
+	}
+
+	if c.Start <= 0 || c.End < c.Start {
+		return nil, fmt.Errorf("sweep needs values, or a start and end with 0 < start <= end")
+	}
+	factor := c.Factor
+	if factor == 0 {
+		factor = 2
+	}
+	if factor <= 1 {
+		return nil, fmt.Errorf("sweep factor must be greater than 1")
+	}
+
+	var steps []int
+	for v := float64(c.Start); ; v *= factor {
+		n := int(math.Round(v))
+		if len(steps) > 0 && n <= steps[len(steps)-1] {
+			n = steps[len(steps)-1] + 1
+		}
+		if n >= c.End {
+			break
+		}
+		steps = append(steps, n)
+		if len(steps) >= maxSweepSteps {
+			return nil, fmt.Errorf("sweep has more than %d steps", maxSweepSteps)
+		}
+	}
+	return append(steps, c.End), nil
+}
+
+// SweepReporter is implemented by runners that run a benchmark at several
+// settings of a parameter
+type SweepReporter interface {
+	// SweepReport returns the curve of the steps finished so far
+	SweepReport() *models.SweepReport
+}
+
+// SweepOf returns the sweep report of runner, or nil if it is not a sweep
+func SweepOf(runner BenchmarkRunner) *models.SweepReport {
+	if r, ok := runner.(SweepReporter); ok {
+		return r.SweepReport()
+	}
+	return nil
+}
+
+// Sweep runs a benchmark once for every step of a SweepConfig, each with a
+// new runner from the factory, to find where throughput stops growing and
+// latency takes off. A step that fails ends the sweep. Its final status
+// carries the metrics of the knee step.
+type Sweep struct {
+	steps  *stepRunner
+	sweep  SweepConfig
+	values []int
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	first   BenchmarkRunner
+	current BenchmarkRunner
+	step    int
+	status  BenchmarkStatus
+	results []*Result
+	points  []models.SweepPoint
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSweep creates a sweep of the benchmark config. The runner of the first
+// step is created right away, so an invalid config fails here.
+func NewSweep(factory Factory, config *models.Benchmark, conn *models.DBConnection, sweep SweepConfig, logger *zap.Logger) (*Sweep, error) {
+	values, err := sweep.Steps()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := newStepRunner(factory, config, conn, sweep.Parameter, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sweep{
+		steps:  steps,
+		sweep:  sweep,
+		values: values,
+		logger: steps.logger,
+		status: BenchmarkStatus{
+			Status:  string(models.BenchmarkStatusPending),
+			Metrics: make(map[string]interface{}),
+		},
+	}
+	if s.first, err = steps.create(values[0], 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// stepRunner creates and runs the runners of a sweep or search, one for
+// each value of the varied parameter
+type stepRunner struct {
+	factory   Factory
+	config    *models.Benchmark
+	conn      *models.DBConnection
+	parameter SweepParameter
+	logger    *zap.Logger
+}
+
+// newStepRunner creates a stepRunner varying parameter of config
+func newStepRunner(factory Factory, config *models.Benchmark, conn *models.DBConnection, parameter SweepParameter, logger *zap.Logger) (*stepRunner, error) {
+	if factory == nil {
+		return nil, fmt.Errorf("factory is required")
+	}
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+	switch parameter {
+	case SweepThreads:
+	case SweepRate:
+		if !acceptsQueryRate(factory) {
+			return nil, fmt.Errorf("workload %s does not support a query rate", factory.Name())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sweep parameter: %s", parameter)
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &stepRunner{
+		factory:   factory,
+		config:    config,
+		conn:      conn,
+		parameter: parameter,
+		logger:    logger,
+	}, nil
+}
+
+// acceptsQueryRate reports whether the workload config of factory has a
+// query_rate setting
+func acceptsQueryRate(factory Factory) bool {
+	schemer, ok := factory.(ConfigSchemer)
+	if !ok {
+		return false
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(schemer.ConfigSchema(), &schema); err != nil {
+		return false
+	}
+	_, ok = schema.Properties["query_rate"]
+	return ok
+}
+
+// create creates a runner with the parameter set to value. A duration other
+// than 0 replaces the duration of the benchmark.
+func (s *stepRunner) create(value int, duration time.Duration) (BenchmarkRunner, error) {
+	config := *s.config
+	if duration > 0 {
+		config.Duration = duration
+	}
+	switch s.parameter {
+	case SweepThreads:
+		config.NumThreads = value
+	case SweepRate:
+		rated, err := withQueryRate(config.Config, value)
+		if err != nil {
+			return nil, err
+		}
+		config.Config = rated
+	}
+
+	runner, err := s.factory.Create(&config, s.conn, s.logger.With(zap.Int("sweep_"+string(s.parameter), value)))
+	if err != nil {
+		return nil, fmt.Errorf("create %s benchmark: %w", s.factory.Name(), err)
+	}
+	return runner, nil
+}
+
+// withQueryRate returns the workload config with its query_rate set to rate
+func withQueryRate(config json.RawMessage, rate int) (json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &fields); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+	fields["query_rate"] = json.RawMessage(fmt.Sprint(rate))
+	return json.Marshal(fields)
+}
+
+// Prepare prepares the workload with the runner of the first step, all
+// steps share its data
+func (s *Sweep) Prepare(ctx context.Context) error {
+	s.mu.Lock()
+	if s.first == nil {
+		first, err := s.steps.create(s.values[0], 0)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.first = first
+	}
+	runner := s.first
+	s.mu.Unlock()
+
+	return prepare(ctx, runner)
+}
+
+// prepare prepares the workload of runner
+func prepare(ctx context.Context, runner BenchmarkRunner) error {
+	preparer, ok := runner.(Preparer)
+	if !ok {
+		return fmt.Errorf("workload does not support prepare")
+	}
+	return preparer.Prepare(ctx)
+}
+
+// Start starts running the steps in the background
+func (s *Sweep) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.Status == string(models.BenchmarkStatusRunning) {
+		return fmt.Errorf("sweep is already running")
+	}
+	if s.first == nil {
+		// A restart runs every step with a new runner
+		first, err := s.steps.create(s.values[0], 0)
+		if err != nil {
+			return err
+		}
+		s.first = first
+	}
+
+	s.step = 0
+	s.results = nil
+	s.points = nil
+	s.status = BenchmarkStatus{
+		Status:  string(models.BenchmarkStatusRunning),
+		Metrics: make(map[string]interface{}),
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+	return nil
+}
+
+// Stop stops the running step and waits for the sweep to end
+func (s *Sweep) Stop() {
+	s.mu.Lock()
+	if s.status.Status != string(models.BenchmarkStatusRunning) {
+		s.mu.Unlock()
+		return
+	}
+	if !isClosed(s.stop) {
+		close(s.stop)
+	}
+	done := s.done
+	s.mu.Unlock()
+
+	<-done
+}
+
+// Status returns the status of the running step, with the progress over
+// all steps. Once finished the metrics are those of the knee step.
+func (s *Sweep) Status() BenchmarkStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return s.status.Copy()
+	}
+
+	// The runner returns a copy, which is ours to add to
+	current := s.current.Status()
+	metrics := current.Metrics
+	if metrics == nil {
+		metrics = make(map[string]interface{}, 2)
+	}
+	metrics["sweep_step"] = s.step + 1
+	metrics["sweep_value"] = s.values[s.step]
+	return BenchmarkStatus{
+		Status:   string(models.BenchmarkStatusRunning),
+		Progress: (float64(s.step) + current.Progress/100) / float64(len(s.values)) * 100,
+		Metrics:  metrics,
+	}
+}
+
+// Results returns the results of the steps finished so far
+func (s *Sweep) Results() []*Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Result(nil), s.results...)
+}
+
+// SweepReport returns the curve of the steps finished so far
+func (s *Sweep) SweepReport() *models.SweepReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := append([]models.SweepPoint{}, s.points...)
+	return &models.SweepReport{
+		Parameter: string(s.sweep.Parameter),
+		Points:    points,
+		Knee:      FindKnee(points),
+	}
+}
+
+// run runs the steps one after another until all finished, one failed or
+// stop is closed
+func (s *Sweep) run(stop, done chan struct{}) {
+	defer close(done)
+
+	final := models.BenchmarkStatusCompleted
+	for i, value := range s.values {
+		if isClosed(stop) {
+			final = models.BenchmarkStatusCancelled
+			break
+		}
+
+		s.mu.Lock()
+		runner := s.first
+		s.first = nil
+		s.mu.Unlock()
+
+		if runner == nil {
+			var err error
+			if runner, err = s.steps.create(value, 0); err != nil {
+				s.logger.Error("Sweep step failed", zap.Int("value", value), zap.Error(err))
+				final = models.BenchmarkStatusFailed
+				break
+			}
+		}
+
+		s.mu.Lock()
+		s.current, s.step = runner, i
+		s.mu.Unlock()
+
+		result, status := s.steps.run(runner, stop)
+		point := newSweepPoint(value, status, result)
+		s.logger.Info("Sweep step finished",
+			zap.Int("value", value),
+			zap.String("status", status),
+			zap.Float64("tps", result.TPS),
+			zap.Duration("latency_p95", result.LatencyP95),
+		)
+
+		s.mu.Lock()
+		s.current = nil
+		s.results = append(s.results, result)
+		s.points = append(s.points, point)
+		s.status.Progress = float64(i+1) / float64(len(s.values)) * 100
+		s.mu.Unlock()
+
+		if point.Status != models.BenchmarkStatusCompleted {
+			final = point.Status
+			break
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Status = string(final)
+	if knee := FindKnee(s.points); knee >= 0 {
+		s.status.Metrics = resultMetrics(s.results[knee])
+		s.status.Metrics["sweep_value"] = s.points[knee].Value
+	}
+	s.status.Metrics["sweep_steps"] = len(s.points)
+}
+
+// isClosed reports whether ch is closed
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// newSweepPoint summarizes the result of the run with the parameter set to value
+func newSweepPoint(value int, status string, result *Result) models.SweepPoint {
+	return models.SweepPoint{
+		Value:      value,
+		Status:     models.BenchmarkStatus(status),
+		TPS:        result.TPS,
+		LatencyAvg: result.LatencyAvg,
+		LatencyP95: result.LatencyP95,
+		LatencyP99: result.LatencyP99,
+		Errors:     result.Errors,
+	}
+}
+
+// run starts runner and polls it until it finished, stopping it when stop
+// is closed
+func (s *stepRunner) run(runner BenchmarkRunner, stop <-chan struct{}) (*Result, string) {
+	startTime := time.Now()
+	if err := runner.Start(); err != nil {
+		s.logger.Error("Sweep step failed to start", zap.Error(err))
+		return NewResult(s.config.Name, runner.Status(), startTime, time.Now()), string(models.BenchmarkStatusFailed)
+	}
+
+	ticker := time.NewTicker(sweepPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			runner.Stop()
+			stop = nil
+		case <-ticker.C:
+		}
+
+		status := runner.Status()
+		if models.BenchmarkStatus(status.Status).IsFinished() {
+			result := NewResult(s.config.Name, status, startTime, time.Now())
+			result.Intervals = IntervalsOf(runner)
+			result.Phases = PhasesOf(runner)
+			return result, status.Status
+		}
+	}
+}
+
+// resultMetrics returns the well known metrics of result, which NewResult
+// lifts back into a Result
+func resultMetrics(result *Result) map[string]interface{} {
+	metrics := map[string]interface{}{
+		"total_transactions": result.TotalTransactions,
+		"tps":                result.TPS,
+		"latency_min":        result.LatencyMin,
+		"latency_avg":        result.LatencyAvg,
+		"latency_p50":        result.LatencyP50,
+		"latency_p90":        result.LatencyP90,
+		"latency_p95":        result.LatencyP95,
+		"latency_p99":        result.LatencyP99,
+		"latency_p999":       result.LatencyP999,
+		"latency_max":        result.LatencyMax,
+		"errors":             result.Errors,
+	}
+	for k, v := range result.Metrics {
+		metrics[k] = v
+	}
+	return metrics
+}
+
+// FindKnee returns the index of the knee of a sweep curve, the completed
+// point with the highest power: throughput divided by mean latency. Below
+// the knee more load raises throughput more than latency, beyond it the
+// workload saturates and more load mostly adds queueing delay. It returns -1
+// if no point completed with a throughput and latency.
+func FindKnee(points []models.SweepPoint) int {
+	knee, best := -1, 0.0
+	for i, p := range points {
+		if p.Status != models.BenchmarkStatusCompleted || p.TPS <= 0 || p.LatencyAvg <= 0 {
+			continue
+		}
+		if power := p.TPS / p.LatencyAvg.Seconds(); power > best {
+			knee, best = i, power
+		}
+	}
+	return knee
+}
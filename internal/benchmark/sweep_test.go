@@ -0,0 +1,293 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// curveRunner completes after its duration with the throughput of a system
+// that saturates at 8 threads or 800 queries per second
+type curveRunner struct {
+	load     int
+	duration time.Duration
+	mu       sync.Mutex
+	status   BenchmarkStatus
+	stop     chan struct{}
+}
+
+func (r *curveRunner) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Status = string(models.BenchmarkStatusRunning)
+	r.stop = make(chan struct{})
+	go func() {
+		select {
+		case <-time.After(r.duration):
+		case <-r.stop:
+			r.mu.Lock()
+			r.status.Status = string(models.BenchmarkStatusCancelled)
+			r.mu.Unlock()
+			return
+		}
+
+		served := r.load
+		if served > 8 {
+			served = 8
+		}
+		r.mu.Lock()
+		r.status = BenchmarkStatus{
+			Status:   string(models.BenchmarkStatusCompleted),
+			Progress: 100,
+			Metrics: map[string]interface{}{
+				"total_transactions": int64(served * 10),
+				"tps":                float64(served * 100),
+				"latency_avg":        time.Duration(r.load) * time.Millisecond / time.Duration(served),
+			},
+		}
+		r.mu.Unlock()
+	}()
+	return nil
+}
+
+func (r *curveRunner) Stop() {
+	close(r.stop)
+}
+
+func (r *curveRunner) Status() BenchmarkStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status.Copy()
+}
+
+// curveFactory creates curveRunners loaded with the threads of the
+// benchmark, or its query rate in hundreds when set
+type curveFactory struct {
+	mu      sync.Mutex
+	configs []models.Benchmark
+}
+
+func (f *curveFactory) Name() string { return "curve" }
+
+func (f *curveFactory) ConfigSchema() json.RawMessage { return ConfigSchema(QueryConfig{}) }
+
+func (f *curveFactory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (BenchmarkRunner, error) {
+	query, err := ParseQueryConfig(config.Config)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.configs = append(f.configs, *config)
+	f.mu.Unlock()
+
+	load := config.NumThreads
+	if query.QueryRate > 0 {
+		load = query.QueryRate / 100
+	}
+	return &curveRunner{
+		load:     load,
+		duration: config.Duration,
+		status:   BenchmarkStatus{Status: string(models.BenchmarkStatusPending)},
+	}, nil
+}
+
+func TestSweepConfigSteps(t *testing.T) {
+	steps, err := SweepConfig{Parameter: SweepThreads, Start: 1, End: 20}.Steps()
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4, 8, 16, 20}, steps)
+
+	steps, err = SweepConfig{Parameter: SweepRate, Start: 100, End: 400, Factor: 1.5}.Steps()
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 150, 225, 338, 400}, steps)
+
+	steps, err = SweepConfig{Parameter: SweepThreads, Start: 1, End: 4, Factor: 1.1}.Steps()
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, steps)
+
+	steps, err = SweepConfig{Parameter: SweepThreads, Values: []int{1, 3, 5}}.Steps()
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 5}, steps)
+
+	for name, c := range map[string]SweepConfig{
+		"UnknownParameter": {Parameter: "memory", Values: []int{1}},
+		"ZeroValue":        {Parameter: SweepThreads, Values: []int{0, 1}},
+		"Decreasing":       {Parameter: SweepThreads, Values: []int{4, 2}},
+		"NoRange":          {Parameter: SweepThreads},
+		"EndBeforeStart":   {Parameter: SweepThreads, Start: 8, End: 4},
+		"Factor":           {Parameter: SweepThreads, Start: 1, End: 4, Factor: 1},
+		"TooManySteps":     {Parameter: SweepRate, Start: 1, End: 1000, Factor: 1.01},
+	} {
+		_, err := c.Steps()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFindKnee(t *testing.T) {
+	point := func(tps float64, latency time.Duration) models.SweepPoint {
+		return models.SweepPoint{Status: models.BenchmarkStatusCompleted, TPS: tps, LatencyAvg: latency}
+	}
+	ms := time.Millisecond
+	assert.Equal(t, 2, FindKnee([]models.SweepPoint{
+		point(100, ms), point(200, ms), point(390, ms), point(400, 2*ms), point(400, 4*ms),
+	}))
+
+	failed := point(1000, ms)
+	failed.Status = models.BenchmarkStatusFailed
+	assert.Equal(t, 0, FindKnee([]models.SweepPoint{point(100, ms), failed}))
+	assert.Equal(t, -1, FindKnee([]models.SweepPoint{{TPS: 100}}))
+	assert.Equal(t, -1, FindKnee(nil))
+}
+
+func TestSweep(t *testing.T) {
+	sweepPollInterval = time.Millisecond
+	config := &models.Benchmark{Name: "curve", NumThreads: 1, Duration: 5 * time.Millisecond}
+
+	t.Run("Threads", func(t *testing.T) {
+		factory := &curveFactory{}
+		s, err := NewSweep(factory, config, nil, SweepConfig{Parameter: SweepThreads, Start: 1, End: 32}, nil)
+		require.NoError(t, err)
+		assert.Nil(t, SweepOf(&curveRunner{}))
+
+		require.NoError(t, s.Start())
+		require.Eventually(t, func() bool {
+			return models.BenchmarkStatus(s.Status().Status).IsFinished()
+		}, 5*time.Second, time.Millisecond)
+
+		status := s.Status()
+		assert.Equal(t, string(models.BenchmarkStatusCompleted), status.Status)
+		assert.Equal(t, float64(100), status.Progress)
+		assert.Equal(t, 8, status.Metrics["sweep_value"])
+		assert.Equal(t, 6, status.Metrics["sweep_steps"])
+
+		report := SweepOf(s)
+		require.NotNil(t, report)
+		assert.Equal(t, "threads", report.Parameter)
+		require.Len(t, report.Points, 6)
+		assert.Equal(t, 3, report.Knee)
+		for i, want := range []int{1, 2, 4, 8, 16, 32} {
+			assert.Equal(t, want, report.Points[i].Value)
+			assert.Equal(t, want, factory.configs[i].NumThreads)
+		}
+		assert.Equal(t, float64(800), report.Points[5].TPS)
+		assert.Equal(t, 4*time.Millisecond, report.Points[5].LatencyAvg)
+		assert.Len(t, s.Results(), 6)
+
+		// The headline result is the knee step
+		result := NewResult("curve", status, time.Now().Add(-time.Second), time.Now())
+		assert.Equal(t, float64(800), result.TPS)
+		assert.Equal(t, time.Millisecond, result.LatencyAvg)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		factory := &curveFactory{}
+		rated := *config
+		rated.Config = json.RawMessage(`{"arrival":"poisson"}`)
+		s, err := NewSweep(factory, &rated, nil, SweepConfig{Parameter: SweepRate, Values: []int{400, 800, 1600}}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Start())
+		require.Eventually(t, func() bool {
+			return models.BenchmarkStatus(s.Status().Status).IsFinished()
+		}, 5*time.Second, time.Millisecond)
+
+		report := s.SweepReport()
+		assert.Equal(t, "rate", report.Parameter)
+		assert.Equal(t, 1, report.Knee)
+		require.Len(t, factory.configs, 3)
+		query, err := ParseQueryConfig(factory.configs[2].Config)
+		require.NoError(t, err)
+		assert.Equal(t, 1600, query.QueryRate)
+		assert.Equal(t, "poisson", query.Arrival)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		slow := *config
+		slow.Duration = time.Hour
+		s, err := NewSweep(&curveFactory{}, &slow, nil, SweepConfig{Parameter: SweepThreads, Values: []int{1, 2}}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Start())
+		require.Eventually(t, func() bool {
+			return s.Status().Metrics["sweep_step"] == 1
+		}, time.Second, time.Millisecond)
+		s.Stop()
+
+		status := s.Status()
+		assert.Equal(t, string(models.BenchmarkStatusCancelled), status.Status)
+		report := s.SweepReport()
+		require.Len(t, report.Points, 1)
+		assert.Equal(t, models.BenchmarkStatusCancelled, report.Points[0].Status)
+		assert.Equal(t, -1, report.Knee)
+	})
+
+	t.Run("QueryRunner", func(t *testing.T) {
+		stepConfig := models.Benchmark{
+			Name:          "Point Select",
+			QueryTemplate: "SELECT balance FROM accounts WHERE id = {{uniform 1 10}}",
+			Duration:      50 * time.Millisecond,
+		}
+		s, err := NewSweep(queryFactory{}, &stepConfig, setupSQLiteConnection(t), SweepConfig{Parameter: SweepThreads, Values: []int{1, 2}}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Start())
+		require.Eventually(t, func() bool {
+			return models.BenchmarkStatus(s.Status().Status).IsFinished()
+		}, 5*time.Second, time.Millisecond)
+
+		// The steps of the query workload have a throughput, so the curve
+		// has a knee
+		report := s.SweepReport()
+		require.Len(t, report.Points, 2)
+		for _, p := range report.Points {
+			assert.Equal(t, models.BenchmarkStatusCompleted, p.Status)
+			assert.Greater(t, p.TPS, float64(0))
+		}
+		assert.GreaterOrEqual(t, report.Knee, 0)
+	})
+
+	t.Run("StatusWhileRunning", func(t *testing.T) {
+		b, db, mock := setupTestBenchmark(t)
+		db.SetMaxOpenConns(1)
+		mock.MatchExpectationsInOrder(false)
+		for step := 0; step < 2; step++ {
+			mock.ExpectPrepare("SELECT 1").WillBeClosed()
+		}
+		for i := 0; i < 200; i++ {
+			mock.ExpectQuery("SELECT 1").WillDelayFor(2 * time.Millisecond).WillReturnRows(oneRow())
+		}
+		stepConfig := *b.config
+		stepConfig.Duration = 50 * time.Millisecond
+		s, err := NewSweep(queryFactory{}, &stepConfig, b.connection, SweepConfig{Parameter: SweepThreads, Values: []int{1, 2}}, nil)
+		require.NoError(t, err)
+
+		// Polling the status reads the metrics of the running step while
+		// its workers update them, which -race checks
+		require.NoError(t, s.Start())
+		for !models.BenchmarkStatus(s.Status().Status).IsFinished() {
+			status := s.Status()
+			for k, v := range status.Metrics {
+				status.Metrics[k] = v
+			}
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, string(models.BenchmarkStatusCompleted), s.Status().Status)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewSweep(&curveFactory{}, config, nil, SweepConfig{Parameter: SweepThreads}, nil)
+		assert.Error(t, err)
+		_, err = NewSweep(queryFactory{}, config, nil, SweepConfig{Parameter: SweepRate, Values: []int{1}}, nil)
+		assert.Error(t, err, "the query factory needs a connection")
+
+		tpccLike := struct{ Factory }{&curveFactory{}}
+		_, err = NewSweep(tpccLike, config, nil, SweepConfig{Parameter: SweepRate, Values: []int{1}}, nil)
+		assert.ErrorContains(t, err, "does not support a query rate")
+	})
+}
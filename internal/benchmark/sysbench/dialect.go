@@ -0,0 +1,160 @@
+package sysbench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// dialect produces the SQL that differs between database backends
+type dialect interface {
+	// createTable returns the statements creating sbtest<table>
+	createTable(table int, config *types.OLTPTestConfig) ([]string, error)
+	// rebind rewrites the ? placeholders of query into the backend's syntax
+	rebind(query string) string
+}
+
+// dialectFor returns the dialect of dbType. Unknown types get the SQLite
+// dialect, whose DDL is plain enough for most other databases.
+func dialectFor(dbType models.DBType) dialect {
+	switch dbType {
+	case models.MySQL:
+		return mysqlDialect{}
+	case models.PostgreSQL:
+		return postgresDialect{}
+	default:
+		return sqliteDialect{}
+	}
+}
+
+// dbTypeFor returns the database type of conn, derived from its driver when
+// the type is not set
+func dbTypeFor(conn *models.DBConnection) models.DBType {
+	if conn.Type != "" {
+		return conn.Type
+	}
+	switch conn.Driver {
+	case "mysql":
+		return models.MySQL
+	case "postgres", "pgx":
+		return models.PostgreSQL
+	case "sqlite3":
+		return models.SQLite
+	default:
+		return ""
+	}
+}
+
+// sbtestColumns are the columns of an sbtest table after id
+const sbtestColumns = `
+	k INTEGER DEFAULT '0' NOT NULL,
+	c CHAR(120) DEFAULT '' NOT NULL,
+	pad CHAR(60) DEFAULT '' NOT NULL`
+
+// mysqlDialect creates tables like sysbench's MySQL driver
+type mysqlDialect struct{}
+
+func (mysqlDialect) createTable(table int, config *types.OLTPTestConfig) ([]string, error) {
+	id := "id INTEGER NOT NULL"
+	if config.AutoInc {
+		id += " AUTO_INCREMENT"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE sbtest%d (\n\t%s,%s,\n\tPRIMARY KEY (id)\n)", table, id, sbtestColumns)
+	if config.Engine != "" {
+		fmt.Fprintf(&b, " ENGINE = %s", config.Engine)
+	}
+	if config.TableOptions != "" {
+		fmt.Fprintf(&b, " %s", config.TableOptions)
+	}
+	if config.Partitions > 0 {
+		fmt.Fprintf(&b, " PARTITION BY HASH (id) PARTITIONS %d", config.Partitions)
+	}
+	return []string{b.String()}, nil
+}
+
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+// postgresDialect creates tables like sysbench's PostgreSQL driver. The MySQL
+// storage engine is ignored and hash partitions are created as separate
+// tables attached to sbtest<table>.
+type postgresDialect struct{}
+
+func (postgresDialect) createTable(table int, config *types.OLTPTestConfig) ([]string, error) {
+	id := "id INTEGER NOT NULL"
+	if config.AutoInc {
+		// BY DEFAULT keeps explicit ids, which the loader and inserts use
+		id = "id INTEGER GENERATED BY DEFAULT AS IDENTITY"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE sbtest%d (\n\t%s,%s,\n\tPRIMARY KEY (id)\n)", table, id, sbtestColumns)
+	if config.Partitions > 0 {
+		b.WriteString(" PARTITION BY HASH (id)")
+	}
+	if config.TableOptions != "" {
+		fmt.Fprintf(&b, " %s", config.TableOptions)
+	}
+
+	statements := []string{b.String()}
+	for i := 0; i < config.Partitions; i++ {
+		statements = append(statements, fmt.Sprintf(
+			"CREATE TABLE sbtest%d_p%d PARTITION OF sbtest%d FOR VALUES WITH (MODULUS %d, REMAINDER %d)",
+			table, i, table, config.Partitions, i))
+	}
+	return statements, nil
+}
+
+// rebind numbers the placeholders $1, $2, ... as lib/pq expects
+func (postgresDialect) rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			b.WriteByte(query[i])
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// sqliteDialect creates tables for SQLite. The MySQL storage engine is
+// ignored; SQLite has no partitioning.
+type sqliteDialect struct{}
+
+func (sqliteDialect) createTable(table int, config *types.OLTPTestConfig) ([]string, error) {
+	if config.Partitions > 0 {
+		return nil, fmt.Errorf("partitioning is not supported by SQLite")
+	}
+
+	// An INTEGER PRIMARY KEY is SQLite's rowid and is assigned automatically;
+	// AUTOINCREMENT additionally keeps ids from being reused
+	id := "id INTEGER NOT NULL PRIMARY KEY"
+	if config.AutoInc {
+		id += " AUTOINCREMENT"
+	}
+
+	query := fmt.Sprintf("CREATE TABLE sbtest%d (\n\t%s,%s\n)", table, id, sbtestColumns)
+	if config.TableOptions != "" {
+		query += " " + config.TableOptions
+	}
+	return []string{query}, nil
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
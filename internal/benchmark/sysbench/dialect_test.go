@@ -0,0 +1,78 @@
+package sysbench
+
+import (
+	"testing"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectCreateTable(t *testing.T) {
+	config := types.NewOLTPTestConfig()
+
+	t.Run("MySQL", func(t *testing.T) {
+		config := *config
+		config.TableOptions = "ROW_FORMAT=COMPRESSED"
+		config.Partitions = 4
+
+		statements, err := dialectFor(models.MySQL).createTable(2, &config)
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		assert.Contains(t, statements[0], "CREATE TABLE sbtest2 (")
+		assert.Contains(t, statements[0], "id INTEGER NOT NULL AUTO_INCREMENT")
+		assert.Contains(t, statements[0], ") ENGINE = InnoDB ROW_FORMAT=COMPRESSED PARTITION BY HASH (id) PARTITIONS 4")
+
+		config.AutoInc = false
+		config.Engine = ""
+		statements, err = dialectFor(models.MySQL).createTable(2, &config)
+		require.NoError(t, err)
+		assert.NotContains(t, statements[0], "AUTO_INCREMENT")
+		assert.NotContains(t, statements[0], "ENGINE")
+	})
+
+	t.Run("PostgreSQL", func(t *testing.T) {
+		config := *config
+		config.Partitions = 2
+
+		statements, err := dialectFor(models.PostgreSQL).createTable(1, &config)
+		require.NoError(t, err)
+		require.Len(t, statements, 3)
+		assert.Contains(t, statements[0], "id INTEGER GENERATED BY DEFAULT AS IDENTITY")
+		assert.Contains(t, statements[0], ") PARTITION BY HASH (id)")
+		assert.NotContains(t, statements[0], "ENGINE")
+		assert.Equal(t,
+			"CREATE TABLE sbtest1_p1 PARTITION OF sbtest1 FOR VALUES WITH (MODULUS 2, REMAINDER 1)",
+			statements[2])
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		config := *config
+
+		statements, err := dialectFor(models.SQLite).createTable(1, &config)
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		assert.Contains(t, statements[0], "id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT")
+		assert.NotContains(t, statements[0], "ENGINE")
+
+		config.Partitions = 2
+		_, err = dialectFor(models.SQLite).createTable(1, &config)
+		assert.Error(t, err)
+	})
+}
+
+func TestDialectRebind(t *testing.T) {
+	query := "SELECT c FROM sbtest1 WHERE id BETWEEN ? AND ?"
+	assert.Equal(t, query, dialectFor(models.MySQL).rebind(query))
+	assert.Equal(t, query, dialectFor(models.SQLite).rebind(query))
+	assert.Equal(t, "SELECT c FROM sbtest1 WHERE id BETWEEN $1 AND $2", dialectFor(models.PostgreSQL).rebind(query))
+}
+
+func TestDBTypeFor(t *testing.T) {
+	assert.Equal(t, models.PostgreSQL, dbTypeFor(&models.DBConnection{Type: models.PostgreSQL, Driver: "mysql"}))
+	assert.Equal(t, models.MySQL, dbTypeFor(&models.DBConnection{Driver: "mysql"}))
+	assert.Equal(t, models.PostgreSQL, dbTypeFor(&models.DBConnection{Driver: "postgres"}))
+	assert.Equal(t, models.SQLite, dbTypeFor(&models.DBConnection{Driver: "sqlite3"}))
+	assert.Equal(t, models.DBType(""), dbTypeFor(&models.DBConnection{Driver: "other"}))
+}
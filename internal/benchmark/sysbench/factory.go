@@ -9,15 +9,21 @@ import (
 
 	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/config"
 	"github.com/deadjoe/benchphant/internal/models"
 )
 
-// Factory creates sysbench benchmarks
-type Factory struct{}
+// Factory creates sysbench benchmarks. Stored scenarios are read from
+// scenarioDir, which is set by whoever runs the server or the command line,
+// never by the workload config of a request.
+type Factory struct {
+	scenarioDir string
+}
 
-// NewFactory creates a new sysbench benchmark factory
-func NewFactory() *Factory {
-	return &Factory{}
+// NewFactory creates a new sysbench benchmark factory reading stored
+// scenarios from scenarioDir
+func NewFactory(scenarioDir string) *Factory {
+	return &Factory{scenarioDir: scenarioDir}
 }
 
 // Name returns the name of the benchmark type
@@ -25,6 +31,16 @@ func (f *Factory) Name() string {
 	return "sysbench"
 }
 
+// Description returns a short summary of the workload
+func (f *Factory) Description() string {
+	return "sysbench compatible OLTP workloads against generated sbtest tables"
+}
+
+// ConfigSchema returns the JSON Schema of the workload config
+func (f *Factory) ConfigSchema() json.RawMessage {
+	return benchmark.ConfigSchema(types.NewOLTPTestConfig())
+}
+
 // Create creates a new sysbench benchmark instance
 func (f *Factory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
 	if config == nil {
@@ -35,25 +51,95 @@ func (f *Factory) Create(config *models.Benchmark, conn *models.DBConnection, lo
 		return nil, fmt.Errorf("connection is required")
 	}
 
-	// Parse sysbench specific config
-	var oltpConfig types.OLTPTestConfig
-	if err := json.Unmarshal(config.Config, &oltpConfig); err != nil {
-		return nil, fmt.Errorf("parse config: %w", err)
+	// Parse sysbench specific config on top of the defaults
+	oltpConfig := types.NewOLTPTestConfig()
+	if len(config.Config) > 0 {
+		if err := benchmark.UnmarshalConfig(config.Config, oltpConfig); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+		if err := rejectScenarioDir(config.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	// Generic benchmark settings take precedence over the workload config
+	if config.NumThreads > 0 {
+		oltpConfig.NumThreads = config.NumThreads
+	}
+	if config.Duration > 0 {
+		oltpConfig.Duration = config.Duration
+	}
+	if config.ReportInterval > 0 {
+		oltpConfig.ReportInterval = config.ReportInterval
+	}
+	if config.Phases.RampUp > 0 {
+		oltpConfig.RampUp = config.Phases.RampUp
+	}
+	if config.Phases.RampSteps > 0 {
+		oltpConfig.RampSteps = config.Phases.RampSteps
+	}
+	if config.Phases.Warmup > 0 {
+		oltpConfig.Warmup = config.Phases.Warmup
+	}
+	if config.Phases.CoolDown > 0 {
+		oltpConfig.CoolDown = config.Phases.CoolDown
+	}
+	if !config.ErrorPolicy.IsZero() {
+		oltpConfig.ErrorPolicy = config.ErrorPolicy
+	}
+
+	// A scenario brings its own test configs
+	var scenario *Scenario
+	if oltpConfig.Scenario != "" {
+		storage, err := NewFileStorage(f.scenarioDir)
+		if err != nil {
+			return nil, err
+		}
+		if scenario, err = FindScenario(storage, oltpConfig.Scenario); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create database connection
-	db, err := sql.Open(conn.Driver, conn.DSN)
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+	// Reuse the connection's pool when the caller already opened one
+	db := conn.DB
+	if db == nil {
+		var err error
+		db, err = sql.Open(conn.Driver, conn.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+	}
+
+	// Run a stored or predefined scenario instead of a single test
+	if scenario != nil {
+		scenario.DBType = dbTypeFor(conn)
+		scenario.ReportInterval = oltpConfig.ReportInterval
+		scenario.ErrorPolicy = oltpConfig.ErrorPolicy
+		return NewScenarioRunner(scenario, db, logger), nil
 	}
 
 	// Create benchmark
-	b := NewOLTPTest(&oltpConfig, logger)
+	b := NewOLTPTest(oltpConfig, logger)
 	b.SetDB(db)
+	b.SetDBType(dbTypeFor(conn))
 	return b, nil
 }
 
+// rejectScenarioDir fails if the workload config sets the scenario
+// directory, which would let any client pick the files the server reads
+func rejectScenarioDir(config json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if _, ok := fields["scenario_dir"]; ok {
+		return fmt.Errorf("scenario_dir cannot be set in the workload config, it is part of the server configuration")
+	}
+	return nil
+}
+
 func init() {
-	// Register factory
-	benchmark.RegisterFactory("sysbench", &Factory{})
+	// Register factory, the server and command line replace it with one
+	// reading scenarios from the configured directory
+	benchmark.RegisterFactory("sysbench", NewFactory(config.DefaultScenarioDir))
 }
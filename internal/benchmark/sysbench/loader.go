@@ -0,0 +1,124 @@
+package sysbench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// loadTables fills the sbtest tables with TableSize rows each, loading up to
+// LoadThreads tables in parallel with multi-row INSERTs of LoadBatchSize rows.
+// The secondary index on k is created once a table is loaded, which is much
+// faster than maintaining it row by row.
+func (t *OLTPTest) loadTables(ctx context.Context) error {
+	threads := t.config.LoadThreads
+	if threads <= 0 {
+		threads = 1
+	}
+	if threads > t.config.NumTables {
+		threads = t.config.NumTables
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tables := make(chan int)
+	errs := make(chan error, threads)
+	var loaded int64
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for table := range tables {
+				if err := t.loadTable(ctx, table, &loaded); err != nil {
+					errs <- fmt.Errorf("failed to load table %d: %w", table, err)
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for table := 1; table <= t.config.NumTables; table++ {
+		select {
+		case tables <- table:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(tables)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// loadTable inserts the rows of sbtest<table> and creates its secondary index.
+// loaded counts the rows inserted across all tables.
+func (t *OLTPTest) loadTable(ctx context.Context, table int, loaded *int64) error {
+	t.logger.Info("Loading table",
+		zap.Int("table", table),
+		zap.Int("rows", t.config.TableSize),
+	)
+
+	batchSize := t.config.LoadBatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	for first := 1; first <= t.config.TableSize; first += batchSize {
+		rows := batchSize
+		if remaining := t.config.TableSize - first + 1; rows > remaining {
+			rows = remaining
+		}
+
+		query, args := t.insertBatch(table, first, rows)
+		if _, err := t.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+		t.addLoadedRows(loaded, rows)
+	}
+
+	return t.createIndex(ctx, table)
+}
+
+// insertBatch returns a multi-row INSERT of rows sysbench compatible rows
+// into sbtest<table>, with ids starting at first
+func (t *OLTPTest) insertBatch(table, first, rows int) (string, []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO sbtest%d (id, k, c, pad) VALUES ", table)
+	args := make([]interface{}, 0, rows*4)
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(?, ?, ?, ?)")
+		args = append(args, first+i, rand.Intn(t.config.TableSize)+1, randomC(), randomPad())
+	}
+	return t.dialect.rebind(b.String()), args
+}
+
+// addLoadedRows adds rows to loaded and reports the load progress in the
+// status
+func (t *OLTPTest) addLoadedRows(loaded *int64, rows int) {
+	total := int64(t.config.NumTables) * int64(t.config.TableSize)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*loaded += int64(rows)
+	t.status.Progress = float64(*loaded) / float64(total) * 100
+	t.status.Metrics = map[string]interface{}{
+		"rows_loaded": *loaded,
+		"rows_total":  total,
+	}
+}
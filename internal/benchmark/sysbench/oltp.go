@@ -5,111 +5,90 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
-	"os/exec"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
 	"go.uber.org/zap"
 )
 
-// OLTPTestConfig represents configuration for OLTP tests
-type OLTPTestConfig struct {
-	TestType        types.TestType  `json:"test_type"`
-	NumThreads      int            `json:"num_threads"`
-	Duration        time.Duration  `json:"duration"`
-	ReportInterval  time.Duration  `json:"report_interval"`
-	TableSize       int            `json:"table_size"`
-	TablesCount     int            `json:"tables_count"`
-	DistinctRanges  int            `json:"distinct_ranges"`
-	SimpleRanges    int            `json:"simple_ranges"`
-	SumRanges       int            `json:"sum_ranges"`
-	OrderRanges     int            `json:"order_ranges"`
-	PointSelects    int            `json:"point_selects"`
-	SimpleSelects   int            `json:"simple_selects"`
-	IndexUpdates    int            `json:"index_updates"`
-	NonIndexUpdates int            `json:"non_index_updates"`
-	Inserts         int            `json:"inserts"`
-	Deletes         int            `json:"deletes"`
-	ReadOnly        bool           `json:"read_only"`
-	WriteOnly       bool           `json:"write_only"`
-	AutoInc         bool           `json:"auto_inc"`
-	SecondaryIndexes int           `json:"secondary_indexes"`
-	DeleteInserts    bool          `json:"delete_inserts"`
-	RangeSize        int           `json:"range_size"`
-	RangeSelects     bool          `json:"range_selects"`
-	JoinSelects      bool          `json:"join_selects"`
-	SkipTrx          bool          `json:"skip_trx"`
-	TrxRate          float64       `json:"trx_rate"`
-}
-
-// NewOLTPTestConfig creates a new OLTP test configuration with default values
-func NewOLTPTestConfig() *OLTPTestConfig {
-	return &OLTPTestConfig{
-		TestType:        types.TestTypeOLTPReadWrite,
-		NumThreads:      1,
-		Duration:        10 * time.Second,
-		ReportInterval:  1 * time.Second,
-		TableSize:       10000,
-		TablesCount:     1,
-		DistinctRanges:  100,
-		SimpleRanges:    100,
-		SumRanges:       100,
-		OrderRanges:     100,
-		PointSelects:    10,
-		SimpleSelects:   1,
-		IndexUpdates:    1,
-		NonIndexUpdates: 1,
-		Inserts:         1,
-		Deletes:         1,
-		ReadOnly:        false,
-		WriteOnly:       false,
-		AutoInc:         true,
-		SecondaryIndexes: 0,
-		DeleteInserts:    false,
-		RangeSize:        100,
-		RangeSelects:     true,
-		JoinSelects:      false,
-		SkipTrx:          false,
-		TrxRate:          0, // No limit
-	}
-}
-
 // OLTPTest represents a sysbench OLTP test
 type OLTPTest struct {
-	db     *sql.DB
-	config *types.OLTPTestConfig
-	logger *zap.Logger
-	stats  *types.TestStats
-	status benchmark.BenchmarkStatus
-	mu     sync.RWMutex
-	done   chan struct{}
+	db       *sql.DB
+	dialect  dialect
+	config   *types.OLTPTestConfig
+	logger   *zap.Logger
+	stats    *types.TestStats
+	status   benchmark.BenchmarkStatus
+	mu       sync.RWMutex
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// intervals is shared with the other tests of a scenario, which start
+	// and stop it around the whole scenario instead of each test
+	intervals    *benchmark.IntervalRecorder
+	ownIntervals bool
+
+	// phases keeps the statistics of the ramp-up, warmup, measurement and
+	// cool-down, only the measurement counts in stats
+	phases *benchmark.PhaseRecorder
+
+	// errors applies the error policy of the running test
+	errors *benchmark.ErrorTracker
+
+	// breakdown holds the statistics of each kind of statement run during
+	// the measurement
+	breakdown *benchmark.Breakdown
+
+	// Statement counters, classified like sysbench's "queries performed"
+	reads      int64
+	writes     int64
+	other      int64
+	statements [numStatementKinds]int64
 }
 
 // NewOLTPTest creates a new OLTP test
 func NewOLTPTest(config *types.OLTPTestConfig, logger *zap.Logger) *OLTPTest {
 	return &OLTPTest{
-		config: config,
-		logger: logger,
-		stats:  types.NewTestStats(),
+		dialect: dialectFor(""),
+		config:  config,
+		logger:  logger,
+		stats:   types.NewTestStats(),
 		status: benchmark.BenchmarkStatus{
 			Status:   string(types.TestStatusPending),
 			Progress: 0,
 			Metrics:  make(map[string]interface{}),
 		},
-		done: make(chan struct{}),
+		done:         make(chan struct{}),
+		intervals:    benchmark.NewIntervalRecorder(config.ReportInterval),
+		ownIntervals: true,
+		phases:       benchmark.NewPhaseRecorder(config.Phases(), config.Duration),
+		breakdown:    benchmark.NewBreakdown(),
 	}
 }
 
+// useIntervals makes the test record its intervals in r, which the caller
+// starts and stops
+func (t *OLTPTest) useIntervals(r *benchmark.IntervalRecorder) {
+	t.intervals = r
+	t.ownIntervals = false
+}
+
 // SetDB sets the database connection
 func (t *OLTPTest) SetDB(db *sql.DB) {
 	t.db = db
 }
 
+// SetDBType sets the type of database the test runs against, which selects
+// the DDL and placeholder syntax
+func (t *OLTPTest) SetDBType(dbType models.DBType) {
+	t.dialect = dialectFor(dbType)
+}
+
 // Start starts the test
 func (t *OLTPTest) Start() error {
 	t.mu.Lock()
@@ -123,39 +102,33 @@ func (t *OLTPTest) Start() error {
 		return fmt.Errorf("test is already running")
 	}
 
+	if err := validateConfig(t.config); err != nil {
+		return err
+	}
+
 	t.status.Status = string(types.TestStatusRunning)
 	t.status.Progress = 0
 	t.status.Metrics = make(map[string]interface{})
 
-	t.logger.Info("Starting OLTP test",
-		zap.String("test_type", string(t.config.TestType)),
-		zap.Int("num_threads", t.config.NumThreads),
-		zap.Duration("duration", t.config.Duration),
-	)
-
 	// Run test in a goroutine
 	go func() {
-		ctx := context.Background()
-		if err := t.Run(ctx); err != nil {
+		err := t.Run(context.Background())
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if err != nil {
 			t.logger.Error("Test failed", zap.Error(err))
-			t.mu.Lock()
 			t.status.Status = string(types.TestStatusFailed)
-			t.mu.Unlock()
+			t.status.Metrics = t.metrics()
 			return
 		}
 
-		t.mu.Lock()
-		t.status.Status = string(types.TestStatusCompleted)
-		t.status.Progress = 100
-		t.status.Metrics = map[string]interface{}{
-			"total_transactions": t.stats.TotalTransactions,
-			"tps":               t.stats.TPS,
-			"latency_avg":       t.stats.AvgLatency,
-			"latency_p95":       t.stats.P95Latency,
-			"latency_p99":       t.stats.P99Latency,
-			"errors":            t.stats.TotalErrors,
+		if t.status.Status != string(types.TestStatusCancelled) {
+			t.status.Status = string(types.TestStatusCompleted)
 		}
-		t.mu.Unlock()
+		t.status.Progress = 100
+		t.status.Metrics = t.metrics()
 	}()
 
 	return nil
@@ -166,15 +139,52 @@ func (t *OLTPTest) Stop() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	close(t.done)
+	t.stop()
 	t.status.Status = string(types.TestStatusCancelled)
 }
 
-// Status returns the current test status
+// stop signals the workers to finish
+func (t *OLTPTest) stop() {
+	t.stopOnce.Do(func() { close(t.done) })
+}
+
+// metrics returns the final metrics of the test. The caller must hold t.mu.
+func (t *OLTPTest) metrics() map[string]interface{} {
+	metrics := t.stats.LatencyMetrics()
+	metrics["test_type"] = string(t.config.TestType)
+	metrics["total_transactions"] = t.stats.TotalTransactions
+	metrics["tps"] = t.stats.TPS
+	metrics["errors"] = t.stats.TotalErrors
+	metrics["queries_read"] = atomic.LoadInt64(&t.reads)
+	metrics["queries_write"] = atomic.LoadInt64(&t.writes)
+	metrics["queries_other"] = atomic.LoadInt64(&t.other)
+	metrics["statements"] = t.statementCounts()
+	metrics["breakdown"] = t.breakdown.Stats()
+	if t.errors != nil {
+		for k, v := range t.errors.Metrics() {
+			metrics[k] = v
+		}
+	}
+	return metrics
+}
+
+// Status returns a copy of the current test status
 func (t *OLTPTest) Status() benchmark.BenchmarkStatus {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.status
+	return t.status.Copy()
+}
+
+// Intervals returns the reports of the intervals finished so far
+func (t *OLTPTest) Intervals() []models.IntervalReport {
+	return t.intervals.Intervals()
+}
+
+// Phases returns the reports of the phases started so far
+func (t *OLTPTest) Phases() []models.PhaseReport {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.phases.Phases()
 }
 
 // GetReport returns the test report
@@ -183,9 +193,10 @@ func (t *OLTPTest) GetReport() *types.Report {
 	defer t.mu.RUnlock()
 
 	return &types.Report{
-		Name:     string(t.config.TestType),
-		Duration: t.config.Duration,
-		Stats:    t.stats,
+		Name:      string(t.config.TestType),
+		Duration:  t.config.Duration,
+		Stats:     t.stats,
+		Breakdown: t.breakdown.Stats(),
 	}
 }
 
@@ -199,37 +210,100 @@ func (t *OLTPTest) Run(ctx context.Context) error {
 		return fmt.Errorf("logger not set")
 	}
 
+	if err := validateConfig(t.config); err != nil {
+		return err
+	}
+
 	t.logger.Info("Starting OLTP test",
 		zap.String("test_type", string(t.config.TestType)),
 		zap.Int("num_threads", t.config.NumThreads),
 		zap.Duration("duration", t.config.Duration),
+		zap.Duration("ramp_up", t.config.RampUp),
+		zap.Duration("warmup", t.config.Warmup),
+		zap.Duration("cool_down", t.config.CoolDown),
+		zap.Int("query_rate", t.config.QueryRate),
+		zap.String("arrival", t.config.Arrival),
 	)
 
+	if t.ownIntervals {
+		t.intervals.Start()
+		defer t.intervals.Stop()
+	}
+
+	tracker, err := benchmark.NewErrorTracker(t.config.ErrorPolicy, models.ErrorPolicyContinue)
+	if err != nil {
+		return err
+	}
+
+	// Only transactions started during the measurement are counted
+	phases := benchmark.NewPhaseRecorder(t.config.Phases(), t.config.Duration)
+	t.mu.Lock()
+	t.phases = phases
+	t.errors = tracker
+	t.breakdown.Reset()
+	t.mu.Unlock()
+	phases.Start()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	phases.Watch(finished, t.intervals.SetPhase)
+
+	// With a query rate the workers start transactions on an open-loop
+	// schedule shared between them
+	var limiter *benchmark.RateLimiter
+	if t.config.QueryRate > 0 {
+		limiter, err = benchmark.NewRateLimiter(float64(t.config.QueryRate), benchmark.Arrival(t.config.Arrival))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < t.config.NumThreads; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			t.worker(ctx, workerID)
+			t.worker(ctx, workerID, phases, tracker, limiter)
 		}(i)
 	}
 
-	// Wait for test completion or context cancellation
+	// Wait for test completion, cancellation or Stop
+	timer := time.NewTimer(phases.Total())
+	defer timer.Stop()
+
 	select {
 	case <-ctx.Done():
-		close(t.done)
-		wg.Wait()
-		return ctx.Err()
-	case <-time.After(t.config.Duration):
-		close(t.done)
-		wg.Wait()
-		return nil
+		err = ctx.Err()
+	case <-t.done:
+	case <-timer.C:
 	}
+	t.stop()
+	wg.Wait()
+	if reason := tracker.Reason(); reason != "" && err == nil {
+		err = fmt.Errorf("%w: %s", types.ErrTestFailed, reason)
+	}
+
+	t.mu.Lock()
+	if elapsed := phases.Measured(time.Now()).Seconds(); elapsed > 0 {
+		t.stats.TPS = float64(t.stats.TotalTransactions) / elapsed
+	}
+	t.stats.Finalize()
+	t.mu.Unlock()
+
+	return err
 }
 
-// worker represents a test worker
-func (t *OLTPTest) worker(ctx context.Context, id int) {
+// worker runs transactions from its turn in the ramp-up until the test
+// ends, recording in stats those phases reports as measured. Failed
+// transactions are retried and may end the test as the tracker's error
+// policy says. A non-nil limiter schedules the transactions, whose latency
+// then counts from their scheduled start.
+func (t *OLTPTest) worker(ctx context.Context, id int, phases *benchmark.PhaseRecorder, tracker *benchmark.ErrorTracker, limiter *benchmark.RateLimiter) {
+	if !phases.WaitForThread(ctx, t.done, id, t.config.NumThreads) {
+		return
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -237,19 +311,47 @@ func (t *OLTPTest) worker(ctx context.Context, id int) {
 		case <-t.done:
 			return
 		default:
-			start := time.Now()
-			err := t.executeTransaction(ctx)
-			elapsed := time.Since(start)
+		}
 
-			if err != nil {
+		start := time.Now()
+		if limiter != nil {
+			var ok bool
+			if start, ok = limiter.Wait(ctx, t.done); !ok {
+				return
+			}
+		}
+
+		err := tracker.Retry(ctx, t.done, func() error {
+			return t.executeTransaction(ctx)
+		})
+		elapsed := time.Since(start)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.intervals.RecordError(err)
+			if phases.RecordError(start) {
+				t.mu.Lock()
 				t.stats.AddError()
-				t.logger.Error("Transaction failed",
-					zap.Int("worker", id),
-					zap.Error(err))
-				continue
+				t.mu.Unlock()
+			}
+			t.logger.Error("Transaction failed",
+				zap.Int("worker", id),
+				zap.Error(err))
+			if tracker.Failed(err) {
+				t.stop()
+				return
 			}
+			continue
+		}
 
+		tracker.Succeeded()
+		t.intervals.RecordTransaction(elapsed)
+		if phases.RecordTransaction(start, elapsed) {
+			t.mu.Lock()
 			t.stats.AddTransaction(elapsed)
+			t.mu.Unlock()
 		}
 	}
 }
@@ -269,6 +371,14 @@ func (t *OLTPTest) executeTransaction(ctx context.Context) error {
 		return t.executeSimpleSelect(ctx)
 	case types.TestTypeOLTPSumRange:
 		return t.executeSumRange(ctx)
+	case types.TestTypeOLTPOrderRange:
+		return t.executeOrderRange(ctx)
+	case types.TestTypeOLTPDistinctRange:
+		return t.executeDistinctRange(ctx)
+	case types.TestTypeOLTPIndexScan:
+		return t.executeIndexScan(ctx)
+	case types.TestTypeOLTPNonIndexScan:
+		return t.executeNonIndexScan(ctx)
 	default:
 		return fmt.Errorf("unsupported test type: %s", t.config.TestType)
 	}
@@ -276,9 +386,9 @@ func (t *OLTPTest) executeTransaction(ctx context.Context) error {
 
 // executeReadOnly executes a read-only transaction
 func (t *OLTPTest) executeReadOnly(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -287,14 +397,14 @@ func (t *OLTPTest) executeReadOnly(ctx context.Context) error {
 		return err
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
 // executeWriteOnly executes a write-only transaction
 func (t *OLTPTest) executeWriteOnly(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, nil)
+	tx, err := t.begin(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -303,14 +413,14 @@ func (t *OLTPTest) executeWriteOnly(ctx context.Context) error {
 		return err
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
 // executeReadWrite executes a mixed read-write transaction
 func (t *OLTPTest) executeReadWrite(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, nil)
+	tx, err := t.begin(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -319,19 +429,21 @@ func (t *OLTPTest) executeReadWrite(ctx context.Context) error {
 		return err
 	}
 
-	// Execute write operations
-	if err := t.doWrites(ctx, tx); err != nil {
-		return err
+	// Execute write operations, unless the test is configured read only
+	if !t.config.ReadOnly {
+		if err := t.doWrites(ctx, tx); err != nil {
+			return err
+		}
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
 // executePointSelect executes point select queries
 func (t *OLTPTest) executePointSelect(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -340,14 +452,14 @@ func (t *OLTPTest) executePointSelect(ctx context.Context) error {
 		return err
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
 // executeSimpleSelect executes simple range select queries
 func (t *OLTPTest) executeSimpleSelect(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -356,14 +468,14 @@ func (t *OLTPTest) executeSimpleSelect(ctx context.Context) error {
 		return err
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
 // executeSumRange executes sum range queries
 func (t *OLTPTest) executeSumRange(ctx context.Context) error {
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+		return err
 	}
 	defer tx.Rollback()
 
@@ -372,59 +484,370 @@ func (t *OLTPTest) executeSumRange(ctx context.Context) error {
 		return err
 	}
 
-	return tx.Commit()
+	return t.commit(tx)
 }
 
-// doReads performs read operations
-func (t *OLTPTest) doReads(ctx context.Context, tx *sql.Tx) error {
-	// Implement read operations
+// executeOrderRange executes range selects sorted by c
+func (t *OLTPTest) executeOrderRange(ctx context.Context) error {
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Execute order range selects
+	if err := t.doOrderRangeSelects(ctx, tx); err != nil {
+		return err
+	}
+
+	return t.commit(tx)
+}
+
+// executeDistinctRange executes DISTINCT range selects sorted by c
+func (t *OLTPTest) executeDistinctRange(ctx context.Context) error {
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Execute distinct range selects
+	if err := t.doDistinctRangeSelects(ctx, tx); err != nil {
+		return err
+	}
+
+	return t.commit(tx)
+}
+
+// executeIndexScan executes range scans of the secondary index on k
+func (t *OLTPTest) executeIndexScan(ctx context.Context) error {
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Execute index scans
+	if err := t.doIndexScans(ctx, tx); err != nil {
+		return err
+	}
+
+	return t.commit(tx)
+}
+
+// executeNonIndexScan executes full table scans filtering on the non-indexed column c
+func (t *OLTPTest) executeNonIndexScan(ctx context.Context) error {
+	tx, err := t.begin(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Execute non-index scans
+	if err := t.doNonIndexScans(ctx, tx); err != nil {
+		return err
+	}
+
+	return t.commit(tx)
+}
+
+// statementKind identifies a statement in the per-statement counts
+type statementKind int
+
+const (
+	stmtPointSelect statementKind = iota
+	stmtSimpleRange
+	stmtSumRange
+	stmtOrderRange
+	stmtDistinctRange
+	stmtIndexScan
+	stmtNonIndexScan
+	stmtIndexUpdate
+	stmtNonIndexUpdate
+	stmtDelete
+	stmtInsert
+	numStatementKinds
+)
+
+// statementNames are the keys of the per-statement counts in the metrics
+var statementNames = [numStatementKinds]string{
+	stmtPointSelect:    "point_select",
+	stmtSimpleRange:    "simple_range",
+	stmtSumRange:       "sum_range",
+	stmtOrderRange:     "order_range",
+	stmtDistinctRange:  "distinct_range",
+	stmtIndexScan:      "index_scan",
+	stmtNonIndexScan:   "non_index_scan",
+	stmtIndexUpdate:    "index_update",
+	stmtNonIndexUpdate: "non_index_update",
+	stmtDelete:         "delete",
+	stmtInsert:         "insert",
+}
+
+// statementQueries holds the statements of sysbench's oltp_common.lua plus
+// the index and non-index scans. %d is replaced by the table number.
+var statementQueries = [numStatementKinds]string{
+	stmtPointSelect:    "SELECT c FROM sbtest%d WHERE id = ?",
+	stmtSimpleRange:    "SELECT c FROM sbtest%d WHERE id BETWEEN ? AND ?",
+	stmtSumRange:       "SELECT SUM(k) FROM sbtest%d WHERE id BETWEEN ? AND ?",
+	stmtOrderRange:     "SELECT c FROM sbtest%d WHERE id BETWEEN ? AND ? ORDER BY c",
+	stmtDistinctRange:  "SELECT DISTINCT c FROM sbtest%d WHERE id BETWEEN ? AND ? ORDER BY c",
+	stmtIndexScan:      "SELECT id, k FROM sbtest%d WHERE k BETWEEN ? AND ?",
+	stmtNonIndexScan:   "SELECT id, k FROM sbtest%d WHERE c LIKE ?",
+	stmtIndexUpdate:    "UPDATE sbtest%d SET k = k + 1 WHERE id = ?",
+	stmtNonIndexUpdate: "UPDATE sbtest%d SET c = ? WHERE id = ?",
+	stmtDelete:         "DELETE FROM sbtest%d WHERE id = ?",
+	stmtInsert:         "INSERT INTO sbtest%d (id, k, c, pad) VALUES (?, ?, ?, ?)",
+}
+
+// begin starts a transaction, counting it like sysbench counts BEGIN
+func (t *OLTPTest) begin(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := t.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	atomic.AddInt64(&t.other, 1)
+	t.intervals.RecordQueries(1)
+	return tx, nil
+}
+
+// commit commits tx, counting it like sysbench counts COMMIT
+func (t *OLTPTest) commit(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	atomic.AddInt64(&t.other, 1)
+	t.intervals.RecordQueries(1)
 	return nil
 }
 
-// doWrites performs write operations
+// doReads performs the read statements of a sysbench OLTP transaction
+func (t *OLTPTest) doReads(ctx context.Context, tx *sql.Tx) error {
+	if err := t.doPointSelects(ctx, tx); err != nil {
+		return err
+	}
+	if err := t.doSimpleRangeSelects(ctx, tx); err != nil {
+		return err
+	}
+	if err := t.doSumRangeQueries(ctx, tx); err != nil {
+		return err
+	}
+	if err := t.doOrderRangeSelects(ctx, tx); err != nil {
+		return err
+	}
+	return t.doDistinctRangeSelects(ctx, tx)
+}
+
+// doWrites performs the write statements of a sysbench OLTP transaction
 func (t *OLTPTest) doWrites(ctx context.Context, tx *sql.Tx) error {
-	// Implement write operations
-	return nil
+	if err := t.doIndexUpdates(ctx, tx); err != nil {
+		return err
+	}
+	if err := t.doNonIndexUpdates(ctx, tx); err != nil {
+		return err
+	}
+	return t.doDeleteInserts(ctx, tx)
 }
 
-// doPointSelects performs point select operations
+// doPointSelects performs PointSelects primary key lookups
 func (t *OLTPTest) doPointSelects(ctx context.Context, tx *sql.Tx) error {
-	// Implement point select operations
+	for i := 0; i < t.config.PointSelects; i++ {
+		if err := t.read(ctx, tx, stmtPointSelect, t.randomID()); err != nil {
+			return fmt.Errorf("%s failed: %w", statementNames[stmtPointSelect], err)
+		}
+	}
 	return nil
 }
 
-// doSimpleRangeSelects performs simple range select operations
+// doSimpleRangeSelects performs SimpleRanges primary key range selects
 func (t *OLTPTest) doSimpleRangeSelects(ctx context.Context, tx *sql.Tx) error {
-	// Implement simple range select operations
-	return nil
+	return t.doRanges(ctx, tx, stmtSimpleRange, t.config.SimpleRanges)
 }
 
-// doSumRangeQueries performs sum range queries
+// doSumRangeQueries performs SumRanges range selects summing k
 func (t *OLTPTest) doSumRangeQueries(ctx context.Context, tx *sql.Tx) error {
-	// Implement sum range queries
+	return t.doRanges(ctx, tx, stmtSumRange, t.config.SumRanges)
+}
+
+// doOrderRangeSelects performs OrderRanges range selects sorted by c
+func (t *OLTPTest) doOrderRangeSelects(ctx context.Context, tx *sql.Tx) error {
+	return t.doRanges(ctx, tx, stmtOrderRange, t.config.OrderRanges)
+}
+
+// doDistinctRangeSelects performs DistinctRanges DISTINCT range selects sorted by c
+func (t *OLTPTest) doDistinctRangeSelects(ctx context.Context, tx *sql.Tx) error {
+	return t.doRanges(ctx, tx, stmtDistinctRange, t.config.DistinctRanges)
+}
+
+// doIndexScans performs IndexScans selects of a RangeSize wide range of k,
+// read through the secondary index
+func (t *OLTPTest) doIndexScans(ctx context.Context, tx *sql.Tx) error {
+	return t.doRanges(ctx, tx, stmtIndexScan, t.config.IndexScans)
+}
+
+// doNonIndexScans performs NonIndexScans selects filtering on a prefix of
+// the non-indexed column c, which makes the database scan the whole table
+func (t *OLTPTest) doNonIndexScans(ctx context.Context, tx *sql.Tx) error {
+	for i := 0; i < t.config.NonIndexScans; i++ {
+		prefix := randomDigitGroups(1)[:3]
+		if err := t.read(ctx, tx, stmtNonIndexScan, prefix+"%"); err != nil {
+			return fmt.Errorf("%s failed: %w", statementNames[stmtNonIndexScan], err)
+		}
+	}
+	return nil
+}
+
+// doRanges runs count range selects of kind over RangeSize values
+func (t *OLTPTest) doRanges(ctx context.Context, tx *sql.Tx, kind statementKind, count int) error {
+	for i := 0; i < count; i++ {
+		start, end := t.randomRange()
+		if err := t.read(ctx, tx, kind, start, end); err != nil {
+			return fmt.Errorf("%s failed: %w", statementNames[kind], err)
+		}
+	}
 	return nil
 }
 
+// doIndexUpdates performs IndexUpdates updates of the indexed column k
+func (t *OLTPTest) doIndexUpdates(ctx context.Context, tx *sql.Tx) error {
+	for i := 0; i < t.config.IndexUpdates; i++ {
+		if err := t.write(ctx, tx, stmtIndexUpdate, t.randomID()); err != nil {
+			return fmt.Errorf("index update failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// doNonIndexUpdates performs NonIndexUpdates updates of the non-indexed column c
+func (t *OLTPTest) doNonIndexUpdates(ctx context.Context, tx *sql.Tx) error {
+	for i := 0; i < t.config.NonIndexUpdates; i++ {
+		if err := t.write(ctx, tx, stmtNonIndexUpdate, randomC(), t.randomID()); err != nil {
+			return fmt.Errorf("non-index update failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// doDeleteInserts performs DeleteInserts pairs of deleting a row and
+// inserting it again with new values, leaving the table size unchanged
+func (t *OLTPTest) doDeleteInserts(ctx context.Context, tx *sql.Tx) error {
+	for i := 0; i < t.config.DeleteInserts; i++ {
+		table := t.randomTable()
+		id := t.randomID()
+		if err := t.writeTable(ctx, tx, table, stmtDelete, id); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		if err := t.writeTable(ctx, tx, table, stmtInsert, id, t.randomID(), randomC(), randomPad()); err != nil {
+			return fmt.Errorf("insert failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// read runs a select of kind against a random table and reads all its rows
+func (t *OLTPTest) read(ctx context.Context, tx *sql.Tx, kind statementKind, args ...interface{}) error {
+	query := t.dialect.rebind(fmt.Sprintf(statementQueries[kind], t.randomTable()))
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		t.recordStatement(ctx, kind, start, 0, err)
+		return err
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.recordStatement(ctx, kind, start, 0, err)
+		return err
+	}
+	t.recordStatement(ctx, kind, start, n, nil)
+	atomic.AddInt64(&t.reads, 1)
+	t.intervals.RecordQueries(1)
+	atomic.AddInt64(&t.statements[kind], 1)
+	return nil
+}
+
+// write runs a modifying statement of kind against a random table
+func (t *OLTPTest) write(ctx context.Context, tx *sql.Tx, kind statementKind, args ...interface{}) error {
+	return t.writeTable(ctx, tx, t.randomTable(), kind, args...)
+}
+
+// writeTable runs a modifying statement of kind against sbtest<table>
+func (t *OLTPTest) writeTable(ctx context.Context, tx *sql.Tx, table int, kind statementKind, args ...interface{}) error {
+	query := t.dialect.rebind(fmt.Sprintf(statementQueries[kind], table))
+	start := time.Now()
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		t.recordStatement(ctx, kind, start, 0, err)
+		return err
+	}
+	affected, _ := result.RowsAffected()
+	t.recordStatement(ctx, kind, start, affected, nil)
+	atomic.AddInt64(&t.writes, 1)
+	t.intervals.RecordQueries(1)
+	atomic.AddInt64(&t.statements[kind], 1)
+	return nil
+}
+
+// recordStatement records a statement of kind started at start in the
+// breakdown, with the rows it returned or affected, if it started during the
+// measurement. Statements cut short by the end of the test are left out.
+func (t *OLTPTest) recordStatement(ctx context.Context, kind statementKind, start time.Time, rows int64, err error) {
+	if t.phases.PhaseAt(start) != models.PhaseMeasure {
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			t.breakdown.RecordError(statementNames[kind])
+		}
+		return
+	}
+	t.breakdown.Record(statementNames[kind], time.Since(start), rows)
+}
+
+// statementCounts returns the number of successful statements of each kind
+// that was executed at least once
+func (t *OLTPTest) statementCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	for kind := statementKind(0); kind < numStatementKinds; kind++ {
+		if n := atomic.LoadInt64(&t.statements[kind]); n > 0 {
+			counts[statementNames[kind]] = n
+		}
+	}
+	return counts
+}
+
+// randomTable returns the number of a random sbtest table
+func (t *OLTPTest) randomTable() int {
+	return rand.Intn(t.config.NumTables) + 1
+}
+
+// randomID returns a random row id
+func (t *OLTPTest) randomID() int {
+	return rand.Intn(t.config.TableSize) + 1
+}
+
+// randomRange returns the bounds of a random id range of RangeSize rows
+func (t *OLTPTest) randomRange() (int, int) {
+	size := t.config.RangeSize
+	if size <= 0 || size > t.config.TableSize {
+		size = t.config.TableSize
+	}
+	start := rand.Intn(t.config.TableSize-size+1) + 1
+	return start, start + size - 1
+}
+
 // validateConfig validates the test configuration
-func validateConfig(config *OLTPTestConfig) error {
+func validateConfig(config *types.OLTPTestConfig) error {
 	if config == nil {
 		return types.ErrInvalidConfig
 	}
 
 	// Validate test type
-	switch config.TestType {
-	case types.TestTypeOLTPRead,
-		types.TestTypeOLTPWrite,
-		types.TestTypeOLTPReadWrite,
-		types.TestTypeOLTPPointSelect,
-		types.TestTypeOLTPSimpleSelect,
-		types.TestTypeOLTPSumRange,
-		types.TestTypeOLTPOrderRange,
-		types.TestTypeOLTPDistinctRange,
-		types.TestTypeOLTPIndexScan,
-		types.TestTypeOLTPNonIndexScan:
-		// Valid test type
-	default:
+	if !config.TestType.IsValid() {
 		return fmt.Errorf("invalid test type: %s", config.TestType)
 	}
 
@@ -432,12 +855,30 @@ func validateConfig(config *OLTPTestConfig) error {
 	if config.TableSize <= 0 {
 		return types.ErrInvalidTableSize
 	}
-	if config.TablesCount <= 0 {
+	if config.NumTables <= 0 {
 		return types.ErrInvalidNumTables
 	}
 	if config.NumThreads <= 0 {
 		return types.ErrInvalidNumThreads
 	}
+	if config.Duration <= 0 {
+		return types.ErrInvalidDuration
+	}
+	if config.Partitions < 0 {
+		return fmt.Errorf("partitions must not be negative")
+	}
+	if config.Warmup < 0 {
+		return types.ErrInvalidDuration
+	}
+	if err := config.Phases().Validate(); err != nil {
+		return err
+	}
+	if config.QueryRate < 0 {
+		return fmt.Errorf("query rate must not be negative")
+	}
+	if _, err := benchmark.ParseArrival(config.Arrival); err != nil {
+		return err
+	}
 
 	// Validate test-specific parameters
 	switch config.TestType {
@@ -461,26 +902,72 @@ func validateConfig(config *OLTPTestConfig) error {
 		if config.DistinctRanges <= 0 {
 			return fmt.Errorf("distinct ranges must be positive for distinct range test")
 		}
+	case types.TestTypeOLTPIndexScan:
+		if config.IndexScans <= 0 {
+			return fmt.Errorf("index scans must be positive for index scan test")
+		}
+	case types.TestTypeOLTPNonIndexScan:
+		if config.NonIndexScans <= 0 {
+			return fmt.Errorf("non-index scans must be positive for non-index scan test")
+		}
 	}
 
 	return nil
 }
 
-// Prepare prepares the test database
+// Prepare creates the sbtest tables and loads TableSize rows into each. The
+// load progress is reported by Status.
 func (t *OLTPTest) Prepare(ctx context.Context) error {
+	if t.db == nil {
+		return types.ErrDatabaseNotConnected
+	}
+	if err := validateConfig(t.config); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	if t.status.Status == string(types.TestStatusRunning) {
+		t.mu.Unlock()
+		return fmt.Errorf("test is already running")
+	}
+	t.status.Status = string(types.TestStatusPreparing)
+	t.status.Progress = 0
+	t.status.Metrics = make(map[string]interface{})
+	t.mu.Unlock()
+
+	err := t.prepare(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.status.Status = string(types.TestStatusFailed)
+		return err
+	}
+	t.status.Status = string(types.TestStatusPending)
+	return nil
+}
+
+// prepare creates and loads the tables
+func (t *OLTPTest) prepare(ctx context.Context) error {
 	// Create tables
-	for i := 1; i <= t.config.TablesCount; i++ {
+	for i := 1; i <= t.config.NumTables; i++ {
 		if err := t.createTable(ctx, i); err != nil {
 			return fmt.Errorf("failed to create table %d: %w", i, err)
 		}
 	}
-	return nil
+
+	// Load rows
+	return t.loadTables(ctx)
 }
 
 // Cleanup cleans up the test database
 func (t *OLTPTest) Cleanup(ctx context.Context) error {
+	if t.db == nil {
+		return types.ErrDatabaseNotConnected
+	}
+
 	// Drop tables
-	for i := 1; i <= t.config.TablesCount; i++ {
+	for i := 1; i <= t.config.NumTables; i++ {
 		if err := t.dropTable(ctx, i); err != nil {
 			return fmt.Errorf("failed to drop table %d: %w", i, err)
 		}
@@ -488,31 +975,27 @@ func (t *OLTPTest) Cleanup(ctx context.Context) error {
 	return nil
 }
 
-// createTable creates a table
+// createTable creates a table using the DDL of the database's dialect
 func (t *OLTPTest) createTable(ctx context.Context, tableNum int) error {
-	query := fmt.Sprintf(`
-		CREATE TABLE sbtest%d (
-			id INTEGER NOT NULL,
-			k INTEGER DEFAULT '0' NOT NULL,
-			c CHAR(120) DEFAULT '' NOT NULL,
-			pad CHAR(60) DEFAULT '' NOT NULL,
-			PRIMARY KEY (id)
-		)`, tableNum)
-
-	if _, err := t.db.ExecContext(ctx, query); err != nil {
+	statements, err := t.dialect.createTable(tableNum, t.config)
+	if err != nil {
 		return err
 	}
-
-	if t.config.SecondaryIndexes > 0 {
-		query = fmt.Sprintf("CREATE INDEX k_%d ON sbtest%d(k)", tableNum, tableNum)
+	for _, query := range statements {
 		if _, err := t.db.ExecContext(ctx, query); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
+// createIndex creates the secondary index on k that sysbench always creates
+func (t *OLTPTest) createIndex(ctx context.Context, tableNum int) error {
+	query := fmt.Sprintf("CREATE INDEX k_%d ON sbtest%d(k)", tableNum, tableNum)
+	_, err := t.db.ExecContext(ctx, query)
+	return err
+}
+
 // dropTable drops a table
 func (t *OLTPTest) dropTable(ctx context.Context, tableNum int) error {
 	query := fmt.Sprintf("DROP TABLE IF EXISTS sbtest%d", tableNum)
@@ -520,11 +1003,28 @@ func (t *OLTPTest) dropTable(ctx context.Context, tableNum int) error {
 	return err
 }
 
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+// randomC returns a value for the c column in sysbench's format: ten groups
+// of eleven digits separated by dashes
+func randomC() string {
+	return randomDigitGroups(10)
+}
+
+// randomPad returns a value for the pad column: five groups of eleven digits
+func randomPad() string {
+	return randomDigitGroups(5)
+}
+
+// randomDigitGroups returns n dash separated groups of eleven random digits
+func randomDigitGroups(n int) string {
+	var b strings.Builder
+	b.Grow(n * 12)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		for j := 0; j < 11; j++ {
+			b.WriteByte(byte('0' + rand.Intn(10)))
+		}
 	}
-	return string(b)
+	return b.String()
 }
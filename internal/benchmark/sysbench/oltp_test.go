@@ -2,115 +2,378 @@ package sysbench
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 )
 
-func TestOLTPTest(t *testing.T) {
-	// Create test configuration
-	config := types.NewOLTPTestConfig()
-	config.TestType = types.TestTypeOLTPRead
-	config.NumThreads = 4
-	config.Duration = 10 * time.Second
-	config.ReportInterval = 1 * time.Second
+// newTestDB opens a file backed SQLite database. A single connection keeps
+// concurrent workers from running into SQLITE_BUSY.
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sbtest.db"))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
 
-	// Create test instance
+// newPreparedTest creates and loads the sbtest tables of config
+func newPreparedTest(t *testing.T, config *types.OLTPTestConfig) *OLTPTest {
 	test := NewOLTPTest(config, zaptest.NewLogger(t))
+	test.SetDB(newTestDB(t))
+	require.NoError(t, test.Prepare(context.Background()))
+	return test
+}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+func testConfig(testType types.TestType) *types.OLTPTestConfig {
+	config := types.NewOLTPTestConfig()
+	config.TestType = testType
+	config.NumThreads = 4
+	config.NumTables = 2
+	config.TableSize = 200
+	config.RangeSize = 10
+	config.Duration = 200 * time.Millisecond
+	return config
+}
 
-	// Prepare test
-	t.Log("Preparing test...")
-	if err := test.Prepare(ctx); err != nil {
-		t.Fatalf("Failed to prepare test: %v", err)
-	}
-	defer func() {
-		if err := test.Cleanup(ctx); err != nil {
-			t.Errorf("Failed to cleanup test: %v", err)
-		}
-	}()
-
-	// Run test
-	t.Log("Running test...")
-	err := test.Run(ctx)
-	if err != nil {
-		t.Errorf("Failed to run test: %v", err)
+func TestOLTPTest(t *testing.T) {
+	testTypes := []types.TestType{
+		types.TestTypeOLTPRead,
+		types.TestTypeOLTPWrite,
+		types.TestTypeOLTPReadWrite,
+		types.TestTypeOLTPPointSelect,
+		types.TestTypeOLTPSimpleSelect,
+		types.TestTypeOLTPSumRange,
+		types.TestTypeOLTPOrderRange,
+		types.TestTypeOLTPDistinctRange,
+		types.TestTypeOLTPIndexScan,
+		types.TestTypeOLTPNonIndexScan,
 	}
 
-	// Verify test status
-	if test.Status().Status != string(types.TestStatusCompleted) {
-		t.Errorf("Expected test status to be completed, got %s", test.Status().Status)
+	for _, testType := range testTypes {
+		t.Run(string(testType), func(t *testing.T) {
+			test := newPreparedTest(t, testConfig(testType))
+			ctx := context.Background()
+			defer test.Cleanup(ctx)
+
+			require.NoError(t, test.Run(ctx))
+
+			report := test.GetReport()
+			require.NotNil(t, report)
+			assert.Equal(t, string(testType), report.Name)
+			assert.Greater(t, report.Stats.TotalTransactions, int64(0))
+			assert.Zero(t, report.Stats.TotalErrors)
+			assert.Greater(t, report.Stats.TPS, float64(0))
+			assert.Greater(t, report.Stats.AvgLatency, time.Duration(0))
+			assert.LessOrEqual(t, report.Stats.MinLatency, report.Stats.P50Latency)
+			assert.LessOrEqual(t, report.Stats.P50Latency, report.Stats.P99Latency)
+			assert.LessOrEqual(t, report.Stats.P99Latency, report.Stats.P999Latency)
+			assert.LessOrEqual(t, report.Stats.P999Latency, report.Stats.MaxLatency)
+			assert.NotEmpty(t, report.Breakdown)
+		})
 	}
+}
 
-	// Get report
-	report := test.GetReport()
-	if report == nil {
-		t.Error("Expected report to be non-nil")
+func TestOLTPTestIntervals(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	config.ReportInterval = 50 * time.Millisecond
+	test := newPreparedTest(t, config)
+
+	require.NoError(t, test.Run(context.Background()))
+
+	intervals := test.Intervals()
+	require.GreaterOrEqual(t, len(intervals), 3)
+	var transactions int64
+	for _, interval := range intervals {
+		transactions += interval.Transactions
+		assert.Greater(t, interval.Queries, interval.Transactions)
+		assert.LessOrEqual(t, interval.Latency.P95, test.GetReport().Stats.MaxLatency)
 	}
+	assert.Equal(t, test.GetReport().Stats.TotalTransactions, transactions)
+	assert.Greater(t, intervals[0].TPS, float64(0))
+}
+
+func TestOLTPTestStatementCounts(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPReadWrite)
+	config.NumThreads = 1
+	test := newPreparedTest(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, test.executeTransaction(ctx))
 
-	// Verify report
-	t.Log("Verifying report...")
-	if report.TestName != string(types.TestTypeOLTPRead) {
-		t.Errorf("Expected test name %s, got %s", types.TestTypeOLTPRead, report.TestName)
+	// 10 point selects, one select for each range type, two updates and a
+	// delete+insert pair, as in sysbench's oltp_read_write
+	assert.Equal(t, int64(14), test.reads)
+	assert.Equal(t, int64(4), test.writes)
+	assert.Equal(t, int64(2), test.other)
+
+	// Delete+insert keeps the table size unchanged
+	for table := 1; table <= config.NumTables; table++ {
+		var count int
+		require.NoError(t, test.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM sbtest%d", table)).Scan(&count))
+		assert.Equal(t, config.TableSize, count)
 	}
 
-	if report.TotalTransactions == 0 {
-		t.Error("Expected non-zero total transactions")
+	config.ReadOnly = true
+	require.NoError(t, test.executeTransaction(ctx))
+	assert.Equal(t, int64(28), test.reads)
+	assert.Equal(t, int64(4), test.writes)
+	assert.Equal(t, map[string]int64{
+		"point_select":     20,
+		"simple_range":     2,
+		"sum_range":        2,
+		"order_range":      2,
+		"distinct_range":   2,
+		"index_update":     1,
+		"non_index_update": 1,
+		"delete":           1,
+		"insert":           1,
+	}, test.statementCounts())
+
+	// The breakdown counts the rows each kind of statement read or wrote
+	breakdown := test.breakdown.Stats()
+	assert.Len(t, breakdown, 9)
+	assert.Equal(t, int64(20), breakdown["point_select"].Count)
+	assert.Equal(t, int64(20), breakdown["point_select"].Rows)
+	assert.Equal(t, int64(2*config.RangeSize), breakdown["simple_range"].Rows)
+	assert.Equal(t, int64(2), breakdown["sum_range"].Rows)
+	assert.Equal(t, int64(1), breakdown["delete"].Rows)
+	assert.Equal(t, int64(1), breakdown["insert"].Rows)
+	assert.Equal(t, int64(20), breakdown["point_select"].Latency.Count)
+	assert.Greater(t, breakdown["order_range"].Latency.Max, time.Duration(0))
+}
+
+func TestOLTPTestScanStatements(t *testing.T) {
+	testCases := []struct {
+		testType types.TestType
+		kind     statementKind
+	}{
+		{types.TestTypeOLTPOrderRange, stmtOrderRange},
+		{types.TestTypeOLTPDistinctRange, stmtDistinctRange},
+		{types.TestTypeOLTPIndexScan, stmtIndexScan},
+		{types.TestTypeOLTPNonIndexScan, stmtNonIndexScan},
 	}
 
-	if report.TPS == 0 {
-		t.Error("Expected non-zero TPS")
+	for _, tc := range testCases {
+		t.Run(string(tc.testType), func(t *testing.T) {
+			config := testConfig(tc.testType)
+			config.OrderRanges = 2
+			config.DistinctRanges = 2
+			config.IndexScans = 2
+			config.NonIndexScans = 2
+			test := newPreparedTest(t, config)
+
+			require.NoError(t, test.executeTransaction(context.Background()))
+
+			// Each mode runs only its own statement
+			assert.Equal(t, map[string]int64{statementNames[tc.kind]: 2}, test.statementCounts())
+			assert.Equal(t, int64(2), test.reads)
+			assert.Zero(t, test.writes)
+
+			test.mu.RLock()
+			metrics := test.metrics()
+			test.mu.RUnlock()
+			assert.Equal(t, string(tc.testType), metrics["test_type"])
+		})
 	}
+}
 
-	if report.LatencyAvg == 0 {
-		t.Error("Expected non-zero average latency")
+func TestOLTPTestPrepare(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	config.NumTables = 3
+	config.TableSize = 250
+	config.LoadBatchSize = 100
+	config.LoadThreads = 2
+	test := newPreparedTest(t, config)
+
+	for table := 1; table <= config.NumTables; table++ {
+		var count, minID, maxID, minK, maxK int
+		require.NoError(t, test.db.QueryRow(
+			fmt.Sprintf("SELECT COUNT(*), MIN(id), MAX(id), MIN(k), MAX(k) FROM sbtest%d", table),
+		).Scan(&count, &minID, &maxID, &minK, &maxK))
+		assert.Equal(t, config.TableSize, count)
+		assert.Equal(t, 1, minID)
+		assert.Equal(t, config.TableSize, maxID)
+		assert.GreaterOrEqual(t, minK, 1)
+		assert.LessOrEqual(t, maxK, config.TableSize)
+
+		var c, pad string
+		require.NoError(t, test.db.QueryRow(
+			fmt.Sprintf("SELECT c, pad FROM sbtest%d WHERE id = 1", table),
+		).Scan(&c, &pad))
+		assert.Regexp(t, regexp.MustCompile(`^(\d{11}-){9}\d{11}$`), c)
+		assert.Regexp(t, regexp.MustCompile(`^(\d{11}-){4}\d{11}$`), pad)
+
+		// The secondary index is created after loading
+		var index string
+		require.NoError(t, test.db.QueryRow(
+			"SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?",
+			fmt.Sprintf("sbtest%d", table),
+		).Scan(&index))
+		assert.Equal(t, fmt.Sprintf("k_%d", table), index)
 	}
 
-	// Test different OLTP test types
-	testTypes := []types.TestType{
-		types.TestTypeOLTPWrite,
-		types.TestTypeOLTPReadWrite,
-		types.TestTypeOLTPPointSelect,
-		types.TestTypeOLTPSimpleSelect,
-		types.TestTypeOLTPSumRange,
-		types.TestTypeOLTPOrderRange,
-		types.TestTypeOLTPDistinctRange,
+	status := test.Status()
+	assert.Equal(t, string(types.TestStatusPending), status.Status)
+	assert.Equal(t, float64(100), status.Progress)
+	assert.Equal(t, int64(750), status.Metrics["rows_loaded"])
+}
+
+func TestOLTPTestPrepareCancelled(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	test := NewOLTPTest(config, zaptest.NewLogger(t))
+	test.SetDB(newTestDB(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, test.Prepare(ctx))
+	assert.Equal(t, string(types.TestStatusFailed), test.Status().Status)
+}
+
+func TestOLTPTestQueryRateAndWarmup(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	config.QueryRate = 50
+	config.Warmup = 100 * time.Millisecond
+	config.Duration = 400 * time.Millisecond
+	test := newPreparedTest(t, config)
+
+	start := time.Now()
+	require.NoError(t, test.Run(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+
+	// About 20 paced transactions are measured after the warmup
+	report := test.GetReport()
+	assert.Greater(t, report.Stats.TotalTransactions, int64(0))
+	assert.LessOrEqual(t, report.Stats.TotalTransactions, int64(25))
+	assert.InDelta(t, 50, report.Stats.TPS, 15)
+}
+
+func TestOLTPTestPhases(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	config.RampUp = 100 * time.Millisecond
+	config.RampSteps = 2
+	config.Warmup = 50 * time.Millisecond
+	config.CoolDown = 50 * time.Millisecond
+	config.ReportInterval = time.Hour
+	test := newPreparedTest(t, config)
+
+	start := time.Now()
+	require.NoError(t, test.Run(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	phases := test.Phases()
+	require.Len(t, phases, 4)
+	assert.Equal(t, models.PhaseRampUp, phases[0].Phase)
+	assert.Equal(t, models.PhaseMeasure, phases[2].Phase)
+	assert.Equal(t, 150*time.Millisecond, phases[2].Start)
+	assert.Equal(t, config.Duration, phases[2].Duration)
+
+	// The headline stats cover the measurement only, the intervals are
+	// reported for each phase
+	stats := test.GetReport().Stats
+	assert.Equal(t, phases[2].Transactions, stats.TotalTransactions)
+	assert.InDelta(t, phases[2].TPS, stats.TPS, 0.001)
+
+	// Intervals count transactions when they finish, phases when they start
+	intervals := test.Intervals()
+	require.Len(t, intervals, 4)
+	var phaseTransactions, intervalTransactions int64
+	for i, interval := range intervals {
+		assert.Equal(t, phases[i].Phase, interval.Phase)
+		phaseTransactions += phases[i].Transactions
+		intervalTransactions += interval.Transactions
 	}
+	assert.Equal(t, phaseTransactions, intervalTransactions)
+}
 
-	for _, testType := range testTypes {
-		t.Run(string(testType), func(t *testing.T) {
-			config.TestType = testType
-			test := NewOLTPTest(config, zaptest.NewLogger(t))
+func TestOLTPTestErrorPolicy(t *testing.T) {
+	// Without tables every transaction fails
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	config.Duration = time.Hour
 
-			if err := test.Prepare(ctx); err != nil {
-				t.Fatalf("Failed to prepare %s test: %v", testType, err)
-			}
-			defer test.Cleanup(ctx)
+	t.Run("Continue", func(t *testing.T) {
+		config := *config
+		config.Duration = 100 * time.Millisecond
+		test := NewOLTPTest(&config, zaptest.NewLogger(t))
+		test.SetDB(newTestDB(t))
 
-			err := test.Run(ctx)
-			if err != nil {
-				t.Fatalf("Failed to run %s test: %v", testType, err)
-			}
+		require.NoError(t, test.Run(context.Background()))
+		assert.Greater(t, test.GetReport().Stats.TotalErrors, int64(0))
+	})
 
-			if test.Status().Status != string(types.TestStatusCompleted) {
-				t.Errorf("Expected test status to be completed, got %s", test.Status().Status)
-			}
+	t.Run("MaxCount", func(t *testing.T) {
+		config := *config
+		config.ErrorPolicy = models.ErrorPolicy{OnError: models.ErrorPolicyMaxCount, MaxErrors: 5}
+		test := NewOLTPTest(&config, zaptest.NewLogger(t))
+		test.SetDB(newTestDB(t))
 
-			report := test.GetReport()
-			if report == nil {
-				t.Fatal("Report is nil")
-			}
+		require.NoError(t, test.Start())
+		require.Eventually(t, func() bool {
+			return test.Status().Status == string(types.TestStatusFailed)
+		}, 5*time.Second, 10*time.Millisecond)
 
-			if report.TestName != string(testType) {
-				t.Errorf("Expected test name %s, got %s", testType, report.TestName)
-			}
-		})
+		metrics := test.Status().Metrics
+		assert.Contains(t, metrics["abort_reason"], "the limit is 5")
+		assert.GreaterOrEqual(t, metrics["error_classes"].(map[string]int64)["other"], int64(5))
+	})
+}
+
+func TestOLTPTestStartStop(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPReadWrite)
+	config.Duration = time.Hour
+	test := newPreparedTest(t, config)
+
+	require.NoError(t, test.Start())
+	assert.Error(t, test.Start())
+
+	time.Sleep(50 * time.Millisecond)
+	test.Stop()
+
+	require.Eventually(t, func() bool {
+		return test.Status().Progress == 100
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := test.Status()
+	assert.Equal(t, string(types.TestStatusCancelled), status.Status)
+	assert.Greater(t, status.Metrics["total_transactions"], int64(0))
+	assert.Greater(t, status.Metrics["queries_read"], int64(0))
+
+	// The status is a copy the caller may change
+	delete(status.Metrics, "total_transactions")
+	assert.Contains(t, test.Status().Metrics, "total_transactions")
+}
+
+func TestRandomColumnValues(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^(\d{11}-){9}\d{11}$`), randomC())
+	assert.Len(t, randomC(), 119)
+	assert.Regexp(t, regexp.MustCompile(`^(\d{11}-){4}\d{11}$`), randomPad())
+}
+
+func TestRandomRange(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPSimpleSelect)
+	test := NewOLTPTest(config, zaptest.NewLogger(t))
+
+	for i := 0; i < 1000; i++ {
+		start, end := test.randomRange()
+		assert.GreaterOrEqual(t, start, 1)
+		assert.LessOrEqual(t, end, config.TableSize)
+		assert.Equal(t, config.RangeSize-1, end-start)
 	}
+
+	config.RangeSize = config.TableSize * 2
+	start, end := test.randomRange()
+	assert.Equal(t, 1, start)
+	assert.Equal(t, config.TableSize, end)
 }
 
 func TestOLTPTestConfig(t *testing.T) {
@@ -129,33 +392,16 @@ func TestOLTPTestConfig(t *testing.T) {
 		t.Errorf("Expected default report interval to be 1s, got %s", config.ReportInterval)
 	}
 
-	// Test configuration updates
-	config.NumThreads = 4
-	config.Duration = 5 * time.Second
-	config.ReportInterval = 2 * time.Second
-
-	test := NewOLTPTest(config, zaptest.NewLogger(t))
-	args := test.buildBaseArgs()
-
-	// Verify command line arguments
-	expectedArgs := []string{
-		"--threads=4",
-		"--time=5",
-		"--report-interval=2",
-	}
-
-	for _, expected := range expectedArgs {
-		found := false
-		for _, arg := range args {
-			if arg == expected {
-				found = true
-				break
-			}
-		}
-		if !found {
-			t.Errorf("Expected argument %s not found in args: %v", expected, args)
-		}
-	}
+	// sysbench oltp_common defaults
+	assert.Equal(t, 10, config.PointSelects)
+	assert.Equal(t, 1, config.SimpleRanges)
+	assert.Equal(t, 1, config.SumRanges)
+	assert.Equal(t, 1, config.OrderRanges)
+	assert.Equal(t, 1, config.DistinctRanges)
+	assert.Equal(t, 1, config.IndexUpdates)
+	assert.Equal(t, 1, config.NonIndexUpdates)
+	assert.Equal(t, 1, config.DeleteInserts)
+	assert.Equal(t, 100, config.RangeSize)
 }
 
 func TestOLTPTestValidation(t *testing.T) {
@@ -164,6 +410,11 @@ func TestOLTPTestValidation(t *testing.T) {
 		configModify  func(*types.OLTPTestConfig)
 		expectedError bool
 	}{
+		{
+			name:          "valid",
+			configModify:  func(config *types.OLTPTestConfig) {},
+			expectedError: false,
+		},
 		{
 			name: "invalid threads",
 			configModify: func(config *types.OLTPTestConfig) {
@@ -178,220 +429,97 @@ func TestOLTPTestValidation(t *testing.T) {
 			},
 			expectedError: true,
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			config := types.NewOLTPTestConfig()
-			tc.configModify(config)
-
-			test := NewOLTPTest(config, zaptest.NewLogger(t))
-			ctx := context.Background()
-
-			err := test.Prepare(ctx)
-			if tc.expectedError && err == nil {
-				t.Error("Expected error but got nil")
-			}
-			if !tc.expectedError && err != nil {
-				t.Errorf("Expected no error but got: %v", err)
-			}
-
-			if err == nil {
-				defer test.Cleanup(ctx)
-
-				err := test.Run(ctx)
-				if tc.expectedError && err == nil {
-					t.Error("Expected error but got nil")
-				}
-				if !tc.expectedError && err != nil {
-					t.Errorf("Expected no error but got: %v", err)
-				}
-			}
-		})
-	}
-}
-
-func TestMultiDatabaseSupport(t *testing.T) {
-	testCases := []struct {
-		name     string
-		dbType   string
-		host     string
-		port     int
-		username string
-		password string
-		database string
-	}{
 		{
-			name:     "MySQL",
-			dbType:   "mysql",
-			host:     "localhost",
-			port:     3306,
-			username: "root",
-			password: "",
-			database: "sbtest",
+			name: "invalid table size",
+			configModify: func(config *types.OLTPTestConfig) {
+				config.TableSize = -1
+			},
+			expectedError: true,
 		},
 		{
-			name:     "PostgreSQL",
-			dbType:   "pgsql",
-			host:     "localhost",
-			port:     5432,
-			username: "postgres",
-			password: "",
-			database: "sbtest",
+			name: "invalid tables",
+			configModify: func(config *types.OLTPTestConfig) {
+				config.NumTables = 0
+			},
+			expectedError: true,
+		},
+		{
+			name: "invalid index scans",
+			configModify: func(config *types.OLTPTestConfig) {
+				config.TestType = types.TestTypeOLTPIndexScan
+				config.IndexScans = 0
+			},
+			expectedError: true,
+		},
+		{
+			name: "invalid non-index scans",
+			configModify: func(config *types.OLTPTestConfig) {
+				config.TestType = types.TestTypeOLTPNonIndexScan
+				config.NonIndexScans = 0
+			},
+			expectedError: true,
+		},
+		{
+			name: "invalid test type",
+			configModify: func(config *types.OLTPTestConfig) {
+				config.TestType = "oltp_unknown"
+			},
+			expectedError: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			config := types.NewOLTPTestConfig()
-			config.DBType = tc.dbType
-			config.Host = tc.host
-			config.Port = tc.port
-			config.Username = tc.username
-			config.Password = tc.password
-			config.Database = tc.database
-			config.TableSize = 100
-			config.TablesCount = 1
+			tc.configModify(config)
 
 			test := NewOLTPTest(config, zaptest.NewLogger(t))
-			args := test.buildBaseArgs()
-
-			// Verify database-specific arguments
-			expectedArgs := []string{
-				"--db-driver=" + tc.dbType,
-				"--mysql-host=" + tc.host,
-				"--mysql-port=" + string(tc.port),
-				"--mysql-user=" + tc.username,
-				"--mysql-password=" + tc.password,
-				"--mysql-db=" + tc.database,
-			}
+			test.SetDB(newTestDB(t))
+			ctx := context.Background()
 
-			for _, expected := range expectedArgs {
-				found := false
-				for _, arg := range args {
-					if arg == expected {
-						found = true
-						break
-					}
-				}
-				assert.True(t, found, "Expected argument %s not found in args: %v", expected, args)
+			err := test.Prepare(ctx)
+			if tc.expectedError {
+				assert.Error(t, err)
+				assert.Error(t, test.Start())
+				return
 			}
+			require.NoError(t, err)
+			assert.NoError(t, test.Cleanup(ctx))
 		})
 	}
 }
 
-func TestHighConcurrency(t *testing.T) {
-	config := types.NewOLTPTestConfig()
-	config.TestType = types.TestTypeOLTPReadWrite
-	config.NumThreads = 32
-	config.TableSize = 1000
-	config.TablesCount = 4
-	config.Duration = 10 * time.Second
-
-	test := NewOLTPTest(config, zaptest.NewLogger(t))
-	ctx := context.Background()
-
-	// Prepare test data
-	err := test.Prepare(ctx)
-	require.NoError(t, err)
-	defer test.Cleanup(ctx)
-
-	// Run high concurrency test
-	err = test.Run(ctx)
-	require.NoError(t, err)
-
-	// Verify high concurrency metrics
-	report := test.GetReport()
-	require.NotNil(t, report)
-
-	assert.True(t, report.TotalTransactions > 0, "Expected non-zero total transactions")
-	assert.True(t, report.TPS > 0, "Expected non-zero TPS")
-	assert.True(t, report.LatencyAvg > 0, "Expected non-zero average latency")
-	assert.True(t, report.LatencyP95 > 0, "Expected non-zero P95 latency")
-	assert.True(t, report.LatencyP99 > 0, "Expected non-zero P99 latency")
-}
-
-func TestStressTest(t *testing.T) {
-	config := types.NewOLTPTestConfig()
-	config.TestType = types.TestTypeOLTPReadWrite
-	config.NumThreads = 16
-	config.TableSize = 10000
-	config.TablesCount = 8
-	config.Duration = 30 * time.Second
-
-	test := NewOLTPTest(config, zaptest.NewLogger(t))
-	ctx := context.Background()
-
-	// Prepare test data
-	err := test.Prepare(ctx)
-	require.NoError(t, err)
-	defer test.Cleanup(ctx)
-
-	// Run stress test
-	err = test.Run(ctx)
-	require.NoError(t, err)
-
-	// Verify stress test metrics
-	report := test.GetReport()
-	require.NotNil(t, report)
-
-	assert.True(t, report.TotalTransactions > 0, "Expected non-zero total transactions")
-	assert.True(t, report.TPS > 0, "Expected non-zero TPS")
-	assert.True(t, report.LatencyAvg > 0, "Expected non-zero average latency")
-	assert.True(t, report.LatencyP95 > report.LatencyAvg, "P95 latency should be higher than average")
-	assert.True(t, report.LatencyP99 > report.LatencyP95, "P99 latency should be higher than P95")
-}
-
 func TestErrorRecovery(t *testing.T) {
-	config := types.NewOLTPTestConfig()
-	config.TestType = types.TestTypeOLTPReadWrite
-	config.NumThreads = 4
-	config.TableSize = 100
-	config.TablesCount = 2
-
-	test := NewOLTPTest(config, zaptest.NewLogger(t))
-	ctx := context.Background()
-
-	t.Run("DatabaseConnectionError", func(t *testing.T) {
-		// Test with invalid database connection
-		config.Host = "nonexistent-host"
-		test := NewOLTPTest(config, zaptest.NewLogger(t))
-		err := test.Prepare(ctx)
-		assert.Error(t, err, "Expected error for invalid database connection")
+	t.Run("DatabaseNotConnected", func(t *testing.T) {
+		test := NewOLTPTest(types.NewOLTPTestConfig(), zaptest.NewLogger(t))
+		assert.ErrorIs(t, test.Prepare(context.Background()), types.ErrDatabaseNotConnected)
+		assert.Error(t, test.Run(context.Background()))
+		assert.Error(t, test.Start())
 	})
 
-	t.Run("InvalidTableSize", func(t *testing.T) {
-		// Test with invalid table size
-		config.TableSize = -1
+	t.Run("MissingTables", func(t *testing.T) {
+		config := testConfig(types.TestTypeOLTPRead)
 		test := NewOLTPTest(config, zaptest.NewLogger(t))
-		err := test.Prepare(ctx)
-		assert.Error(t, err, "Expected error for invalid table size")
-	})
+		test.SetDB(newTestDB(t))
 
-	t.Run("InvalidThreadCount", func(t *testing.T) {
-		// Test with invalid thread count
-		config.NumThreads = 0
-		test := NewOLTPTest(config, zaptest.NewLogger(t))
-		err := test.Prepare(ctx)
-		assert.Error(t, err, "Expected error for invalid thread count")
+		// Failed transactions are counted and the test keeps running
+		require.NoError(t, test.Run(context.Background()))
+		assert.Greater(t, test.GetReport().Stats.TotalErrors, int64(0))
+		assert.Zero(t, test.GetReport().Stats.TotalTransactions)
 	})
 
 	t.Run("TestInterruption", func(t *testing.T) {
-		// Test interruption during test execution
-		config := types.NewOLTPTestConfig()
+		config := testConfig(types.TestTypeOLTPReadWrite)
 		config.Duration = 10 * time.Second
-		test := NewOLTPTest(config, zaptest.NewLogger(t))
+		test := newPreparedTest(t, config)
 
 		ctx, cancel := context.WithCancel(context.Background())
-		err := test.Prepare(ctx)
-		require.NoError(t, err)
-		defer test.Cleanup(ctx)
+		defer test.Cleanup(context.Background())
 
 		// Start test in goroutine
 		errChan := make(chan error)
 		go func() {
-			err := test.Run(ctx)
-			errChan <- err
+			errChan <- test.Run(ctx)
 		}()
 
 		// Cancel context after short delay
@@ -399,7 +527,7 @@ func TestErrorRecovery(t *testing.T) {
 		cancel()
 
 		// Verify test was interrupted
-		err = <-errChan
-		assert.Error(t, err, "Expected error due to context cancellation")
+		err := <-errChan
+		assert.ErrorIs(t, err, context.Canceled)
 	})
 }
@@ -3,10 +3,13 @@ package sysbench
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/deadjoe/benchphant/internal/benchmark"
 	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
 	"go.uber.org/zap"
 )
 
@@ -24,6 +27,11 @@ const (
 	ScenarioTypeStressTest      = ScenarioType(types.ScenarioTypeStressTest)
 )
 
+// scenarioCleanupTimeout bounds dropping the tables of a scenario, which runs
+// on a context of its own so that a stopped or timed out scenario still
+// cleans up
+var scenarioCleanupTimeout = time.Minute
+
 // Scenario represents a test scenario configuration
 type Scenario struct {
 	Type        ScenarioType
@@ -31,6 +39,19 @@ type Scenario struct {
 	Description string
 	Duration    time.Duration
 	Tests       []*types.Test
+
+	// DBType selects the SQL dialect the tests run with
+	DBType models.DBType
+
+	// ReportInterval is the interval a ScenarioRunner reports the progress
+	// of the scenario at
+	ReportInterval time.Duration
+
+	// ErrorPolicy replaces the error policy of every test when set
+	ErrorPolicy models.ErrorPolicy
+
+	// intervals records the intervals of all tests when set
+	intervals *benchmark.IntervalRecorder
 }
 
 // NewScenario creates a new test scenario
@@ -262,43 +283,175 @@ func (s *Scenario) addStressTests() {
 	)
 }
 
-// Run executes the scenario
-func (s *Scenario) Run(ctx context.Context, db *sql.DB, logger *zap.Logger) ([]*types.Report, error) {
-	var reports []*types.Report
-
-	// Create test configuration
-	config := types.NewOLTPTestConfig()
-	config.TestType = types.TestType(s.Type)
-	config.Duration = s.Duration
-	config.NumThreads = 8
-	config.NumTables = 4
-	config.TableSize = 1000000
+// Run prepares the tables once, runs each test of the scenario in sequence
+// with its own config and duration, and cleans up. It returns a report for
+// every test and one for the whole scenario.
+func (s *Scenario) Run(ctx context.Context, db *sql.DB, logger *zap.Logger) (*types.ScenarioReport, error) {
+	if len(s.Tests) == 0 {
+		return nil, fmt.Errorf("scenario %s has no tests", s.Name)
+	}
 
-	// Create test
-	test := NewOLTPTest(config)
-	test.SetDB(db)
-	test.SetLogger(logger)
+	configs := make([]*types.OLTPTestConfig, len(s.Tests))
+	for i, test := range s.Tests {
+		configs[i] = s.testConfig(test)
+	}
 
-	// Prepare test
-	if err := test.Prepare(ctx); err != nil {
+	// Prepare tables large enough for every test
+	prepare := NewOLTPTest(tablesConfig(configs), logger)
+	prepare.SetDB(db)
+	prepare.SetDBType(s.DBType)
+	if err := prepare.Prepare(ctx); err != nil {
 		return nil, fmt.Errorf("prepare test failed: %w", err)
 	}
 
-	// Run test
-	if err := test.Run(ctx); err != nil {
-		return nil, fmt.Errorf("run test failed: %w", err)
+	report, err := s.runTests(ctx, db, logger, configs)
+
+	// Clean up, even when ctx is done
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), scenarioCleanupTimeout)
+	defer cancel()
+	if cleanupErr := prepare.Cleanup(cleanupCtx); cleanupErr != nil && err == nil {
+		err = fmt.Errorf("cleanup test failed: %w", cleanupErr)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
 
-	// Get report
-	report := test.GetReport()
-	reports = append(reports, report)
+// runTests runs the tests of the scenario with configs
+func (s *Scenario) runTests(ctx context.Context, db *sql.DB, logger *zap.Logger, configs []*types.OLTPTestConfig) (*types.ScenarioReport, error) {
+	report := &types.ScenarioReport{Name: s.Name}
+	stats := make([]*types.TestStats, 0, len(s.Tests))
+	var elapsed time.Duration
+
+	for i, test := range s.Tests {
+		logger.Info("Running scenario test",
+			zap.String("scenario", s.Name),
+			zap.String("test", test.Name),
+			zap.String("test_type", string(configs[i].TestType)),
+			zap.Duration("duration", configs[i].Duration),
+		)
+
+		oltpTest := NewOLTPTest(configs[i], logger)
+		oltpTest.SetDB(db)
+		oltpTest.SetDBType(s.DBType)
+		if s.intervals != nil {
+			oltpTest.useIntervals(s.intervals)
+		}
 
-	// Clean up
-	if err := test.Cleanup(ctx); err != nil {
-		return nil, fmt.Errorf("cleanup test failed: %w", err)
+		start := time.Now()
+		if err := oltpTest.Run(ctx); err != nil {
+			return nil, fmt.Errorf("run test %s failed: %w", test.Name, err)
+		}
+		elapsed += time.Since(start)
+
+		phase := oltpTest.GetReport()
+		if test.Name != "" {
+			phase.Name = test.Name
+		}
+		report.Phases = append(report.Phases, phase)
+		stats = append(stats, phase.Stats)
 	}
 
-	return reports, nil
+	report.Total = &types.Report{
+		Name:     s.Name,
+		Duration: elapsed,
+		Stats:    types.MergeTestStats(elapsed, stats...),
+	}
+	return report, nil
+}
+
+// testConfig returns the config test runs with: a copy of its config, or
+// the defaults, with the test's type and overrides applied
+func (s *Scenario) testConfig(test *types.Test) *types.OLTPTestConfig {
+	config := types.NewOLTPTestConfig()
+	if test.Config != nil {
+		*config = *test.Config
+	}
+	config.TestType = test.Type
+	if test.Duration > 0 {
+		config.Duration = test.Duration
+	}
+	if test.Threads > 0 {
+		config.NumThreads = test.Threads
+	}
+	if test.NumTables > 0 {
+		config.NumTables = test.NumTables
+	}
+	if test.TableSize > 0 {
+		config.TableSize = test.TableSize
+	}
+	if test.Rate > 0 {
+		config.QueryRate = test.Rate
+	}
+	if test.Warmup > 0 {
+		config.Warmup = test.Warmup
+	}
+	if !s.ErrorPolicy.IsZero() {
+		config.ErrorPolicy = s.ErrorPolicy
+	}
+	return config
+}
+
+// tablesConfig returns a config for creating the tables of all configs: the
+// first config with the largest number of tables and table size
+func tablesConfig(configs []*types.OLTPTestConfig) *types.OLTPTestConfig {
+	config := *configs[0]
+	for _, c := range configs[1:] {
+		if c.NumTables > config.NumTables {
+			config.NumTables = c.NumTables
+		}
+		if c.TableSize > config.TableSize {
+			config.TableSize = c.TableSize
+		}
+	}
+	return &config
+}
+
+// NewScenarioFromDefinition creates a scenario from a stored definition
+func NewScenarioFromDefinition(def *types.Scenario) (*Scenario, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Scenario{
+		Type:        ScenarioType(def.Type),
+		Name:        def.Name,
+		Description: def.Description,
+		Duration:    def.Duration,
+		Tests:       def.Tests,
+	}, nil
+}
+
+// Definition returns the storable definition of the scenario
+func (s *Scenario) Definition() types.Scenario {
+	return types.Scenario{
+		Type:        types.ScenarioType(s.Type),
+		Name:        s.Name,
+		Description: s.Description,
+		Duration:    s.Duration,
+		Tests:       s.Tests,
+	}
+}
+
+// FindScenario returns the scenario stored in storage under name or, when
+// there is none, the predefined scenario with that type or name
+func FindScenario(storage *FileStorage, name string) (*Scenario, error) {
+	def, err := storage.LoadScenario(name)
+	if err == nil {
+		return NewScenarioFromDefinition(def)
+	}
+	if !errors.Is(err, types.ErrScenarioNotFound) {
+		return nil, err
+	}
+
+	for _, s := range GetPredefinedScenarios() {
+		if string(s.Type) == name || s.Name == name {
+			scenario := s
+			return &scenario, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", types.ErrScenarioNotFound, name)
 }
 
 // GetPredefinedScenarios returns a list of predefined test scenarios
@@ -0,0 +1,150 @@
+package sysbench
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
+	"go.uber.org/zap"
+)
+
+// ScenarioRunner runs a scenario as a benchmark.BenchmarkRunner, so stored
+// scenarios can be started through the API and CLI like any workload
+type ScenarioRunner struct {
+	scenario  *Scenario
+	db        *sql.DB
+	logger    *zap.Logger
+	status    benchmark.BenchmarkStatus
+	report    *types.ScenarioReport
+	intervals *benchmark.IntervalRecorder
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
+}
+
+// NewScenarioRunner creates a runner for scenario
+func NewScenarioRunner(scenario *Scenario, db *sql.DB, logger *zap.Logger) *ScenarioRunner {
+	// One recorder spans all tests, so intervals continue across phases
+	interval := scenario.ReportInterval
+	if interval <= 0 {
+		interval = benchmark.DefaultReportInterval
+	}
+	scenario.intervals = benchmark.NewIntervalRecorder(interval)
+
+	return &ScenarioRunner{
+		scenario:  scenario,
+		db:        db,
+		logger:    logger,
+		intervals: scenario.intervals,
+		status: benchmark.BenchmarkStatus{
+			Status:  string(types.TestStatusPending),
+			Metrics: make(map[string]interface{}),
+		},
+	}
+}
+
+// Start starts running the scenario
+func (r *ScenarioRunner) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status.Status == string(types.TestStatusRunning) {
+		return fmt.Errorf("scenario is already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.status.Status = string(types.TestStatusRunning)
+	r.status.Progress = 0
+	r.status.Metrics = map[string]interface{}{"scenario": r.scenario.Name}
+
+	go func() {
+		defer cancel()
+		r.intervals.Start()
+		report, err := r.scenario.Run(ctx, r.db, r.logger)
+		r.intervals.Stop()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			r.status.Status = string(types.TestStatusCancelled)
+		case err != nil:
+			r.logger.Error("Scenario failed", zap.String("scenario", r.scenario.Name), zap.Error(err))
+			r.status.Status = string(types.TestStatusFailed)
+			r.status.Metrics["error"] = err.Error()
+		default:
+			r.report = report
+			r.status.Status = string(types.TestStatusCompleted)
+			r.status.Progress = 100
+			r.status.Metrics = scenarioMetrics(report)
+		}
+	}()
+
+	return nil
+}
+
+// Stop cancels the scenario
+func (r *ScenarioRunner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		// The scenario goroutine records the cancellation once Run returns
+		r.cancel()
+		return
+	}
+	r.status.Status = string(types.TestStatusCancelled)
+}
+
+// Status returns a copy of the current status of the scenario
+func (r *ScenarioRunner) Status() benchmark.BenchmarkStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status.Copy()
+}
+
+// Intervals returns the reports of the intervals finished so far
+func (r *ScenarioRunner) Intervals() []models.IntervalReport {
+	return r.intervals.Intervals()
+}
+
+// Report returns the report of the finished scenario, or nil
+func (r *ScenarioRunner) Report() *types.ScenarioReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.report
+}
+
+// scenarioMetrics returns the status metrics of a finished scenario: the
+// totals under the usual keys and a summary of each phase
+func scenarioMetrics(report *types.ScenarioReport) map[string]interface{} {
+	phases := make([]map[string]interface{}, 0, len(report.Phases))
+	for _, phase := range report.Phases {
+		phases = append(phases, reportMetrics(phase))
+	}
+
+	metrics := reportMetrics(report.Total)
+	metrics["scenario"] = report.Name
+	metrics["phases"] = phases
+	return metrics
+}
+
+// reportMetrics returns the metrics of a test report
+func reportMetrics(report *types.Report) map[string]interface{} {
+	metrics := report.Stats.LatencyMetrics()
+	metrics["name"] = report.Name
+	metrics["duration"] = report.Duration
+	metrics["total_transactions"] = report.Stats.TotalTransactions
+	metrics["tps"] = report.Stats.TPS
+	metrics["errors"] = report.Stats.TotalErrors
+	if report.Breakdown != nil {
+		metrics["breakdown"] = report.Breakdown
+	}
+	return metrics
+}
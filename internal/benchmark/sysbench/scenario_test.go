@@ -0,0 +1,172 @@
+package sysbench
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestScenarioRun(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPReadWrite)
+	large := *config
+	large.NumTables = 3
+
+	scenario := &Scenario{
+		Name: "Phases",
+		Tests: []*types.Test{
+			{Name: "Point Selects", Type: types.TestTypeOLTPPointSelect, Config: config, Duration: 100 * time.Millisecond},
+			{Name: "Write Mix", Type: types.TestTypeOLTPReadWrite, Config: &large, Duration: 150 * time.Millisecond},
+		},
+	}
+
+	db := newTestDB(t)
+	report, err := scenario.Run(context.Background(), db, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	// One report per test, in order, with the test's own duration
+	require.Len(t, report.Phases, 2)
+	assert.Equal(t, "Point Selects", report.Phases[0].Name)
+	assert.Equal(t, 100*time.Millisecond, report.Phases[0].Duration)
+	assert.Equal(t, "Write Mix", report.Phases[1].Name)
+	assert.Equal(t, 150*time.Millisecond, report.Phases[1].Duration)
+
+	var total int64
+	for _, phase := range report.Phases {
+		assert.Greater(t, phase.Stats.TotalTransactions, int64(0))
+		assert.Zero(t, phase.Stats.TotalErrors)
+		total += phase.Stats.TotalTransactions
+	}
+
+	// The scenario report aggregates all phases
+	require.NotNil(t, report.Total)
+	assert.Equal(t, "Phases", report.Total.Name)
+	assert.Equal(t, total, report.Total.Stats.TotalTransactions)
+	assert.GreaterOrEqual(t, report.Total.Duration, 250*time.Millisecond)
+	assert.Greater(t, report.Total.Stats.TPS, float64(0))
+	assert.Greater(t, report.Total.Stats.P99Latency, time.Duration(0))
+	assert.Equal(t, total, report.Total.Stats.Histogram.Count())
+	for _, phase := range report.Phases {
+		assert.LessOrEqual(t, phase.Stats.MaxLatency, report.Total.Stats.MaxLatency)
+	}
+
+	// The scenario config was not modified and the tables were dropped
+	assert.Equal(t, types.TestTypeOLTPReadWrite, config.TestType)
+	var tables int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'sbtest%'").Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestScenarioRunCancelled(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPPointSelect)
+	scenario := &Scenario{
+		Name:  "Stopped",
+		Tests: []*types.Test{{Name: "Point Selects", Type: types.TestTypeOLTPPointSelect, Config: config, Duration: time.Hour}},
+	}
+
+	// The tables are dropped even though the run was stopped
+	db := newTestDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, _ = scenario.Run(ctx, db, zaptest.NewLogger(t))
+
+	var tables int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'sbtest%'").Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestScenarioRunErrors(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	_, err := (&Scenario{Name: "Empty"}).Run(context.Background(), newTestDB(t), logger)
+	assert.Error(t, err)
+
+	config := testConfig(types.TestTypeOLTPReadWrite)
+	scenario := &Scenario{
+		Name:  "Invalid",
+		Tests: []*types.Test{{Name: "Unknown", Type: "oltp_unknown", Config: config}},
+	}
+	_, err = scenario.Run(context.Background(), newTestDB(t), logger)
+	assert.Error(t, err)
+}
+
+func TestPredefinedScenarioPlans(t *testing.T) {
+	scenario := NewScenario(ScenarioTypeReadIntensive)
+	require.Len(t, scenario.Tests, 3)
+
+	var total time.Duration
+	for _, test := range scenario.Tests {
+		config := scenario.testConfig(test)
+		assert.Equal(t, test.Type, config.TestType)
+		assert.Equal(t, test.Duration, config.Duration)
+		assert.Equal(t, 16, config.NumThreads)
+		total += config.Duration
+	}
+	assert.Equal(t, scenario.Duration, total)
+}
+
+func TestScenarioRunner(t *testing.T) {
+	config := testConfig(types.TestTypeOLTPReadWrite)
+	storage, err := NewFileStorage(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveScenario(types.Scenario{
+		Name: "recipe",
+		Tests: []*types.Test{
+			{Name: "Warm Reads", Type: types.TestTypeOLTPPointSelect, Config: config, Duration: 100 * time.Millisecond, Warmup: 50 * time.Millisecond},
+			{Name: "Paced Writes", Type: types.TestTypeOLTPWrite, Config: config, Duration: 100 * time.Millisecond, Rate: 100, Threads: 2},
+		},
+	}))
+
+	// Stored scenarios are selected through the workload config, from the
+	// directory the factory was created with
+	factory := NewFactory(storage.baseDir)
+	runner, err := factory.Create(
+		&models.Benchmark{Config: json.RawMessage(`{"scenario": "recipe"}`), ReportInterval: 50 * time.Millisecond},
+		&models.DBConnection{Driver: "sqlite3", DB: newTestDB(t)},
+		zaptest.NewLogger(t),
+	)
+	require.NoError(t, err)
+	require.IsType(t, &ScenarioRunner{}, runner)
+
+	require.NoError(t, runner.Start())
+	require.Eventually(t, func() bool {
+		return runner.Status().Status == string(types.TestStatusCompleted)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	metrics := runner.Status().Metrics
+	assert.Equal(t, "recipe", metrics["scenario"])
+	assert.Greater(t, metrics["total_transactions"], int64(0))
+	phases, ok := metrics["phases"].([]map[string]interface{})
+	require.True(t, ok)
+	require.Len(t, phases, 2)
+	assert.Equal(t, "Warm Reads", phases[0]["name"])
+	assert.Equal(t, "Paced Writes", phases[1]["name"])
+	delete(metrics, "scenario")
+	assert.Contains(t, runner.Status().Metrics, "scenario")
+
+	// The intervals run on across the phases
+	intervals := runner.(*ScenarioRunner).Intervals()
+	require.GreaterOrEqual(t, len(intervals), 4)
+	assert.Greater(t, intervals[len(intervals)-1].Elapsed, 250*time.Millisecond)
+
+	_, err = factory.Create(
+		&models.Benchmark{Config: json.RawMessage(`{"scenario": "missing"}`)},
+		&models.DBConnection{Driver: "sqlite3", DB: newTestDB(t)},
+		zaptest.NewLogger(t),
+	)
+	assert.ErrorIs(t, err, types.ErrScenarioNotFound)
+
+	// Clients cannot point the server at another directory
+	_, err = factory.Create(
+		&models.Benchmark{Config: json.RawMessage(`{"scenario": "recipe", "scenario_dir": "/etc"}`)},
+		&models.DBConnection{Driver: "sqlite3", DB: newTestDB(t)},
+		zaptest.NewLogger(t),
+	)
+	assert.ErrorContains(t, err, "scenario_dir cannot be set")
+}
@@ -1,9 +1,10 @@
 package sysbench
 
 import (
-	"sort"
 	"sync"
 	"time"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
 )
 
 // TestStats represents test statistics
@@ -12,15 +13,19 @@ type TestStats struct {
 	startTime         time.Time
 	totalTransactions int64
 	errors            int64
-	latencies         []time.Duration
+	latencies         *metrics.Histogram
 }
 
 // Stats represents a snapshot of test statistics
 type Stats struct {
 	TPS               float64
 	LatencyAvg        time.Duration
+	LatencyP50        time.Duration
+	LatencyP90        time.Duration
 	LatencyP95        time.Duration
 	LatencyP99        time.Duration
+	LatencyP999       time.Duration
+	LatencyMax        time.Duration
 	TotalTransactions int64
 	Errors            int64
 }
@@ -29,7 +34,7 @@ type Stats struct {
 func NewTestStats() *TestStats {
 	return &TestStats{
 		startTime: time.Now(),
-		latencies: make([]time.Duration, 0, 1000),
+		latencies: metrics.NewHistogram(),
 	}
 }
 
@@ -39,7 +44,7 @@ func (s *TestStats) AddTransaction(latency time.Duration) {
 	defer s.mu.Unlock()
 
 	s.totalTransactions++
-	s.latencies = append(s.latencies, latency)
+	s.latencies.Record(latency)
 }
 
 // AddError adds an error to the statistics
@@ -66,31 +71,14 @@ func (s *TestStats) GetStats() Stats {
 		Errors:            s.errors,
 	}
 
-	if len(s.latencies) > 0 {
-		// Calculate average latency
-		var total time.Duration
-		for _, lat := range s.latencies {
-			total += lat
-		}
-		stats.LatencyAvg = total / time.Duration(len(s.latencies))
-
-		// Sort latencies for percentiles
-		sorted := make([]time.Duration, len(s.latencies))
-		copy(sorted, s.latencies)
-		sort.Slice(sorted, func(i, j int) bool {
-			return sorted[i] < sorted[j]
-		})
-
-		// Calculate percentiles
-		p95 := int(float64(len(sorted)) * 0.95)
-		p99 := int(float64(len(sorted)) * 0.99)
-		if p95 < len(sorted) {
-			stats.LatencyP95 = sorted[p95]
-		}
-		if p99 < len(sorted) {
-			stats.LatencyP99 = sorted[p99]
-		}
-	}
+	latency := s.latencies.Summary()
+	stats.LatencyAvg = latency.Avg
+	stats.LatencyP50 = latency.P50
+	stats.LatencyP90 = latency.P90
+	stats.LatencyP95 = latency.P95
+	stats.LatencyP99 = latency.P99
+	stats.LatencyP999 = latency.P999
+	stats.LatencyMax = latency.Max
 
 	return stats
 }
@@ -103,5 +91,5 @@ func (s *TestStats) Reset() {
 	s.startTime = time.Now()
 	s.totalTransactions = 0
 	s.errors = 0
-	s.latencies = make([]time.Duration, 0, 1000)
+	s.latencies.Reset()
 }
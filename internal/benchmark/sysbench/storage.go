@@ -1,9 +1,8 @@
 package sysbench
 
 import (
-	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -64,8 +63,12 @@ func (s *FileStorage) LoadReport(filename string) (*types.Report, error) {
 	return &report, nil
 }
 
-// SaveScenario saves a test scenario to a file
+// SaveScenario validates a test scenario and saves it to a file
 func (s *FileStorage) SaveScenario(scenario types.Scenario) error {
+	if err := scenario.Validate(); err != nil {
+		return fmt.Errorf("invalid scenario: %w", err)
+	}
+
 	scenarioDir := filepath.Join(s.baseDir, "scenarios")
 	if err := os.MkdirAll(scenarioDir, 0755); err != nil {
 		return fmt.Errorf("failed to create scenarios directory: %v", err)
@@ -86,11 +89,18 @@ func (s *FileStorage) SaveScenario(scenario types.Scenario) error {
 	return nil
 }
 
-// LoadScenario loads a test scenario from a file
+// LoadScenario loads a test scenario from a file. It returns
+// types.ErrScenarioNotFound when no scenario is stored under name.
 func (s *FileStorage) LoadScenario(name string) (*types.Scenario, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid scenario name: %s", name)
+	}
 	filepath := filepath.Join(s.baseDir, "scenarios", fmt.Sprintf("%s.json", name))
 
 	data, err := os.ReadFile(filepath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", types.ErrScenarioNotFound, name)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read scenario file: %v", err)
 	}
@@ -1,10 +1,13 @@
 package sysbench
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark/sysbench/types"
 )
 
 // testHelper contains common test utilities and assertions
@@ -39,24 +42,28 @@ func (h *testHelper) cleanup() {
 }
 
 // createTestReport creates a test report with sample data
-func (h *testHelper) createTestReport() *Report {
-	startTime := time.Now().Add(-time.Hour)
-	endTime := time.Now()
-	config := TestConfig{
-		Threads:   4,
-		Tables:    10,
-		TableSize: 1000,
-		Database:  "test_db",
+func (h *testHelper) createTestReport() types.Report {
+	stats := types.NewTestStats()
+	stats.AddTransaction(10 * time.Millisecond)
+	stats.AddTransaction(20 * time.Millisecond)
+	stats.AddError()
+	return types.Report{
+		Name:     string(types.TestTypeOLTPReadWrite),
+		Duration: time.Minute,
+		Stats:    stats,
 	}
-	stats := Stats{
-		TPS:               1000.0,
-		LatencyAvg:        time.Millisecond * 10,
-		LatencyP95:        time.Millisecond * 20,
-		LatencyP99:        time.Millisecond * 30,
-		TotalTransactions: 10000,
-		Errors:            5,
+}
+
+// createTestScenario creates a scenario definition with two phases
+func (h *testHelper) createTestScenario() types.Scenario {
+	return types.Scenario{
+		Name:     "nightly",
+		Duration: 15 * time.Minute,
+		Tests: []*types.Test{
+			{Name: "Reads", Type: types.TestTypeOLTPRead, Duration: 10 * time.Minute, Threads: 16, Warmup: time.Minute},
+			{Name: "Writes", Type: types.TestTypeOLTPWrite, Duration: 5 * time.Minute, TableSize: 50000, Rate: 200},
+		},
 	}
-	return NewReport("test_oltp", config, stats, startTime, endTime)
 }
 
 // assertFileCount verifies the number of files in a directory
@@ -75,6 +82,7 @@ func TestFileStorage(t *testing.T) {
 	defer h.cleanup()
 
 	report := h.createTestReport()
+	scenario := h.createTestScenario()
 
 	// Test SaveReport
 	t.Run("SaveReport", func(t *testing.T) {
@@ -84,32 +92,37 @@ func TestFileStorage(t *testing.T) {
 		h.assertFileCount(filepath.Join(h.tmpDir, "reports"), 1)
 	})
 
-	// Test ListReports
-	t.Run("ListReports", func(t *testing.T) {
-		reports, err := h.storage.ListReports()
+	// Test LoadReport
+	t.Run("LoadReport", func(t *testing.T) {
+		files, err := os.ReadDir(filepath.Join(h.tmpDir, "reports"))
+		if err != nil || len(files) == 0 {
+			t.Fatalf("No reports found: %v", err)
+		}
+
+		loaded, err := h.storage.LoadReport(files[0].Name())
 		if err != nil {
-			t.Errorf("ListReports failed: %v", err)
+			t.Fatalf("LoadReport failed: %v", err)
 		}
-		if len(reports) != 1 {
-			t.Errorf("Expected 1 report, got %d", len(reports))
+		if loaded.Name != report.Name {
+			t.Errorf("Expected test name %s, got %s", report.Name, loaded.Name)
 		}
-		if reports[0].TestName != report.TestName {
-			t.Errorf("Expected test name %s, got %s", report.TestName, reports[0].TestName)
+		if loaded.Stats.TotalTransactions != 2 {
+			t.Errorf("Expected 2 transactions, got %d", loaded.Stats.TotalTransactions)
 		}
 	})
 
-	// Test SaveScenarioReports
-	t.Run("SaveScenarioReports", func(t *testing.T) {
-		scenario := &Scenario{
-			Type:        ScenarioTypeBasicOLTP,
-			Name:        "Basic OLTP Test",
-			Description: "Basic OLTP test scenario",
-			Duration:    time.Hour,
+	// Test SaveScenario
+	t.Run("SaveScenario", func(t *testing.T) {
+		if err := h.storage.SaveScenario(scenario); err != nil {
+			t.Errorf("SaveScenario failed: %v", err)
 		}
-		reports := []*Report{report}
+		h.assertFileCount(filepath.Join(h.tmpDir, "scenarios"), 1)
 
-		if err := h.storage.SaveScenarioReports(scenario, reports); err != nil {
-			t.Errorf("SaveScenarioReports failed: %v", err)
+		invalid := scenario
+		invalid.Name = "invalid"
+		invalid.Tests = nil
+		if err := h.storage.SaveScenario(invalid); err == nil {
+			t.Error("Expected SaveScenario to reject a scenario without tests")
 		}
 		h.assertFileCount(filepath.Join(h.tmpDir, "scenarios"), 1)
 	})
@@ -120,33 +133,86 @@ func TestFileStorage(t *testing.T) {
 		if err != nil {
 			t.Errorf("ListScenarios failed: %v", err)
 		}
-		if len(scenarios) != 1 {
-			t.Errorf("Expected 1 scenario, got %d", len(scenarios))
+		if len(scenarios) != 1 || scenarios[0] != scenario.Name {
+			t.Errorf("Expected scenario %s, got %v", scenario.Name, scenarios)
+		}
+	})
+
+	// Test LoadScenario
+	t.Run("LoadScenario", func(t *testing.T) {
+		loaded, err := h.storage.LoadScenario(scenario.Name)
+		if err != nil {
+			t.Fatalf("LoadScenario failed: %v", err)
+		}
+		if len(loaded.Tests) != 2 {
+			t.Fatalf("Expected 2 tests, got %d", len(loaded.Tests))
+		}
+		if *loaded.Tests[0] != *scenario.Tests[0] || *loaded.Tests[1] != *scenario.Tests[1] {
+			t.Errorf("Expected tests %+v, got %+v", scenario.Tests, loaded.Tests)
+		}
+
+		if _, err := h.storage.LoadScenario("missing"); !errors.Is(err, types.ErrScenarioNotFound) {
+			t.Errorf("Expected ErrScenarioNotFound, got %v", err)
 		}
-		if scenarios[0].Type != ScenarioTypeBasicOLTP {
-			t.Errorf("Expected scenario type %s, got %s", ScenarioTypeBasicOLTP, scenarios[0].Type)
+		if _, err := h.storage.LoadScenario("../nightly"); err == nil {
+			t.Error("Expected LoadScenario to reject a path")
 		}
 	})
 
-	// Test LoadScenarioReports
-	t.Run("LoadScenarioReports", func(t *testing.T) {
-		scenarios, err := h.storage.ListScenarios()
+	// Test FindScenario
+	t.Run("FindScenario", func(t *testing.T) {
+		found, err := FindScenario(h.storage, scenario.Name)
 		if err != nil {
-			t.Fatalf("Failed to list scenarios: %v", err)
+			t.Fatalf("FindScenario failed: %v", err)
 		}
-		if len(scenarios) == 0 {
-			t.Fatal("No scenarios found")
+		config := found.testConfig(found.Tests[1])
+		if config.TableSize != 50000 || config.QueryRate != 200 || config.Duration != 5*time.Minute {
+			t.Errorf("Unexpected config of the second test: %+v", config)
 		}
 
-		reports, err := h.storage.LoadScenarioReports(filepath.Base(filepath.Join(h.tmpDir, "scenarios")))
+		found, err = FindScenario(h.storage, string(ScenarioTypeReadIntensive))
 		if err != nil {
-			t.Errorf("LoadScenarioReports failed: %v", err)
+			t.Fatalf("FindScenario failed for a predefined scenario: %v", err)
 		}
-		if len(reports) != 1 {
-			t.Errorf("Expected 1 report, got %d", len(reports))
+		if found.Name != "Read Intensive" {
+			t.Errorf("Expected Read Intensive, got %s", found.Name)
 		}
-		if reports[0].TestName != report.TestName {
-			t.Errorf("Expected test name %s, got %s", report.TestName, reports[0].TestName)
+
+		if _, err := FindScenario(h.storage, "missing"); !errors.Is(err, types.ErrScenarioNotFound) {
+			t.Errorf("Expected ErrScenarioNotFound, got %v", err)
 		}
 	})
 }
+
+func TestScenarioValidate(t *testing.T) {
+	h := newTestHelper(t)
+	defer h.cleanup()
+
+	testCases := []struct {
+		name   string
+		modify func(*types.Scenario)
+	}{
+		{"MissingName", func(s *types.Scenario) { s.Name = "" }},
+		{"PathName", func(s *types.Scenario) { s.Name = "a/b" }},
+		{"NoTests", func(s *types.Scenario) { s.Tests = nil }},
+		{"InvalidType", func(s *types.Scenario) { s.Tests[0].Type = "oltp_unknown" }},
+		{"NegativeThreads", func(s *types.Scenario) { s.Tests[0].Threads = -1 }},
+		{"NegativeRate", func(s *types.Scenario) { s.Tests[1].Rate = -1 }},
+		{"NegativeWarmup", func(s *types.Scenario) { s.Tests[0].Warmup = -time.Second }},
+	}
+
+	scenario := h.createTestScenario()
+	if err := scenario.Validate(); err != nil {
+		t.Fatalf("Expected a valid scenario, got %v", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scenario := h.createTestScenario()
+			tc.modify(&scenario)
+			if err := scenario.Validate(); err == nil {
+				t.Error("Expected validation to fail")
+			}
+		})
+	}
+}
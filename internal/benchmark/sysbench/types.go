@@ -1,7 +1,6 @@
 package sysbench
 
 import (
-	"encoding/json"
 	"time"
 )
 
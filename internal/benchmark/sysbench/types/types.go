@@ -1,8 +1,12 @@
 package types
 
 import (
+	"fmt"
+	"strings"
 	"time"
-	"sort"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
 )
 
 // ScenarioType represents different predefined test scenarios
@@ -52,23 +56,40 @@ const (
 
 // OLTPTestConfig represents the configuration for OLTP tests
 type OLTPTestConfig struct {
-	TestType        TestType      `json:"test_type"`
-	TableSize       int           `json:"table_size"`
-	NumTables       int           `json:"num_tables"`
-	NumThreads      int           `json:"num_threads"`
-	Duration        time.Duration `json:"duration"`
-	ReportInterval  time.Duration `json:"report_interval"`
-	ReadOnly        bool          `json:"read_only"`
-	PointSelects    int           `json:"point_selects"`
-	SimpleRanges    int           `json:"simple_ranges"`
-	SumRanges       int           `json:"sum_ranges"`
-	OrderRanges     int           `json:"order_ranges"`
-	DistinctRanges  int           `json:"distinct_ranges"`
-	IndexUpdates    int           `json:"index_updates"`
-	NonIndexUpdates int           `json:"non_index_updates"`
-	DeleteInserts   int           `json:"delete_inserts"`
-	WriteWeight     float64       `json:"write_weight"`
-	ReadWeight      float64       `json:"read_weight"`
+	TestType        TestType           `json:"test_type" description:"OLTP test to run" enum:"oltp_read_only,oltp_write_only,oltp_read_write,oltp_point_select,oltp_simple_select,oltp_sum_range,oltp_order_range,oltp_distinct_range,oltp_index_scan,oltp_non_index_scan"`
+	TableSize       int                `json:"table_size" description:"Rows per table"`
+	NumTables       int                `json:"num_tables" description:"Number of tables"`
+	NumThreads      int                `json:"num_threads" description:"Concurrent worker threads"`
+	Duration        time.Duration      `json:"duration" description:"Test duration"`
+	ReportInterval  time.Duration      `json:"report_interval" description:"Interval between progress reports"`
+	ReadOnly        bool               `json:"read_only" description:"Skip write statements"`
+	PointSelects    int                `json:"point_selects" description:"Point selects per transaction"`
+	SimpleRanges    int                `json:"simple_ranges" description:"Simple range selects per transaction"`
+	SumRanges       int                `json:"sum_ranges" description:"SUM range selects per transaction"`
+	OrderRanges     int                `json:"order_ranges" description:"ORDER BY range selects per transaction"`
+	DistinctRanges  int                `json:"distinct_ranges" description:"DISTINCT range selects per transaction"`
+	IndexUpdates    int                `json:"index_updates" description:"Indexed column updates per transaction"`
+	NonIndexUpdates int                `json:"non_index_updates" description:"Non-indexed column updates per transaction"`
+	DeleteInserts   int                `json:"delete_inserts" description:"Delete and insert pairs per transaction"`
+	RangeSize       int                `json:"range_size" description:"Rows read by each range select"`
+	IndexScans      int                `json:"index_scans" description:"Secondary index range scans per transaction of oltp_index_scan"`
+	NonIndexScans   int                `json:"non_index_scans" description:"Full table scans per transaction of oltp_non_index_scan"`
+	LoadBatchSize   int                `json:"load_batch_size" description:"Rows inserted by each statement during prepare"`
+	LoadThreads     int                `json:"load_threads" description:"Tables loaded in parallel during prepare"`
+	AutoInc         bool               `json:"auto_inc" description:"Make id an auto increment column"`
+	Engine          string             `json:"engine" description:"MySQL storage engine of the tables"`
+	TableOptions    string             `json:"table_options" description:"Options appended to CREATE TABLE"`
+	Partitions      int                `json:"partitions" description:"Hash partitions of each table by id, 0 for none"`
+	QueryRate       int                `json:"query_rate" description:"Transactions per second across all threads, 0 for unlimited"`
+	Arrival         string             `json:"arrival" description:"Distribution of transaction start times with a query rate" enum:"constant,poisson"`
+	Warmup          time.Duration      `json:"warmup" description:"Time run at full concurrency before measuring starts"`
+	RampUp          time.Duration      `json:"ramp_up" description:"Time over which the threads are started, before the warmup"`
+	RampSteps       int                `json:"ramp_steps" description:"Groups the threads are started in during the ramp-up, 0 to start them one by one"`
+	CoolDown        time.Duration      `json:"cool_down" description:"Time the load keeps running after measuring ends"`
+	ErrorPolicy     models.ErrorPolicy `json:"error_policy" description:"How failed transactions are retried and when they fail the test, by default it continues"`
+	Scenario        string             `json:"scenario" description:"Stored or predefined scenario to run instead of a single test"`
+	WriteWeight     float64            `json:"write_weight" description:"Share of write transactions in mixed tests"`
+	ReadWeight      float64            `json:"read_weight" description:"Share of read transactions in mixed tests"`
 }
 
 // NewOLTPTestConfig creates a new OLTP test configuration with default values
@@ -89,6 +110,14 @@ func NewOLTPTestConfig() *OLTPTestConfig {
 		IndexUpdates:    1,
 		NonIndexUpdates: 1,
 		DeleteInserts:   1,
+		RangeSize:       100,
+		IndexScans:      1,
+		NonIndexScans:   1,
+		LoadBatchSize:   1000,
+		LoadThreads:     4,
+		AutoInc:         true,
+		Engine:          "InnoDB",
+		Arrival:         "constant",
 		WriteWeight:     0.5,
 		ReadWeight:      0.5,
 	}
@@ -124,31 +153,37 @@ type TestConfig struct {
 	Debug bool
 }
 
-// TestStats represents test statistics
+// TestStats represents test statistics. Latencies are recorded in a
+// fixed-memory histogram and the latency fields are filled in by Finalize.
 type TestStats struct {
 	TotalTransactions int64
-	TotalErrors      int64
-	TPS              float64
-	AvgLatency      time.Duration
-	P95Latency      time.Duration
-	P99Latency      time.Duration
-	MaxLatency      time.Duration
-	MinLatency      time.Duration
-	Latencies       []time.Duration
+	TotalErrors       int64
+	TPS               float64
+	AvgLatency        time.Duration
+	P50Latency        time.Duration
+	P90Latency        time.Duration
+	P95Latency        time.Duration
+	P99Latency        time.Duration
+	P999Latency       time.Duration
+	MaxLatency        time.Duration
+	MinLatency        time.Duration
+	Histogram         *metrics.Histogram `json:"-"`
 }
 
 // NewTestStats creates a new test statistics object
 func NewTestStats() *TestStats {
 	return &TestStats{
-		Latencies: make([]time.Duration, 0),
+		Histogram: metrics.NewHistogram(),
 	}
 }
 
 // AddTransaction adds a transaction to the statistics
 func (s *TestStats) AddTransaction(latency time.Duration) {
+	if s.Histogram == nil {
+		s.Histogram = metrics.NewHistogram()
+	}
 	s.TotalTransactions++
-	s.Latencies = append(s.Latencies, latency)
-	s.updateLatencyStats(latency)
+	s.Histogram.Record(latency)
 }
 
 // AddError increments the error count
@@ -156,40 +191,69 @@ func (s *TestStats) AddError() {
 	s.TotalErrors++
 }
 
-// updateLatencyStats updates latency statistics
-func (s *TestStats) updateLatencyStats(latency time.Duration) {
-	if s.MinLatency == 0 || latency < s.MinLatency {
-		s.MinLatency = latency
-	}
-	if latency > s.MaxLatency {
-		s.MaxLatency = latency
+// Finalize fills the latency fields from the histogram. Stats loaded from a
+// stored report have no histogram and keep their latency fields.
+func (s *TestStats) Finalize() {
+	if s.Histogram == nil {
+		return
 	}
+	summary := s.Histogram.Summary()
+	s.AvgLatency = summary.Avg
+	s.P50Latency = summary.P50
+	s.P90Latency = summary.P90
+	s.P95Latency = summary.P95
+	s.P99Latency = summary.P99
+	s.P999Latency = summary.P999
+	s.MaxLatency = summary.Max
+	s.MinLatency = summary.Min
+}
 
-	// Update average latency
-	totalLatency := s.AvgLatency.Nanoseconds() * int64(s.TotalTransactions-1)
-	totalLatency += latency.Nanoseconds()
-	s.AvgLatency = time.Duration(totalLatency / s.TotalTransactions)
-
-	// Sort latencies for percentile calculation
-	if len(s.Latencies) > 1 {
-		sort.Slice(s.Latencies, func(i, j int) bool {
-			return s.Latencies[i] < s.Latencies[j]
-		})
+// LatencyMetrics returns the latency fields under the latency_* status
+// metric keys
+func (s *TestStats) LatencyMetrics() map[string]interface{} {
+	return metrics.LatencySummary{
+		Min:  s.MinLatency,
+		Avg:  s.AvgLatency,
+		P50:  s.P50Latency,
+		P90:  s.P90Latency,
+		P95:  s.P95Latency,
+		P99:  s.P99Latency,
+		P999: s.P999Latency,
+		Max:  s.MaxLatency,
+	}.Metrics()
+}
 
-		// Calculate P95 and P99 latencies
-		p95Index := int(float64(len(s.Latencies)) * 0.95)
-		p99Index := int(float64(len(s.Latencies)) * 0.99)
+// MergeTestStats combines the statistics of several tests run one after the
+// other. TPS is the combined transactions over the sum of elapsed.
+func MergeTestStats(elapsed time.Duration, stats ...*TestStats) *TestStats {
+	merged := NewTestStats()
+	for _, s := range stats {
+		merged.TotalTransactions += s.TotalTransactions
+		merged.TotalErrors += s.TotalErrors
+		merged.Histogram.Merge(s.Histogram)
+	}
 
-		s.P95Latency = s.Latencies[p95Index]
-		s.P99Latency = s.Latencies[p99Index]
+	if elapsed > 0 {
+		merged.TPS = float64(merged.TotalTransactions) / elapsed.Seconds()
 	}
+	merged.Finalize()
+	return merged
 }
 
 // Report represents a test report
 type Report struct {
-	Name     string
-	Duration time.Duration
-	Stats    *TestStats
+	Name      string
+	Duration  time.Duration
+	Stats     *TestStats
+	Breakdown map[string]models.QueryStats `json:",omitempty"`
+}
+
+// ScenarioReport holds the report of each test of a scenario, in the order
+// they ran, and the report of the scenario as a whole
+type ScenarioReport struct {
+	Name   string
+	Phases []*Report
+	Total  *Report
 }
 
 // TestReport represents the results of a sysbench OLTP test
@@ -214,6 +278,16 @@ type TestReport struct {
 	Errors int64
 }
 
+// Phases returns the phases run around the measurement of the test
+func (c *OLTPTestConfig) Phases() models.PhaseConfig {
+	return models.PhaseConfig{
+		RampUp:    c.RampUp,
+		RampSteps: c.RampSteps,
+		Warmup:    c.Warmup,
+		CoolDown:  c.CoolDown,
+	}
+}
+
 // Scenario represents a test scenario
 type Scenario struct {
 	Type        ScenarioType  `json:"type"`
@@ -223,13 +297,71 @@ type Scenario struct {
 	Tests       []*Test       `json:"tests"`
 }
 
-// Test represents a single test within a scenario
+// Test represents a single test within a scenario. Threads, NumTables,
+// TableSize, Rate and Warmup override Config when set.
 type Test struct {
-	Name     string          `json:"name"`
-	Type     TestType        `json:"type"`
-	Config   *OLTPTestConfig `json:"config"`
-	Weight   float64         `json:"weight"`
-	Duration time.Duration   `json:"duration"`
+	Name      string          `json:"name"`
+	Type      TestType        `json:"type"`
+	Config    *OLTPTestConfig `json:"config,omitempty"`
+	Weight    float64         `json:"weight"`
+	Duration  time.Duration   `json:"duration"`
+	Threads   int             `json:"threads,omitempty"`
+	NumTables int             `json:"num_tables,omitempty"`
+	TableSize int             `json:"table_size,omitempty"`
+	Rate      int             `json:"rate,omitempty"`
+	Warmup    time.Duration   `json:"warmup,omitempty"`
+}
+
+// Validate checks that the scenario can be stored and run
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario name is required")
+	}
+	if strings.ContainsAny(s.Name, `/\`) || s.Name == "." || s.Name == ".." {
+		return fmt.Errorf("invalid scenario name: %s", s.Name)
+	}
+	if s.Duration < 0 {
+		return ErrInvalidDuration
+	}
+	if len(s.Tests) == 0 {
+		return fmt.Errorf("scenario %s has no tests", s.Name)
+	}
+
+	for i, test := range s.Tests {
+		if test == nil {
+			return fmt.Errorf("test %d of scenario %s is empty", i+1, s.Name)
+		}
+		if err := test.Validate(); err != nil {
+			return fmt.Errorf("test %d (%s) of scenario %s: %w", i+1, test.Name, s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the type and overrides of the test
+func (t *Test) Validate() error {
+	if !t.Type.IsValid() {
+		return fmt.Errorf("invalid test type: %s", t.Type)
+	}
+	if t.Weight < 0 || t.Weight > 1 {
+		return ErrInvalidWeight
+	}
+	if t.Duration < 0 || t.Warmup < 0 {
+		return ErrInvalidDuration
+	}
+	if t.Threads < 0 {
+		return ErrInvalidNumThreads
+	}
+	if t.NumTables < 0 {
+		return ErrInvalidNumTables
+	}
+	if t.TableSize < 0 {
+		return ErrInvalidTableSize
+	}
+	if t.Rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	return nil
 }
 
 // Result represents a single operation result
@@ -246,6 +378,8 @@ type TestStatus string
 const (
 	// TestStatusPending indicates the test is pending
 	TestStatusPending TestStatus = "pending"
+	// TestStatusPreparing indicates the test tables are being created and loaded
+	TestStatusPreparing TestStatus = "preparing"
 	// TestStatusRunning indicates the test is running
 	TestStatusRunning TestStatus = "running"
 	// TestStatusCompleted indicates the test has completed successfully
@@ -256,6 +390,19 @@ const (
 	TestStatusCancelled TestStatus = "cancelled"
 )
 
+// IsValid checks if the test type is one of the known OLTP tests
+func (t TestType) IsValid() bool {
+	switch t {
+	case TestTypeOLTPRead, TestTypeOLTPWrite, TestTypeOLTPReadWrite,
+		TestTypeOLTPPointSelect, TestTypeOLTPSimpleSelect, TestTypeOLTPSumRange,
+		TestTypeOLTPOrderRange, TestTypeOLTPDistinctRange,
+		TestTypeOLTPIndexScan, TestTypeOLTPNonIndexScan:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsReadOnly checks if the test type is read-only
 func (t TestType) IsReadOnly() bool {
 	switch t {
@@ -0,0 +1,437 @@
+package benchmark
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// defaultZipfExponent is the skew of zipf placeholders that do not set one.
+// The lowest value of the range is the hottest.
+const defaultZipfExponent = 1.1
+
+// defaultSampleSize is how many values a sample placeholder reads by default
+const defaultSampleSize = 1000
+
+// identifierPattern matches the table and column names of sample
+// placeholders, so they can be put into a query without quoting
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QueryTemplate is a query whose {{...}} placeholders are bound to value
+// generators, so that every execution runs with new values:
+//
+//	{{uniform MIN MAX}}         an integer drawn uniformly from [MIN, MAX]
+//	{{zipf MIN MAX [S]}}        an integer with a Zipfian skew S > 1 (1.1) towards MIN
+//	{{gaussian MIN MAX}}        an integer normally distributed around the middle of [MIN, MAX]
+//	{{string LEN [MAXLEN]}}     a random alphanumeric string
+//	{{uuid}}                    a random UUID
+//	{{timestamp [RANGE]}}       the current time, or a time up to RANGE, e.g. 24h, before it
+//	{{pick V1 V2 ...}}          one of the values, quoted with ' if they contain spaces
+//	{{sample TABLE.COLUMN [N]}} one of the first N (1000) values of a column
+//
+// The placeholders become bind parameters of the prepared statement, so they
+// must not be quoted in the SQL.
+type QueryTemplate struct {
+	// SQL is the query with the placeholders replaced by bind parameters
+	SQL        string
+	generators []valueGenerator
+}
+
+// valueGenerator produces the values bound to one placeholder
+type valueGenerator interface {
+	// values returns a function drawing values from r. Every worker draws
+	// from an r of its own.
+	values(r *rand.Rand) func() interface{}
+}
+
+// ParseQueryTemplate parses the placeholders of query. With numbered the bind
+// parameters are written $1, $2, ... as PostgreSQL expects, otherwise ?.
+func ParseQueryTemplate(query string, numbered bool) (*QueryTemplate, error) {
+	t := &QueryTemplate{}
+	var b strings.Builder
+	rest := query
+	for {
+		open := strings.Index(rest, "{{")
+		if open < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[open:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in query: %s", rest[open:])
+		}
+		spec := strings.TrimSpace(rest[open+2 : open+end])
+		g, err := parseGenerator(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder {{%s}}: %w", spec, err)
+		}
+		t.generators = append(t.generators, g)
+
+		b.WriteString(rest[:open])
+		if numbered {
+			b.WriteString("$" + strconv.Itoa(len(t.generators)))
+		} else {
+			b.WriteString("?")
+		}
+		rest = rest[open+end+2:]
+	}
+	t.SQL = b.String()
+	return t, nil
+}
+
+// numberedPlaceholders reports whether the database of conn expects bind
+// parameters numbered $1, $2, ...
+func numberedPlaceholders(conn *models.DBConnection) bool {
+	if conn == nil {
+		return false
+	}
+	return conn.Type == models.PostgreSQL || conn.Driver == "postgres" || conn.Driver == "pgx"
+}
+
+// Placeholders returns the number of placeholders of the template
+func (t *QueryTemplate) Placeholders() int {
+	return len(t.generators)
+}
+
+// LoadSamples reads the values of the sample placeholders from db
+func (t *QueryTemplate) LoadSamples(ctx context.Context, db *sql.DB) error {
+	for _, g := range t.generators {
+		s, ok := g.(*sampleGenerator)
+		if !ok {
+			continue
+		}
+		if err := s.load(ctx, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Args returns a function producing the arguments of one execution of the
+// template, drawing from r. It returns nil for a template without
+// placeholders.
+func (t *QueryTemplate) Args(r *rand.Rand) func() []interface{} {
+	if len(t.generators) == 0 {
+		return func() []interface{} { return nil }
+	}
+	draws := make([]func() interface{}, len(t.generators))
+	for i, g := range t.generators {
+		draws[i] = g.values(r)
+	}
+	return func() []interface{} {
+		args := make([]interface{}, len(draws))
+		for i, draw := range draws {
+			args[i] = draw()
+		}
+		return args
+	}
+}
+
+// parseGenerator parses the contents of a placeholder
+func parseGenerator(spec string) (valueGenerator, error) {
+	fields, err := splitFields(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty placeholder")
+	}
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "uniform", "zipf", "gaussian":
+		if len(args) < 2 || len(args) > 3 || (len(args) == 3 && name != "zipf") {
+			return nil, fmt.Errorf("%s needs a minimum and a maximum", name)
+		}
+		min, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum %q", args[0])
+		}
+		max, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum %q", args[1])
+		}
+		if max < min {
+			return nil, fmt.Errorf("maximum %d is below minimum %d", max, min)
+		}
+		switch name {
+		case "uniform":
+			return uniformGenerator{min: min, max: max}, nil
+		case "gaussian":
+			return gaussianGenerator{min: min, max: max}, nil
+		}
+		s := defaultZipfExponent
+		if len(args) == 3 {
+			if s, err = strconv.ParseFloat(args[2], 64); err != nil || s <= 1 {
+				return nil, fmt.Errorf("zipf exponent must be a number above 1")
+			}
+		}
+		return zipfGenerator{min: min, max: max, s: s}, nil
+
+	case "string":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("string needs a length")
+		}
+		min, err := strconv.Atoi(args[0])
+		if err != nil || min < 0 {
+			return nil, fmt.Errorf("invalid length %q", args[0])
+		}
+		max := min
+		if len(args) == 2 {
+			if max, err = strconv.Atoi(args[1]); err != nil || max < min {
+				return nil, fmt.Errorf("invalid maximum length %q", args[1])
+			}
+		}
+		return stringGenerator{min: min, max: max}, nil
+
+	case "uuid":
+		if len(args) > 0 {
+			return nil, fmt.Errorf("uuid takes no arguments")
+		}
+		return uuidGenerator{}, nil
+
+	case "timestamp":
+		if len(args) > 1 {
+			return nil, fmt.Errorf("timestamp takes at most a range")
+		}
+		var span time.Duration
+		if len(args) == 1 {
+			if span, err = time.ParseDuration(args[0]); err != nil || span <= 0 {
+				return nil, fmt.Errorf("invalid range %q", args[0])
+			}
+		}
+		return timestampGenerator{span: span}, nil
+
+	case "pick":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("pick needs at least one value")
+		}
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			if n, err := strconv.ParseInt(arg, 10, 64); err == nil {
+				values[i] = n
+			} else {
+				values[i] = arg
+			}
+		}
+		return &pickGenerator{choices: values}, nil
+
+	case "sample":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("sample needs a table and column")
+		}
+		dot := strings.LastIndex(args[0], ".")
+		if dot < 0 {
+			return nil, fmt.Errorf("sample needs a TABLE.COLUMN")
+		}
+		table, column := args[0][:dot], args[0][dot+1:]
+		for _, part := range append(strings.Split(table, "."), column) {
+			if !identifierPattern.MatchString(part) {
+				return nil, fmt.Errorf("invalid identifier %q", part)
+			}
+		}
+		size := defaultSampleSize
+		if len(args) == 2 {
+			if size, err = strconv.Atoi(args[1]); err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid sample size %q", args[1])
+			}
+		}
+		return &sampleGenerator{table: table, column: column, size: size}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown generator %q", name)
+	}
+}
+
+// splitFields splits spec at spaces. Fields quoted with ' may contain
+// spaces, and a doubled quote within them stands for one.
+func splitFields(spec string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(spec); {
+		switch {
+		case spec[i] == ' ' || spec[i] == '\t':
+			i++
+		case spec[i] == '\'':
+			var field strings.Builder
+			i++
+			for {
+				if i >= len(spec) {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+				if spec[i] == '\'' {
+					if i+1 < len(spec) && spec[i+1] == '\'' {
+						field.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				field.WriteByte(spec[i])
+				i++
+			}
+			fields = append(fields, field.String())
+		default:
+			end := strings.IndexAny(spec[i:], " \t")
+			if end < 0 {
+				end = len(spec) - i
+			}
+			fields = append(fields, spec[i:i+end])
+			i += end
+		}
+	}
+	return fields, nil
+}
+
+// uniformGenerator draws integers uniformly from [min, max]
+type uniformGenerator struct {
+	min, max int64
+}
+
+func (g uniformGenerator) values(r *rand.Rand) func() interface{} {
+	span := g.max - g.min + 1
+	return func() interface{} {
+		return g.min + r.Int63n(span)
+	}
+}
+
+// zipfGenerator draws integers from [min, max] with a Zipfian skew, min
+// being the most frequent
+type zipfGenerator struct {
+	min, max int64
+	s        float64
+}
+
+func (g zipfGenerator) values(r *rand.Rand) func() interface{} {
+	zipf := rand.NewZipf(r, g.s, 1, uint64(g.max-g.min))
+	return func() interface{} {
+		return g.min + int64(zipf.Uint64())
+	}
+}
+
+// gaussianGenerator draws integers from [min, max] normally distributed
+// around the middle, with 99.7% of them within the range. The others are
+// drawn again.
+type gaussianGenerator struct {
+	min, max int64
+}
+
+func (g gaussianGenerator) values(r *rand.Rand) func() interface{} {
+	mean := float64(g.min) + float64(g.max-g.min)/2
+	stddev := float64(g.max-g.min) / 6
+	return func() interface{} {
+		for {
+			v := int64(math.Round(mean + r.NormFloat64()*stddev))
+			if v >= g.min && v <= g.max {
+				return v
+			}
+		}
+	}
+}
+
+// alphanumeric are the characters of random strings
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// stringGenerator draws alphanumeric strings of min to max characters
+type stringGenerator struct {
+	min, max int
+}
+
+func (g stringGenerator) values(r *rand.Rand) func() interface{} {
+	return func() interface{} {
+		n := g.min
+		if g.max > g.min {
+			n += r.Intn(g.max - g.min + 1)
+		}
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphanumeric[r.Intn(len(alphanumeric))]
+		}
+		return string(b)
+	}
+}
+
+// uuidGenerator draws random version 4 UUIDs
+type uuidGenerator struct{}
+
+func (uuidGenerator) values(r *rand.Rand) func() interface{} {
+	return func() interface{} {
+		var u [16]byte
+		r.Read(u[:])
+		u[6] = u[6]&0x0f | 0x40 // version 4
+		u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+	}
+}
+
+// timestampGenerator produces the current time, or with a span a time drawn
+// uniformly from the span before it
+type timestampGenerator struct {
+	span time.Duration
+}
+
+func (g timestampGenerator) values(r *rand.Rand) func() interface{} {
+	return func() interface{} {
+		now := time.Now()
+		if g.span == 0 {
+			return now
+		}
+		return now.Add(-time.Duration(r.Int63n(int64(g.span))))
+	}
+}
+
+// pickGenerator picks one of a list of values
+type pickGenerator struct {
+	choices []interface{}
+}
+
+func (g *pickGenerator) values(r *rand.Rand) func() interface{} {
+	return func() interface{} {
+		return g.choices[r.Intn(len(g.choices))]
+	}
+}
+
+// sampleGenerator picks one of the values of a column read by load
+type sampleGenerator struct {
+	table, column string
+	size          int
+	pickGenerator
+}
+
+// load reads the first size values of the column
+func (g *sampleGenerator) load(ctx context.Context, db *sql.DB) error {
+	query := fmt.Sprintf("SELECT %s FROM %s LIMIT %d", g.column, g.table, g.size)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to sample %s.%s: %w", g.table, g.column, err)
+	}
+	defer rows.Close()
+
+	g.choices = g.choices[:0]
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			return fmt.Errorf("failed to sample %s.%s: %w", g.table, g.column, err)
+		}
+		// MySQL returns text as bytes, which the next scan would reuse
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		g.choices = append(g.choices, v)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to sample %s.%s: %w", g.table, g.column, err)
+	}
+	if len(g.choices) == 0 {
+		return fmt.Errorf("no values to sample in %s.%s", g.table, g.column)
+	}
+	return nil
+}
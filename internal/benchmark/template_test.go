@@ -0,0 +1,114 @@
+package benchmark
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryTemplate(t *testing.T) {
+	query := "SELECT * FROM t WHERE id = {{ uniform 1 10 }} AND name = {{pick 'a b' 'it''s' 3}}"
+	tmpl, err := ParseQueryTemplate(query, false)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE id = ? AND name = ?", tmpl.SQL)
+	assert.Equal(t, 2, tmpl.Placeholders())
+	assert.Equal(t, []interface{}{"a b", "it's", int64(3)}, tmpl.generators[1].(*pickGenerator).choices)
+
+	tmpl, err = ParseQueryTemplate(query, true)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE id = $1 AND name = $2", tmpl.SQL)
+
+	tmpl, err = ParseQueryTemplate("SELECT 1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1", tmpl.SQL)
+	assert.Nil(t, tmpl.Args(rand.New(rand.NewSource(1)))())
+
+	for name, query := range map[string]string{
+		"Unterminated":    "SELECT {{uniform 1 2",
+		"Empty":           "SELECT {{ }}",
+		"Unknown":         "SELECT {{serial}}",
+		"MissingMax":      "SELECT {{uniform 1}}",
+		"Reversed":        "SELECT {{uniform 10 1}}",
+		"GaussianSkew":    "SELECT {{gaussian 1 10 2}}",
+		"ZipfExponent":    "SELECT {{zipf 1 10 0.9}}",
+		"StringLength":    "SELECT {{string -1}}",
+		"UUIDArgs":        "SELECT {{uuid 4}}",
+		"TimestampRange":  "SELECT {{timestamp yesterday}}",
+		"PickNothing":     "SELECT {{pick}}",
+		"UnquotedPick":    "SELECT {{pick 'a}}",
+		"SampleNoColumn":  "SELECT {{sample accounts}}",
+		"SampleInjection": "SELECT {{sample accounts.id;DROP}}",
+		"SampleSize":      "SELECT {{sample accounts.id 0}}",
+	} {
+		_, err := ParseQueryTemplate(query, false)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestQueryTemplateArgs(t *testing.T) {
+	tmpl, err := ParseQueryTemplate("{{uniform 5 7}} {{zipf 1 1000}} {{gaussian 0 60}} {{string 3 5}} {{uuid}} {{timestamp 1h}} {{timestamp}}", false)
+	require.NoError(t, err)
+
+	args := tmpl.Args(rand.New(rand.NewSource(1)))
+	zipfCounts := make(map[int64]int)
+	var gaussianSum int64
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	const draws = 10000
+	for i := 0; i < draws; i++ {
+		values := args()
+		require.Len(t, values, 7)
+
+		assert.GreaterOrEqual(t, values[0].(int64), int64(5))
+		assert.LessOrEqual(t, values[0].(int64), int64(7))
+
+		z := values[1].(int64)
+		require.True(t, z >= 1 && z <= 1000)
+		zipfCounts[z]++
+
+		g := values[2].(int64)
+		require.True(t, g >= 0 && g <= 60)
+		gaussianSum += g
+
+		s := values[3].(string)
+		assert.True(t, len(s) >= 3 && len(s) <= 5, s)
+		assert.Regexp(t, uuid, values[4])
+
+		ts := values[5].(time.Time)
+		assert.WithinDuration(t, time.Now().Add(-30*time.Minute), ts, 31*time.Minute)
+		assert.WithinDuration(t, time.Now(), values[6].(time.Time), time.Second)
+	}
+
+	// The lowest value of a zipf range is the hottest key by far
+	assert.Greater(t, zipfCounts[1], draws/10)
+	assert.Greater(t, zipfCounts[1], zipfCounts[2])
+	assert.Greater(t, zipfCounts[2], zipfCounts[100])
+	assert.InDelta(t, 30, float64(gaussianSum)/draws, 0.5)
+}
+
+func TestQueryTemplateSamples(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	tmpl, err := ParseQueryTemplate("SELECT * FROM users WHERE name = {{sample app.users.name 3}}", false)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT name FROM app\.users LIMIT 3`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow([]byte("ann")).AddRow([]byte("bob")))
+	require.NoError(t, tmpl.LoadSamples(context.Background(), db))
+
+	args := tmpl.Args(rand.New(rand.NewSource(1)))
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, []interface{}{"ann", "bob"}, args()[0])
+	}
+
+	mock.ExpectQuery(`SELECT name FROM app\.users LIMIT 3`).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	assert.ErrorContains(t, tmpl.LoadSamples(context.Background(), db), "no values to sample")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
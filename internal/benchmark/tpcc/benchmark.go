@@ -49,6 +49,18 @@ func (b *TPCCBenchmark) Setup(ctx context.Context) error {
 		zap.Duration("duration", b.config.Duration),
 	)
 
+	if err := b.Prepare(ctx); err != nil {
+		return err
+	}
+
+	// Create runner
+	b.runner = NewRunner(b.db, b.config, b.logger)
+
+	return nil
+}
+
+// Prepare creates the TPC-C schema and loads the initial data set
+func (b *TPCCBenchmark) Prepare(ctx context.Context) error {
 	// Create schema
 	if err := CreateSchema(ctx, b.db); err != nil {
 		return fmt.Errorf("create schema: %w", err)
@@ -60,9 +72,6 @@ func (b *TPCCBenchmark) Setup(ctx context.Context) error {
 		return fmt.Errorf("load data: %w", err)
 	}
 
-	// Create runner
-	b.runner = NewRunner(b.db, b.config, b.logger)
-
 	return nil
 }
 
@@ -78,31 +87,41 @@ func (b *TPCCBenchmark) Run(ctx context.Context) (*benchmark.Result, error) {
 		zap.Duration("duration", b.config.Duration),
 	)
 
-	startTime := time.Now()
 	stats, err := b.runner.Run(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("run: %w", err)
 	}
-	endTime := time.Now()
 
+	// The statistics cover the measurement, without ramp-up, warmup and
+	// cool-down
+	latency := stats.Latencies.Summary()
 	result := &benchmark.Result{
 		Name:              "TPC-C",
-		Duration:          endTime.Sub(startTime),
+		Duration:          stats.Duration,
 		TotalTransactions: stats.TotalTransactions,
-		TPS:              float64(stats.TotalTransactions) / endTime.Sub(startTime).Seconds(),
-		LatencyAvg:       stats.LatencyAvg,
-		LatencyP95:       stats.LatencyP95,
-		LatencyP99:       stats.LatencyP99,
-		Errors:           stats.Errors,
-		StartTime:        startTime,
-		EndTime:          endTime,
-		Metrics:          make(map[string]interface{}),
+		TPS:               stats.TPS,
+		LatencyMin:        latency.Min,
+		LatencyAvg:        latency.Avg,
+		LatencyP50:        latency.P50,
+		LatencyP90:        latency.P90,
+		LatencyP95:        latency.P95,
+		LatencyP99:        latency.P99,
+		LatencyP999:       latency.P999,
+		LatencyMax:        latency.Max,
+		Errors:            stats.Errors,
+		StartTime:         stats.StartTime,
+		EndTime:           stats.EndTime,
+		Metrics:           make(map[string]interface{}),
+		Intervals:         b.runner.Intervals(),
+		Phases:            b.runner.Phases(),
+		Breakdown:         stats.Breakdown.Stats(),
 	}
 
 	// Convert metrics to interface{} map
 	for k, v := range stats.Metrics {
 		result.Metrics[k] = interface{}(v)
 	}
+	b.specMetrics(stats, result.Breakdown, result.Metrics)
 
 	return result, nil
 }
@@ -118,28 +137,48 @@ func (b *TPCCBenchmark) GetStats() *benchmark.Result {
 	}
 
 	stats := b.runner.GetStats()
+	latency := stats.Latencies.Summary()
 	result := &benchmark.Result{
 		Name:              "TPC-C",
-		Duration:          time.Since(b.runner.startTime),
+		Duration:          time.Since(stats.StartTime),
 		TotalTransactions: stats.TotalTransactions,
-		TPS:              float64(stats.TotalTransactions) / time.Since(b.runner.startTime).Seconds(),
-		LatencyAvg:       stats.LatencyAvg,
-		LatencyP95:       stats.LatencyP95,
-		LatencyP99:       stats.LatencyP99,
-		Errors:           stats.Errors,
-		StartTime:        b.runner.startTime,
-		EndTime:          time.Now(),
-		Metrics:          make(map[string]interface{}),
+		TPS:               float64(stats.TotalTransactions) / time.Since(stats.StartTime).Seconds(),
+		LatencyMin:        latency.Min,
+		LatencyAvg:        latency.Avg,
+		LatencyP50:        latency.P50,
+		LatencyP90:        latency.P90,
+		LatencyP95:        latency.P95,
+		LatencyP99:        latency.P99,
+		LatencyP999:       latency.P999,
+		LatencyMax:        latency.Max,
+		Errors:            stats.Errors,
+		StartTime:         stats.StartTime,
+		EndTime:           time.Now(),
+		Metrics:           make(map[string]interface{}),
+		Breakdown:         stats.Breakdown.Stats(),
 	}
 
 	// Convert metrics to interface{} map
 	for k, v := range stats.Metrics {
 		result.Metrics[k] = interface{}(v)
 	}
+	b.specMetrics(stats, result.Breakdown, result.Metrics)
 
 	return result
 }
 
+// specMetrics adds the terminal mode to metrics, the checks of breakdown
+// against the response time and mix constraints of the TPC-C spec and, for
+// spec terminals, whether the tpmC stayed within the maximum they can
+// reach. More means the terminals did not wait as the spec requires.
+func (b *TPCCBenchmark) specMetrics(stats *Stats, breakdown map[string]benchmark.QueryStats, metrics map[string]interface{}) {
+	metrics["terminal_mode"] = b.config.TerminalMode
+	metrics["compliance"] = benchmark.CheckTPCCCompliance(breakdown)
+	if b.config.TerminalMode == TerminalModeSpec {
+		metrics["within_max_tpmC"] = stats.TPMc <= stats.MaxTPMc
+	}
+}
+
 // Cleanup performs necessary cleanup after the benchmark
 func (b *TPCCBenchmark) Cleanup(ctx context.Context) error {
 	b.logger.Info("Cleaning up TPC-C benchmark")
@@ -160,7 +199,20 @@ func (b *TPCCBenchmark) Validate() error {
 	return b.config.Validate()
 }
 
-// Start starts the benchmark
+// checkPrepared fails unless Prepare has loaded the configured warehouses
+func (b *TPCCBenchmark) checkPrepared(ctx context.Context) error {
+	var warehouses int
+	if err := b.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM warehouse").Scan(&warehouses); err != nil {
+		return fmt.Errorf("TPC-C tables not found, run prepare first: %w", err)
+	}
+	if warehouses < b.config.Warehouses {
+		return fmt.Errorf("%d TPC-C warehouses loaded but %d configured, run prepare first", warehouses, b.config.Warehouses)
+	}
+	return nil
+}
+
+// Start starts the benchmark against the data loaded by Prepare. It fails
+// if the data has not been prepared.
 func (b *TPCCBenchmark) Start() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -168,6 +220,9 @@ func (b *TPCCBenchmark) Start() error {
 	if b.status.Status == string(models.BenchmarkStatusRunning) {
 		return fmt.Errorf("benchmark is already running")
 	}
+	if err := b.checkPrepared(context.Background()); err != nil {
+		return err
+	}
 
 	b.status.Status = string(models.BenchmarkStatusRunning)
 	b.status.Progress = 0
@@ -176,32 +231,42 @@ func (b *TPCCBenchmark) Start() error {
 	// Create runner
 	b.runner = NewRunner(b.db, b.config, b.logger)
 
-	// Run benchmark in a goroutine
+	// Run benchmark in a goroutine against data loaded by Prepare. A run
+	// stopped by Stop stays cancelled however it ends.
 	go func() {
 		ctx := context.Background()
-		if err := b.Setup(ctx); err != nil {
-			b.logger.Error("Failed to setup benchmark", zap.Error(err))
-			b.status.Status = string(models.BenchmarkStatusFailed)
-			return
-		}
-
 		stats, err := b.runner.Run(ctx)
 		if err != nil {
 			b.logger.Error("Failed to run benchmark", zap.Error(err))
-			b.status.Status = string(models.BenchmarkStatusFailed)
+			b.mu.Lock()
+			if b.status.Status != string(models.BenchmarkStatusCancelled) {
+				b.status.Status = string(models.BenchmarkStatusFailed)
+			}
+			for k, v := range b.runner.ErrorMetrics() {
+				b.status.Metrics[k] = v
+			}
+			b.mu.Unlock()
 			return
 		}
 
 		b.mu.Lock()
-		b.status.Status = string(models.BenchmarkStatusCompleted)
+		if b.status.Status != string(models.BenchmarkStatusCancelled) {
+			b.status.Status = string(models.BenchmarkStatusCompleted)
+		}
 		b.status.Progress = 100
-		b.status.Metrics = map[string]interface{}{
-			"total_transactions": stats.TotalTransactions,
-			"tps":               stats.TPS,
-			"latency_avg":       stats.LatencyAvg,
-			"latency_p95":       stats.LatencyP95,
-			"latency_p99":       stats.LatencyP99,
-			"errors":            stats.Errors,
+		b.status.Metrics = stats.Latencies.Summary().Metrics()
+		b.status.Metrics["total_transactions"] = stats.TotalTransactions
+		b.status.Metrics["tps"] = stats.TPS
+		b.status.Metrics["tpmC"] = stats.TPMc
+		b.status.Metrics["max_tpmC"] = stats.MaxTPMc
+		b.status.Metrics["efficiency"] = stats.Efficiency
+		b.status.Metrics["success_rate"] = stats.SuccessRate
+		b.status.Metrics["errors"] = stats.Errors
+		breakdown := stats.Breakdown.Stats()
+		b.status.Metrics["breakdown"] = breakdown
+		b.specMetrics(stats, breakdown, b.status.Metrics)
+		for k, v := range b.runner.ErrorMetrics() {
+			b.status.Metrics[k] = v
 		}
 		b.mu.Unlock()
 	}()
@@ -220,9 +285,31 @@ func (b *TPCCBenchmark) Stop() {
 	b.status.Status = string(models.BenchmarkStatusCancelled)
 }
 
-// Status returns the current benchmark status
+// Intervals returns the reports of the intervals finished so far
+func (b *TPCCBenchmark) Intervals() []models.IntervalReport {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.runner == nil {
+		return nil
+	}
+	return b.runner.Intervals()
+}
+
+// Phases returns the reports of the phases started so far
+func (b *TPCCBenchmark) Phases() []models.PhaseReport {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.runner == nil {
+		return nil
+	}
+	return b.runner.Phases()
+}
+
+// Status returns a copy of the current benchmark status
 func (b *TPCCBenchmark) Status() benchmark.BenchmarkStatus {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return b.status
+	return b.status.Copy()
 }
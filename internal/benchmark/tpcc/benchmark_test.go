@@ -0,0 +1,60 @@
+package tpcc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+func TestStopKeepsCancelled(t *testing.T) {
+	// With just a warehouse every transaction fails, which the run
+	// continues past
+	db := newTestDB(t)
+	require.NoError(t, CreateSchema(context.Background(), db))
+	_, err := db.Exec("INSERT INTO warehouse VALUES (1, 'w1', 's1', 's2', 'city', 'st', '123456789', 0.1, 300000)")
+	require.NoError(t, err)
+
+	config := DefaultConfig()
+	config.Warehouses = 1
+	config.Terminals = 1
+	config.Duration = time.Hour
+	b := NewTPCCBenchmark(config, db, zaptest.NewLogger(t))
+
+	require.NoError(t, b.Start())
+	time.Sleep(50 * time.Millisecond)
+	b.Stop()
+
+	require.Eventually(t, func() bool {
+		return b.Status().Progress == 100
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := b.Status()
+	assert.Equal(t, string(models.BenchmarkStatusCancelled), status.Status)
+
+	// The status is a copy the caller may change
+	delete(status.Metrics, "tpmC")
+	assert.Contains(t, b.Status().Metrics, "tpmC")
+}
+
+func TestStartNeedsPrepare(t *testing.T) {
+	db := newTestDB(t)
+	config := DefaultConfig()
+	config.Warehouses = 2
+	config.Terminals = 1
+	b := NewTPCCBenchmark(config, db, zaptest.NewLogger(t))
+
+	assert.ErrorContains(t, b.Start(), "run prepare first")
+	assert.Equal(t, string(models.BenchmarkStatusPending), b.Status().Status)
+
+	// Fewer warehouses than configured were loaded
+	require.NoError(t, CreateSchema(context.Background(), db))
+	_, err := db.Exec("INSERT INTO warehouse VALUES (1, 'w1', 's1', 's2', 'city', 'st', '123456789', 0.1, 300000)")
+	require.NoError(t, err)
+	assert.ErrorContains(t, b.Start(), "1 TPC-C warehouses loaded but 2 configured")
+}
@@ -24,6 +24,16 @@ func (f *Factory) Name() string {
 	return "tpcc"
 }
 
+// Description returns a short summary of the workload
+func (f *Factory) Description() string {
+	return "TPC-C order processing workload with the standard five transaction mix, run back to back or by terminals waiting keying and think times"
+}
+
+// ConfigSchema returns the JSON Schema of the workload config
+func (f *Factory) ConfigSchema() json.RawMessage {
+	return benchmark.ConfigSchema(DefaultConfig())
+}
+
 // Create creates a new TPC-C benchmark instance
 func (f *Factory) Create(config *models.Benchmark, conn *models.DBConnection, logger *zap.Logger) (benchmark.BenchmarkRunner, error) {
 	if config == nil {
@@ -34,20 +44,55 @@ func (f *Factory) Create(config *models.Benchmark, conn *models.DBConnection, lo
 		return nil, fmt.Errorf("connection is required")
 	}
 
-	// Parse TPC-C specific config
-	var tpccConfig Config
-	if err := json.Unmarshal(config.Config, &tpccConfig); err != nil {
-		return nil, fmt.Errorf("parse config: %w", err)
+	// Parse TPC-C specific config on top of the defaults
+	tpccConfig := DefaultConfig()
+	if len(config.Config) > 0 {
+		if err := benchmark.UnmarshalConfig(config.Config, tpccConfig); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+
+	// Generic benchmark settings take precedence over the workload config
+	if config.NumThreads > 0 {
+		tpccConfig.Terminals = config.NumThreads
+	}
+	if config.Duration > 0 {
+		tpccConfig.Duration = config.Duration
+	}
+	if config.ReportInterval > 0 {
+		tpccConfig.ReportInterval = config.ReportInterval
+	}
+	if config.Phases.RampUp > 0 {
+		tpccConfig.RampUp = config.Phases.RampUp
+	}
+	if config.Phases.RampSteps > 0 {
+		tpccConfig.RampSteps = config.Phases.RampSteps
+	}
+	if config.Phases.Warmup > 0 {
+		tpccConfig.Warmup = config.Phases.Warmup
+	}
+	if config.Phases.CoolDown > 0 {
+		tpccConfig.CoolDown = config.Phases.CoolDown
+	}
+	if !config.ErrorPolicy.IsZero() {
+		tpccConfig.ErrorPolicy = config.ErrorPolicy
+	}
+	if err := tpccConfig.validateTerminals(); err != nil {
+		return nil, err
 	}
 
-	// Create database connection
-	db, err := sql.Open(conn.Driver, conn.DSN)
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+	// Reuse the connection's pool when the caller already opened one
+	db := conn.DB
+	if db == nil {
+		var err error
+		db, err = sql.Open(conn.Driver, conn.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
 	}
 
 	// Create benchmark
-	b := NewTPCCBenchmark(&tpccConfig, db, logger)
+	b := NewTPCCBenchmark(tpccConfig, db, logger)
 	return b, nil
 }
 
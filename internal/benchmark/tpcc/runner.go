@@ -3,12 +3,15 @@ package tpcc
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
 	"go.uber.org/zap"
 )
 
@@ -20,8 +23,12 @@ type Runner struct {
 	stats     *Stats
 	executor  *TransactionExecutor
 	stopChan  chan struct{}
+	stopOnce  sync.Once
 	terminals []*Terminal
 	wg        sync.WaitGroup
+	intervals *benchmark.IntervalRecorder
+	phases    *benchmark.PhaseRecorder
+	errors    *benchmark.ErrorTracker
 }
 
 // Terminal represents a client terminal that executes transactions
@@ -36,13 +43,17 @@ type Terminal struct {
 
 // NewRunner creates a new TPC-C test runner
 func NewRunner(db *sql.DB, config *Config, logger *zap.Logger) *Runner {
+	stats := NewStats()
+	stats.MaxTPMc = config.MaxTPMC()
 	return &Runner{
-		db:       db,
-		config:   config,
-		logger:   logger,
-		stats:    NewStats(),
-		executor: NewTransactionExecutor(db, config),
-		stopChan: make(chan struct{}),
+		db:        db,
+		config:    config,
+		logger:    logger,
+		stats:     stats,
+		executor:  NewTransactionExecutor(db, config),
+		stopChan:  make(chan struct{}),
+		intervals: benchmark.NewIntervalRecorder(config.ReportInterval),
+		phases:    benchmark.NewPhaseRecorder(config.Phases(), config.Duration),
 	}
 }
 
@@ -52,34 +63,57 @@ func (r *Runner) Run(ctx context.Context) (*Stats, error) {
 	if err := r.initialize(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize test: %w", err)
 	}
+	tracker, err := benchmark.NewErrorTracker(r.config.ErrorPolicy, models.ErrorPolicyContinue)
+	if err != nil {
+		return nil, err
+	}
+	r.errors = tracker
+
+	ctx, cancel := context.WithTimeout(ctx, r.phases.Total())
+	defer cancel()
 
-	// Create worker channels
-	workCh := make(chan struct{}, r.config.NumThreads)
-	errCh := make(chan error, r.config.NumThreads)
+	r.intervals.Start()
+	r.phases.Start()
+	r.phases.Watch(ctx.Done(), r.intervals.SetPhase)
+	r.startTerminals()
+	go r.monitor(ctx)
 
-	// Start workers
-	for i := 0; i < r.config.NumThreads; i++ {
-		go r.worker(ctx, workCh, errCh)
+	// Run test for the specified duration or until stopped
+	select {
+	case <-ctx.Done():
+	case <-r.stopChan:
 	}
+	r.stopTerminals()
+	r.intervals.Stop()
+	r.calculateStats()
 
-	// Run test for the specified duration
-	timer := time.NewTimer(r.config.Duration)
-	defer timer.Stop()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
+	if reason := r.errors.Reason(); reason != "" {
+		return nil, fmt.Errorf("error policy ended the test: %s", reason)
+	}
+	return r.stats, nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case err := <-errCh:
-			return nil, err
-		case <-timer.C:
-			close(workCh)
-			r.stats.Finalize()
-			return r.stats, nil
-		default:
-			workCh <- struct{}{}
-		}
+// ErrorMetrics returns the failed transactions and retries of each error
+// class, or nil before the test ran
+func (r *Runner) ErrorMetrics() map[string]interface{} {
+	if r.errors == nil {
+		return nil
 	}
+	return r.errors.Metrics()
+}
+
+// Intervals returns the reports of the intervals finished so far. TPC-C
+// counts transactions, not the statements they are made of.
+func (r *Runner) Intervals() []models.IntervalReport {
+	return r.intervals.Intervals()
+}
+
+// Phases returns the reports of the phases started so far
+func (r *Runner) Phases() []models.PhaseReport {
+	return r.phases.Phases()
 }
 
 // GetStats returns the current test statistics
@@ -92,7 +126,11 @@ func (r *Runner) initialize(ctx context.Context) error {
 	r.logger.Info("Initializing TPC-C test",
 		zap.Int("warehouses", r.config.Warehouses),
 		zap.Int("terminals", r.config.Terminals),
+		zap.String("terminal_mode", r.config.TerminalMode),
 		zap.Duration("duration", r.config.Duration),
+		zap.Duration("ramp_up", r.config.RampUp),
+		zap.Duration("warmup", r.config.Warmup),
+		zap.Duration("cool_down", r.config.CoolDown),
 		zap.Float64("new_order_percentage", r.config.NewOrderPercentage),
 		zap.Float64("payment_percentage", r.config.PaymentPercentage),
 		zap.Float64("order_status_percentage", r.config.OrderStatusPercentage),
@@ -106,7 +144,9 @@ func (r *Runner) initialize(ctx context.Context) error {
 	return nil
 }
 
-// startTerminals starts all client terminals
+// startTerminals starts all client terminals. Spec terminals are assigned
+// a district of their own, the terminals of the same warehouse following
+// each other.
 func (r *Runner) startTerminals() {
 	r.terminals = make([]*Terminal, r.config.Terminals)
 	r.wg.Add(r.config.Terminals)
@@ -120,14 +160,25 @@ func (r *Runner) startTerminals() {
 			stopChan: make(chan struct{}),
 			rng:      rand.New(rand.NewSource(time.Now().UnixNano() + int64(i))),
 		}
+		if r.config.TerminalMode == TerminalModeSpec {
+			terminal.wID = (i/benchmark.TerminalsPerWarehouse)%r.config.Warehouses + 1
+		}
 		r.terminals[i] = terminal
 		go terminal.run()
 	}
 }
 
-// Stop stops the test
+// Stop stops the test. Run returns the statistics gathered so far.
 func (r *Runner) Stop() {
-	close(r.stopChan)
+	r.stopOnce.Do(func() { close(r.stopChan) })
+}
+
+// stopTerminals stops all client terminals and waits for their current
+// transaction to finish
+func (r *Runner) stopTerminals() {
+	for _, terminal := range r.terminals {
+		close(terminal.stopChan)
+	}
 	r.wg.Wait()
 }
 
@@ -148,17 +199,22 @@ func (r *Runner) monitor(ctx context.Context) {
 			currentTime := time.Now()
 			interval := currentTime.Sub(lastTime)
 
-			// Calculate interval metrics
+			// Calculate interval metrics. Only the measurement is counted.
 			newOrders := currentStats.NewOrderCount - lastStats.NewOrderCount
 			tpmC := float64(newOrders) / interval.Minutes()
+			var overallTPMc float64
+			if measured := r.phases.Measured(currentTime); measured > 0 {
+				overallTPMc = float64(currentStats.NewOrderCount) / measured.Minutes()
+			}
 
 			r.logger.Info("Test progress",
 				zap.Duration("elapsed", time.Since(r.stats.StartTime)),
+				zap.String("phase", r.phases.PhaseAt(currentTime)),
 				zap.Int64("total_transactions", currentStats.TotalTransactions),
-				zap.Int64("total_errors", currentStats.TotalErrors),
+				zap.Int64("total_errors", currentStats.Errors),
 				zap.Float64("current_tpmC", tpmC),
-				zap.Float64("overall_tpmC", float64(currentStats.NewOrderCount)/time.Since(r.stats.StartTime).Minutes()),
-				zap.Float64("efficiency", float64(currentStats.TotalTransactions-currentStats.TotalErrors)/float64(currentStats.TotalTransactions)*100),
+				zap.Float64("overall_tpmC", overallTPMc),
+				zap.Float64("success_rate", float64(currentStats.TotalTransactions-currentStats.Errors)/float64(currentStats.TotalTransactions)*100),
 				zap.Int64("new_orders", currentStats.NewOrderCount),
 				zap.Int64("payments", currentStats.PaymentCount),
 				zap.Int64("order_status", currentStats.OrderStatusCount),
@@ -172,99 +228,155 @@ func (r *Runner) monitor(ctx context.Context) {
 	}
 }
 
-// calculateStats calculates final test statistics
+// calculateStats calculates final test statistics over the measurement
 func (r *Runner) calculateStats() {
-	r.stats.EndTime = time.Now()
-	r.stats.Duration = r.stats.EndTime.Sub(r.stats.StartTime)
-	r.stats.TPMc = float64(r.stats.NewOrderCount) / r.stats.Duration.Minutes()
-	if r.stats.TotalTransactions > 0 {
-		r.stats.Efficiency = float64(r.stats.TotalTransactions-r.stats.TotalErrors) / float64(r.stats.TotalTransactions) * 100
-	}
+	r.stats.StartTime = r.phases.MeasureStart()
+	r.stats.EndTime = r.stats.StartTime.Add(r.phases.Measured(time.Now()))
+	r.stats.Finalize()
 
 	r.logger.Info("Test completed",
 		zap.Duration("duration", r.stats.Duration),
 		zap.Int64("total_transactions", r.stats.TotalTransactions),
-		zap.Int64("total_errors", r.stats.TotalErrors),
+		zap.Int64("total_errors", r.stats.Errors),
 		zap.Float64("tpmC", r.stats.TPMc),
+		zap.Float64("max_tpmC", r.stats.MaxTPMc),
 		zap.Float64("efficiency", r.stats.Efficiency),
-		zap.Float64("latency_avg_ms", r.stats.OverallLatencyAvg),
+		zap.Float64("success_rate", r.stats.SuccessRate),
+		zap.Duration("latency_avg", r.stats.LatencyAvg),
+		zap.Duration("latency_p99", r.stats.LatencyP99),
+		zap.Bool("compliant", r.stats.Compliance.Passed),
 	)
+	if r.config.TerminalMode == TerminalModeSpec && r.stats.TPMc > r.stats.MaxTPMc {
+		r.logger.Warn("tpmC is above the maximum spec terminals can reach",
+			zap.Float64("tpmC", r.stats.TPMc),
+			zap.Float64("max_tpmC", r.stats.MaxTPMc),
+		)
+	}
 }
 
-// run executes transactions for a terminal
+// run executes transactions for a terminal from its turn in the ramp-up
+// until the test stops or its error policy ends it. Spec terminals wait the
+// keying time before and the think time after every transaction.
 func (t *Terminal) run() {
 	defer t.runner.wg.Done()
+	if !t.runner.phases.WaitForThread(context.Background(), t.stopChan, t.id-1, len(t.runner.terminals)) {
+		return
+	}
+	spec := t.runner.config.TerminalMode == TerminalModeSpec
 
 	for {
 		select {
 		case <-t.stopChan:
 			return
 		default:
-			if err := t.executeTransaction(); err != nil {
+			txType := t.nextTransaction()
+			if spec && !t.wait(time.Duration(benchmark.KeyingTimes[txType]*float64(time.Second))) {
+				return
+			}
+			err := t.executeTransaction(txType)
+			if err != nil {
 				t.runner.logger.Error("Transaction error",
 					zap.Int("terminal", t.id),
 					zap.Error(err),
 				)
+				if t.runner.errors.Failed(err) {
+					t.runner.Stop()
+					return
+				}
+			} else {
+				t.runner.errors.Succeeded()
+			}
+			if spec && !t.wait(benchmark.ExponentialThinkTime(txType, t.rng)) {
+				return
 			}
 		}
 	}
 }
 
-// executeTransaction executes a random transaction based on the configured mix
-func (t *Terminal) executeTransaction() error {
-	r := t.rng.Float64() * 100
-	start := time.Now()
-	var err error
+// wait pauses the terminal for d and reports whether it was not stopped
+// meanwhile
+func (t *Terminal) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
+	select {
+	case <-t.stopChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// nextTransaction picks the type of the next transaction based on the
+// configured mix
+func (t *Terminal) nextTransaction() benchmark.OLTPTransactionType {
+	r := t.rng.Float64() * 100
 	switch {
 	case r < t.runner.config.NewOrderPercentage:
-		err = t.executeNewOrderTransaction()
-		if err == nil {
-			atomic.AddInt64(&t.runner.stats.NewOrderCount, 1)
-		} else {
-			atomic.AddInt64(&t.runner.stats.NewOrderErrors, 1)
-		}
-
+		return benchmark.NewOrder
 	case r < t.runner.config.NewOrderPercentage+t.runner.config.PaymentPercentage:
-		err = t.executePaymentTransaction()
-		if err == nil {
-			atomic.AddInt64(&t.runner.stats.PaymentCount, 1)
-		} else {
-			atomic.AddInt64(&t.runner.stats.PaymentErrors, 1)
-		}
-
+		return benchmark.Payment
 	case r < t.runner.config.NewOrderPercentage+t.runner.config.PaymentPercentage+t.runner.config.OrderStatusPercentage:
-		err = t.executeOrderStatusTransaction()
-		if err == nil {
-			atomic.AddInt64(&t.runner.stats.OrderStatusCount, 1)
-		} else {
-			atomic.AddInt64(&t.runner.stats.OrderStatusErrors, 1)
-		}
-
+		return benchmark.OrderStatus
 	case r < t.runner.config.NewOrderPercentage+t.runner.config.PaymentPercentage+t.runner.config.OrderStatusPercentage+t.runner.config.DeliveryPercentage:
-		err = t.executeDeliveryTransaction()
-		if err == nil {
-			atomic.AddInt64(&t.runner.stats.DeliveryCount, 1)
-		} else {
-			atomic.AddInt64(&t.runner.stats.DeliveryErrors, 1)
-		}
+		return benchmark.Delivery
+	default:
+		return benchmark.StockLevel
+	}
+}
+
+// executeTransaction executes a transaction of txType, retrying it as the
+// error policy allows. Only transactions started during the measurement are
+// counted in the statistics.
+func (t *Terminal) executeTransaction(txType benchmark.OLTPTransactionType) error {
+	start := time.Now()
+	var execute func() error
+	var count, errs *int64
+
+	switch txType {
+	case benchmark.NewOrder:
+		execute = t.executeNewOrderTransaction
+		count, errs = &t.runner.stats.NewOrderCount, &t.runner.stats.NewOrderErrors
+
+	case benchmark.Payment:
+		execute = t.executePaymentTransaction
+		count, errs = &t.runner.stats.PaymentCount, &t.runner.stats.PaymentErrors
+
+	case benchmark.OrderStatus:
+		execute = t.executeOrderStatusTransaction
+		count, errs = &t.runner.stats.OrderStatusCount, &t.runner.stats.OrderStatusErrors
+
+	case benchmark.Delivery:
+		execute = t.executeDeliveryTransaction
+		count, errs = &t.runner.stats.DeliveryCount, &t.runner.stats.DeliveryErrors
 
 	default:
-		err = t.executeStockLevelTransaction()
-		if err == nil {
-			atomic.AddInt64(&t.runner.stats.StockLevelCount, 1)
-		} else {
-			atomic.AddInt64(&t.runner.stats.StockLevelErrors, 1)
-		}
+		execute = t.executeStockLevelTransaction
+		count, errs = &t.runner.stats.StockLevelCount, &t.runner.stats.StockLevelErrors
 	}
 
-	if err == nil {
-		atomic.AddInt64(&t.runner.stats.TotalTransactions, 1)
-		t.updateLatencyStats(time.Since(start))
+	err := t.runner.errors.Retry(context.Background(), t.stopChan, execute)
+	latency := time.Since(start)
+	var measured bool
+	if err != nil {
+		t.runner.intervals.RecordError(err)
+		measured = t.runner.phases.RecordError(start)
 	} else {
-		atomic.AddInt64(&t.runner.stats.TotalErrors, 1)
+		t.runner.intervals.RecordTransaction(latency)
+		measured = t.runner.phases.RecordTransaction(start, latency)
+	}
+	if !measured {
+		return err
 	}
 
+	if err != nil {
+		atomic.AddInt64(errs, 1)
+		t.runner.stats.Breakdown.RecordError(string(txType))
+	} else {
+		atomic.AddInt64(count, 1)
+		t.runner.stats.Breakdown.Record(string(txType), latency, 0)
+	}
+	t.runner.stats.AddTransaction(latency, err)
 	return err
 }
 
@@ -350,11 +462,3 @@ func (t *Terminal) executeStockLevelTransaction() error {
 
 	return t.runner.executor.ExecuteStockLevel(context.Background(), tx)
 }
-
-// updateLatencyStats updates latency statistics for a transaction
-func (t *Terminal) updateLatencyStats(d time.Duration) {
-	ms := float64(d.Milliseconds())
-	atomic.StoreFloat64(&t.runner.stats.OverallLatencyAvg,
-		(atomic.LoadFloat64(&t.runner.stats.OverallLatencyAvg)*float64(atomic.LoadInt64(&t.runner.stats.TotalTransactions)-1)+ms)/
-			float64(atomic.LoadInt64(&t.runner.stats.TotalTransactions)))
-}
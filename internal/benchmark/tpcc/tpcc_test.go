@@ -4,22 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+
+	"github.com/deadjoe/benchphant/internal/models"
 )
 
+// newTestDB opens a file backed SQLite database. A single connection keeps
+// concurrent terminals from running into SQLITE_BUSY.
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tpcc.db"))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
 func TestTPCCBenchmark(t *testing.T) {
 	// Create test database
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	// Create test config
 	config := &Config{
@@ -85,12 +95,10 @@ func TestTPCCBenchmark(t *testing.T) {
 }
 
 func TestFactory(t *testing.T) {
-	factory := NewFactory(zaptest.NewLogger(t))
+	factory := NewFactory()
 
 	t.Run("Create", func(t *testing.T) {
-		db, err := sql.Open("sqlite3", ":memory:")
-		require.NoError(t, err)
-		defer db.Close()
+		db := newTestDB(t)
 
 		config := Config{
 			Warehouses:     1,
@@ -102,16 +110,16 @@ func TestFactory(t *testing.T) {
 		configJSON, err := json.Marshal(config)
 		require.NoError(t, err)
 
-		benchmark, err := factory.Create(db, configJSON)
+		conn := &models.DBConnection{Type: models.SQLite}
+		conn.SetDB(db)
+		runner, err := factory.Create(&models.Benchmark{Config: configJSON}, conn, zaptest.NewLogger(t))
 		assert.NoError(t, err)
-		assert.NotNil(t, benchmark)
-		assert.Equal(t, "tpcc", benchmark.Name())
+		require.IsType(t, &TPCCBenchmark{}, runner)
+		assert.Equal(t, "tpcc", runner.(*TPCCBenchmark).Name())
 	})
 
 	t.Run("InvalidConfig", func(t *testing.T) {
-		db, err := sql.Open("sqlite3", ":memory:")
-		require.NoError(t, err)
-		defer db.Close()
+		db := newTestDB(t)
 
 		invalidConfig := struct {
 			Invalid string `json:"invalid"`
@@ -122,16 +130,16 @@ func TestFactory(t *testing.T) {
 		configJSON, err := json.Marshal(invalidConfig)
 		require.NoError(t, err)
 
-		benchmark, err := factory.Create(db, configJSON)
+		conn := &models.DBConnection{Type: models.SQLite}
+		conn.SetDB(db)
+		runner, err := factory.Create(&models.Benchmark{Config: configJSON}, conn, zaptest.NewLogger(t))
 		assert.Error(t, err)
-		assert.Nil(t, benchmark)
+		assert.Nil(t, runner)
 	})
 }
 
 func TestRunner(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses:            1,
@@ -157,10 +165,10 @@ func TestRunner(t *testing.T) {
 		require.NoError(t, loader.Load(ctx))
 
 		// Run benchmark
-		assert.NoError(t, runner.Run(ctx))
+		stats, err := runner.Run(ctx)
+		assert.NoError(t, err)
 
 		// Check stats
-		stats := runner.GetStats()
 		assert.True(t, stats.TotalTransactions > 0)
 		assert.True(t, stats.TPMc > 0)
 		assert.True(t, stats.Efficiency > 0)
@@ -270,9 +278,7 @@ func createStockLevelTx(executor *TransactionExecutor) testTransaction {
 }
 
 func TestConcurrentTransactions(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses: 2,
@@ -317,9 +323,7 @@ func TestConcurrentTransactions(t *testing.T) {
 }
 
 func TestTransactionExecutor(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses: 1,
@@ -384,9 +388,7 @@ func TestTransactionExecutor(t *testing.T) {
 }
 
 func TestBoundaryConditions(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses: 1,
@@ -433,9 +435,7 @@ func TestBoundaryConditions(t *testing.T) {
 }
 
 func TestErrorHandling(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses: 1,
@@ -497,9 +497,7 @@ func TestErrorHandling(t *testing.T) {
 }
 
 func TestPerformanceMetrics(t *testing.T) {
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
-	defer db.Close()
+	db := newTestDB(t)
 
 	config := &Config{
 		Warehouses:            2,
@@ -525,9 +523,12 @@ func TestPerformanceMetrics(t *testing.T) {
 	require.NotNil(t, result)
 
 	// Verify performance metrics
-	assert.True(t, result.Metrics["tpmC"] > 0, "tpmC should be positive")
-	assert.True(t, result.Metrics["efficiency"] > 0, "Efficiency should be positive")
-	assert.True(t, result.Metrics["efficiency"] <= 100, "Efficiency should be <= 100")
-	assert.True(t, result.Metrics["total_transactions"] > 0, "Should have some transactions")
-	assert.True(t, result.Metrics["overall_latency_avg"] > 0, "Average latency should be positive")
+	assert.Greater(t, result.Metrics["tpmC"], 0.0, "tpmC should be positive")
+	assert.Greater(t, result.Metrics["efficiency"], 0.0, "Efficiency should be positive")
+	// Efficiency is the tpmC as a percentage of the spec maximum, which
+	// no-wait terminals exceed; the share of successful transactions it
+	// used to stand for is the success rate
+	assert.LessOrEqual(t, result.Metrics["success_rate"], 100.0, "Success rate should be <= 100")
+	assert.Greater(t, result.TotalTransactions, int64(0), "Should have some transactions")
+	assert.Greater(t, result.LatencyAvg, time.Duration(0), "Average latency should be positive")
 }
@@ -1,12 +1,13 @@
 package tpcc
 
 import (
-	"context"
-	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
 )
 
 // DatabaseConfig represents the database connection configuration
@@ -20,41 +21,61 @@ type DatabaseConfig struct {
 	SSLMode  string `json:"ssl_mode"` // disable, require, verify-ca, verify-full
 }
 
+// Terminal modes
+const (
+	// TerminalModeNoWait runs transactions back to back to measure the
+	// highest throughput of the database
+	TerminalModeNoWait = "no_wait"
+	// TerminalModeSpec waits the keying time before and the think time after
+	// every transaction, as the terminals of the TPC-C spec do
+	TerminalModeSpec = "spec"
+)
+
 // Config represents the TPC-C benchmark configuration
 type Config struct {
 	// Database configuration
 	Database DatabaseConfig `json:"database"`
 
 	// Scale configuration
-	Warehouses int `json:"warehouses"` // Number of warehouses
-	Terminals  int `json:"terminals"`  // Number of terminals (concurrent clients)
+	Warehouses int `json:"warehouses" description:"Number of warehouses"`
+	Terminals  int `json:"terminals" description:"Number of terminals (concurrent clients)"`
+
+	// Terminal configuration
+	TerminalMode string `json:"terminal_mode" description:"no_wait runs transactions back to back, spec waits keying and think times around each one and needs 10 terminals per warehouse" enum:"no_wait,spec"`
 
 	// Duration configuration
-	Duration       time.Duration `json:"duration"`        // Total test duration
-	ReportInterval time.Duration `json:"report_interval"` // Interval between progress reports
+	Duration       time.Duration `json:"duration" description:"Total test duration"`
+	ReportInterval time.Duration `json:"report_interval" description:"Interval between progress reports"`
+	RampUp         time.Duration `json:"ramp_up" description:"Time over which the terminals are started, before the warmup"`
+	RampSteps      int           `json:"ramp_steps" description:"Groups the terminals are started in during the ramp-up, 0 to start them one by one"`
+	Warmup         time.Duration `json:"warmup" description:"Time run with all terminals before measuring starts"`
+	CoolDown       time.Duration `json:"cool_down" description:"Time the terminals keep running after measuring ends"`
+
+	// Error handling configuration
+	ErrorPolicy models.ErrorPolicy `json:"error_policy" description:"How failed transactions are retried and when they fail the run, by default it continues"`
 
 	// Transaction mix configuration
-	NewOrderPercentage    float64 `json:"new_order_percentage"`    // Percentage of new order transactions
-	PaymentPercentage     float64 `json:"payment_percentage"`      // Percentage of payment transactions
-	OrderStatusPercentage float64 `json:"order_status_percentage"` // Percentage of order status transactions
-	DeliveryPercentage    float64 `json:"delivery_percentage"`     // Percentage of delivery transactions
-	StockLevelPercentage  float64 `json:"stock_level_percentage"`  // Percentage of stock level transactions
+	NewOrderPercentage    float64 `json:"new_order_percentage" description:"Percentage of New-Order transactions"`
+	PaymentPercentage     float64 `json:"payment_percentage" description:"Percentage of Payment transactions"`
+	OrderStatusPercentage float64 `json:"order_status_percentage" description:"Percentage of Order-Status transactions"`
+	DeliveryPercentage    float64 `json:"delivery_percentage" description:"Percentage of Delivery transactions"`
+	StockLevelPercentage  float64 `json:"stock_level_percentage" description:"Percentage of Stock-Level transactions"`
 
 	// New order configuration
-	NewOrderItemsMin int `json:"new_order_items_min"` // Minimum items per new order
-	NewOrderItemsMax int `json:"new_order_items_max"` // Maximum items per new order
+	NewOrderItemsMin int `json:"new_order_items_min" description:"Minimum items per new order"`
+	NewOrderItemsMax int `json:"new_order_items_max" description:"Maximum items per new order"`
 
 	// Advanced configuration
-	InitialLoad    bool `json:"initial_load"`    // Whether to load initial data
-	DropExisting   bool `json:"drop_existing"`   // Whether to drop existing tables
-	EnableForeign  bool `json:"enable_foreign"`  // Whether to enable foreign keys
-	EnableIndexes  bool `json:"enable_indexes"`  // Whether to create indexes
-	EnableTriggers bool `json:"enable_triggers"` // Whether to create triggers
+	InitialLoad    bool `json:"initial_load" description:"Load initial data"`
+	DropExisting   bool `json:"drop_existing" description:"Drop existing tables before loading"`
+	EnableForeign  bool `json:"enable_foreign" description:"Create foreign keys"`
+	EnableIndexes  bool `json:"enable_indexes" description:"Create secondary indexes"`
+	EnableTriggers bool `json:"enable_triggers" description:"Create triggers"`
 
 	// Connection pool configuration
-	MaxIdleConns    int           `json:"max_idle_conns"`    // Maximum number of idle connections
-	MaxOpenConns    int           `json:"max_open_conns"`    // Maximum number of open connections
-	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"` // Maximum connection lifetime
+	MaxIdleConns    int           `json:"max_idle_conns" description:"Maximum number of idle connections"`
+	MaxOpenConns    int           `json:"max_open_conns" description:"Maximum number of open connections"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime" description:"Maximum connection lifetime"`
 }
 
 // Validate validates the configuration
@@ -62,8 +83,8 @@ func (c *Config) Validate() error {
 	if c.Warehouses <= 0 {
 		return fmt.Errorf("warehouses must be greater than 0")
 	}
-	if c.Terminals <= 0 {
-		return fmt.Errorf("terminals must be greater than 0")
+	if err := c.validateTerminals(); err != nil {
+		return err
 	}
 	if c.Duration <= 0 {
 		return fmt.Errorf("duration must be greater than 0")
@@ -71,6 +92,12 @@ func (c *Config) Validate() error {
 	if c.ReportInterval <= 0 {
 		return fmt.Errorf("report interval must be greater than 0")
 	}
+	if err := c.Phases().Validate(); err != nil {
+		return err
+	}
+	if err := c.ErrorPolicy.Validate(); err != nil {
+		return err
+	}
 
 	// Validate transaction mix percentages
 	total := c.NewOrderPercentage + c.PaymentPercentage + c.OrderStatusPercentage + c.DeliveryPercentage + c.StockLevelPercentage
@@ -100,6 +127,51 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validateTerminals checks the number of terminals against the terminal mode
+func (c *Config) validateTerminals() error {
+	if c.Terminals <= 0 {
+		return fmt.Errorf("terminals must be greater than 0")
+	}
+	switch c.TerminalMode {
+	case "", TerminalModeNoWait:
+	case TerminalModeSpec:
+		if c.Terminals != c.Warehouses*benchmark.TerminalsPerWarehouse {
+			return fmt.Errorf("terminal mode spec needs %d terminals per warehouse, got %d terminals for %d warehouses",
+				benchmark.TerminalsPerWarehouse, c.Terminals, c.Warehouses)
+		}
+	default:
+		return fmt.Errorf("unsupported terminal mode: %s", c.TerminalMode)
+	}
+	return nil
+}
+
+// Mix returns the percentage of each transaction type
+func (c *Config) Mix() map[benchmark.OLTPTransactionType]float64 {
+	return map[benchmark.OLTPTransactionType]float64{
+		benchmark.NewOrder:    c.NewOrderPercentage,
+		benchmark.Payment:     c.PaymentPercentage,
+		benchmark.OrderStatus: c.OrderStatusPercentage,
+		benchmark.Delivery:    c.DeliveryPercentage,
+		benchmark.StockLevel:  c.StockLevelPercentage,
+	}
+}
+
+// MaxTPMC returns the highest tpmC that spec terminals can reach on the
+// configured warehouses with the configured mix
+func (c *Config) MaxTPMC() float64 {
+	return benchmark.MaxTPMC(c.Mix(), c.Warehouses*benchmark.TerminalsPerWarehouse)
+}
+
+// Phases returns the phases run around the measurement
+func (c *Config) Phases() models.PhaseConfig {
+	return models.PhaseConfig{
+		RampUp:    c.RampUp,
+		RampSteps: c.RampSteps,
+		Warmup:    c.Warmup,
+		CoolDown:  c.CoolDown,
+	}
+}
+
 // GetDSN returns the database connection string
 func (c *DatabaseConfig) GetDSN() string {
 	switch c.Type {
@@ -128,6 +200,7 @@ func DefaultConfig() *Config {
 		},
 		Warehouses:            10,
 		Terminals:             100,
+		TerminalMode:          TerminalModeNoWait,
 		Duration:              30 * time.Minute,
 		ReportInterval:        1 * time.Minute,
 		NewOrderPercentage:    45,
@@ -148,17 +221,46 @@ func DefaultConfig() *Config {
 	}
 }
 
-// Stats represents TPCC test statistics
+// Stats represents TPCC test statistics. The counters are updated
+// atomically by the terminals and latencies are recorded in a fixed-memory
+// histogram, overall and in Breakdown by transaction type. MaxTPMc is set
+// by the runner, the remaining fields are filled in by Finalize. Efficiency
+// is the tpmC as a percentage of MaxTPMc and SuccessRate the percentage of
+// transactions that did not fail.
 type Stats struct {
 	TotalTransactions int64
-	TPS              float64
-	LatencyAvg       time.Duration
-	LatencyP95       time.Duration
-	LatencyP99       time.Duration
-	Errors           int64
-	StartTime        time.Time
-	EndTime          time.Time
-	Metrics          map[string]float64
+	Errors            int64
+
+	NewOrderCount     int64
+	NewOrderErrors    int64
+	PaymentCount      int64
+	PaymentErrors     int64
+	OrderStatusCount  int64
+	OrderStatusErrors int64
+	DeliveryCount     int64
+	DeliveryErrors    int64
+	StockLevelCount   int64
+	StockLevelErrors  int64
+
+	TPS         float64
+	TPMc        float64
+	MaxTPMc     float64
+	Efficiency  float64
+	SuccessRate float64
+	LatencyAvg  time.Duration
+	LatencyP50  time.Duration
+	LatencyP90  time.Duration
+	LatencyP95  time.Duration
+	LatencyP99  time.Duration
+	LatencyP999 time.Duration
+	LatencyMax  time.Duration
+	Duration    time.Duration
+	StartTime   time.Time
+	EndTime     time.Time
+	Metrics     map[string]float64
+	Compliance  benchmark.TPCCCompliance
+	Latencies   *metrics.Histogram   `json:"-"`
+	Breakdown   *benchmark.Breakdown `json:"-"`
 }
 
 // NewStats creates a new Stats instance
@@ -166,33 +268,61 @@ func NewStats() *Stats {
 	return &Stats{
 		StartTime: time.Now(),
 		Metrics:   make(map[string]float64),
+		Latencies: metrics.NewHistogram(),
+		Breakdown: benchmark.NewBreakdown(),
 	}
 }
 
-// AddTransaction adds a transaction to the stats
+// AddTransaction adds a transaction to the stats. It is safe for concurrent
+// use.
 func (s *Stats) AddTransaction(latency time.Duration, err error) {
-	s.TotalTransactions++
+	atomic.AddInt64(&s.TotalTransactions, 1)
 	if err != nil {
-		s.Errors++
+		atomic.AddInt64(&s.Errors, 1)
 		return
 	}
-
-	// Update latency metrics
-	s.LatencyAvg = time.Duration((float64(s.LatencyAvg)*float64(s.TotalTransactions-1) + float64(latency)) / float64(s.TotalTransactions))
-	
-	// Update metrics map
-	s.Metrics["total_transactions"] = float64(s.TotalTransactions)
-	s.Metrics["errors"] = float64(s.Errors)
-	s.Metrics["latency_avg_ms"] = float64(s.LatencyAvg.Milliseconds())
+	s.Latencies.Record(latency)
 }
 
-// Finalize calculates final statistics
+// Finalize calculates final statistics over StartTime to EndTime, which
+// defaults to now
 func (s *Stats) Finalize() {
-	s.EndTime = time.Now()
-	duration := s.EndTime.Sub(s.StartTime)
-	s.TPS = float64(s.TotalTransactions) / duration.Seconds()
+	if s.EndTime.IsZero() {
+		s.EndTime = time.Now()
+	}
+	s.Duration = s.EndTime.Sub(s.StartTime)
+	if s.Duration > 0 {
+		s.TPS = float64(s.TotalTransactions) / s.Duration.Seconds()
+		s.TPMc = float64(s.NewOrderCount) / s.Duration.Minutes()
+	}
+	if s.MaxTPMc > 0 {
+		s.Efficiency = s.TPMc / s.MaxTPMc * 100
+	}
+	if s.TotalTransactions > 0 {
+		s.SuccessRate = float64(s.TotalTransactions-s.Errors) / float64(s.TotalTransactions) * 100
+	}
+	s.Compliance = benchmark.CheckTPCCCompliance(s.Breakdown.Stats())
+
+	latency := s.Latencies.Summary()
+	s.LatencyAvg = latency.Avg
+	s.LatencyP50 = latency.P50
+	s.LatencyP90 = latency.P90
+	s.LatencyP95 = latency.P95
+	s.LatencyP99 = latency.P99
+	s.LatencyP999 = latency.P999
+	s.LatencyMax = latency.Max
+
 	s.Metrics["tps"] = s.TPS
-	s.Metrics["duration_seconds"] = duration.Seconds()
+	s.Metrics["tpmC"] = s.TPMc
+	s.Metrics["max_tpmC"] = s.MaxTPMc
+	s.Metrics["efficiency"] = s.Efficiency
+	s.Metrics["success_rate"] = s.SuccessRate
+	s.Metrics["duration_seconds"] = s.Duration.Seconds()
+	s.Metrics["new_order"] = float64(s.NewOrderCount)
+	s.Metrics["payment"] = float64(s.PaymentCount)
+	s.Metrics["order_status"] = float64(s.OrderStatusCount)
+	s.Metrics["delivery"] = float64(s.DeliveryCount)
+	s.Metrics["stock_level"] = float64(s.StockLevelCount)
 }
 
 // Schema represents the TPC-C database schema
@@ -0,0 +1,47 @@
+package tpcc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+func TestConfigTerminalMode(t *testing.T) {
+	config := DefaultConfig()
+	config.Warehouses = 1
+	config.Terminals = 2
+	assert.NoError(t, config.Validate())
+
+	// Spec terminals need one terminal per district
+	config.TerminalMode = TerminalModeSpec
+	assert.Error(t, config.Validate())
+	config.Terminals = 10
+	assert.NoError(t, config.Validate())
+	assert.InDelta(t, 12.86, config.MaxTPMC(), 0.01)
+
+	config.TerminalMode = "keying"
+	assert.Error(t, config.Validate())
+}
+
+func TestStatsFinalize(t *testing.T) {
+	stats := NewStats()
+	stats.MaxTPMc = 128.6
+	stats.EndTime = stats.StartTime.Add(time.Minute)
+	stats.TotalTransactions = 100
+	stats.Errors = 10
+	stats.NewOrderCount = 45
+	stats.Breakdown.Record(string(benchmark.NewOrder), 6*time.Second, 0)
+	stats.Finalize()
+
+	// Efficiency is the tpmC as a percentage of the maximum, the success
+	// rate the percentage of transactions that did not fail
+	assert.InDelta(t, 45/128.6*100, stats.Efficiency, 0.001)
+	assert.Equal(t, stats.Efficiency, stats.Metrics["efficiency"])
+	assert.Equal(t, 90.0, stats.SuccessRate)
+	assert.Equal(t, stats.SuccessRate, stats.Metrics["success_rate"])
+	assert.False(t, stats.Compliance.Passed)
+	assert.False(t, stats.Compliance.ResponseTimes[benchmark.NewOrder].Passed)
+}
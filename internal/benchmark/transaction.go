@@ -3,8 +3,9 @@ package benchmark
 import (
 	"context"
 	"database/sql"
-	"strings"
 	"time"
+
+	"github.com/deadjoe/benchphant/internal/dberror"
 )
 
 // Transaction represents a database transaction with multiple SQL statements
@@ -17,9 +18,10 @@ type Transaction struct {
 
 // TransactionExecutor executes database transactions
 type TransactionExecutor struct {
-	db     *sql.DB
-	stats  *TransactionStats
-	logger Logger
+	db         *sql.DB
+	stats      *TransactionStats
+	logger     Logger
+	maxRetries int
 }
 
 // NewTransactionExecutor creates a new TransactionExecutor
@@ -31,7 +33,14 @@ func NewTransactionExecutor(db *sql.DB, stats *TransactionStats, logger Logger)
 	}
 }
 
-// Execute executes a transaction
+// SetMaxRetries sets how many times a transaction that lost a conflict with
+// concurrent ones is run again before it fails
+func (e *TransactionExecutor) SetMaxRetries(n int) {
+	e.maxRetries = n
+}
+
+// Execute executes a transaction, running it again after a deadlock, lock
+// timeout or serialization failure as often as the executor retries
 func (e *TransactionExecutor) Execute(ctx context.Context, tx *Transaction) error {
 	tx.StartTime = time.Now()
 	defer func() {
@@ -39,51 +48,72 @@ func (e *TransactionExecutor) Execute(ctx context.Context, tx *Transaction) erro
 		e.updateTransactionTime(tx.EndTime.Sub(tx.StartTime))
 	}()
 
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = e.execute(ctx, tx)
+		if err == nil || attempt >= e.maxRetries || ctx.Err() != nil || !dberror.IsConflict(dberror.Classify(err)) {
+			break
+		}
+		e.stats.mu.Lock()
+		e.stats.LockStats.RetryCount++
+		e.stats.mu.Unlock()
+	}
+
+	e.stats.mu.Lock()
+	if err != nil {
+		e.stats.FailedTransactions++
+	} else {
+		e.stats.SuccessfulTransactions++
+		e.stats.TotalTransactions++
+	}
+	e.stats.mu.Unlock()
+	if err == nil {
+		e.logger.Info("Transaction executed successfully")
+	}
+	return err
+}
+
+// execute runs the statements of a transaction once. A statement or commit
+// failing with a conflict is recorded in the lock statistics.
+func (e *TransactionExecutor) execute(ctx context.Context, tx *Transaction) error {
 	// Begin transaction
 	txn, err := e.db.BeginTx(ctx, nil)
 	if err != nil {
-		e.stats.mu.Lock()
-		e.stats.FailedTransactions++
-		e.stats.mu.Unlock()
 		e.logger.Error("Failed to begin transaction", err)
 		return err
 	}
 
 	// Execute statements
 	for _, stmt := range tx.Statements {
+		start := time.Now()
 		_, err := txn.ExecContext(ctx, stmt)
 		if err != nil {
+			e.recordConflict(err, time.Since(start))
 			rollbackErr := txn.Rollback()
 			if rollbackErr != nil {
 				e.logger.Error("Failed to rollback transaction", rollbackErr)
 			}
-			e.stats.mu.Lock()
-			e.stats.FailedTransactions++
-			if e.IsDeadlock(err) {
-				e.stats.LockStats.DeadlockCount++
-			}
-			e.stats.mu.Unlock()
 			e.logger.Error("Failed to execute statement", err)
 			return err
 		}
 	}
 
 	// Commit transaction
+	start := time.Now()
 	err = txn.Commit()
 	if err != nil {
-		e.stats.mu.Lock()
-		e.stats.FailedTransactions++
-		e.stats.mu.Unlock()
+		e.recordConflict(err, time.Since(start))
 		e.logger.Error("Failed to commit transaction", err)
 		return err
 	}
+	return nil
+}
 
+// recordConflict records err in the lock statistics if it is a conflict
+func (e *TransactionExecutor) recordConflict(err error, waited time.Duration) {
 	e.stats.mu.Lock()
-	e.stats.SuccessfulTransactions++
-	e.stats.TotalTransactions++
-	e.stats.mu.Unlock()
-	e.logger.Info("Transaction executed successfully")
-	return nil
+	defer e.stats.mu.Unlock()
+	e.stats.LockStats.RecordConflict(dberror.Classify(err), waited)
 }
 
 // updateTransactionTime updates transaction time statistics
@@ -99,12 +129,12 @@ func (e *TransactionExecutor) updateTransactionTime(duration time.Duration) {
 	if duration > e.stats.MaxDuration {
 		e.stats.MaxDuration = duration
 	}
+
+	e.stats.durations.Record(duration)
+	e.stats.P95Duration = e.stats.durations.Percentile(95)
 }
 
 // IsDeadlock checks if an error is a deadlock error
 func (e *TransactionExecutor) IsDeadlock(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.Contains(strings.ToLower(err.Error()), "deadlock")
+	return dberror.Classify(err) == dberror.Deadlock
 }
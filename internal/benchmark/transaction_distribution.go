@@ -2,6 +2,7 @@ package benchmark
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -58,6 +59,29 @@ var KeyingTimes = map[OLTPTransactionType]float64{
 	StockLevel:  2.0,
 }
 
+// ResponseTimeLimits are the 90th percentile response times the TPC-C spec
+// allows for each transaction type
+var ResponseTimeLimits = map[OLTPTransactionType]time.Duration{
+	NewOrder:    5 * time.Second,
+	Payment:     5 * time.Second,
+	OrderStatus: 5 * time.Second,
+	Delivery:    5 * time.Second,
+	StockLevel:  20 * time.Second,
+}
+
+// MinimumMix represents the minimum percentage of each transaction type the
+// TPC-C spec requires. New-Order makes up the rest of the mix.
+var MinimumMix = map[OLTPTransactionType]float64{
+	Payment:     43.0,
+	OrderStatus: 4.0,
+	Delivery:    4.0,
+	StockLevel:  4.0,
+}
+
+// TerminalsPerWarehouse is the number of terminals TPC-C runs against each
+// warehouse, one for every district
+const TerminalsPerWarehouse = 10
+
 // NewTPCCDistribution creates a new TPC-C compliant transaction distribution
 func NewTPCCDistribution() *TPCCDistribution {
 	return &TPCCDistribution{
@@ -157,3 +181,88 @@ func (td *TPCCDistribution) GetThinkTime(txType OLTPTransactionType) time.Durati
 func (td *TPCCDistribution) GetKeyingTime(txType OLTPTransactionType) time.Duration {
 	return time.Duration(KeyingTimes[txType] * float64(time.Second))
 }
+
+// ExponentialThinkTime returns a think time for the given transaction type
+// drawn from r as the TPC-C spec requires: negative exponentially
+// distributed with ThinkTimes as its mean, truncated at ten times the mean
+func ExponentialThinkTime(txType OLTPTransactionType, r *rand.Rand) time.Duration {
+	mean := ThinkTimes[txType]
+	thinkTime := math.Min(r.ExpFloat64()*mean, 10*mean)
+	return time.Duration(thinkTime * float64(time.Second))
+}
+
+// MaxTPMC returns the New-Order transactions per minute that terminals
+// waiting the keying and think times can reach at most, taking response
+// times as zero. mix holds the percentage of each transaction type. With
+// the spec mix and TerminalsPerWarehouse terminals for each warehouse this
+// is about 12.86 per warehouse.
+func MaxTPMC(mix map[OLTPTransactionType]float64, terminals int) float64 {
+	var total, cycle float64
+	for txType, percentage := range mix {
+		total += percentage
+		cycle += percentage * (KeyingTimes[txType] + ThinkTimes[txType])
+	}
+	if cycle == 0 {
+		return 0
+	}
+
+	// Every terminal runs a transaction every cycle seconds on average
+	cycle /= total
+	return float64(terminals) * 60 / cycle * mix[NewOrder] / total
+}
+
+// ResponseTimeCheck compares the 90th percentile response time of a
+// transaction type with its limit
+type ResponseTimeCheck struct {
+	P90    time.Duration `json:"p90"`
+	Limit  time.Duration `json:"limit"`
+	Passed bool          `json:"passed"`
+}
+
+// MixCheck compares the percentage of a transaction type in a run with its
+// minimum
+type MixCheck struct {
+	Percentage float64 `json:"percentage"`
+	Minimum    float64 `json:"minimum"`
+	Passed     bool    `json:"passed"`
+}
+
+// TPCCCompliance holds the checks of a run against the response time and
+// mix constraints of the TPC-C spec
+type TPCCCompliance struct {
+	ResponseTimes map[OLTPTransactionType]ResponseTimeCheck `json:"response_times"`
+	Mix           map[OLTPTransactionType]MixCheck          `json:"mix"`
+	Passed        bool                                      `json:"passed"`
+}
+
+// CheckTPCCCompliance checks the transactions of a run, broken down by
+// transaction type, against ResponseTimeLimits and MinimumMix. The mix is
+// that of the completed transactions.
+func CheckTPCCCompliance(breakdown map[string]QueryStats) TPCCCompliance {
+	compliance := TPCCCompliance{
+		ResponseTimes: make(map[OLTPTransactionType]ResponseTimeCheck, len(ResponseTimeLimits)),
+		Mix:           make(map[OLTPTransactionType]MixCheck, len(MinimumMix)),
+		Passed:        true,
+	}
+
+	var total int64
+	for txType, limit := range ResponseTimeLimits {
+		stats := breakdown[string(txType)]
+		total += stats.Count
+		check := ResponseTimeCheck{P90: stats.Latency.P90, Limit: limit, Passed: stats.Latency.P90 <= limit}
+		compliance.ResponseTimes[txType] = check
+		compliance.Passed = compliance.Passed && check.Passed
+	}
+
+	for txType, minimum := range MinimumMix {
+		var percentage float64
+		if total > 0 {
+			percentage = float64(breakdown[string(txType)].Count) / float64(total) * 100
+		}
+		check := MixCheck{Percentage: percentage, Minimum: minimum, Passed: percentage >= minimum}
+		compliance.Mix[txType] = check
+		compliance.Passed = compliance.Passed && check.Passed
+	}
+
+	return compliance
+}
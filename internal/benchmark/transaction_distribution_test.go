@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
 )
 
 func init() {
@@ -129,6 +131,66 @@ func TestKeyingTimes(t *testing.T) {
 	}
 }
 
+func TestExponentialThinkTime(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for txType, mean := range ThinkTimes {
+		var sum time.Duration
+		for i := 0; i < 10000; i++ {
+			thinkTime := ExponentialThinkTime(txType, r)
+			assert.LessOrEqual(t, thinkTime.Seconds(), 10*mean)
+			sum += thinkTime
+		}
+		assert.InDelta(t, mean, sum.Seconds()/10000, mean*0.05, txType)
+	}
+}
+
+func TestMaxTPMC(t *testing.T) {
+	mix := map[OLTPTransactionType]float64{
+		NewOrder:    45,
+		Payment:     43,
+		OrderStatus: 4,
+		Delivery:    4,
+		StockLevel:  4,
+	}
+	assert.InDelta(t, 12.86, MaxTPMC(mix, TerminalsPerWarehouse), 0.01)
+	assert.InDelta(t, 128.6, MaxTPMC(mix, 10*TerminalsPerWarehouse), 0.1)
+
+	// Without New-Orders there is no tpmC to reach
+	assert.Zero(t, MaxTPMC(map[OLTPTransactionType]float64{Payment: 100}, 10))
+	assert.Zero(t, MaxTPMC(nil, 10))
+}
+
+func TestCheckTPCCCompliance(t *testing.T) {
+	latency := func(p90 time.Duration) metrics.LatencySummary {
+		return metrics.LatencySummary{P90: p90}
+	}
+	breakdown := map[string]QueryStats{
+		string(NewOrder):    {Count: 450, Latency: latency(4 * time.Second)},
+		string(Payment):     {Count: 430, Latency: latency(time.Second)},
+		string(OrderStatus): {Count: 40, Latency: latency(time.Second)},
+		string(Delivery):    {Count: 40, Latency: latency(2 * time.Second)},
+		string(StockLevel):  {Count: 40, Latency: latency(15 * time.Second)},
+	}
+
+	compliance := CheckTPCCCompliance(breakdown)
+	assert.True(t, compliance.Passed)
+	assert.Equal(t, ResponseTimeCheck{P90: 15 * time.Second, Limit: 20 * time.Second, Passed: true}, compliance.ResponseTimes[StockLevel])
+	assert.Equal(t, MixCheck{Percentage: 43, Minimum: 43, Passed: true}, compliance.Mix[Payment])
+	assert.NotContains(t, compliance.Mix, NewOrder)
+
+	// A slow New-Order and too few Deliveries fail the run
+	breakdown[string(NewOrder)] = QueryStats{Count: 460, Latency: latency(6 * time.Second)}
+	breakdown[string(Delivery)] = QueryStats{Count: 30, Latency: latency(time.Second)}
+	compliance = CheckTPCCCompliance(breakdown)
+	assert.False(t, compliance.Passed)
+	assert.False(t, compliance.ResponseTimes[NewOrder].Passed)
+	assert.False(t, compliance.Mix[Delivery].Passed)
+	assert.True(t, compliance.Mix[Payment].Passed)
+
+	// Without transactions the mix cannot be met
+	assert.False(t, CheckTPCCCompliance(nil).Passed)
+}
+
 func TestConcurrency(t *testing.T) {
 	dist := NewTPCCDistribution()
 	iterations := 10000
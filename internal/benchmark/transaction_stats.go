@@ -3,6 +3,9 @@ package benchmark
 import (
 	"sync"
 	"time"
+
+	"github.com/deadjoe/benchphant/internal/dberror"
+	"github.com/deadjoe/benchphant/internal/metrics"
 )
 
 // TransactionStats holds statistics about transactions
@@ -16,9 +19,12 @@ type TransactionStats struct {
 	MaxDuration            time.Duration `json:"max_duration"`
 	P95Duration            time.Duration `json:"p95_duration"`
 	LockStats              LockStats     `json:"lock_stats"`
+	durations              metrics.Histogram
 }
 
-// LockStats holds statistics about database locks and deadlocks
+// LockStats holds statistics about database locks and deadlocks. LockCount
+// is the attempts that lost a conflict with concurrent transactions and the
+// lock times are how long those attempts ran before they did.
 type LockStats struct {
 	DeadlockCount int64         `json:"deadlock_count"`
 	RetryCount    int64         `json:"retry_count"`
@@ -28,14 +34,21 @@ type LockStats struct {
 	LockCount     int64         `json:"lock_count"`
 }
 
-// QueryStats holds statistics about SQL queries
-type QueryStats struct {
-	Count         int64         `json:"count"`
-	TotalDuration time.Duration `json:"total_duration"`
-	MinDuration   time.Duration `json:"min_duration"`
-	MaxDuration   time.Duration `json:"max_duration"`
-	P95Duration   time.Duration `json:"p95_duration"`
-	Errors        int64         `json:"errors"`
+// RecordConflict records an attempt that failed with an error of class
+// after waited, if class is a conflict
+func (s *LockStats) RecordConflict(class dberror.Class, waited time.Duration) {
+	if !dberror.IsConflict(class) {
+		return
+	}
+	s.LockCount++
+	if class == dberror.Deadlock {
+		s.DeadlockCount++
+	}
+	s.TotalLockTime += waited
+	s.AvgLockTime = s.TotalLockTime / time.Duration(s.LockCount)
+	if waited > s.MaxLockTime {
+		s.MaxLockTime = waited
+	}
 }
 
 // AverageDuration returns the average duration of successful transactions
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -195,6 +197,61 @@ func TestExecute_Deadlock(t *testing.T) {
 	assert.Equal(t, int64(0), stats.SuccessfulTransactions)
 	assert.Equal(t, int64(0), stats.TotalTransactions)
 	assert.Equal(t, int64(1), stats.FailedTransactions)
+	assert.Equal(t, int64(1), stats.LockStats.DeadlockCount)
+	assert.Equal(t, int64(1), stats.LockStats.LockCount)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecute_Retry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	stats := &TransactionStats{}
+	logger := &MockLogger{}
+	executor := NewTransactionExecutor(db, stats, logger)
+	executor.SetMaxRetries(2)
+
+	transaction := &Transaction{
+		Type:       "test",
+		Statements: []string{"UPDATE test SET value = 1"},
+	}
+
+	// A lock wait timeout and a deadlock are retried
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE test").WillReturnError(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE test").WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE test").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = executor.Execute(context.Background(), transaction)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.SuccessfulTransactions)
+	assert.Equal(t, int64(0), stats.FailedTransactions)
+	assert.Equal(t, int64(2), stats.LockStats.RetryCount)
+	assert.Equal(t, int64(2), stats.LockStats.LockCount)
+	assert.Equal(t, int64(1), stats.LockStats.DeadlockCount)
+	assert.Greater(t, stats.LockStats.TotalLockTime, time.Duration(0))
+	assert.Equal(t, stats.LockStats.TotalLockTime/2, stats.LockStats.AvgLockTime)
+
+	// Other errors are not
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE test").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	err = executor.Execute(context.Background(), transaction)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), stats.FailedTransactions)
+	assert.Equal(t, int64(2), stats.LockStats.RetryCount)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("There were unfulfilled expectations: %s", err)
@@ -232,6 +289,7 @@ func TestUpdateTransactionTime(t *testing.T) {
 	assert.Equal(t, duration1+duration2+duration3, stats.TotalDuration)
 	assert.Equal(t, duration2, stats.MinDuration)
 	assert.Equal(t, duration3, stats.MaxDuration)
+	assert.Equal(t, duration3, stats.P95Duration)
 }
 
 func TestIsDeadlock(t *testing.T) {
@@ -248,4 +306,7 @@ func TestIsDeadlock(t *testing.T) {
 	assert.False(t, executor.IsDeadlock(nil))
 	assert.False(t, executor.IsDeadlock(errors.New("random error")))
 	assert.True(t, executor.IsDeadlock(errors.New("deadlock detected")))
+	assert.True(t, executor.IsDeadlock(&mysql.MySQLError{Number: 1213}))
+	assert.True(t, executor.IsDeadlock(&pq.Error{Code: "40P01"}))
+	assert.False(t, executor.IsDeadlock(&mysql.MySQLError{Number: 1064, Message: "near 'deadlock'"}))
 }
@@ -7,25 +7,33 @@ import (
 	"path/filepath"
 )
 
+// DefaultScenarioDir is the directory user-defined sysbench scenarios are
+// stored in
+const DefaultScenarioDir = "./data/sysbench"
+
 // Config holds the application configuration
 type Config struct {
-	Port              int    `json:"port"`
-	ServerOnly        bool   `json:"server_only"`
-	LogLevel          string `json:"log_level"`
-	StoragePath       string `json:"storage_path"`
-	EncryptionKeyFile string `json:"encryption_key_file"`
-	WebDir            string `json:"web_dir"`
+	Port                 int    `json:"port"`
+	ServerOnly           bool   `json:"server_only"`
+	LogLevel             string `json:"log_level"`
+	StoragePath          string `json:"storage_path"`
+	EncryptionKeyFile    string `json:"encryption_key_file"`
+	WebDir               string `json:"web_dir"`
+	MaxRunsPerConnection int    `json:"max_runs_per_connection"`
+	ScenarioDir          string `json:"scenario_dir"`
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Port:              8080,
-		ServerOnly:        false,
-		LogLevel:          "info",
-		StoragePath:       "./data/benchphant.db",
-		EncryptionKeyFile: "./data/key.txt",
-		WebDir:            "./web/dist",
+		Port:                 8080,
+		ServerOnly:           false,
+		LogLevel:             "info",
+		StoragePath:          "./data/benchphant.db",
+		EncryptionKeyFile:    "./data/key.txt",
+		WebDir:               "./web/dist",
+		MaxRunsPerConnection: 1,
+		ScenarioDir:          DefaultScenarioDir,
 	}
 }
 
@@ -92,6 +100,15 @@ func Load() (*Config, error) {
 	if webDir := os.Getenv("BENCHPHANT_WEB_DIR"); webDir != "" {
 		cfg.WebDir = webDir
 	}
+	if maxRuns := os.Getenv("BENCHPHANT_MAX_RUNS_PER_CONNECTION"); maxRuns != "" {
+		var n int
+		if _, err := fmt.Sscanf(maxRuns, "%d", &n); err == nil {
+			cfg.MaxRunsPerConnection = n
+		}
+	}
+	if scenarioDir := os.Getenv("BENCHPHANT_SCENARIO_DIR"); scenarioDir != "" {
+		cfg.ScenarioDir = scenarioDir
+	}
 
 	return cfg, nil
 }
@@ -147,5 +164,8 @@ func (c *Config) Validate() error {
 	if c.WebDir == "" {
 		return fmt.Errorf("web directory is required")
 	}
+	if c.MaxRunsPerConnection < 0 {
+		return fmt.Errorf("invalid max runs per connection: %d", c.MaxRunsPerConnection)
+	}
 	return nil
 }
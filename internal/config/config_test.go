@@ -66,6 +66,8 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, "./data/benchphant.db", cfg.StoragePath)
 	assert.Equal(t, "./data/key.txt", cfg.EncryptionKeyFile)
 	assert.Equal(t, "./web/dist", cfg.WebDir)
+	assert.Equal(t, 1, cfg.MaxRunsPerConnection)
+	assert.Equal(t, "./data/sysbench", cfg.ScenarioDir)
 }
 
 func TestValidate(t *testing.T) {
@@ -140,6 +142,18 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "NegativeMaxRunsPerConnection",
+			cfg: &Config{
+				Port:                 8080,
+				LogLevel:             "info",
+				StoragePath:          "/tmp/db.sqlite",
+				EncryptionKeyFile:    "/tmp/key.txt",
+				WebDir:               "./web/dist",
+				MaxRunsPerConnection: -1,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
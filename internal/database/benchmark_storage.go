@@ -0,0 +1,403 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// initializeBenchmarks creates the benchmark and result tables
+func (s *SQLiteStorage) initializeBenchmarks() error {
+	queries := []string{`
+	CREATE TABLE IF NOT EXISTS benchmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		type TEXT NOT NULL,
+		connection_id INTEGER NOT NULL,
+		query_template TEXT NOT NULL DEFAULT '',
+		num_threads INTEGER NOT NULL,
+		duration INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		config TEXT,
+		report_interval INTEGER NOT NULL DEFAULT 0,
+		phases TEXT,
+		error_policy TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`, `
+	CREATE TABLE IF NOT EXISTS benchmark_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		benchmark_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		end_time DATETIME NOT NULL,
+		total_queries INTEGER NOT NULL,
+		success_count INTEGER NOT NULL,
+		failure_count INTEGER NOT NULL,
+		average_latency INTEGER NOT NULL,
+		min_latency INTEGER NOT NULL,
+		max_latency INTEGER NOT NULL,
+		p95_latency INTEGER NOT NULL,
+		p99_latency INTEGER NOT NULL,
+		qps REAL NOT NULL,
+		metrics TEXT,
+		intervals TEXT,
+		phases TEXT,
+		sweep TEXT,
+		search TEXT,
+		breakdown TEXT,
+		error TEXT NOT NULL DEFAULT ''
+	)`, `
+	CREATE INDEX IF NOT EXISTS idx_benchmark_results_benchmark_id
+		ON benchmark_results (benchmark_id)`,
+	}
+
+	for _, query := range queries {
+		if _, err := s.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	// Columns added since the tables were first created
+	columns := []struct{ table, column, definition string }{
+		{"benchmarks", "report_interval", "INTEGER NOT NULL DEFAULT 0"},
+		{"benchmark_results", "intervals", "TEXT"},
+		{"benchmarks", "phases", "TEXT"},
+		{"benchmark_results", "phases", "TEXT"},
+		{"benchmark_results", "sweep", "TEXT"},
+		{"benchmark_results", "search", "TEXT"},
+		{"benchmarks", "error_policy", "TEXT"},
+		{"benchmark_results", "breakdown", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := s.addColumn(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// addColumn adds column to table unless the table already has it
+func (s *SQLiteStorage) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// SaveBenchmark implements BenchmarkStorage.SaveBenchmark
+func (s *SQLiteStorage) SaveBenchmark(b *models.Benchmark) error {
+	phases, err := json.Marshal(b.Phases)
+	if err != nil {
+		return fmt.Errorf("failed to encode phases: %w", err)
+	}
+	errorPolicy, err := json.Marshal(b.ErrorPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to encode error policy: %w", err)
+	}
+
+	query := `
+	INSERT INTO benchmarks (
+		name, description, type, connection_id, query_template, num_threads,
+		duration, status, config, report_interval, phases, error_policy,
+		created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query,
+		b.Name, b.Description, b.Type, b.ConnectionID, b.QueryTemplate, b.NumThreads,
+		int64(b.Duration), b.Status, string(b.Config), int64(b.ReportInterval),
+		string(phases), string(errorPolicy), b.CreatedAt, b.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	b.ID = id
+	return nil
+}
+
+// UpdateBenchmark implements BenchmarkStorage.UpdateBenchmark
+func (s *SQLiteStorage) UpdateBenchmark(b *models.Benchmark) error {
+	phases, err := json.Marshal(b.Phases)
+	if err != nil {
+		return fmt.Errorf("failed to encode phases: %w", err)
+	}
+	errorPolicy, err := json.Marshal(b.ErrorPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to encode error policy: %w", err)
+	}
+
+	query := `
+	UPDATE benchmarks SET
+		name = ?, description = ?, type = ?, connection_id = ?, query_template = ?,
+		num_threads = ?, duration = ?, status = ?, config = ?, report_interval = ?,
+		phases = ?, error_policy = ?, updated_at = ?
+	WHERE id = ?`
+
+	result, err := s.db.Exec(query,
+		b.Name, b.Description, b.Type, b.ConnectionID, b.QueryTemplate,
+		b.NumThreads, int64(b.Duration), b.Status, string(b.Config),
+		int64(b.ReportInterval), string(phases), string(errorPolicy), b.UpdatedAt, b.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("benchmark not found: %d", b.ID)
+	}
+
+	return nil
+}
+
+// DeleteBenchmark implements BenchmarkStorage.DeleteBenchmark
+func (s *SQLiteStorage) DeleteBenchmark(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	result, err := tx.Exec("DELETE FROM benchmarks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("benchmark not found: %d", id)
+	}
+
+	if _, err := tx.Exec("DELETE FROM benchmark_results WHERE benchmark_id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// benchmarkColumns is the column list scanned by scanBenchmark
+const benchmarkColumns = `id, name, description, type, connection_id, query_template,
+	num_threads, duration, status, config, report_interval, phases, error_policy,
+	created_at, updated_at`
+
+// scanBenchmark scans a row selected with benchmarkColumns
+func scanBenchmark(row interface{ Scan(...interface{}) error }) (*models.Benchmark, error) {
+	var (
+		b              models.Benchmark
+		duration       int64
+		config         sql.NullString
+		reportInterval int64
+		phases         sql.NullString
+		errorPolicy    sql.NullString
+	)
+	err := row.Scan(&b.ID, &b.Name, &b.Description, &b.Type, &b.ConnectionID,
+		&b.QueryTemplate, &b.NumThreads, &duration, &b.Status, &config,
+		&reportInterval, &phases, &errorPolicy, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Duration = time.Duration(duration)
+	b.ReportInterval = time.Duration(reportInterval)
+	if config.String != "" {
+		b.Config = json.RawMessage(config.String)
+	}
+	if phases.String != "" {
+		if err := json.Unmarshal([]byte(phases.String), &b.Phases); err != nil {
+			return nil, fmt.Errorf("failed to decode phases: %w", err)
+		}
+	}
+	if errorPolicy.String != "" {
+		if err := json.Unmarshal([]byte(errorPolicy.String), &b.ErrorPolicy); err != nil {
+			return nil, fmt.Errorf("failed to decode error policy: %w", err)
+		}
+	}
+	return &b, nil
+}
+
+// GetBenchmark implements BenchmarkStorage.GetBenchmark
+func (s *SQLiteStorage) GetBenchmark(id int64) (*models.Benchmark, error) {
+	row := s.db.QueryRow("SELECT "+benchmarkColumns+" FROM benchmarks WHERE id = ?", id)
+	b, err := scanBenchmark(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("benchmark not found: %d", id)
+	}
+	return b, err
+}
+
+// ListBenchmarks implements BenchmarkStorage.ListBenchmarks
+func (s *SQLiteStorage) ListBenchmarks() ([]*models.Benchmark, error) {
+	rows, err := s.db.Query("SELECT " + benchmarkColumns + " FROM benchmarks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var benchmarks []*models.Benchmark
+	for rows.Next() {
+		b, err := scanBenchmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, b)
+	}
+
+	return benchmarks, rows.Err()
+}
+
+// SaveResult implements BenchmarkStorage.SaveResult
+func (s *SQLiteStorage) SaveResult(r *models.BenchmarkResult) error {
+	metrics, err := json.Marshal(r.Metrics)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	intervals, err := json.Marshal(r.Intervals)
+	if err != nil {
+		return fmt.Errorf("failed to encode intervals: %w", err)
+	}
+	phases, err := json.Marshal(r.Phases)
+	if err != nil {
+		return fmt.Errorf("failed to encode phases: %w", err)
+	}
+	sweep, err := json.Marshal(r.Sweep)
+	if err != nil {
+		return fmt.Errorf("failed to encode sweep: %w", err)
+	}
+	search, err := json.Marshal(r.Search)
+	if err != nil {
+		return fmt.Errorf("failed to encode search: %w", err)
+	}
+	breakdown, err := json.Marshal(r.Breakdown)
+	if err != nil {
+		return fmt.Errorf("failed to encode breakdown: %w", err)
+	}
+
+	query := `
+	INSERT INTO benchmark_results (
+		benchmark_id, status, start_time, end_time, total_queries, success_count,
+		failure_count, average_latency, min_latency, max_latency, p95_latency,
+		p99_latency, qps, metrics, intervals, phases, sweep, search, breakdown, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.Exec(query,
+		r.BenchmarkID, r.Status, r.StartTime, r.EndTime, r.TotalQueries, r.SuccessCount,
+		r.FailureCount, int64(r.AverageLatency), int64(r.MinLatency), int64(r.MaxLatency),
+		int64(r.P95Latency), int64(r.P99Latency), r.QPS, string(metrics),
+		string(intervals), string(phases), string(sweep), string(search),
+		string(breakdown), r.Error)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	r.ID = id
+	return nil
+}
+
+// ListResults implements BenchmarkStorage.ListResults
+func (s *SQLiteStorage) ListResults(benchmarkID int64) ([]*models.BenchmarkResult, error) {
+	rows, err := s.db.Query(`
+		SELECT id, benchmark_id, status, start_time, end_time, total_queries,
+			success_count, failure_count, average_latency, min_latency, max_latency,
+			p95_latency, p99_latency, qps, metrics, intervals, phases, sweep, search,
+			breakdown, error
+		FROM benchmark_results WHERE benchmark_id = ? ORDER BY id`, benchmarkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.BenchmarkResult
+	for rows.Next() {
+		var (
+			r                     models.BenchmarkResult
+			avg, lo, hi, p95, p99 int64
+			metrics, intervals    sql.NullString
+			phases, sweep, search sql.NullString
+			breakdown             sql.NullString
+		)
+		err := rows.Scan(&r.ID, &r.BenchmarkID, &r.Status, &r.StartTime, &r.EndTime,
+			&r.TotalQueries, &r.SuccessCount, &r.FailureCount, &avg, &lo, &hi,
+			&p95, &p99, &r.QPS, &metrics, &intervals, &phases, &sweep, &search, &breakdown, &r.Error)
+		if err != nil {
+			return nil, err
+		}
+
+		r.AverageLatency = time.Duration(avg)
+		r.MinLatency = time.Duration(lo)
+		r.MaxLatency = time.Duration(hi)
+		r.P95Latency = time.Duration(p95)
+		r.P99Latency = time.Duration(p99)
+		if metrics.String != "" {
+			if err := json.Unmarshal([]byte(metrics.String), &r.Metrics); err != nil {
+				return nil, fmt.Errorf("failed to decode metrics: %w", err)
+			}
+		}
+		if intervals.String != "" {
+			if err := json.Unmarshal([]byte(intervals.String), &r.Intervals); err != nil {
+				return nil, fmt.Errorf("failed to decode intervals: %w", err)
+			}
+		}
+		if phases.String != "" {
+			if err := json.Unmarshal([]byte(phases.String), &r.Phases); err != nil {
+				return nil, fmt.Errorf("failed to decode phases: %w", err)
+			}
+		}
+		if sweep.String != "" {
+			if err := json.Unmarshal([]byte(sweep.String), &r.Sweep); err != nil {
+				return nil, fmt.Errorf("failed to decode sweep: %w", err)
+			}
+		}
+		if search.String != "" {
+			if err := json.Unmarshal([]byte(search.String), &r.Search); err != nil {
+				return nil, fmt.Errorf("failed to decode search: %w", err)
+			}
+		}
+		if breakdown.String != "" {
+			if err := json.Unmarshal([]byte(breakdown.String), &r.Breakdown); err != nil {
+				return nil, fmt.Errorf("failed to decode breakdown: %w", err)
+			}
+		}
+		results = append(results, &r)
+	}
+
+	return results, rows.Err()
+}
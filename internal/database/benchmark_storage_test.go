@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkStorage(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test_benchmarks_*.db")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+
+	sqlite, err := NewSQLiteStorage(tmpfile.Name())
+	require.NoError(t, err)
+	defer sqlite.Close()
+
+	storages := map[string]BenchmarkStorage{
+		"SQLite": sqlite,
+		"Memory": NewMemoryStorage(),
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			testBenchmarkStorage(t, storage)
+		})
+	}
+}
+
+func testBenchmarkStorage(t *testing.T, storage BenchmarkStorage) {
+	now := time.Now().UTC().Truncate(time.Second)
+	b := &models.Benchmark{
+		Name:           "oltp",
+		Description:    "sysbench read write",
+		Type:           "sysbench",
+		ConnectionID:   1,
+		NumThreads:     8,
+		Duration:       time.Minute,
+		ReportInterval: 10 * time.Second,
+		Phases:         models.PhaseConfig{RampUp: 10 * time.Second, RampSteps: 4, Warmup: 30 * time.Second},
+		ErrorPolicy:    models.ErrorPolicy{OnError: models.ErrorPolicyMaxCount, MaxErrors: 10, Retries: 3, RetryOn: []string{"deadlock"}},
+		Status:         models.BenchmarkStatusPending,
+		Config:         json.RawMessage(`{"table_size":1000}`),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	t.Run("SaveBenchmark", func(t *testing.T) {
+		require.NoError(t, storage.SaveBenchmark(b))
+		assert.Greater(t, b.ID, int64(0))
+	})
+
+	t.Run("GetBenchmark", func(t *testing.T) {
+		got, err := storage.GetBenchmark(b.ID)
+		require.NoError(t, err)
+		assert.Equal(t, b.Name, got.Name)
+		assert.Equal(t, b.Type, got.Type)
+		assert.Equal(t, b.Duration, got.Duration)
+		assert.Equal(t, b.ReportInterval, got.ReportInterval)
+		assert.Equal(t, b.Phases, got.Phases)
+		assert.Equal(t, b.ErrorPolicy, got.ErrorPolicy)
+		assert.JSONEq(t, string(b.Config), string(got.Config))
+
+		_, err = storage.GetBenchmark(b.ID + 100)
+		assert.Error(t, err)
+	})
+
+	t.Run("UpdateBenchmark", func(t *testing.T) {
+		b.Status = models.BenchmarkStatusRunning
+		require.NoError(t, storage.UpdateBenchmark(b))
+
+		got, err := storage.GetBenchmark(b.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BenchmarkStatusRunning, got.Status)
+
+		assert.Error(t, storage.UpdateBenchmark(&models.Benchmark{ID: b.ID + 100}))
+	})
+
+	t.Run("ListBenchmarks", func(t *testing.T) {
+		benchmarks, err := storage.ListBenchmarks()
+		require.NoError(t, err)
+		require.Len(t, benchmarks, 1)
+		assert.Equal(t, b.ID, benchmarks[0].ID)
+	})
+
+	t.Run("Results", func(t *testing.T) {
+		result := &models.BenchmarkResult{
+			BenchmarkID:    b.ID,
+			Status:         models.BenchmarkStatusCompleted,
+			StartTime:      now,
+			EndTime:        now.Add(time.Minute),
+			TotalQueries:   1000,
+			SuccessCount:   990,
+			FailureCount:   10,
+			AverageLatency: 2 * time.Millisecond,
+			P99Latency:     9 * time.Millisecond,
+			QPS:            16.5,
+			Metrics:        map[string]interface{}{"rows_read": float64(42)},
+			Intervals: []models.IntervalReport{
+				{Elapsed: 30 * time.Second, Duration: 30 * time.Second, Transactions: 500, TPS: 16.7, Errors: 5},
+				{Elapsed: time.Minute, Duration: 30 * time.Second, Transactions: 490, TPS: 16.3, Errors: 5, Reconnects: 1},
+			},
+			Phases: []models.PhaseReport{
+				{Phase: models.PhaseWarmup, Duration: 10 * time.Second, Transactions: 150, TPS: 15},
+				{Phase: models.PhaseMeasure, Start: 10 * time.Second, Duration: time.Minute, Transactions: 1000, TPS: 16.5},
+			},
+			Breakdown: map[string]models.QueryStats{
+				"new_order": {Count: 450, Errors: 5, Latency: metrics.LatencySummary{Count: 450, Avg: 3 * time.Millisecond, P90: 5 * time.Millisecond}},
+				"payment":   {Count: 430, Errors: 2, Rows: 1290, Latency: metrics.LatencySummary{Count: 430, Avg: time.Millisecond}},
+			},
+			Sweep: &models.SweepReport{
+				Parameter: "threads",
+				Points: []models.SweepPoint{
+					{Value: 1, Status: models.BenchmarkStatusCompleted, TPS: 10, LatencyAvg: time.Millisecond},
+					{Value: 2, Status: models.BenchmarkStatusCompleted, TPS: 16.5, LatencyAvg: 2 * time.Millisecond},
+				},
+			},
+			Search: &models.SearchReport{
+				Parameter:  "rate",
+				Objectives: []string{"latency_p99 < 10ms"},
+				Probes: []models.SearchProbe{
+					{SweepPoint: models.SweepPoint{Value: 100, TPS: 99.5}, Duration: time.Minute, Passed: true},
+					{SweepPoint: models.SweepPoint{Value: 200, TPS: 180}, Passed: false, Reasons: []string{"latency_p99 is 12ms, expected < 10ms"}},
+				},
+				Value: 100,
+				TPS:   99.5,
+			},
+		}
+		require.NoError(t, storage.SaveResult(result))
+		assert.Greater(t, result.ID, int64(0))
+
+		results, err := storage.ListResults(b.ID)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, int64(1000), results[0].TotalQueries)
+		assert.Equal(t, 9*time.Millisecond, results[0].P99Latency)
+		assert.Equal(t, float64(42), results[0].Metrics["rows_read"])
+		assert.Equal(t, result.Intervals, results[0].Intervals)
+		assert.Equal(t, result.Phases, results[0].Phases)
+		assert.Equal(t, result.Sweep, results[0].Sweep)
+		assert.Equal(t, result.Search, results[0].Search)
+		assert.Equal(t, result.Breakdown, results[0].Breakdown)
+	})
+
+	t.Run("DeleteBenchmark", func(t *testing.T) {
+		require.NoError(t, storage.DeleteBenchmark(b.ID))
+
+		_, err := storage.GetBenchmark(b.ID)
+		assert.Error(t, err)
+
+		results, err := storage.ListResults(b.ID)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+
+		assert.Error(t, storage.DeleteBenchmark(b.ID))
+	})
+}
+
+func TestBenchmarkStorageMigration(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test_migration_*.db")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+
+	// Tables created before report intervals were stored
+	db, err := sql.Open("sqlite3", tmpfile.Name())
+	require.NoError(t, err)
+	_, err = db.Exec(`
+	CREATE TABLE benchmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		type TEXT NOT NULL,
+		connection_id INTEGER NOT NULL,
+		query_template TEXT NOT NULL DEFAULT '',
+		num_threads INTEGER NOT NULL,
+		duration INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		config TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO benchmarks (name, type, connection_id, num_threads, duration, status, created_at, updated_at)
+		VALUES ('old', 'simple', 1, 4, 1000, 'completed', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	storage, err := NewSQLiteStorage(tmpfile.Name())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	benchmarks, err := storage.ListBenchmarks()
+	require.NoError(t, err)
+	require.Len(t, benchmarks, 1)
+	assert.Equal(t, "old", benchmarks[0].Name)
+	assert.Zero(t, benchmarks[0].ReportInterval)
+	assert.Zero(t, benchmarks[0].Phases)
+	assert.True(t, benchmarks[0].ErrorPolicy.IsZero())
+
+	// Opening a migrated database again leaves it alone
+	require.NoError(t, storage.initializeBenchmarks())
+}
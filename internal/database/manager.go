@@ -255,3 +255,8 @@ func (m *Manager) Close() error {
 	// Close storage
 	return m.storage.Close()
 }
+
+// Benchmarks returns the storage for benchmark definitions and results
+func (m *Manager) Benchmarks() BenchmarkStorage {
+	return m.storage
+}
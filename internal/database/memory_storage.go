@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,16 +11,24 @@ import (
 
 // MemoryStorage implements Storage interface using in-memory storage
 type MemoryStorage struct {
-	connections map[int64]*models.DBConnection
-	nextID      int64
-	mu          sync.RWMutex
+	connections     map[int64]*models.DBConnection
+	nextID          int64
+	benchmarks      map[int64]*models.Benchmark
+	nextBenchmarkID int64
+	results         map[int64][]*models.BenchmarkResult
+	nextResultID    int64
+	mu              sync.RWMutex
 }
 
 // NewMemoryStorage creates a new memory storage
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		connections: make(map[int64]*models.DBConnection),
-		nextID:      1,
+		connections:     make(map[int64]*models.DBConnection),
+		nextID:          1,
+		benchmarks:      make(map[int64]*models.Benchmark),
+		nextBenchmarkID: 1,
+		results:         make(map[int64][]*models.BenchmarkResult),
+		nextResultID:    1,
 	}
 }
 
@@ -117,6 +126,99 @@ func (s *MemoryStorage) ListConnections() ([]*models.DBConnection, error) {
 	return connections, nil
 }
 
+// SaveBenchmark saves a new benchmark. Benchmarks are copied in and out so
+// callers can modify them without holding the storage lock.
+func (s *MemoryStorage) SaveBenchmark(b *models.Benchmark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b.ID = s.nextBenchmarkID
+	s.nextBenchmarkID++
+	stored := *b
+	s.benchmarks[b.ID] = &stored
+	return nil
+}
+
+// UpdateBenchmark updates an existing benchmark
+func (s *MemoryStorage) UpdateBenchmark(b *models.Benchmark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.benchmarks[b.ID]; !exists {
+		return fmt.Errorf("benchmark not found: %d", b.ID)
+	}
+
+	stored := *b
+	s.benchmarks[b.ID] = &stored
+	return nil
+}
+
+// DeleteBenchmark deletes a benchmark and its results
+func (s *MemoryStorage) DeleteBenchmark(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.benchmarks[id]; !exists {
+		return fmt.Errorf("benchmark not found: %d", id)
+	}
+
+	delete(s.benchmarks, id)
+	delete(s.results, id)
+	return nil
+}
+
+// GetBenchmark retrieves a benchmark by ID
+func (s *MemoryStorage) GetBenchmark(id int64) (*models.Benchmark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, exists := s.benchmarks[id]
+	if !exists {
+		return nil, fmt.Errorf("benchmark not found: %d", id)
+	}
+
+	copied := *b
+	return &copied, nil
+}
+
+// ListBenchmarks returns all benchmarks ordered by ID
+func (s *MemoryStorage) ListBenchmarks() ([]*models.Benchmark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	benchmarks := make([]*models.Benchmark, 0, len(s.benchmarks))
+	for _, b := range s.benchmarks {
+		copied := *b
+		benchmarks = append(benchmarks, &copied)
+	}
+	sort.Slice(benchmarks, func(i, j int) bool {
+		return benchmarks[i].ID < benchmarks[j].ID
+	})
+
+	return benchmarks, nil
+}
+
+// SaveResult saves the result of a benchmark run
+func (s *MemoryStorage) SaveResult(result *models.BenchmarkResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result.ID = s.nextResultID
+	s.nextResultID++
+	s.results[result.BenchmarkID] = append(s.results[result.BenchmarkID], result)
+	return nil
+}
+
+// ListResults returns the results of a benchmark, oldest first
+func (s *MemoryStorage) ListResults(benchmarkID int64) ([]*models.BenchmarkResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]*models.BenchmarkResult, len(s.results[benchmarkID]))
+	copy(results, s.results[benchmarkID])
+	return results, nil
+}
+
 // Close implements Storage.Close
 func (s *MemoryStorage) Close() error {
 	return nil
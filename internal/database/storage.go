@@ -25,6 +25,26 @@ type Storage interface {
 	UpdateLastUsed(id int64, lastUsed time.Time) error
 	// Close closes the storage
 	Close() error
+
+	BenchmarkStorage
+}
+
+// BenchmarkStorage defines the interface for benchmark definition and result storage
+type BenchmarkStorage interface {
+	// SaveBenchmark saves a new benchmark
+	SaveBenchmark(b *models.Benchmark) error
+	// UpdateBenchmark updates an existing benchmark
+	UpdateBenchmark(b *models.Benchmark) error
+	// DeleteBenchmark deletes a benchmark and its results
+	DeleteBenchmark(id int64) error
+	// GetBenchmark gets a benchmark by ID
+	GetBenchmark(id int64) (*models.Benchmark, error)
+	// ListBenchmarks lists all benchmarks
+	ListBenchmarks() ([]*models.Benchmark, error)
+	// SaveResult saves the result of a benchmark run
+	SaveResult(result *models.BenchmarkResult) error
+	// ListResults lists the results of a benchmark, oldest first
+	ListResults(benchmarkID int64) ([]*models.BenchmarkResult, error)
 }
 
 // SQLiteStorage implements Storage interface using SQLite
@@ -50,6 +70,14 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 
 // initialize creates necessary tables if they don't exist
 func (s *SQLiteStorage) initialize() error {
+	if err := s.initializeConnections(); err != nil {
+		return err
+	}
+	return s.initializeBenchmarks()
+}
+
+// initializeConnections creates the connections table
+func (s *SQLiteStorage) initializeConnections() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS connections (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
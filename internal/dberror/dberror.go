@@ -0,0 +1,171 @@
+// Package dberror classifies the errors returned by the database drivers
+// into the classes the benchmarks count, retry and report. MySQL errors are
+// told apart by their error number, PostgreSQL errors by their SQLSTATE and
+// errors of other drivers by their message.
+package dberror
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Class is the kind of error a statement or transaction failed with
+type Class string
+
+const (
+	// Deadlock is a transaction chosen as a deadlock victim
+	Deadlock Class = "deadlock"
+	// LockTimeout is a transaction that gave up waiting for a lock
+	LockTimeout Class = "lock_timeout"
+	// DuplicateKey is a write violating a unique key
+	DuplicateKey Class = "duplicate_key"
+	// ConnectionLost is a connection to the database that broke
+	ConnectionLost Class = "connection_lost"
+	// Serialization is a transaction that could not be serialized with
+	// concurrent ones
+	Serialization Class = "serialization_failure"
+	// Canceled is a statement cancelled by a timeout or by the client
+	Canceled Class = "query_canceled"
+	// Other is any other error
+	Other Class = "other"
+)
+
+// Classes lists all classes
+var Classes = []Class{
+	Deadlock,
+	LockTimeout,
+	DuplicateKey,
+	ConnectionLost,
+	Serialization,
+	Canceled,
+	Other,
+}
+
+// mysqlClasses maps the numbers of MySQL server and client errors to their
+// class
+var mysqlClasses = map[uint16]Class{
+	1213: Deadlock,       // ER_LOCK_DEADLOCK
+	1205: LockTimeout,    // ER_LOCK_WAIT_TIMEOUT
+	3572: LockTimeout,    // ER_LOCK_NOWAIT
+	1022: DuplicateKey,   // ER_DUP_KEY
+	1062: DuplicateKey,   // ER_DUP_ENTRY
+	1586: DuplicateKey,   // ER_DUP_ENTRY_WITH_KEY_NAME
+	1053: ConnectionLost, // ER_SERVER_SHUTDOWN
+	1152: ConnectionLost, // ER_ABORTING_CONNECTION
+	1927: ConnectionLost, // ER_CONNECTION_KILLED
+	2002: ConnectionLost, // CR_CONNECTION_ERROR
+	2003: ConnectionLost, // CR_CONN_HOST_ERROR
+	2006: ConnectionLost, // CR_SERVER_GONE_ERROR
+	2013: ConnectionLost, // CR_SERVER_LOST
+	4031: ConnectionLost, // ER_CLIENT_INTERACTION_TIMEOUT
+	3101: Serialization,  // ER_TRANSACTION_ROLLBACK_DURING_COMMIT
+	1317: Canceled,       // ER_QUERY_INTERRUPTED
+	3024: Canceled,       // ER_QUERY_TIMEOUT
+}
+
+// sqlStateClasses maps SQLSTATE codes to their class. Codes of class 08,
+// connection exception, are all ConnectionLost.
+var sqlStateClasses = map[string]Class{
+	"40001": Serialization,  // serialization_failure
+	"40P01": Deadlock,       // deadlock_detected
+	"55P03": LockTimeout,    // lock_not_available
+	"23505": DuplicateKey,   // unique_violation
+	"57014": Canceled,       // query_canceled
+	"57P01": ConnectionLost, // admin_shutdown
+	"57P02": ConnectionLost, // crash_shutdown
+	"57P03": ConnectionLost, // cannot_connect_now
+	"25P03": ConnectionLost, // idle_in_transaction_session_timeout
+}
+
+// Classify returns the class of err, or "" if err is nil
+func Classify(err error) Class {
+	if err == nil {
+		return ""
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		if class, ok := mysqlClasses[mysqlErr.Number]; ok {
+			return class
+		}
+		return Other
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return classifySQLState(string(pqErr.Code))
+	}
+	// Other drivers reporting a SQLSTATE, such as pgx
+	var coded interface{ SQLState() string }
+	if errors.As(err, &coded) {
+		if class := classifySQLState(coded.SQLState()); class != Other {
+			return class
+		}
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return ConnectionLost
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Canceled
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ConnectionLost
+	}
+
+	return classifyMessage(err.Error())
+}
+
+// classifySQLState returns the class of a SQLSTATE code
+func classifySQLState(state string) Class {
+	if class, ok := sqlStateClasses[state]; ok {
+		return class
+	}
+	if strings.HasPrefix(state, "08") {
+		return ConnectionLost
+	}
+	return Other
+}
+
+// classifyMessage returns the class of an error by its message, for drivers
+// such as SQLite that report neither error numbers nor SQLSTATEs
+func classifyMessage(msg string) Class {
+	msg = strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "deadlock"):
+		return Deadlock
+	case strings.Contains(msg, "lock wait timeout"), strings.Contains(msg, "lock timeout"),
+		strings.Contains(msg, "database is locked"), strings.Contains(msg, "database table is locked"):
+		return LockTimeout
+	case strings.Contains(msg, "duplicate"), strings.Contains(msg, "unique constraint"):
+		return DuplicateKey
+	case strings.Contains(msg, "could not serialize"), strings.Contains(msg, "serialization failure"):
+		return Serialization
+	}
+	return Other
+}
+
+// Parse parses the name of a class
+func Parse(name string) (Class, error) {
+	for _, class := range Classes {
+		if string(class) == name {
+			return class, nil
+		}
+	}
+	return "", fmt.Errorf("unknown error class: %s", name)
+}
+
+// IsConflict reports whether class is an error of a transaction that lost a
+// conflict with concurrent ones: a deadlock, a lock timeout or a
+// serialization failure. Such a transaction may succeed when run again.
+func IsConflict(class Class) bool {
+	return class == Deadlock || class == LockTimeout || class == Serialization
+}
@@ -0,0 +1,64 @@
+package dberror
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlStateError is an error of a driver reporting a SQLSTATE
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestClassify(t *testing.T) {
+	for err, class := range map[error]Class{
+		&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}:         Deadlock,
+		&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout"}:      LockTimeout,
+		fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062}):          DuplicateKey,
+		&mysql.MySQLError{Number: 2006, Message: "MySQL server gone away"}: ConnectionLost,
+		&mysql.MySQLError{Number: 3024}:                                    Canceled,
+		&mysql.MySQLError{Number: 1064, Message: "deadlock in the name"}:   Other,
+		mysql.ErrInvalidConn:                               ConnectionLost,
+		&pq.Error{Code: "40P01"}:                           Deadlock,
+		&pq.Error{Code: "40001"}:                           Serialization,
+		&pq.Error{Code: "55P03"}:                           LockTimeout,
+		fmt.Errorf("insert: %w", &pq.Error{Code: "23505"}): DuplicateKey,
+		&pq.Error{Code: "57014"}:                           Canceled,
+		&pq.Error{Code: "08006"}:                           ConnectionLost,
+		&pq.Error{Code: "42601", Message: "deadlock"}:      Other,
+		sqlStateError("40P01"):                             Deadlock,
+		fmt.Errorf("exec: %w", driver.ErrBadConn):          ConnectionLost,
+		context.DeadlineExceeded:                           Canceled,
+		errors.New("Error 1213 (40001): Deadlock found"):   Deadlock,
+		errors.New("database is locked"):                   LockTimeout,
+		errors.New("UNIQUE constraint failed: t.id"):       DuplicateKey,
+		errors.New("syntax error"):                         Other,
+	} {
+		assert.Equal(t, class, Classify(err), err.Error())
+	}
+	assert.Equal(t, Class(""), Classify(nil))
+}
+
+func TestParse(t *testing.T) {
+	for _, class := range Classes {
+		parsed, err := Parse(string(class))
+		require.NoError(t, err)
+		assert.Equal(t, class, parsed)
+	}
+	_, err := Parse("timeout")
+	assert.Error(t, err)
+
+	assert.True(t, IsConflict(Deadlock))
+	assert.True(t, IsConflict(Serialization))
+	assert.False(t, IsConflict(DuplicateKey))
+	assert.False(t, IsConflict(ConnectionLost))
+}
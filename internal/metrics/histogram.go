@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+const (
+	// subBucketBits sets the precision of the histogram. Latencies below
+	// 2^subBucketBits nanoseconds are counted exactly, every power of two
+	// above that is split into 2^(subBucketBits-1) buckets, so a bucket is at
+	// most 1/64 of the latencies it holds wide.
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+
+	// HistogramMax is the largest latency a Histogram tells apart. Longer
+	// latencies are counted in the last bucket but still reported as Max.
+	HistogramMax = time.Hour
+)
+
+// histogramBuckets is the number of buckets needed to count up to HistogramMax
+var histogramBuckets = bucketIndex(int64(HistogramMax)) + 1
+
+// Histogram records latencies in fixed memory, in the style of HdrHistogram.
+// Percentiles are within 1% of the recorded latencies however many are
+// recorded, and histograms of workers, intervals or phases can be merged.
+// The zero value is an empty histogram. It is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts []int64
+	count  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// LatencySummary holds the statistics reported for a histogram
+type LatencySummary struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Avg   time.Duration `json:"avg"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds a latency to the histogram. Negative latencies count as zero.
+func (h *Histogram) Record(latency time.Duration) {
+	v := int64(latency)
+	if v < 0 {
+		v = 0
+	}
+	idx := bucketIndex(v)
+	if idx >= histogramBuckets {
+		idx = histogramBuckets - 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts == nil {
+		h.counts = make([]int64, histogramBuckets)
+	}
+	h.counts[idx]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += v
+}
+
+// Merge adds all latencies recorded in other to h
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other == h {
+		return
+	}
+
+	other.mu.Lock()
+	counts := append([]int64(nil), other.counts...)
+	count, sum, min, max := other.count, other.sum, other.min, other.max
+	other.mu.Unlock()
+
+	if count == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts == nil {
+		h.counts = make([]int64, histogramBuckets)
+	}
+	for i, c := range counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || min < h.min {
+		h.min = min
+	}
+	if max > h.max {
+		h.max = max
+	}
+	h.count += count
+	h.sum += sum
+}
+
+// Reset removes all recorded latencies
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts = nil
+	h.count, h.sum, h.min, h.max = 0, 0, 0, 0
+}
+
+// Count returns the number of recorded latencies
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Mean returns the average recorded latency
+func (h *Histogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mean()
+}
+
+// Min returns the smallest recorded latency
+func (h *Histogram) Min() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.min)
+}
+
+// Max returns the largest recorded latency
+func (h *Histogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.max)
+}
+
+// Percentile returns the latency below which p percent of the recorded
+// latencies fall, for p between 0 and 100
+func (h *Histogram) Percentile(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.percentile(p)
+}
+
+// Summary returns the count, average, extremes and the p50, p90, p95, p99
+// and p99.9 latencies
+func (h *Histogram) Summary() LatencySummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return LatencySummary{
+		Count: h.count,
+		Min:   time.Duration(h.min),
+		Avg:   h.mean(),
+		P50:   h.percentile(50),
+		P90:   h.percentile(90),
+		P95:   h.percentile(95),
+		P99:   h.percentile(99),
+		P999:  h.percentile(99.9),
+		Max:   time.Duration(h.max),
+	}
+}
+
+// mean returns the average latency. The caller must hold h.mu.
+func (h *Histogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / h.count)
+}
+
+// percentile returns the p-th percentile. The caller must hold h.mu.
+func (h *Histogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	if p >= 100 {
+		return time.Duration(h.max)
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			// The bucket value is an estimate, the extremes are exact
+			v := bucketValue(i)
+			if v < h.min {
+				v = h.min
+			}
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// Metrics returns the summary under the latency_* keys of a runner's status
+// metrics
+func (s LatencySummary) Metrics() map[string]interface{} {
+	return map[string]interface{}{
+		"latency_min":  s.Min,
+		"latency_avg":  s.Avg,
+		"latency_p50":  s.P50,
+		"latency_p90":  s.P90,
+		"latency_p95":  s.P95,
+		"latency_p99":  s.P99,
+		"latency_p999": s.P999,
+		"latency_max":  s.Max,
+	}
+}
+
+// bucketIndex returns the bucket counting the latency v in nanoseconds
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	return subBucketCount + (shift-1)*subBucketHalf + int(v>>shift) - subBucketHalf
+}
+
+// bucketValue returns the latency in the middle of bucket idx
+func bucketValue(idx int) int64 {
+	if idx < subBucketCount {
+		return int64(idx)
+	}
+	shift := (idx-subBucketCount)/subBucketHalf + 1
+	sub := int64((idx-subBucketCount)%subBucketHalf + subBucketHalf)
+	return sub<<shift + 1<<(shift-1)
+}
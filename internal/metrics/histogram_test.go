@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// within reports whether got is within 1% of want
+func within(got, want time.Duration) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= want/100
+}
+
+func TestHistogram_Percentiles(t *testing.T) {
+	h := NewHistogram()
+	rng := rand.New(rand.NewSource(1))
+	latencies := make([]time.Duration, 100000)
+	for i := range latencies {
+		// Log-normal-ish spread from microseconds to seconds
+		latencies[i] = time.Duration(rng.ExpFloat64() * float64(time.Millisecond) * float64(1+rng.Intn(100)))
+		h.Record(latencies[i])
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	for _, p := range []float64{50, 90, 95, 99, 99.9} {
+		want := latencies[int(p/100*float64(len(latencies)))-1]
+		if got := h.Percentile(p); !within(got, want) {
+			t.Errorf("p%v: expected %v, got %v", p, want, got)
+		}
+	}
+
+	s := h.Summary()
+	if s.Count != int64(len(latencies)) {
+		t.Errorf("Expected count %d, got %d", len(latencies), s.Count)
+	}
+	if s.Min != latencies[0] || s.Max != latencies[len(latencies)-1] {
+		t.Errorf("Expected min %v and max %v, got %v and %v", latencies[0], latencies[len(latencies)-1], s.Min, s.Max)
+	}
+	if h.Percentile(100) != s.Max {
+		t.Errorf("Expected p100 to be the max, got %v", h.Percentile(100))
+	}
+}
+
+func TestHistogram_Exact(t *testing.T) {
+	var h Histogram
+	if h.Percentile(99) != 0 || h.Mean() != 0 {
+		t.Error("Expected an empty histogram to report zero")
+	}
+
+	for _, v := range []time.Duration{10, 20, 30, 40} {
+		h.Record(v)
+	}
+	if h.Mean() != 25 {
+		t.Errorf("Expected mean 25ns, got %v", h.Mean())
+	}
+	if h.Percentile(50) != 20 || h.Percentile(75) != 30 {
+		t.Errorf("Expected exact small latencies, got p50 %v and p75 %v", h.Percentile(50), h.Percentile(75))
+	}
+
+	// Latencies beyond HistogramMax are kept as the max
+	h.Record(2 * HistogramMax)
+	if h.Max() != 2*HistogramMax || h.Percentile(100) != 2*HistogramMax {
+		t.Errorf("Expected max %v, got %v", 2*HistogramMax, h.Max())
+	}
+
+	h.Reset()
+	if h.Count() != 0 || h.Max() != 0 {
+		t.Error("Expected Reset to empty the histogram")
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		v := time.Duration(i) * time.Microsecond
+		if i%2 == 0 {
+			a.Record(v)
+		} else {
+			b.Record(v)
+		}
+		all.Record(v)
+	}
+
+	merged := NewHistogram()
+	merged.Merge(a)
+	merged.Merge(b)
+	merged.Merge(nil)
+	if merged.Summary() != all.Summary() {
+		t.Errorf("Expected merged summary %+v, got %+v", all.Summary(), merged.Summary())
+	}
+	if !within(merged.Percentile(99), 990*time.Microsecond) {
+		t.Errorf("Expected p99 near 990µs, got %v", merged.Percentile(99))
+	}
+}
+
+func TestHistogram_Concurrent(t *testing.T) {
+	h := NewHistogram()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.Record(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.Count() != 8000 {
+		t.Errorf("Expected 8000 latencies, got %d", h.Count())
+	}
+}
+
+func TestBucketIndex(t *testing.T) {
+	// Buckets grow with the latency and their middle maps back to them
+	prev := 0
+	for v := int64(0); v < 1<<24; v += 13 {
+		idx := bucketIndex(v)
+		if idx < prev {
+			t.Fatalf("Bucket %d for %d is below bucket %d", idx, v, prev)
+		}
+		if got := bucketIndex(bucketValue(idx)); got != idx {
+			t.Fatalf("Bucket %d value %d maps to bucket %d", idx, bucketValue(idx), got)
+		}
+		prev = idx
+	}
+}
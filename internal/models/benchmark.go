@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/deadjoe/benchphant/internal/metrics"
 )
 
 // BenchmarkStatus represents the status of a benchmark
@@ -22,35 +24,240 @@ const (
 	BenchmarkStatusCancelled BenchmarkStatus = "cancelled"
 )
 
+// IsFinished reports whether the status is a final state
+func (s BenchmarkStatus) IsFinished() bool {
+	switch s {
+	case BenchmarkStatusCompleted, BenchmarkStatusFailed, BenchmarkStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// BenchmarkTypeQuery is the workload that runs a benchmark's QueryTemplate
+const BenchmarkTypeQuery = "query"
+
 // Benchmark represents a database benchmark configuration
 type Benchmark struct {
-	ID            int64           `json:"id"`
-	Name          string          `json:"name"`
-	Description   string          `json:"description"`
-	ConnectionID  int64           `json:"connection_id"`
-	QueryTemplate string          `json:"query_template"`
-	NumThreads    int             `json:"num_threads"`
-	Duration      time.Duration   `json:"duration"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
-	Status        BenchmarkStatus `json:"status"`
-	Config        json.RawMessage `json:"config"`
+	ID             int64           `json:"id"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Type           string          `json:"type"`
+	ConnectionID   int64           `json:"connection_id"`
+	QueryTemplate  string          `json:"query_template"`
+	NumThreads     int             `json:"num_threads"`
+	Duration       time.Duration   `json:"duration"`
+	ReportInterval time.Duration   `json:"report_interval,omitempty"`
+	Phases         PhaseConfig     `json:"phases"`
+	ErrorPolicy    ErrorPolicy     `json:"error_policy"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Status         BenchmarkStatus `json:"status"`
+	Config         json.RawMessage `json:"config"`
 }
 
 // BenchmarkResult represents the result of a benchmark run
 type BenchmarkResult struct {
-	ID             int64         `json:"id"`
-	BenchmarkID    int64         `json:"benchmark_id"`
-	StartTime      time.Time     `json:"start_time"`
-	EndTime        time.Time     `json:"end_time"`
-	TotalQueries   int64         `json:"total_queries"`
-	SuccessCount   int64         `json:"success_count"`
-	FailureCount   int64         `json:"failure_count"`
-	AverageLatency time.Duration `json:"average_latency"`
-	MinLatency     time.Duration `json:"min_latency"`
-	MaxLatency     time.Duration `json:"max_latency"`
-	QPS            float64       `json:"qps"`
-	Error          string        `json:"error,omitempty"`
+	ID             int64                  `json:"id"`
+	BenchmarkID    int64                  `json:"benchmark_id"`
+	StartTime      time.Time              `json:"start_time"`
+	EndTime        time.Time              `json:"end_time"`
+	TotalQueries   int64                  `json:"total_queries"`
+	SuccessCount   int64                  `json:"success_count"`
+	FailureCount   int64                  `json:"failure_count"`
+	AverageLatency time.Duration          `json:"average_latency"`
+	MinLatency     time.Duration          `json:"min_latency"`
+	MaxLatency     time.Duration          `json:"max_latency"`
+	P95Latency     time.Duration          `json:"p95_latency"`
+	P99Latency     time.Duration          `json:"p99_latency"`
+	QPS            float64                `json:"qps"`
+	Status         BenchmarkStatus        `json:"status"`
+	Metrics        map[string]interface{} `json:"metrics,omitempty"`
+	Intervals      []IntervalReport       `json:"intervals,omitempty"`
+	Phases         []PhaseReport          `json:"phases,omitempty"`
+	Breakdown      map[string]QueryStats  `json:"breakdown,omitempty"`
+	Sweep          *SweepReport           `json:"sweep,omitempty"`
+	Search         *SearchReport          `json:"search,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+// QueryStats holds the statistics of one query or one type of transaction of
+// a run: how often it succeeded and failed, the rows it returned or affected
+// and the latency of its successful executions
+type QueryStats struct {
+	Count   int64                  `json:"count"`
+	Errors  int64                  `json:"errors"`
+	Rows    int64                  `json:"rows"`
+	Latency metrics.LatencySummary `json:"latency"`
+}
+
+// IntervalReport holds the statistics of one report interval of a run, like
+// a line of sysbench's --report-interval output. Elapsed is the time from the
+// start of the run to the end of the interval and Duration the length of the
+// interval, which is shorter for the last one and the last of each phase. The
+// latency percentiles cover the interval only.
+type IntervalReport struct {
+	Elapsed      time.Duration          `json:"elapsed"`
+	Duration     time.Duration          `json:"duration"`
+	Transactions int64                  `json:"transactions"`
+	Queries      int64                  `json:"queries"`
+	TPS          float64                `json:"tps"`
+	QPS          float64                `json:"qps"`
+	Errors       int64                  `json:"errors"`
+	Reconnects   int64                  `json:"reconnects"`
+	Latency      metrics.LatencySummary `json:"latency"`
+	Phase        string                 `json:"phase,omitempty"`
+}
+
+// Phases of a run, in the order they run. Only the measurement counts in
+// the result of a run.
+const (
+	// PhaseRampUp starts the threads of the run one after another
+	PhaseRampUp = "ramp_up"
+	// PhaseWarmup runs at full concurrency to warm up caches and buffer pools
+	PhaseWarmup = "warmup"
+	// PhaseMeasure is the measured part of the run
+	PhaseMeasure = "measure"
+	// PhaseCoolDown keeps the load running after the measurement, so its end
+	// is not skewed by threads finishing early
+	PhaseCoolDown = "cool_down"
+)
+
+// PhaseConfig sets the phases run around the measurement of a benchmark.
+// The threads are started over RampUp, evenly or in RampSteps groups, then
+// the run warms up at full concurrency for Warmup before the measurement
+// starts. CoolDown keeps the load running after it. Zero values skip a phase.
+type PhaseConfig struct {
+	RampUp    time.Duration `json:"ramp_up,omitempty"`
+	RampSteps int           `json:"ramp_steps,omitempty"`
+	Warmup    time.Duration `json:"warmup,omitempty"`
+	CoolDown  time.Duration `json:"cool_down,omitempty"`
+}
+
+// Validate validates the phase configuration
+func (c PhaseConfig) Validate() error {
+	if c.RampUp < 0 || c.Warmup < 0 || c.CoolDown < 0 {
+		return errors.New("phase durations must not be negative")
+	}
+	if c.RampSteps < 0 {
+		return errors.New("ramp steps must not be negative")
+	}
+	return nil
+}
+
+// Actions of an ErrorPolicy when a transaction fails
+const (
+	// ErrorPolicyAbort fails the run on the first error
+	ErrorPolicyAbort = "abort"
+	// ErrorPolicyContinue counts errors and keeps running
+	ErrorPolicyContinue = "continue"
+	// ErrorPolicyMaxCount fails the run once MaxErrors transactions failed
+	ErrorPolicyMaxCount = "max_count"
+	// ErrorPolicyMaxRate fails the run once the share of failed transactions
+	// exceeds MaxErrorRate
+	ErrorPolicyMaxRate = "max_rate"
+)
+
+// ErrorPolicy sets how a run reacts to failed transactions. Errors of the
+// classes in RetryOn are retried up to Retries times, waiting RetryBackoff
+// and twice as long after each further attempt, before they count as
+// failures. An empty OnError keeps the default of the workload: the query
+// workload aborts, sysbench and TPC-C continue.
+type ErrorPolicy struct {
+	OnError      string        `json:"on_error,omitempty" description:"What a failed transaction does to the run" enum:"abort,continue,max_count,max_rate"`
+	MaxErrors    int64         `json:"max_errors,omitempty" description:"Failed transactions that end the run with max_count"`
+	MaxErrorRate float64       `json:"max_error_rate,omitempty" description:"Share of failed transactions that ends the run with max_rate, between 0 and 1"`
+	Retries      int           `json:"retries,omitempty" description:"Retries of a transaction failing with a retryable error"`
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty" description:"Wait before the first retry, doubled for each further one"`
+	RetryOn      []string      `json:"retry_on,omitempty" description:"Error classes retried, by default deadlock, lock_timeout, serialization_failure and connection_lost"`
+}
+
+// IsZero reports whether the policy is unset
+func (p ErrorPolicy) IsZero() bool {
+	return p.OnError == "" && p.MaxErrors == 0 && p.MaxErrorRate == 0 &&
+		p.Retries == 0 && p.RetryBackoff == 0 && len(p.RetryOn) == 0
+}
+
+// Validate validates the error policy
+func (p ErrorPolicy) Validate() error {
+	switch p.OnError {
+	case "", ErrorPolicyAbort, ErrorPolicyContinue:
+	case ErrorPolicyMaxCount:
+		if p.MaxErrors <= 0 {
+			return errors.New("max_count error policy needs max errors greater than 0")
+		}
+	case ErrorPolicyMaxRate:
+		if p.MaxErrorRate <= 0 || p.MaxErrorRate > 1 {
+			return errors.New("max_rate error policy needs a max error rate between 0 and 1")
+		}
+	default:
+		return errors.New("unknown error policy: " + p.OnError)
+	}
+	if p.MaxErrors < 0 || p.MaxErrorRate < 0 {
+		return errors.New("error limits must not be negative")
+	}
+	if p.Retries < 0 || p.RetryBackoff < 0 {
+		return errors.New("retries and retry backoff must not be negative")
+	}
+	return nil
+}
+
+// PhaseReport holds the statistics of one phase of a run. Start is the time
+// from the start of the run to the start of the phase and Duration the time
+// the phase ran for, which is shorter than configured for a stopped run.
+type PhaseReport struct {
+	Phase        string                 `json:"phase"`
+	Start        time.Duration          `json:"start"`
+	Duration     time.Duration          `json:"duration"`
+	Transactions int64                  `json:"transactions"`
+	Errors       int64                  `json:"errors"`
+	TPS          float64                `json:"tps"`
+	Latency      metrics.LatencySummary `json:"latency"`
+}
+
+// SweepPoint holds the throughput and latency measured at one step of a
+// sweep, where the swept parameter was set to Value
+type SweepPoint struct {
+	Value      int             `json:"value"`
+	Status     BenchmarkStatus `json:"status"`
+	TPS        float64         `json:"tps"`
+	LatencyAvg time.Duration   `json:"latency_avg"`
+	LatencyP95 time.Duration   `json:"latency_p95"`
+	LatencyP99 time.Duration   `json:"latency_p99"`
+	Errors     int64           `json:"errors"`
+}
+
+// SweepReport is the throughput-vs-latency curve of a sweep over the threads
+// or rate of a benchmark. Knee is the index of the point where the workload
+// saturates, or -1 if none of the points completed.
+type SweepReport struct {
+	Parameter string       `json:"parameter"`
+	Points    []SweepPoint `json:"points"`
+	Knee      int          `json:"knee"`
+}
+
+// SearchProbe is one run of a capacity search and whether it met the
+// service level objectives. The confirmation run repeats the answer of the
+// search for the full benchmark duration.
+type SearchProbe struct {
+	SweepPoint
+	Duration     time.Duration `json:"duration"`
+	Passed       bool          `json:"passed"`
+	Reasons      []string      `json:"reasons,omitempty"`
+	Confirmation bool          `json:"confirmation,omitempty"`
+}
+
+// SearchReport lists the probes of a search for the highest threads or rate
+// meeting the objectives. Value is that highest setting, or 0 if even the
+// lowest probed failed, and TPS the throughput it sustained. Confirmed is
+// set when the confirmation run passed too.
+type SearchReport struct {
+	Parameter  string        `json:"parameter"`
+	Objectives []string      `json:"objectives"`
+	Probes     []SearchProbe `json:"probes"`
+	Value      int           `json:"value"`
+	TPS        float64       `json:"tps"`
+	Confirmed  bool          `json:"confirmed"`
 }
 
 // BenchmarkConfig represents the configuration for starting a benchmark
@@ -91,7 +298,10 @@ func (b *Benchmark) Validate() error {
 	if b.ConnectionID <= 0 {
 		return errors.New("invalid connection ID")
 	}
-	if b.QueryTemplate == "" {
+	// Only the plain query workload runs QueryTemplate, or the queries of
+	// its Config, registered workloads such as sysbench and tpcc bring
+	// their own statements
+	if (b.Type == "" || b.Type == BenchmarkTypeQuery) && b.QueryTemplate == "" && len(b.Config) == 0 {
 		return errors.New("query template is required")
 	}
 	if b.NumThreads <= 0 {
@@ -100,6 +310,12 @@ func (b *Benchmark) Validate() error {
 	if b.Duration <= 0 {
 		return errors.New("duration must be greater than 0")
 	}
+	if b.ReportInterval < 0 {
+		return errors.New("report interval must not be negative")
+	}
+	if err := b.Phases.Validate(); err != nil {
+		return err
+	}
 
 	switch b.Status {
 	case BenchmarkStatusPending, BenchmarkStatusRunning, BenchmarkStatusCompleted,
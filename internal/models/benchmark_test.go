@@ -74,6 +74,30 @@ func TestBenchmark(t *testing.T) {
 				},
 				wantErr: true,
 			},
+			{
+				name: "WorkloadWithoutQueryTemplate",
+				bench: &Benchmark{
+					Name:         "test_benchmark",
+					Type:         "sysbench",
+					ConnectionID: 1,
+					NumThreads:   10,
+					Duration:     time.Minute * 5,
+					Status:       BenchmarkStatusPending,
+				},
+				wantErr: false,
+			},
+			{
+				name: "QueryTypeWithoutQueryTemplate",
+				bench: &Benchmark{
+					Name:         "test_benchmark",
+					Type:         BenchmarkTypeQuery,
+					ConnectionID: 1,
+					NumThreads:   10,
+					Duration:     time.Minute * 5,
+					Status:       BenchmarkStatusPending,
+				},
+				wantErr: true,
+			},
 			{
 				name: "InvalidNumThreads",
 				bench: &Benchmark{
@@ -139,6 +163,12 @@ func TestBenchmark(t *testing.T) {
 		assert.Equal(t, "completed", string(BenchmarkStatusCompleted))
 		assert.Equal(t, "failed", string(BenchmarkStatusFailed))
 		assert.Equal(t, "cancelled", string(BenchmarkStatusCancelled))
+
+		assert.False(t, BenchmarkStatusPending.IsFinished())
+		assert.False(t, BenchmarkStatusRunning.IsFinished())
+		assert.True(t, BenchmarkStatusCompleted.IsFinished())
+		assert.True(t, BenchmarkStatusFailed.IsFinished())
+		assert.True(t, BenchmarkStatusCancelled.IsFinished())
 	})
 
 	t.Run("BenchmarkResult", func(t *testing.T) {
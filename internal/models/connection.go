@@ -20,6 +20,8 @@ const (
 	MySQL DBType = "mysql"
 	// PostgreSQL database type
 	PostgreSQL DBType = "postgresql"
+	// SQLite database type
+	SQLite DBType = "sqlite"
 )
 
 // Common errors
@@ -0,0 +1,209 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+// Operator compares a metric against a threshold
+type Operator string
+
+const (
+	// OpGreaterEqual passes when the metric is >= the threshold
+	OpGreaterEqual Operator = ">="
+	// OpLessEqual passes when the metric is <= the threshold
+	OpLessEqual Operator = "<="
+	// OpGreater passes when the metric is > the threshold
+	OpGreater Operator = ">"
+	// OpLess passes when the metric is < the threshold
+	OpLess Operator = "<"
+	// OpEqual passes when the metric equals the threshold
+	OpEqual Operator = "=="
+	// OpNotEqual passes when the metric differs from the threshold
+	OpNotEqual Operator = "!="
+)
+
+// operators is ordered so that two character operators match first
+var operators = []Operator{OpGreaterEqual, OpLessEqual, OpEqual, OpNotEqual, OpGreater, OpLess}
+
+// Assertion is a threshold on a result metric, e.g. "tps >= 500" or
+// "latency_p99 <= 20ms"
+type Assertion struct {
+	Expr     string   `json:"expr"`
+	Metric   string   `json:"metric"`
+	Operator Operator `json:"operator"`
+	// Threshold is the raw right hand side, a number or a Go duration
+	Threshold string `json:"threshold"`
+}
+
+// AssertionResult is the outcome of evaluating an Assertion against a Result
+type AssertionResult struct {
+	Assertion
+	Actual  string `json:"actual"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// ParseAssertion parses an assertion of the form "<metric> <op> <threshold>"
+func ParseAssertion(expr string) (*Assertion, error) {
+	for _, op := range operators {
+		idx := strings.Index(expr, string(op))
+		if idx < 0 {
+			continue
+		}
+
+		metric := strings.TrimSpace(expr[:idx])
+		threshold := strings.TrimSpace(expr[idx+len(op):])
+		if metric == "" || threshold == "" {
+			return nil, fmt.Errorf("invalid assertion %q: expected <metric> <op> <threshold>", expr)
+		}
+		if _, _, err := parseThreshold(threshold); err != nil {
+			return nil, fmt.Errorf("invalid assertion %q: %w", expr, err)
+		}
+
+		return &Assertion{
+			Expr:      strings.TrimSpace(expr),
+			Metric:    metric,
+			Operator:  op,
+			Threshold: threshold,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid assertion %q: missing operator", expr)
+}
+
+// Evaluate checks the assertion against result
+func (a *Assertion) Evaluate(result *benchmark.Result) AssertionResult {
+	res := AssertionResult{Assertion: *a}
+
+	actual, isDuration, ok := metricValue(result, a.Metric)
+	if !ok {
+		res.Message = fmt.Sprintf("unknown metric %q", a.Metric)
+		return res
+	}
+	if isDuration {
+		res.Actual = time.Duration(actual).String()
+	} else {
+		res.Actual = strconv.FormatFloat(actual, 'f', -1, 64)
+	}
+
+	threshold, thresholdIsDuration, err := parseThreshold(a.Threshold)
+	if err != nil {
+		res.Message = err.Error()
+		return res
+	}
+	if isDuration != thresholdIsDuration {
+		if isDuration {
+			res.Message = fmt.Sprintf("metric %q is a duration, threshold needs a unit such as 20ms", a.Metric)
+		} else {
+			res.Message = fmt.Sprintf("metric %q is not a duration", a.Metric)
+		}
+		return res
+	}
+
+	res.Passed = compare(actual, a.Operator, threshold)
+	if !res.Passed {
+		res.Message = fmt.Sprintf("%s is %s, expected %s %s", a.Metric, res.Actual, a.Operator, a.Threshold)
+	}
+	return res
+}
+
+// Check evaluates the assertion against result, so assertions can serve as
+// the objectives of a benchmark.Search
+func (a *Assertion) Check(result *benchmark.Result) (bool, string) {
+	res := a.Evaluate(result)
+	return res.Passed, res.Message
+}
+
+// String returns the assertion as written
+func (a *Assertion) String() string {
+	return a.Expr
+}
+
+// parseThreshold parses a threshold, returning durations in nanoseconds
+func parseThreshold(s string) (float64, bool, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, false, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return float64(d), true, nil
+	}
+	return 0, false, fmt.Errorf("threshold %q is neither a number nor a duration", s)
+}
+
+// metricValue looks up a metric by name, returning durations in nanoseconds
+func metricValue(result *benchmark.Result, name string) (float64, bool, bool) {
+	switch name {
+	case "tps":
+		return result.TPS, false, true
+	case "total_transactions":
+		return float64(result.TotalTransactions), false, true
+	case "errors":
+		return float64(result.Errors), false, true
+	case "error_rate":
+		if result.TotalTransactions == 0 {
+			return 0, false, true
+		}
+		return float64(result.Errors) / float64(result.TotalTransactions), false, true
+	case "duration":
+		return float64(result.Duration), true, true
+	case "latency_min":
+		return float64(result.LatencyMin), true, true
+	case "latency_avg":
+		return float64(result.LatencyAvg), true, true
+	case "latency_p50":
+		return float64(result.LatencyP50), true, true
+	case "latency_p90":
+		return float64(result.LatencyP90), true, true
+	case "latency_p95":
+		return float64(result.LatencyP95), true, true
+	case "latency_p99":
+		return float64(result.LatencyP99), true, true
+	case "latency_p999":
+		return float64(result.LatencyP999), true, true
+	case "latency_max":
+		return float64(result.LatencyMax), true, true
+	}
+
+	v, ok := result.Metrics[name]
+	if !ok {
+		return 0, false, false
+	}
+	switch n := v.(type) {
+	case time.Duration:
+		return float64(n), true, true
+	case float64:
+		return n, false, true
+	case float32:
+		return float64(n), false, true
+	case int:
+		return float64(n), false, true
+	case int64:
+		return float64(n), false, true
+	default:
+		return 0, false, false
+	}
+}
+
+// compare applies op to actual and threshold
+func compare(actual float64, op Operator, threshold float64) bool {
+	switch op {
+	case OpGreaterEqual:
+		return actual >= threshold
+	case OpLessEqual:
+		return actual <= threshold
+	case OpGreater:
+		return actual > threshold
+	case OpLess:
+		return actual < threshold
+	case OpEqual:
+		return actual == threshold
+	case OpNotEqual:
+		return actual != threshold
+	default:
+		return false
+	}
+}
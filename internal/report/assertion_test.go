@@ -0,0 +1,128 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+)
+
+func testResult() *benchmark.Result {
+	return &benchmark.Result{
+		Name:              "sysbench",
+		Duration:          time.Minute,
+		TotalTransactions: 60000,
+		TPS:               1000,
+		LatencyMin:        time.Millisecond,
+		LatencyAvg:        5 * time.Millisecond,
+		LatencyP50:        4 * time.Millisecond,
+		LatencyP90:        10 * time.Millisecond,
+		LatencyP95:        12 * time.Millisecond,
+		LatencyP99:        25 * time.Millisecond,
+		LatencyP999:       40 * time.Millisecond,
+		LatencyMax:        60 * time.Millisecond,
+		Errors:            6,
+		Metrics: map[string]interface{}{
+			"rows_read": int64(1200),
+		},
+	}
+}
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr      string
+		metric    string
+		op        Operator
+		threshold string
+		wantErr   bool
+	}{
+		{expr: "tps >= 500", metric: "tps", op: OpGreaterEqual, threshold: "500"},
+		{expr: "latency_p99<=20ms", metric: "latency_p99", op: OpLessEqual, threshold: "20ms"},
+		{expr: "errors == 0", metric: "errors", op: OpEqual, threshold: "0"},
+		{expr: "errors != 0", metric: "errors", op: OpNotEqual, threshold: "0"},
+		{expr: "tps > 1", metric: "tps", op: OpGreater, threshold: "1"},
+		{expr: "error_rate < 0.01", metric: "error_rate", op: OpLess, threshold: "0.01"},
+		{expr: "tps 500", wantErr: true},
+		{expr: ">= 500", wantErr: true},
+		{expr: "tps >=", wantErr: true},
+		{expr: "tps >= fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			a, err := ParseAssertion(tt.expr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.metric, a.Metric)
+			assert.Equal(t, tt.op, a.Operator)
+			assert.Equal(t, tt.threshold, a.Threshold)
+		})
+	}
+}
+
+func TestAssertionEvaluate(t *testing.T) {
+	tests := []struct {
+		expr   string
+		passed bool
+	}{
+		{"tps >= 500", true},
+		{"tps >= 1500", false},
+		{"latency_p99 <= 30ms", true},
+		{"latency_p99 <= 20ms", false},
+		{"latency_avg < 1s", true},
+		{"latency_min >= 1ms", true},
+		{"latency_p50 <= 5ms", true},
+		{"latency_p90 <= 10ms", true},
+		{"latency_p90 < 10ms", false},
+		{"latency_p999 <= 50ms", true},
+		{"latency_max <= 50ms", false},
+		{"latency_max <= 100ms", true},
+		{"errors == 0", false},
+		{"error_rate <= 0.001", true},
+		{"rows_read > 1000", true},
+		{"duration >= 1m", true},
+		// Durations need a unit
+		{"latency_p99 <= 20", false},
+		// Counts must not use a unit
+		{"tps >= 500ms", false},
+		{"unknown >= 1", false},
+	}
+
+	result := testResult()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			a, err := ParseAssertion(tt.expr)
+			require.NoError(t, err)
+
+			res := a.Evaluate(result)
+			assert.Equal(t, tt.passed, res.Passed)
+			if !tt.passed {
+				assert.NotEmpty(t, res.Message)
+			}
+		})
+	}
+}
+
+func TestAssertionObjective(t *testing.T) {
+	a, err := ParseAssertion("latency_p99 < 20ms")
+	require.NoError(t, err)
+
+	var objective benchmark.Objective = a
+	assert.Equal(t, "latency_p99 < 20ms", objective.String())
+
+	ok, reason := objective.Check(testResult())
+	assert.False(t, ok)
+	assert.Equal(t, "latency_p99 is 25ms, expected < 20ms", reason)
+
+	result := testResult()
+	result.LatencyP99 = 10 * time.Millisecond
+	ok, reason = objective.Check(result)
+	assert.True(t, ok)
+	assert.Empty(t, reason)
+}
@@ -0,0 +1,471 @@
+// Package report renders benchmark results as machine readable documents
+// and evaluates threshold assertions against them.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+// Format is an output format for a Report
+type Format string
+
+const (
+	// FormatText is a human readable summary
+	FormatText Format = "text"
+	// FormatJSON is a single JSON document
+	FormatJSON Format = "json"
+	// FormatJUnit is a JUnit XML test report
+	FormatJUnit Format = "junit"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatJUnit:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported report format: %s", s)
+	}
+}
+
+// Report is the result document of a headless benchmark run
+type Report struct {
+	Status     string            `json:"status"`
+	Passed     bool              `json:"passed"`
+	Result     *benchmark.Result `json:"result"`
+	Assertions []AssertionResult `json:"assertions"`
+}
+
+// New evaluates the assertions against result and builds a Report. The report
+// passes when the run completed and every assertion holds.
+func New(status string, result *benchmark.Result, assertions []*Assertion) *Report {
+	r := &Report{
+		Status:     status,
+		Passed:     status == string(models.BenchmarkStatusCompleted),
+		Result:     result,
+		Assertions: make([]AssertionResult, 0, len(assertions)),
+	}
+
+	for _, a := range assertions {
+		res := a.Evaluate(result)
+		if !res.Passed {
+			r.Passed = false
+		}
+		r.Assertions = append(r.Assertions, res)
+	}
+
+	return r
+}
+
+// Failures returns the assertions that did not hold
+func (r *Report) Failures() []AssertionResult {
+	var failures []AssertionResult
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			failures = append(failures, a)
+		}
+	}
+	return failures
+}
+
+// Write renders the report to w in the given format
+func (r *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText, "":
+		return r.WriteText(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatJUnit:
+		return r.WriteJUnit(w)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// WriteJSON writes the report as an indented JSON document
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human readable summary of the report
+func (r *Report) WriteText(w io.Writer) error {
+	res := r.Result
+	lines := []string{
+		fmt.Sprintf("Benchmark:          %s", res.Name),
+		fmt.Sprintf("Status:             %s", r.Status),
+		fmt.Sprintf("Duration:           %v", res.Duration),
+		fmt.Sprintf("Total Transactions: %d", res.TotalTransactions),
+		fmt.Sprintf("TPS:                %.2f", res.TPS),
+		fmt.Sprintf("Latency (avg):      %v", res.LatencyAvg),
+		fmt.Sprintf("Latency (p95):      %v", res.LatencyP95),
+		fmt.Sprintf("Latency (p99):      %v", res.LatencyP99),
+		fmt.Sprintf("Errors:             %d", res.Errors),
+	}
+	if locks, ok := res.Metrics["lock_stats"].(benchmark.LockStats); ok && locks.LockCount > 0 {
+		lines = append(lines, fmt.Sprintf("Conflicts:          %d (deadlocks: %d retries: %d lock time avg: %v max: %v)",
+			locks.LockCount, locks.DeadlockCount, locks.RetryCount, locks.AvgLockTime, locks.MaxLockTime))
+	}
+	if rows, ok := res.Metrics["rows_read"].(int64); ok && rows > 0 {
+		bytes, _ := res.Metrics["bytes_read"].(int64)
+		rowRate, _ := res.Metrics["rows_read_per_sec"].(float64)
+		byteRate, _ := res.Metrics["bytes_read_per_sec"].(float64)
+		lines = append(lines, fmt.Sprintf("Rows read:          %d (%.2f/s) bytes: %d (%.2f/s)", rows, rowRate, bytes, byteRate))
+	}
+	if tpmC, ok := res.Metrics["tpmC"].(float64); ok {
+		maxTPMC, _ := res.Metrics["max_tpmC"].(float64)
+		efficiency, _ := res.Metrics["efficiency"].(float64)
+		lines = append(lines, fmt.Sprintf("tpmC:               %.2f (max: %.2f efficiency: %.2f%%)", tpmC, maxTPMC, efficiency))
+	}
+	if compliance, ok := res.Metrics["compliance"].(benchmark.TPCCCompliance); ok {
+		lines = append(lines, "TPC-C constraints:  "+passFail(compliance.Passed))
+		lines = append(lines, formatCompliance(compliance)...)
+	}
+	if len(res.Breakdown) > 0 {
+		lines = append(lines, "Breakdown:", "  "+breakdownHeader())
+		for _, name := range breakdownNames(res.Breakdown) {
+			lines = append(lines, "  "+FormatQueryStats(name, res.Breakdown[name]))
+		}
+	}
+	// A run without ramp-up, warmup or cool-down is all measurement
+	if len(res.Phases) > 1 {
+		lines = append(lines, "Phases:")
+		for _, phase := range res.Phases {
+			lines = append(lines, "  "+FormatPhase(phase))
+		}
+	}
+	if len(res.Intervals) > 0 {
+		lines = append(lines, "Intervals:")
+		for _, interval := range res.Intervals {
+			lines = append(lines, "  "+FormatInterval(interval))
+		}
+	}
+	for _, a := range r.Assertions {
+		line := fmt.Sprintf("[%s] %s", passFail(a.Passed), a.Expr)
+		if a.Message != "" {
+			line += ": " + a.Message
+		}
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatInterval formats an interval report like a line of sysbench's
+// --report-interval output. Intervals outside the measurement are marked
+// with their phase.
+func FormatInterval(r models.IntervalReport) string {
+	var errorRate, reconnectRate float64
+	if seconds := r.Duration.Seconds(); seconds > 0 {
+		errorRate = float64(r.Errors) / seconds
+		reconnectRate = float64(r.Reconnects) / seconds
+	}
+	var phase string
+	if r.Phase != "" && r.Phase != models.PhaseMeasure {
+		phase = " " + r.Phase
+	}
+	return fmt.Sprintf("[ %v ]%s tps: %.2f qps: %.2f lat (ms,95%%): %.2f err/s: %.2f reconn/s: %.2f",
+		r.Elapsed.Round(time.Millisecond), phase, r.TPS, r.QPS,
+		float64(r.Latency.P95)/float64(time.Millisecond), errorRate, reconnectRate)
+}
+
+// FormatPhase formats the statistics of a phase of a run
+func FormatPhase(r models.PhaseReport) string {
+	return fmt.Sprintf("%-9s %v transactions: %d tps: %.2f lat (ms,95%%): %.2f errors: %d",
+		r.Phase+":", r.Duration.Round(time.Millisecond), r.Transactions, r.TPS,
+		float64(r.Latency.P95)/float64(time.Millisecond), r.Errors)
+}
+
+// passFail returns the state of a check as shown in the text report
+func passFail(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// formatCompliance formats the 90th percentile response time of every
+// transaction type against its limit, then the percentage of every type
+// with a minimum against it
+func formatCompliance(c benchmark.TPCCCompliance) []string {
+	types := complianceTypes(c)
+	lines := make([]string, 0, len(c.ResponseTimes)+len(c.Mix))
+	for _, txType := range types {
+		check := c.ResponseTimes[txType]
+		lines = append(lines, fmt.Sprintf("  %-12s lat 90%% (ms): %10.2f limit: %10.2f %s",
+			txType, milliseconds(check.P90), milliseconds(check.Limit), passFail(check.Passed)))
+	}
+	for _, txType := range types {
+		check, ok := c.Mix[txType]
+		if !ok {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %-12s mix (%%):      %10.2f min:   %10.2f %s",
+			txType, check.Percentage, check.Minimum, passFail(check.Passed)))
+	}
+	return lines
+}
+
+// complianceTypes returns the transaction types checked by c in order
+func complianceTypes(c benchmark.TPCCCompliance) []benchmark.OLTPTransactionType {
+	types := make([]benchmark.OLTPTransactionType, 0, len(c.ResponseTimes))
+	for txType := range c.ResponseTimes {
+		types = append(types, txType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// breakdownHeader returns the header of the table of a breakdown
+func breakdownHeader() string {
+	return fmt.Sprintf("%-20s %10s %8s %12s %12s %12s %12s %12s",
+		"name", "count", "errors", "rows", "lat avg (ms)", "lat 95% (ms)", "lat 99% (ms)", "lat max (ms)")
+}
+
+// FormatQueryStats formats the statistics of a query or transaction type
+// of a breakdown as a table row
+func FormatQueryStats(name string, s models.QueryStats) string {
+	return fmt.Sprintf("%-20s %10d %8d %12d %12.2f %12.2f %12.2f %12.2f",
+		name, s.Count, s.Errors, s.Rows, milliseconds(s.Latency.Avg), milliseconds(s.Latency.P95),
+		milliseconds(s.Latency.P99), milliseconds(s.Latency.Max))
+}
+
+// breakdownNames returns the names of a breakdown in order
+func breakdownNames(breakdown map[string]models.QueryStats) []string {
+	names := make([]string, 0, len(breakdown))
+	for name := range breakdown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteSweep writes the curve of a sweep as a table with the knee marked, or
+// as a JSON document
+func WriteSweep(w io.Writer, sweep *models.SweepReport, format Format) error {
+	switch format {
+	case FormatText, "":
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sweep)
+	default:
+		return fmt.Errorf("unsupported sweep report format: %s", format)
+	}
+
+	lines := []string{pointHeader(sweep.Parameter, "status")}
+	for i, p := range sweep.Points {
+		line := formatPoint(p, string(p.Status))
+		if i == sweep.Knee {
+			line += "  <- knee"
+		}
+		lines = append(lines, line)
+	}
+	if sweep.Knee < 0 {
+		lines = append(lines, "No knee found")
+	}
+	return writeLines(w, lines)
+}
+
+// WriteSearch writes the probes and answer of a search as a table, or as a
+// JSON document
+func WriteSearch(w io.Writer, search *models.SearchReport, format Format) error {
+	switch format {
+	case FormatText, "":
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(search)
+	default:
+		return fmt.Errorf("unsupported search report format: %s", format)
+	}
+
+	lines := []string{
+		"Objectives: " + strings.Join(search.Objectives, ", "),
+		pointHeader(search.Parameter, "result"),
+	}
+	for _, p := range search.Probes {
+		outcome := "pass"
+		if !p.Passed {
+			outcome = "fail: " + strings.Join(p.Reasons, "; ")
+		}
+		if p.Confirmation {
+			outcome = "confirmation " + outcome
+		}
+		lines = append(lines, formatPoint(p.SweepPoint, outcome))
+	}
+
+	switch {
+	case search.Value == 0:
+		lines = append(lines, "No "+search.Parameter+" in the range meets the objectives")
+	case search.Confirmed:
+		lines = append(lines, fmt.Sprintf("Maximum: %s %d sustains %.2f tps", search.Parameter, search.Value, search.TPS))
+	default:
+		lines = append(lines, fmt.Sprintf("Maximum: %s %d sustains %.2f tps, not confirmed", search.Parameter, search.Value, search.TPS))
+	}
+	return writeLines(w, lines)
+}
+
+// pointHeader returns the header of a table of sweep points
+func pointHeader(parameter, last string) string {
+	return fmt.Sprintf("%8s %10s %12s %12s %12s %8s  %s",
+		parameter, "tps", "lat avg (ms)", "lat 95% (ms)", "lat 99% (ms)", "errors", last)
+}
+
+// formatPoint formats a sweep point as a table row ending with last
+func formatPoint(p models.SweepPoint, last string) string {
+	return fmt.Sprintf("%8d %10.2f %12.2f %12.2f %12.2f %8d  %s",
+		p.Value, p.TPS, milliseconds(p.LatencyAvg), milliseconds(p.LatencyP95),
+		milliseconds(p.LatencyP99), p.Errors, last)
+}
+
+// writeLines writes each line to w
+func writeLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// junitTestSuites is the root element of a JUnit report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite groups the test cases of one benchmark run
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty carries a result metric
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is a single check, either the run itself or an assertion
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes why a test case failed
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML. The run itself and every
+// assertion become a test case so CI systems can show them individually.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	res := r.Result
+	className := "benchphant." + res.Name
+	seconds := fmt.Sprintf("%.3f", res.Duration.Seconds())
+
+	suite := junitTestSuite{
+		Name:      res.Name,
+		Time:      seconds,
+		Timestamp: res.StartTime.Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "status", Value: r.Status},
+			{Name: "total_transactions", Value: fmt.Sprintf("%d", res.TotalTransactions)},
+			{Name: "tps", Value: fmt.Sprintf("%.2f", res.TPS)},
+			{Name: "latency_avg", Value: res.LatencyAvg.String()},
+			{Name: "latency_p95", Value: res.LatencyP95.String()},
+			{Name: "latency_p99", Value: res.LatencyP99.String()},
+			{Name: "errors", Value: fmt.Sprintf("%d", res.Errors)},
+		},
+	}
+	for _, name := range breakdownNames(res.Breakdown) {
+		s := res.Breakdown[name]
+		prefix := "breakdown." + name + "."
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: prefix + "count", Value: fmt.Sprintf("%d", s.Count)},
+			junitProperty{Name: prefix + "errors", Value: fmt.Sprintf("%d", s.Errors)},
+			junitProperty{Name: prefix + "rows", Value: fmt.Sprintf("%d", s.Rows)},
+			junitProperty{Name: prefix + "latency_avg", Value: s.Latency.Avg.String()},
+			junitProperty{Name: prefix + "latency_p95", Value: s.Latency.P95.String()},
+			junitProperty{Name: prefix + "latency_p99", Value: s.Latency.P99.String()},
+		)
+	}
+
+	if compliance, ok := res.Metrics["compliance"].(benchmark.TPCCCompliance); ok {
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: "compliance.passed", Value: fmt.Sprintf("%t", compliance.Passed)})
+		for _, txType := range complianceTypes(compliance) {
+			prefix := "compliance." + string(txType) + "."
+			suite.Properties = append(suite.Properties,
+				junitProperty{Name: prefix + "latency_p90", Value: compliance.ResponseTimes[txType].P90.String()})
+			if check, ok := compliance.Mix[txType]; ok {
+				suite.Properties = append(suite.Properties,
+					junitProperty{Name: prefix + "mix", Value: fmt.Sprintf("%.2f", check.Percentage)})
+			}
+		}
+	}
+
+	run := junitTestCase{Name: "run", ClassName: className, Time: seconds}
+	if r.Status != string(models.BenchmarkStatusCompleted) {
+		run.Failure = &junitFailure{
+			Message: fmt.Sprintf("benchmark finished with status %s", r.Status),
+			Type:    "status",
+		}
+	}
+	suite.Cases = append(suite.Cases, run)
+
+	for _, a := range r.Assertions {
+		tc := junitTestCase{Name: a.Expr, ClassName: className, Time: "0"}
+		if !a.Passed {
+			tc.Failure = &junitFailure{Message: a.Message, Type: "assertion"}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suite.Tests = len(suite.Cases)
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,258 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/deadjoe/benchphant/internal/benchmark"
+	"github.com/deadjoe/benchphant/internal/metrics"
+	"github.com/deadjoe/benchphant/internal/models"
+)
+
+func mustParse(t *testing.T, exprs ...string) []*Assertion {
+	assertions := make([]*Assertion, 0, len(exprs))
+	for _, expr := range exprs {
+		a, err := ParseAssertion(expr)
+		require.NoError(t, err)
+		assertions = append(assertions, a)
+	}
+	return assertions
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Passed", func(t *testing.T) {
+		r := New("completed", testResult(), mustParse(t, "tps >= 500", "latency_p99 <= 30ms"))
+		assert.True(t, r.Passed)
+		assert.Empty(t, r.Failures())
+	})
+
+	t.Run("AssertionFailed", func(t *testing.T) {
+		r := New("completed", testResult(), mustParse(t, "tps >= 500", "latency_p99 <= 20ms"))
+		assert.False(t, r.Passed)
+		require.Len(t, r.Failures(), 1)
+		assert.Equal(t, "latency_p99 <= 20ms", r.Failures()[0].Expr)
+	})
+
+	t.Run("RunFailed", func(t *testing.T) {
+		r := New("failed", testResult(), nil)
+		assert.False(t, r.Passed)
+	})
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := New("completed", testResult(), mustParse(t, "tps >= 1500"))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf, FormatJSON))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "completed", doc["status"])
+	assert.Equal(t, false, doc["passed"])
+	assert.Equal(t, float64(1000), doc["result"].(map[string]interface{})["tps"])
+
+	assertions := doc["assertions"].([]interface{})
+	require.Len(t, assertions, 1)
+	assert.Equal(t, "tps >= 1500", assertions[0].(map[string]interface{})["expr"])
+	assert.Equal(t, "1000", assertions[0].(map[string]interface{})["actual"])
+}
+
+func TestWriteText(t *testing.T) {
+	result := testResult()
+	result.Intervals = []models.IntervalReport{{
+		Elapsed:    10 * time.Second,
+		Duration:   10 * time.Second,
+		TPS:        1000,
+		QPS:        20000,
+		Errors:     5,
+		Reconnects: 1,
+		Latency:    metrics.LatencySummary{P95: 12500 * time.Microsecond},
+		Phase:      models.PhaseMeasure,
+	}, {
+		Elapsed:  15 * time.Second,
+		Duration: 5 * time.Second,
+		TPS:      900,
+		Phase:    models.PhaseCoolDown,
+	}}
+	result.Phases = []models.PhaseReport{{
+		Phase:        models.PhaseMeasure,
+		Duration:     10 * time.Second,
+		Transactions: 10000,
+		TPS:          1000,
+		Latency:      metrics.LatencySummary{P95: 12500 * time.Microsecond},
+	}, {
+		Phase:        models.PhaseCoolDown,
+		Start:        10 * time.Second,
+		Duration:     5 * time.Second,
+		Transactions: 4500,
+		TPS:          900,
+	}}
+	result.Metrics["lock_stats"] = benchmark.LockStats{
+		DeadlockCount: 2,
+		RetryCount:    3,
+		LockCount:     4,
+		AvgLockTime:   5 * time.Millisecond,
+		MaxLockTime:   12 * time.Millisecond,
+	}
+	result.Metrics["rows_read"] = int64(30000)
+	result.Metrics["bytes_read"] = int64(600000)
+	result.Metrics["rows_read_per_sec"] = float64(3000)
+	result.Metrics["bytes_read_per_sec"] = float64(60000)
+	result.Breakdown = map[string]models.QueryStats{
+		"transfer": {Count: 200, Errors: 1, Rows: 400, Latency: metrics.LatencySummary{Avg: 4 * time.Millisecond, P95: 9 * time.Millisecond, P99: 11 * time.Millisecond, Max: 20 * time.Millisecond}},
+		"lookup":   {Count: 800, Rows: 800, Latency: metrics.LatencySummary{Avg: time.Millisecond}},
+	}
+	result.Metrics["tpmC"] = 120.5
+	result.Metrics["max_tpmC"] = 128.6
+	result.Metrics["efficiency"] = 93.7
+	result.Metrics["compliance"] = benchmark.CheckTPCCCompliance(map[string]models.QueryStats{
+		"new-order":    {Count: 45, Latency: metrics.LatencySummary{P90: 6 * time.Second}},
+		"payment":      {Count: 43},
+		"order-status": {Count: 4},
+		"delivery":     {Count: 4},
+		"stock-level":  {Count: 4, Latency: metrics.LatencySummary{P90: 12500 * time.Millisecond}},
+	})
+	r := New("completed", result, mustParse(t, "tps >= 500"))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf, FormatText))
+	assert.Contains(t, buf.String(), "TPS:                1000.00")
+	assert.Contains(t, buf.String(), "Conflicts:          4 (deadlocks: 2 retries: 3 lock time avg: 5ms max: 12ms)")
+	assert.Contains(t, buf.String(), "Rows read:          30000 (3000.00/s) bytes: 600000 (60000.00/s)")
+	assert.Contains(t, buf.String(), "tpmC:               120.50 (max: 128.60 efficiency: 93.70%)")
+	assert.Contains(t, buf.String(), "TPC-C constraints:  FAIL\n  delivery     lat 90% (ms):       0.00 limit:    5000.00 PASS\n")
+	assert.Contains(t, buf.String(), "  new-order    lat 90% (ms):    6000.00 limit:    5000.00 FAIL\n")
+	assert.Contains(t, buf.String(), "  stock-level  lat 90% (ms):   12500.00 limit:   20000.00 PASS\n")
+	assert.Contains(t, buf.String(), "  payment      mix (%):           43.00 min:        43.00 PASS\n")
+	assert.Contains(t, buf.String(), "Breakdown:\n  name                      count   errors         rows lat avg (ms) lat 95% (ms) lat 99% (ms) lat max (ms)\n  lookup")
+	assert.Contains(t, buf.String(), "  transfer                    200        1          400         4.00         9.00        11.00        20.00\n")
+	assert.Contains(t, buf.String(), "measure:  10s transactions: 10000 tps: 1000.00 lat (ms,95%): 12.50 errors: 0")
+	assert.Contains(t, buf.String(), "[ 10s ] tps: 1000.00 qps: 20000.00 lat (ms,95%): 12.50 err/s: 0.50 reconn/s: 0.10")
+	assert.Contains(t, buf.String(), "[ 15s ] cool_down tps: 900.00")
+	assert.Contains(t, buf.String(), "[PASS] tps >= 500")
+}
+
+func TestWriteSweep(t *testing.T) {
+	sweep := &models.SweepReport{
+		Parameter: "threads",
+		Points: []models.SweepPoint{
+			{Value: 1, Status: models.BenchmarkStatusCompleted, TPS: 100, LatencyAvg: time.Millisecond, LatencyP95: 2 * time.Millisecond},
+			{Value: 2, Status: models.BenchmarkStatusCompleted, TPS: 150, LatencyAvg: 4 * time.Millisecond, Errors: 3},
+		},
+		Knee: 0,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSweep(&buf, sweep, FormatText))
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, " threads        tps lat avg (ms) lat 95% (ms) lat 99% (ms)   errors  status", lines[0])
+	assert.Equal(t, "       1     100.00         1.00         2.00         0.00        0  completed  <- knee", lines[1])
+	assert.NotContains(t, lines[2], "knee")
+
+	sweep.Knee = -1
+	buf.Reset()
+	require.NoError(t, WriteSweep(&buf, sweep, FormatText))
+	assert.Contains(t, buf.String(), "No knee found")
+
+	buf.Reset()
+	require.NoError(t, WriteSweep(&buf, sweep, FormatJSON))
+	var decoded models.SweepReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *sweep, decoded)
+
+	assert.Error(t, WriteSweep(&buf, sweep, FormatJUnit))
+}
+
+func TestWriteSearch(t *testing.T) {
+	search := &models.SearchReport{
+		Parameter:  "rate",
+		Objectives: []string{"latency_p99 < 10ms", "error_rate < 0.001"},
+		Probes: []models.SearchProbe{
+			{SweepPoint: models.SweepPoint{Value: 100, TPS: 100, LatencyP99: 4 * time.Millisecond}, Passed: true},
+			{SweepPoint: models.SweepPoint{Value: 200, TPS: 180, LatencyP99: 12 * time.Millisecond}, Reasons: []string{"latency_p99 is 12ms, expected < 10ms"}},
+			{SweepPoint: models.SweepPoint{Value: 100, TPS: 99.5, LatencyP99: 5 * time.Millisecond}, Passed: true, Confirmation: true},
+		},
+		Value:     100,
+		TPS:       99.5,
+		Confirmed: true,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSearch(&buf, search, FormatText))
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, "Objectives: latency_p99 < 10ms, error_rate < 0.001", lines[0])
+	assert.Equal(t, "     200     180.00         0.00         0.00        12.00        0  fail: latency_p99 is 12ms, expected < 10ms", lines[3])
+	assert.True(t, strings.HasSuffix(lines[4], "confirmation pass"))
+	assert.Equal(t, "Maximum: rate 100 sustains 99.50 tps", lines[5])
+
+	search.Confirmed = false
+	buf.Reset()
+	require.NoError(t, WriteSearch(&buf, search, FormatText))
+	assert.Contains(t, buf.String(), "not confirmed")
+
+	search.Value = 0
+	buf.Reset()
+	require.NoError(t, WriteSearch(&buf, search, FormatText))
+	assert.Contains(t, buf.String(), "No rate in the range meets the objectives")
+
+	buf.Reset()
+	require.NoError(t, WriteSearch(&buf, search, FormatJSON))
+	var decoded models.SearchReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *search, decoded)
+
+	assert.Error(t, WriteSearch(&buf, search, FormatJUnit))
+}
+
+func TestWriteJUnit(t *testing.T) {
+	result := testResult()
+	result.Breakdown = map[string]models.QueryStats{
+		"payment": {Count: 40, Errors: 2, Latency: metrics.LatencySummary{P99: 15 * time.Millisecond}},
+	}
+	result.Metrics["compliance"] = benchmark.CheckTPCCCompliance(result.Breakdown)
+	r := New("completed", result, mustParse(t, "tps >= 500", "errors == 0"))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf, FormatJUnit))
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Suites, 1)
+
+	suite := doc.Suites[0]
+	assert.Equal(t, "sysbench", suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Contains(t, suite.Properties, junitProperty{Name: "breakdown.payment.errors", Value: "2"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "breakdown.payment.latency_p99", Value: "15ms"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "compliance.passed", Value: "false"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "compliance.payment.mix", Value: "100.00"})
+	assert.Contains(t, suite.Properties, junitProperty{Name: "compliance.stock-level.latency_p90", Value: "0s"})
+	require.Len(t, suite.Cases, 3)
+	assert.Equal(t, "run", suite.Cases[0].Name)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Nil(t, suite.Cases[1].Failure)
+	require.NotNil(t, suite.Cases[2].Failure)
+	assert.Equal(t, "assertion", suite.Cases[2].Failure.Type)
+}
+
+func TestFormat(t *testing.T) {
+	f, err := ParseFormat("junit")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJUnit, f)
+
+	_, err = ParseFormat("csv")
+	assert.Error(t, err)
+
+	r := New("completed", testResult(), nil)
+	assert.Error(t, r.Write(&bytes.Buffer{}, Format("csv")))
+}
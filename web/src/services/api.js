@@ -115,22 +115,45 @@ export const databaseAPI = {
 
 export const benchmarkAPI = {
   // Get all benchmarks
-  getBenchmarks: () => api.get('/api/benchmarks'),
+  getBenchmarks: () => api.get('/benchmarks'),
   
   // Get a single benchmark
-  getBenchmark: (id) => api.get(`/api/benchmarks/${id}`),
+  getBenchmark: (id) => api.get(`/benchmarks/${id}`),
   
   // Create a new benchmark
-  createBenchmark: (data) => api.post('/api/benchmarks', data),
+  createBenchmark: (data) => api.post('/benchmarks', data),
   
-  // Start a benchmark
-  startBenchmark: (id) => api.post(`/api/benchmarks/${id}/start`),
+  // Delete a benchmark and its results
+  deleteBenchmark: (id) => api.delete(`/benchmarks/${id}`),
+  
+  // Start a benchmark, optionally creating its schema and data first
+  startBenchmark: (id, options = {}) => api.post(`/benchmarks/${id}/start`, null, { params: options }),
   
   // Stop a benchmark
-  stopBenchmark: (id) => api.post(`/api/benchmarks/${id}/stop`),
+  stopBenchmark: (id) => api.post(`/benchmarks/${id}/stop`),
+  
+  // Get the live status of a benchmark
+  getBenchmarkStatus: (id) => api.get(`/benchmarks/${id}/status`),
   
   // Get benchmark results
-  getBenchmarkResults: (id) => api.get(`/api/benchmarks/${id}/results`)
+  getBenchmarkResults: (id) => api.get(`/benchmarks/${id}/results`),
+  
+  // Get all runs
+  getRuns: () => api.get('/runs'),
+  
+  // Get a run
+  getRun: (id) => api.get(`/runs/${id}`),
+  
+  // Stop a run
+  stopRun: (id) => api.post(`/runs/${id}/stop`)
+}
+
+export const workloadAPI = {
+  // Get all registered workloads with their config schema
+  getWorkloads: () => api.get('/workloads'),
+  
+  // Get a single workload
+  getWorkload: (name) => api.get(`/workloads/${name}`)
 }
 
 export const authAPI = {